@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+
+	"github.com/jianxcao/watch-docker/backend/internal/agent"
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// runAgent 运行 agent 子命令：watch-docker agent --server https://central --join-token <token>
+// 参数也可以通过环境变量 WATCH_AGENT_SERVER / WATCH_AGENT_JOIN_TOKEN / WATCH_AGENT_NAME 设置
+func runAgent(args []string) {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	opts := agent.Options{}
+	fs.StringVar(&opts.Server, "server", os.Getenv("WATCH_AGENT_SERVER"), "中心端地址，例如 https://watch.example.com")
+	fs.StringVar(&opts.JoinToken, "join-token", os.Getenv("WATCH_AGENT_JOIN_TOKEN"), "一次性加入令牌（首次运行时需要）")
+	fs.StringVar(&opts.Name, "name", os.Getenv("WATCH_AGENT_NAME"), "agent 名称，默认主机名")
+	fs.StringVar(&opts.StatePath, "state", path.Join(conf.EnvCfg.CONFIG_PATH, "agent.json"), "保存 agent 凭据的文件")
+	fs.StringVar(&opts.DockerHost, "docker-host", "", "本机 Docker 地址，默认 DOCKER_HOST 或 unix:///var/run/docker.sock")
+	_ = fs.Parse(args)
+
+	log, err := logger.NewLogger("info", "")
+	if err != nil {
+		panic(fmt.Errorf("init logger: %w", err))
+	}
+	defer log.Sync() //nolint:errcheck
+	log.Info("starting watch-docker agent", zap.String("server", opts.Server), zap.String("version", conf.GetVersion()))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := agent.Run(ctx, opts); err != nil {
+		log.Fatal("agent exited", logger.ZapErr(err))
+	}
+}
@@ -10,22 +10,26 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/jianxcao/watch-docker/backend/internal/agent"
 	"github.com/jianxcao/watch-docker/backend/internal/api"
+	"github.com/jianxcao/watch-docker/backend/internal/audit"
+	"github.com/jianxcao/watch-docker/backend/internal/auth"
 	"github.com/jianxcao/watch-docker/backend/internal/conf"
 	"github.com/jianxcao/watch-docker/backend/internal/config"
-	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	"github.com/jianxcao/watch-docker/backend/internal/endpoint"
 	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
 	"github.com/jianxcao/watch-docker/backend/internal/notificationmanager"
-	"github.com/jianxcao/watch-docker/backend/internal/notify"
+	"github.com/jianxcao/watch-docker/backend/internal/recording"
 	"github.com/jianxcao/watch-docker/backend/internal/registry"
-	"github.com/jianxcao/watch-docker/backend/internal/scanner"
-	"github.com/jianxcao/watch-docker/backend/internal/scheduler"
-	"github.com/jianxcao/watch-docker/backend/internal/updater"
 
 	"go.uber.org/zap"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		runAgent(os.Args[2:])
+		return
+	}
 
 	configPath := path.Join(conf.EnvCfg.CONFIG_PATH, conf.EnvCfg.CONFIG_FILE)
 	// init logger first
@@ -45,22 +49,42 @@ func main() {
 	log.Info("starting watch-docker", zap.String("configPath", configPath))
 	log.Info("version info", zap.String("version", conf.GetVersion()))
 	// init long-lived clients
-	dockerClient, err := dockercli.New(context.Background(), cfg.Docker.Host)
+	reg := registry.New()
+
+	// init notification system
+	notificationManager := notificationmanager.New(path.Join(conf.EnvCfg.CONFIG_PATH, "notification-history.json"))
+
+	// Docker 端点：本机（docker.host）与 docker.endpoints 中的远程主机，各自拥有扫描器、更新器与调度器
+	// 同一端点的调度器与 API 共用更新器，保证同一容器的更新互斥
+	endpoints, err := endpoint.Open(context.Background(), log, reg, notificationManager, conf.EnvCfg.CONFIG_PATH)
 	if err != nil {
 		log.Fatal("docker client init failed", logger.ZapErr(err))
 	}
-	reg := registry.New()
-	sc := scanner.New(dockerClient, reg)
 
-	// init notification system
-	notifier := notify.New(func() *config.Config { return cfg })
-	notificationManager := notificationmanager.New(notifier, path.Join(conf.EnvCfg.CONFIG_PATH, "notification-history.json"))
+	// 远程 agent：每个已注册的 agent 对应一个端点，Docker 连接经 agent 的 WebSocket 隧道建立
+	agents := agent.NewHub(agent.NewStore(path.Join(conf.EnvCfg.CONFIG_PATH, "agents.json")))
+	for _, a := range agents.Store().List() {
+		if _, err := endpoints.AddAgent(context.Background(), a.ID, a.Name, agents.Dialer(a.ID), false); err != nil {
+			log.Error("创建 agent 端点失败", zap.String("agent", a.ID), logger.ZapErr(err))
+		}
+	}
+
+	// start schedulers
+	endpoints.StartSchedulers()
+
+	// 每个端点订阅 Docker 事件流发送容器生命周期通知（是否通知由 notify.containerEvents 控制），
+	// 并记录资源历史（statsHistory.enabled）、评估资源告警（resourceAlerts.enabled）
+	endpoints.StartMonitors()
+	defer endpoints.StopMonitors()
 
-	// start scheduler
-	sch := scheduler.New(log, sc, updater.New(dockerClient), notificationManager)
-	sch.Start()
+	// 审计日志（是否记录由 audit.enabled 控制）
+	auditLog := audit.New(path.Join(conf.EnvCfg.CONFIG_PATH, "audit"))
+	defer auditLog.Close()
 
-	r := api.NewRouter(log, dockerClient, reg, sc, sch)
+	// 终端会话录像（是否录制由 recording.enabled 控制）
+	recordings := recording.NewStore(path.Join(conf.EnvCfg.CONFIG_PATH, "recordings"))
+
+	r := api.NewRouter(log, endpoints, reg, notificationManager, agents, auditLog, recordings)
 
 	srv := &http.Server{
 		Addr:         cfg.Server.Addr,
@@ -84,11 +108,23 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	logger.Logger.Info("shutting down http server")
-	// stop scheduler
-	sch.Stop()
+	// stop schedulers
+	endpoints.StopSchedulers()
+	// stop docker events subscription, stats history recording and resource alerts
+	endpoints.StopMonitors()
 	// close notification manager (flush pending notifications)
 	notificationManager.Close()
+	// close remote docker endpoints
+	endpoints.Close()
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Logger.Error("server shutdown error", logger.ZapErr(err))
 	}
+	// persist api token last-used times
+	if err := auth.GetAPITokenStore().Flush(); err != nil {
+		logger.Logger.Error("save api tokens", logger.ZapErr(err))
+	}
+	// persist session last-seen times
+	if err := auth.GetSessionStore().Flush(); err != nil {
+		logger.Logger.Error("save sessions", logger.ZapErr(err))
+	}
 }
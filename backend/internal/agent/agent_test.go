@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+func TestJoinTokenIsSingleUse(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	store := NewStore(filepath.Join(t.TempDir(), "agents.json"))
+	token, _, err := store.CreateJoinToken("edge", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, secret, err := store.Enroll(token, "")
+	if err != nil || a.Name != "edge" {
+		t.Fatalf("enroll: %+v %v", a, err)
+	}
+	if _, _, err := store.Enroll(token, "again"); !errors.Is(err, ErrInvalidJoinToken) {
+		t.Fatalf("second enroll should fail, got %v", err)
+	}
+	if _, ok := store.Authenticate(a.ID, secret); !ok {
+		t.Fatal("secret should authenticate")
+	}
+	if _, ok := store.Authenticate(a.ID, "wrong"); ok {
+		t.Fatal("wrong secret must not authenticate")
+	}
+
+	expired, _, _ := store.CreateJoinToken("", -time.Minute)
+	if _, _, err := store.Enroll(expired, "x"); !errors.Is(err, ErrInvalidJoinToken) {
+		t.Fatalf("expired token should fail, got %v", err)
+	}
+
+	// 重新加载后凭据仍然有效
+	if _, ok := NewStore(store.path).Authenticate(a.ID, secret); !ok {
+		t.Fatal("credentials should persist")
+	}
+}
+
+func TestTunnelForwardsToDockerSocket(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	dir := t.TempDir()
+
+	// 模拟 agent 主机上的 Docker socket
+	sock := filepath.Join(dir, "docker.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix socket unavailable: %v", err)
+	}
+	docker := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "docker:"+r.URL.Path)
+	})}
+	go docker.Serve(ln) //nolint:errcheck
+	defer docker.Close()
+
+	store := NewStore(filepath.Join(dir, "agents.json"))
+	token, _, _ := store.CreateJoinToken("", time.Hour)
+	a, secret, _ := store.Enroll(token, "edge")
+	hub := NewHub(store)
+
+	upgrader := websocket.Upgrader{}
+	central := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.Serve(conn, a, r.RemoteAddr)
+	}))
+	defer central.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go connect(ctx, Options{Server: central.URL, DockerHost: "unix://" + sock}, Credentials{ID: a.ID, Secret: secret}) //nolint:errcheck
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !hub.List()[0].Online {
+		if time.Now().After(deadline) {
+			t.Fatal("agent did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	client := &http.Client{Transport: &http.Transport{DialContext: hub.Dialer(a.ID)}}
+	for _, p := range []string{"/version", "/containers/json"} {
+		resp, err := client.Get("http://docker" + p)
+		if err != nil {
+			t.Fatalf("GET %s: %v", p, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "docker:"+p {
+			t.Fatalf("GET %s = %q", p, body)
+		}
+	}
+
+	hub.Disconnect(a.ID)
+	if _, err := hub.Dialer(a.ID)(ctx, "tcp", ""); !errors.Is(err, ErrAgentOffline) {
+		t.Fatalf("dial after disconnect should report offline, got %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	// EnrollPath 注册接口路径
+	EnrollPath = "/api/v1/agents/enroll"
+	// ConnectPath 连接接口路径
+	ConnectPath = "/api/v1/agents/connect"
+
+	maxReconnectDelay = time.Minute
+)
+
+// Options agent 运行参数
+type Options struct {
+	Server     string // 中心端地址，例如 https://watch.example.com
+	JoinToken  string // 一次性加入令牌，首次运行时使用
+	Name       string // 注册时使用的名称，默认主机名
+	StatePath  string // 保存 agent ID 与连接密钥的文件
+	DockerHost string // 本机 Docker 地址，默认 unix:///var/run/docker.sock
+}
+
+// Credentials agent 注册后获得的凭据
+type Credentials struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+// EnrollRequest 注册请求
+type EnrollRequest struct {
+	Token string `json:"token"`
+	Name  string `json:"name"`
+}
+
+// Run 运行 agent：首次运行时使用加入令牌注册并保存凭据，然后保持与中心端的连接，断开后按退避时间重连，直到 ctx 结束
+func Run(ctx context.Context, opts Options) error {
+	if opts.Server == "" {
+		return fmt.Errorf("server address is required")
+	}
+	opts.Server = strings.TrimRight(opts.Server, "/")
+	if opts.DockerHost == "" {
+		opts.DockerHost = os.Getenv("DOCKER_HOST")
+	}
+	if opts.DockerHost == "" {
+		opts.DockerHost = "unix:///var/run/docker.sock"
+	}
+
+	creds, err := loadCredentials(opts.StatePath)
+	if err != nil {
+		if opts.JoinToken == "" {
+			return fmt.Errorf("agent is not enrolled and no join token is given: %w", err)
+		}
+		if opts.Name == "" {
+			opts.Name, _ = os.Hostname()
+		}
+		creds, err = enroll(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("enroll agent: %w", err)
+		}
+		if err := saveCredentials(opts.StatePath, creds); err != nil {
+			return fmt.Errorf("save agent credentials: %w", err)
+		}
+		logger.Logger.Info("agent 注册成功", zap.String("id", creds.ID))
+	}
+
+	delay := time.Second
+	for {
+		start := time.Now()
+		err := connect(ctx, opts, creds)
+		if ctx.Err() != nil {
+			return nil
+		}
+		// 连接维持较久说明网络正常，重置退避时间
+		if time.Since(start) > maxReconnectDelay {
+			delay = time.Second
+		}
+		logger.Logger.Warn("与中心端的连接已断开，稍后重连", zap.Error(err), zap.Duration("delay", delay))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+func enroll(ctx context.Context, opts Options) (Credentials, error) {
+	body, _ := json.Marshal(EnrollRequest{Token: opts.JoinToken, Name: opts.Name})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.Server+EnrollPath, bytes.NewReader(body))
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Credentials{}, err
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		Code int         `json:"code"`
+		Msg  string      `json:"msg"`
+		Data Credentials `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&res); err != nil {
+		return Credentials{}, fmt.Errorf("decode enroll response (status %d): %w", resp.StatusCode, err)
+	}
+	if res.Code != 0 || res.Data.ID == "" || res.Data.Secret == "" {
+		return Credentials{}, fmt.Errorf("enroll rejected: %s", res.Msg)
+	}
+	return res.Data, nil
+}
+
+// connect 建立一次连接并处理中心端打开的流，连接断开后返回
+func connect(ctx context.Context, opts Options, creds Credentials) error {
+	u, err := url.Parse(opts.Server + ConnectPath)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+creds.ID+":"+creds.Secret)
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("dial %s: %w (status %d)", u.Redacted(), err, resp.StatusCode)
+		}
+		return fmt.Errorf("dial %s: %w", u.Redacted(), err)
+	}
+	logger.Logger.Info("已连接到中心端", zap.String("server", opts.Server))
+
+	_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+	session := NewSession(conn, func(_ *Session, _ []byte) {
+		_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+	defer session.Close()
+	go heartbeat(ctx, session, opts.DockerHost)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Close()
+		case <-session.Done():
+		}
+	}()
+
+	for {
+		st, err := session.Accept()
+		if err != nil {
+			return err
+		}
+		go forward(st, opts.DockerHost)
+	}
+}
+
+// heartbeat 定期发送心跳与版本信息
+func heartbeat(ctx context.Context, session *Session, dockerHost string) {
+	hostname, _ := os.Hostname()
+	docker, err := dockercli.NewEndpoint(ctx, dockercli.EndpointOptions{Host: dockerHost})
+	if err != nil {
+		logger.Logger.Warn("创建本机 Docker 客户端失败，心跳中不包含 Docker 版本", zap.Error(err))
+	} else {
+		defer docker.Close()
+	}
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		hb := Heartbeat{Type: "heartbeat", Hostname: hostname, Version: conf.GetVersion()}
+		if docker != nil {
+			vctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			if v, err := docker.GetVersion(vctx); err == nil {
+				hb.DockerVersion = v.Version
+			}
+			cancel()
+		}
+		data, _ := json.Marshal(hb)
+		if err := session.SendControl(data); err != nil {
+			return
+		}
+		select {
+		case <-session.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// forward 把一个流转发到本机 Docker socket
+func forward(st net.Conn, dockerHost string) {
+	defer st.Close()
+	network, addr, err := dockerAddr(dockerHost)
+	if err != nil {
+		logger.Logger.Error("无效的 Docker 地址", zap.String("host", dockerHost), zap.Error(err))
+		return
+	}
+	dc, err := net.DialTimeout(network, addr, 10*time.Second)
+	if err != nil {
+		logger.Logger.Warn("连接本机 Docker 失败", zap.String("host", dockerHost), zap.Error(err))
+		return
+	}
+	defer dc.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(dc, st)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(st, dc)
+		done <- struct{}{}
+	}()
+	// 任一方向结束即关闭两端：流不支持半关闭，中心端关闭流时已不再需要响应
+	<-done
+}
+
+// dockerAddr 把 unix:// 或 tcp:// 地址转换为 net.Dial 参数
+func dockerAddr(host string) (string, string, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", "", err
+	}
+	switch u.Scheme {
+	case "unix":
+		return "unix", u.Path, nil
+	case "tcp":
+		return "tcp", u.Host, nil
+	}
+	return "", "", fmt.Errorf("unsupported docker host scheme %q", u.Scheme)
+}
+
+func loadCredentials(path string) (Credentials, error) {
+	var creds Credentials
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return creds, err
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return creds, err
+	}
+	if creds.ID == "" || creds.Secret == "" {
+		return creds, fmt.Errorf("incomplete credentials in %s", path)
+	}
+	return creds, nil
+}
+
+func saveCredentials(path string, creds Credentials) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,187 @@
+// Package agent 实现远程 agent 模式：agent 主动以 WebSocket 连接中心端，
+// 中心端在该连接上打开流并由 agent 转发到其本机 Docker socket，
+// 因此中心端的 dockercli 客户端（包括日志、统计与 exec 等流式接口）可原样使用，远程主机无需暴露 Docker TCP。
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	// HeartbeatInterval agent 发送心跳的间隔
+	HeartbeatInterval = 15 * time.Second
+	// readTimeout 超过该时间未收到对端消息视为连接已断开
+	readTimeout = 3 * HeartbeatInterval
+)
+
+// ErrAgentOffline agent 未连接
+var ErrAgentOffline = errors.New("agent is not connected")
+
+// Heartbeat agent 定期发送的心跳，包含版本信息
+type Heartbeat struct {
+	Type          string `json:"type"` // heartbeat 或 heartbeat_ack
+	Hostname      string `json:"hostname,omitempty"`
+	Version       string `json:"version,omitempty"`
+	DockerVersion string `json:"dockerVersion,omitempty"`
+}
+
+// Info 返回给前端的 agent 状态
+type Info struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Hostname      string    `json:"hostname"`
+	Version       string    `json:"version"`
+	DockerVersion string    `json:"dockerVersion"`
+	Online        bool      `json:"online"`
+	RemoteAddr    string    `json:"remoteAddr,omitempty"`
+	ConnectedAt   time.Time `json:"connectedAt,omitempty"`
+	LastHeartbeat time.Time `json:"lastHeartbeat,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// live 一个在线 agent 的会话
+type live struct {
+	session     *Session
+	remoteAddr  string
+	connectedAt time.Time
+
+	mu            sync.Mutex
+	heartbeat     Heartbeat
+	lastHeartbeat time.Time
+}
+
+// Hub 管理 agent 的注册信息与在线会话
+type Hub struct {
+	store *Store
+
+	mu       sync.Mutex
+	sessions map[string]*live
+}
+
+// NewHub 创建 Hub
+func NewHub(store *Store) *Hub {
+	return &Hub{store: store, sessions: make(map[string]*live)}
+}
+
+// Store 返回注册信息存储
+func (h *Hub) Store() *Store { return h.store }
+
+// Serve 处理一个已认证的 agent 连接，阻塞直到连接断开。
+// 同一 agent 重复连接时关闭旧连接。
+func (h *Hub) Serve(conn *websocket.Conn, a Agent, remoteAddr string) {
+	l := &live{remoteAddr: remoteAddr, connectedAt: time.Now()}
+	_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+	first := true
+	l.session = NewSession(conn, func(s *Session, data []byte) {
+		_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+		var hb Heartbeat
+		if err := json.Unmarshal(data, &hb); err != nil || hb.Type != "heartbeat" {
+			return
+		}
+		now := time.Now()
+		l.mu.Lock()
+		l.heartbeat = hb
+		l.lastHeartbeat = now
+		l.mu.Unlock()
+		// 只在连接后的第一次心跳持久化版本信息，断开时再记录最后心跳时间
+		if first {
+			first = false
+			h.store.UpdateStatus(a.ID, hb, now)
+		}
+		ack, _ := json.Marshal(Heartbeat{Type: "heartbeat_ack"})
+		_ = s.SendControl(ack)
+	})
+
+	h.mu.Lock()
+	old := h.sessions[a.ID]
+	h.sessions[a.ID] = l
+	h.mu.Unlock()
+	if old != nil {
+		old.session.Close()
+	}
+	logger.Logger.Info("agent 已连接", zap.String("agent", a.ID), zap.String("name", a.Name), zap.String("remote", remoteAddr))
+
+	<-l.session.Done()
+
+	h.mu.Lock()
+	if h.sessions[a.ID] == l {
+		delete(h.sessions, a.ID)
+	}
+	h.mu.Unlock()
+	l.mu.Lock()
+	hb, last := l.heartbeat, l.lastHeartbeat
+	l.mu.Unlock()
+	if !last.IsZero() {
+		h.store.UpdateStatus(a.ID, hb, last)
+	}
+	logger.Logger.Info("agent 已断开", zap.String("agent", a.ID), zap.String("name", a.Name))
+}
+
+// Disconnect 断开 agent 的在线连接
+func (h *Hub) Disconnect(id string) {
+	h.mu.Lock()
+	l := h.sessions[id]
+	delete(h.sessions, id)
+	h.mu.Unlock()
+	if l != nil {
+		l.session.Close()
+	}
+}
+
+// Dialer 返回通过 agent 连接其 Docker 的拨号函数，agent 离线时返回 ErrAgentOffline
+func (h *Hub) Dialer(id string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		h.mu.Lock()
+		l := h.sessions[id]
+		h.mu.Unlock()
+		if l == nil {
+			return nil, fmt.Errorf("%w: %s", ErrAgentOffline, id)
+		}
+		return l.session.Open()
+	}
+}
+
+// List 返回全部 agent 及在线状态
+func (h *Hub) List() []Info {
+	agents := h.store.List()
+	list := make([]Info, 0, len(agents))
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, a := range agents {
+		info := Info{
+			ID:            a.ID,
+			Name:          a.Name,
+			Hostname:      a.Hostname,
+			Version:       a.Version,
+			DockerVersion: a.DockerVersion,
+			LastHeartbeat: a.LastHeartbeat,
+			CreatedAt:     a.CreatedAt,
+		}
+		if l := h.sessions[a.ID]; l != nil {
+			info.Online = true
+			info.RemoteAddr = l.remoteAddr
+			info.ConnectedAt = l.connectedAt
+			l.mu.Lock()
+			if !l.lastHeartbeat.IsZero() {
+				info.Hostname = l.heartbeat.Hostname
+				info.Version = l.heartbeat.Version
+				info.DockerVersion = l.heartbeat.DockerVersion
+				info.LastHeartbeat = l.lastHeartbeat
+			}
+			l.mu.Unlock()
+		}
+		list = append(list, info)
+	}
+	return list
+}
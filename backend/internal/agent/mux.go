@@ -0,0 +1,278 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 二进制消息的帧格式：1 字节类型 + 4 字节流 ID（大端）+ 负载。
+// 中心端为每个 Docker API 连接打开一个流，agent 把流转发到本机 Docker socket。
+// 文本消息用于控制信息（心跳），不属于任何流。
+const (
+	frameOpen  byte = 1
+	frameData  byte = 2
+	frameClose byte = 3
+
+	frameHeader   = 5
+	maxFrameData  = 32 * 1024
+	writeTimeout  = 30 * time.Second
+	acceptBacklog = 64
+)
+
+// ErrSessionClosed 会话已关闭
+var ErrSessionClosed = errors.New("agent session closed")
+
+// Session 在一个 WebSocket 连接上复用多个双向字节流
+type Session struct {
+	conn *websocket.Conn
+	wmu  sync.Mutex
+
+	mu      sync.Mutex
+	streams map[uint32]*stream
+	nextID  uint32
+
+	accept    chan *stream
+	onControl func(*Session, []byte)
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSession 创建会话并启动读循环；onControl 处理对端发送的文本消息，可以为 nil
+func NewSession(conn *websocket.Conn, onControl func(*Session, []byte)) *Session {
+	s := &Session{
+		conn:      conn,
+		streams:   make(map[uint32]*stream),
+		accept:    make(chan *stream, acceptBacklog),
+		onControl: onControl,
+		done:      make(chan struct{}),
+	}
+	go s.readLoop()
+	return s
+}
+
+// Open 打开一个新流
+func (s *Session) Open() (net.Conn, error) {
+	s.mu.Lock()
+	s.nextID++
+	st := newStream(s, s.nextID)
+	s.streams[st.id] = st
+	s.mu.Unlock()
+	if err := s.writeFrame(frameOpen, st.id, nil); err != nil {
+		s.removeStream(st.id)
+		return nil, err
+	}
+	return st, nil
+}
+
+// Accept 等待对端打开的流
+func (s *Session) Accept() (net.Conn, error) {
+	select {
+	case st := <-s.accept:
+		return st, nil
+	case <-s.done:
+		return nil, ErrSessionClosed
+	}
+}
+
+// SendControl 发送文本控制消息
+func (s *Session) SendControl(data []byte) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	_ = s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return s.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// Done 会话关闭时关闭的通道
+func (s *Session) Done() <-chan struct{} { return s.done }
+
+// Close 关闭会话与其上的全部流
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		_ = s.conn.Close()
+		s.mu.Lock()
+		streams := s.streams
+		s.streams = make(map[uint32]*stream)
+		s.mu.Unlock()
+		for _, st := range streams {
+			st.remoteClose()
+		}
+	})
+	return nil
+}
+
+func (s *Session) readLoop() {
+	defer s.Close()
+	for {
+		typ, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if typ == websocket.TextMessage {
+			if s.onControl != nil {
+				s.onControl(s, data)
+			}
+			continue
+		}
+		if typ != websocket.BinaryMessage || len(data) < frameHeader {
+			continue
+		}
+		id := binary.BigEndian.Uint32(data[1:frameHeader])
+		payload := data[frameHeader:]
+		switch data[0] {
+		case frameOpen:
+			st := newStream(s, id)
+			s.mu.Lock()
+			s.streams[id] = st
+			s.mu.Unlock()
+			select {
+			case s.accept <- st:
+			case <-s.done:
+				return
+			}
+		case frameData:
+			s.mu.Lock()
+			st := s.streams[id]
+			s.mu.Unlock()
+			if st != nil {
+				st.push(payload)
+			}
+		case frameClose:
+			if st := s.removeStream(id); st != nil {
+				st.remoteClose()
+			}
+		}
+	}
+}
+
+func (s *Session) writeFrame(typ byte, id uint32, payload []byte) error {
+	buf := make([]byte, frameHeader+len(payload))
+	buf[0] = typ
+	binary.BigEndian.PutUint32(buf[1:frameHeader], id)
+	copy(buf[frameHeader:], payload)
+
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	select {
+	case <-s.done:
+		return ErrSessionClosed
+	default:
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := s.conn.WriteMessage(websocket.BinaryMessage, buf); err != nil {
+		go s.Close()
+		return err
+	}
+	return nil
+}
+
+func (s *Session) removeStream(id uint32) *stream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.streams[id]
+	delete(s.streams, id)
+	return st
+}
+
+// stream 会话中的一个流，实现 net.Conn。
+// 接收的数据缓存在内存中，不做流量控制。
+type stream struct {
+	session *Session
+	id      uint32
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	eof    bool // 对端已关闭
+	closed bool // 本端已关闭
+}
+
+func newStream(s *Session, id uint32) *stream {
+	st := &stream{session: s, id: id}
+	st.cond = sync.NewCond(&st.mu)
+	return st
+}
+
+func (st *stream) push(p []byte) {
+	st.mu.Lock()
+	st.buf.Write(p)
+	st.mu.Unlock()
+	st.cond.Broadcast()
+}
+
+func (st *stream) remoteClose() {
+	st.mu.Lock()
+	st.eof = true
+	st.mu.Unlock()
+	st.cond.Broadcast()
+}
+
+func (st *stream) Read(p []byte) (int, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for st.buf.Len() == 0 {
+		if st.closed {
+			return 0, net.ErrClosed
+		}
+		if st.eof {
+			return 0, io.EOF
+		}
+		st.cond.Wait()
+	}
+	return st.buf.Read(p)
+}
+
+func (st *stream) Write(p []byte) (int, error) {
+	st.mu.Lock()
+	closed := st.closed || st.eof
+	st.mu.Unlock()
+	if closed {
+		return 0, net.ErrClosed
+	}
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), maxFrameData)
+		if err := st.session.writeFrame(frameData, st.id, p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (st *stream) Close() error {
+	st.mu.Lock()
+	if st.closed {
+		st.mu.Unlock()
+		return nil
+	}
+	st.closed = true
+	st.mu.Unlock()
+	st.cond.Broadcast()
+	if st.session.removeStream(st.id) != nil {
+		_ = st.session.writeFrame(frameClose, st.id, nil)
+	}
+	return nil
+}
+
+func (st *stream) LocalAddr() net.Addr  { return streamAddr(st.id) }
+func (st *stream) RemoteAddr() net.Addr { return streamAddr(st.id) }
+
+// 流不支持超时，由调用方关闭连接取消阻塞的读取
+func (st *stream) SetDeadline(time.Time) error      { return nil }
+func (st *stream) SetReadDeadline(time.Time) error  { return nil }
+func (st *stream) SetWriteDeadline(time.Time) error { return nil }
+
+type streamAddr uint32
+
+func (a streamAddr) Network() string { return "agent" }
+func (a streamAddr) String() string  { return "stream" }
@@ -0,0 +1,244 @@
+package agent
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// ErrInvalidJoinToken 加入令牌无效、已使用或已过期
+var ErrInvalidJoinToken = errors.New("invalid or expired join token")
+
+// Agent 已注册的 agent
+type Agent struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	SecretHash    string    `json:"secretHash"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Hostname      string    `json:"hostname,omitempty"`
+	Version       string    `json:"version,omitempty"`
+	DockerVersion string    `json:"dockerVersion,omitempty"`
+	LastHeartbeat time.Time `json:"lastHeartbeat,omitempty"`
+}
+
+// JoinToken 一次性加入令牌，只保存哈希
+type JoinToken struct {
+	Hash      string    `json:"hash"`
+	Name      string    `json:"name,omitempty"` // 预设的 agent 名称，可被注册请求覆盖
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type storeData struct {
+	Agents     []Agent     `json:"agents"`
+	JoinTokens []JoinToken `json:"joinTokens"`
+}
+
+// Store 持久化已注册的 agent 与未使用的加入令牌
+type Store struct {
+	path string
+	mu   sync.Mutex
+	data storeData
+}
+
+// NewStore 创建存储并加载已有数据
+func NewStore(path string) *Store {
+	s := &Store{path: path}
+	s.load()
+	return s
+}
+
+// CreateJoinToken 创建一次性加入令牌，返回明文令牌（只返回这一次）
+func (s *Store) CreateJoinToken(name string, ttl time.Duration) (string, JoinToken, error) {
+	token, err := randomHex(24)
+	if err != nil {
+		return "", JoinToken{}, err
+	}
+	now := time.Now()
+	jt := JoinToken{Hash: hashSecret(token), Name: name, CreatedAt: now, ExpiresAt: now.Add(ttl)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(now)
+	s.data.JoinTokens = append(s.data.JoinTokens, jt)
+	s.saveLocked()
+	return token, jt, nil
+}
+
+// Enroll 使用加入令牌注册 agent，令牌随即失效；返回 agent 与其连接密钥（只返回这一次）
+func (s *Store) Enroll(token, name string) (Agent, string, error) {
+	now := time.Now()
+	hash := hashSecret(token)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(now)
+	idx := -1
+	for i, jt := range s.data.JoinTokens {
+		if subtle.ConstantTimeCompare([]byte(jt.Hash), []byte(hash)) == 1 {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return Agent{}, "", ErrInvalidJoinToken
+	}
+	jt := s.data.JoinTokens[idx]
+	s.data.JoinTokens = append(s.data.JoinTokens[:idx], s.data.JoinTokens[idx+1:]...)
+
+	id, err := randomHex(6)
+	if err != nil {
+		return Agent{}, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return Agent{}, "", err
+	}
+	if name == "" {
+		name = jt.Name
+	}
+	if name == "" {
+		name = id
+	}
+	a := Agent{ID: id, Name: name, SecretHash: hashSecret(secret), CreatedAt: now}
+	s.data.Agents = append(s.data.Agents, a)
+	s.saveLocked()
+	return a, secret, nil
+}
+
+// Authenticate 校验 agent 的连接密钥
+func (s *Store) Authenticate(id, secret string) (Agent, bool) {
+	hash := hashSecret(secret)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.data.Agents {
+		if a.ID == id && subtle.ConstantTimeCompare([]byte(a.SecretHash), []byte(hash)) == 1 {
+			return a, true
+		}
+	}
+	return Agent{}, false
+}
+
+// Get 按 ID 查找 agent
+func (s *Store) Get(id string) (Agent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.data.Agents {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return Agent{}, false
+}
+
+// List 返回全部 agent，按注册时间排序
+func (s *Store) List() []Agent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := append([]Agent(nil), s.data.Agents...)
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// JoinTokens 返回未过期的加入令牌
+func (s *Store) JoinTokens() []JoinToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(time.Now())
+	return append([]JoinToken(nil), s.data.JoinTokens...)
+}
+
+// Remove 删除 agent，其连接密钥随即失效
+func (s *Store) Remove(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, a := range s.data.Agents {
+		if a.ID == id {
+			s.data.Agents = append(s.data.Agents[:i], s.data.Agents[i+1:]...)
+			s.saveLocked()
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateStatus 记录 agent 上报的版本信息与最后心跳时间
+func (s *Store) UpdateStatus(id string, hb Heartbeat, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.data.Agents {
+		a := &s.data.Agents[i]
+		if a.ID == id {
+			a.Hostname = hb.Hostname
+			a.Version = hb.Version
+			a.DockerVersion = hb.DockerVersion
+			a.LastHeartbeat = at
+			s.saveLocked()
+			return
+		}
+	}
+}
+
+// pruneLocked 删除已过期的加入令牌
+func (s *Store) pruneLocked(now time.Time) {
+	kept := s.data.JoinTokens[:0]
+	for _, jt := range s.data.JoinTokens {
+		if now.Before(jt.ExpiresAt) {
+			kept = append(kept, jt)
+		}
+	}
+	s.data.JoinTokens = kept
+}
+
+func (s *Store) load() {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		logger.Logger.Debug("无法读取 agent 文件", zap.String("path", s.path), zap.Error(err))
+		return
+	}
+	if err := json.Unmarshal(data, &s.data); err != nil {
+		logger.Logger.Error("解析 agent 文件失败", zap.String("path", s.path), zap.Error(err))
+	}
+}
+
+// saveLocked 持久化数据（需要在持有锁的情况下调用），文件包含密钥哈希，权限为 0600
+func (s *Store) saveLocked() {
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logger.Logger.Error("创建 agent 目录失败", zap.String("dir", dir), zap.Error(err))
+		return
+	}
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		logger.Logger.Error("序列化 agent 数据失败", zap.Error(err))
+		return
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		logger.Logger.Error("保存 agent 文件失败", zap.String("path", s.path), zap.Error(err))
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
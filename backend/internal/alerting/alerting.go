@@ -0,0 +1,270 @@
+// Package alerting 按 resourceAlerts 规则在服务端评估容器资源使用情况，
+// 持续超过阈值时触发告警，恢复后发送解除通知。
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// evalInterval 评估间隔
+const evalInterval = 10 * time.Second
+
+// Alert 一条告警
+type Alert struct {
+	Rule          string    `json:"rule"`
+	Metric        string    `json:"metric"`
+	ContainerID   string    `json:"containerId"`
+	ContainerName string    `json:"containerName"`
+	Image         string    `json:"image"`
+	Value         float64   `json:"value"`     // 最近一次评估的值（百分比）
+	Threshold     float64   `json:"threshold"` // 阈值（百分比）
+	Since         time.Time `json:"since"`     // 开始超过阈值的时间
+	Firing        bool      `json:"firing"`    // false 表示已超过阈值但未达到持续时间
+	FiredAt       time.Time `json:"firedAt,omitempty"`
+	ResolvedAt    time.Time `json:"resolvedAt,omitempty"`
+}
+
+// Notifier 发送告警与解除通知，由 notificationmanager.Manager 实现
+type Notifier interface {
+	NotifyResourceAlert(ctx context.Context, a Alert, resolved bool) error
+}
+
+// Source 资源统计来源，由 dockercli.Client 实现
+type Source interface {
+	AddStatsConnection(ctx context.Context)
+	RemoveStatsConnection()
+	ListContainers(ctx context.Context, includeStopped bool) ([]dockercli.ContainerInfo, error)
+	GetAllContainersStats() []dockercli.ContainerStats
+}
+
+// Evaluator 资源告警评估器
+type Evaluator struct {
+	notifier Notifier
+	mu       sync.Mutex
+	alerts   map[string]*Alert // key: 规则名称|容器 ID
+}
+
+// New 创建评估器
+func New(n Notifier) *Evaluator {
+	return &Evaluator{notifier: n, alerts: make(map[string]*Alert)}
+}
+
+// Run 周期评估告警规则，直到 ctx 结束。
+// resourceAlerts.enabled 开启时作为一个统计连接让 StatsManager 持续采集，不依赖 WebSocket 客户端。
+func (e *Evaluator) Run(ctx context.Context, src Source) {
+	ticker := time.NewTicker(evalInterval)
+	defer ticker.Stop()
+	connected := false
+	defer func() {
+		if connected {
+			src.RemoveStatsConnection()
+		}
+	}()
+
+	for {
+		enabled := config.Get().ResourceAlerts.Enabled
+		switch {
+		case enabled && !connected:
+			logger.Logger.Info("开始评估容器资源告警")
+			src.AddStatsConnection(ctx)
+			connected = true
+		case !enabled && connected:
+			logger.Logger.Info("停止评估容器资源告警")
+			src.RemoveStatsConnection()
+			connected = false
+			// 关闭后不再评估，清除当前告警
+			e.evaluate(ctx, nil, nil, time.Now())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if !connected {
+				continue
+			}
+			containers, err := src.ListContainers(ctx, false)
+			if err != nil {
+				logger.Logger.Warn("读取容器列表失败，跳过资源告警评估", zap.Error(err))
+				continue
+			}
+			e.evaluate(ctx, containers, src.GetAllContainersStats(), now)
+		}
+	}
+}
+
+// Active 返回当前的告警（含未达到持续时间的待触发告警），触发中的在前
+func (e *Evaluator) Active() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list := make([]Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		list = append(list, *a)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Firing != list[j].Firing {
+			return list[i].Firing
+		}
+		return list[i].Since.Before(list[j].Since)
+	})
+	return list
+}
+
+// evaluate 评估一轮：超过阈值的开始计时，持续时间达到后触发；低于阈值或容器已停止的解除，
+// 暂时没有统计数据的容器保持原状态
+func (e *Evaluator) evaluate(ctx context.Context, containers []dockercli.ContainerInfo, stats []dockercli.ContainerStats, now time.Time) {
+	byID := make(map[string]dockercli.ContainerStats, len(stats))
+	for _, st := range stats {
+		byID[st.ID] = st
+	}
+	rules := config.Get().ResourceAlerts.Rules
+
+	var fired, resolved []Alert
+	e.mu.Lock()
+	seen := make(map[string]bool)
+	for _, ct := range containers {
+		st, ok := byID[ct.ID]
+		if !ok {
+			// 容器仍在运行但本轮还没有统计数据（如刚连上统计流或采集暂时失败），保留已有告警
+			for key, a := range e.alerts {
+				if a.ContainerID == ct.ID {
+					seen[key] = true
+				}
+			}
+			continue
+		}
+		for _, r := range rulesFor(rules, ct.Labels) {
+			value, ok := metricValue(r.Metric, st)
+			if !ok {
+				continue
+			}
+			if value <= r.Threshold {
+				continue
+			}
+			name := ruleName(r)
+			key := name + "|" + ct.ID
+			seen[key] = true
+			a, exists := e.alerts[key]
+			if !exists {
+				a = &Alert{
+					Rule:          name,
+					Metric:        r.Metric,
+					ContainerID:   ct.ID,
+					ContainerName: ct.Name,
+					Image:         ct.Image,
+					Threshold:     r.Threshold,
+					Since:         now,
+				}
+				e.alerts[key] = a
+			}
+			a.Value = value
+			if !a.Firing && now.Sub(a.Since) >= time.Duration(r.Duration)*time.Second {
+				a.Firing = true
+				a.FiredAt = now
+				fired = append(fired, *a)
+			}
+		}
+	}
+	for key, a := range e.alerts {
+		if seen[key] {
+			continue
+		}
+		delete(e.alerts, key)
+		if a.Firing {
+			a.ResolvedAt = now
+			resolved = append(resolved, *a)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, a := range fired {
+		logger.Logger.Warn("容器资源告警",
+			zap.String("container", a.ContainerName),
+			zap.String("rule", a.Rule),
+			zap.Float64("value", a.Value))
+		e.notify(ctx, a, false)
+	}
+	for _, a := range resolved {
+		logger.Logger.Info("容器资源告警已解除", zap.String("container", a.ContainerName), zap.String("rule", a.Rule))
+		e.notify(ctx, a, true)
+	}
+}
+
+func (e *Evaluator) notify(ctx context.Context, a Alert, resolved bool) {
+	if e.notifier == nil {
+		return
+	}
+	if err := e.notifier.NotifyResourceAlert(ctx, a, resolved); err != nil {
+		logger.Logger.Error("发送资源告警通知失败", zap.String("container", a.ContainerName), zap.Error(err))
+	}
+}
+
+// rulesFor 返回对容器生效的规则：同一指标有匹配标签的规则时，忽略该指标的全局规则
+func rulesFor(rules []config.ResourceAlertRule, labels map[string]string) []config.ResourceAlertRule {
+	labeled := make(map[string]bool)
+	var out []config.ResourceAlertRule
+	for _, r := range rules {
+		if strings.TrimSpace(r.Label) != "" && matchLabel(r.Label, labels) {
+			labeled[r.Metric] = true
+			out = append(out, r)
+		}
+	}
+	for _, r := range rules {
+		if strings.TrimSpace(r.Label) == "" && !labeled[r.Metric] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// matchLabel 标签格式为 key 或 key=value
+func matchLabel(selector string, labels map[string]string) bool {
+	key, value, hasValue := strings.Cut(strings.TrimSpace(selector), "=")
+	v, ok := labels[key]
+	return ok && (!hasValue || v == value)
+}
+
+// metricValue 返回指标的百分比值，无法计算（如未设置 PidsLimit）时返回 false
+func metricValue(metric string, st dockercli.ContainerStats) (float64, bool) {
+	switch metric {
+	case "cpu":
+		return st.CPUPercent, true
+	case "memory":
+		if st.MemoryLimit == 0 {
+			return 0, false
+		}
+		return float64(st.MemoryUsage) / float64(st.MemoryLimit) * 100, true
+	case "pids":
+		if st.PidsLimit == 0 {
+			return 0, false
+		}
+		return float64(st.PidsCurrent) / float64(st.PidsLimit) * 100, true
+	}
+	return 0, false
+}
+
+// ruleName 返回规则名称，未配置时按指标与阈值生成，如 cpu>90%
+func ruleName(r config.ResourceAlertRule) string {
+	if name := strings.TrimSpace(r.Name); name != "" {
+		return name
+	}
+	name := fmt.Sprintf("%s>%g%%", r.Metric, r.Threshold)
+	if r.Duration > 0 {
+		name += fmt.Sprintf(" for %ds", r.Duration)
+	}
+	if r.Label != "" {
+		name += " [" + r.Label + "]"
+	}
+	return name
+}
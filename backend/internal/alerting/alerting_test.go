@@ -0,0 +1,112 @@
+package alerting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+type fakeNotifier struct {
+	fired, resolved []Alert
+}
+
+func (f *fakeNotifier) NotifyResourceAlert(_ context.Context, a Alert, resolved bool) error {
+	if resolved {
+		f.resolved = append(f.resolved, a)
+	} else {
+		f.fired = append(f.fired, a)
+	}
+	return nil
+}
+
+func setRules(t *testing.T, rules ...config.ResourceAlertRule) {
+	t.Helper()
+	logger.Logger = zap.NewNop()
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	old := config.Get()
+	t.Cleanup(func() { config.SetGlobal(old) })
+	cfg := *old
+	cfg.ResourceAlerts = config.ResourceAlertsConfig{Enabled: true, Rules: rules}
+	config.SetGlobal(&cfg)
+}
+
+func TestEvaluateFiresAfterDurationAndResolves(t *testing.T) {
+	setRules(t, config.ResourceAlertRule{Name: "high cpu", Metric: "cpu", Threshold: 90, Duration: 20})
+	n := &fakeNotifier{}
+	e := New(n)
+	containers := []dockercli.ContainerInfo{{ID: "c1", Name: "web"}}
+	base := time.Unix(1700000000, 0)
+	tick := func(i int, cpu float64) {
+		e.evaluate(context.Background(), containers, []dockercli.ContainerStats{{ID: "c1", CPUPercent: cpu}}, base.Add(time.Duration(i)*10*time.Second))
+	}
+
+	tick(0, 95)
+	tick(1, 97)
+	if len(n.fired) != 0 || len(e.Active()) != 1 || e.Active()[0].Firing {
+		t.Fatalf("alert should be pending, fired=%v active=%v", n.fired, e.Active())
+	}
+	tick(2, 96)
+	tick(3, 99)
+	if len(n.fired) != 1 || n.fired[0].ContainerName != "web" || n.fired[0].Value != 96 {
+		t.Fatalf("expected one firing alert, got %+v", n.fired)
+	}
+	// 某一轮缺少该容器的统计数据时不解除告警
+	e.evaluate(context.Background(), containers, nil, base.Add(35*time.Second))
+	if len(n.resolved) != 0 || len(e.Active()) != 1 || !e.Active()[0].Firing {
+		t.Fatalf("missing stats must keep the alert, resolved=%v active=%v", n.resolved, e.Active())
+	}
+	tick(4, 10)
+	if len(n.resolved) != 1 || len(e.Active()) != 0 {
+		t.Fatalf("expected alert resolved, resolved=%v active=%v", n.resolved, e.Active())
+	}
+
+	// 未持续到设定时长就恢复的不通知
+	tick(5, 95)
+	tick(6, 50)
+	if len(n.fired) != 1 || len(n.resolved) != 1 {
+		t.Fatalf("short spike must not notify: fired=%d resolved=%d", len(n.fired), len(n.resolved))
+	}
+
+	// 容器停止（不在容器列表中）时解除
+	tick(7, 95)
+	tick(9, 95)
+	tick(10, 95)
+	e.evaluate(context.Background(), nil, nil, base.Add(110*time.Second))
+	if len(n.fired) != 2 || len(n.resolved) != 2 || len(e.Active()) != 0 {
+		t.Fatalf("stopped container must resolve: fired=%d resolved=%d active=%v", len(n.fired), len(n.resolved), e.Active())
+	}
+}
+
+func TestRulesForPrefersLabelRules(t *testing.T) {
+	rules := []config.ResourceAlertRule{
+		{Name: "global-cpu", Metric: "cpu", Threshold: 90},
+		{Name: "global-mem", Metric: "memory", Threshold: 80},
+		{Name: "batch-cpu", Label: "role=batch", Metric: "cpu", Threshold: 99},
+	}
+	got := rulesFor(rules, map[string]string{"role": "batch"})
+	if len(got) != 2 || got[0].Name != "batch-cpu" || got[1].Name != "global-mem" {
+		t.Fatalf("unexpected rules %+v", got)
+	}
+	if got := rulesFor(rules, nil); len(got) != 2 || got[0].Name != "global-cpu" {
+		t.Fatalf("unexpected global rules %+v", got)
+	}
+}
+
+func TestMetricValueSkipsUnlimitedPids(t *testing.T) {
+	if _, ok := metricValue("pids", dockercli.ContainerStats{PidsCurrent: 10}); ok {
+		t.Fatal("pids without limit must be skipped")
+	}
+	if v, ok := metricValue("pids", dockercli.ContainerStats{PidsCurrent: 95, PidsLimit: 100}); !ok || v != 95 {
+		t.Fatalf("pids = %v, %v", v, ok)
+	}
+	if v, _ := metricValue("memory", dockercli.ContainerStats{MemoryUsage: 512, MemoryLimit: 1024}); v != 50 {
+		t.Fatalf("memory = %v", v)
+	}
+}
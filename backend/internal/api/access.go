@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/auth"
+	"github.com/jianxcao/watch-docker/backend/internal/composecli"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/scanner"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// composeBodyRoutes 在请求体中指定 compose 项目的路由
+var composeBodyRoutes = map[string]bool{
+	"/compose/start":   true,
+	"/compose/stop":    true,
+	"/compose/restart": true,
+	"/compose/delete":  true,
+}
+
+// restrictionOf 返回当前用户角色的容器访问限制
+func restrictionOf(c *gin.Context) (config.RoleRestriction, bool) {
+	r, ok := config.Get().RBAC.Roles[c.GetString("role")]
+	return r, ok && r.Restricted()
+}
+
+// allowsContainer 容器是否在限制范围内
+func allowsContainer(r config.RoleRestriction, labels map[string]string) bool {
+	for _, sel := range r.Labels {
+		key, value, hasValue := strings.Cut(strings.TrimSpace(sel), "=")
+		v, ok := labels[key]
+		if ok && (!hasValue || v == value) {
+			return true
+		}
+	}
+	return allowsProject(r, labels[composecli.LabelProject])
+}
+
+// allowsProject compose 项目是否在限制范围内
+func allowsProject(r config.RoleRestriction, project string) bool {
+	return project != "" && slices.Contains(r.ComposeProjects, project)
+}
+
+// accessMiddleware 按角色的 rbac 限制检查请求的目标容器或 compose 项目。
+// 角色能否访问路由已由 auth.AuthMiddleware 检查；受限角色只能查看列表（由各列表接口过滤）
+// 以及操作限制范围内的容器与 compose 项目。
+func (s *Server) accessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, restricted := restrictionOf(c)
+		if !restricted {
+			c.Next()
+			return
+		}
+		route := auth.RouteOf(c.FullPath())
+		id := c.Param("id")
+		if id == "" {
+			id = c.Param("containerID")
+		}
+		var allowed bool
+		switch {
+		case id != "" && strings.HasPrefix(route, "/containers/"):
+			allowed = s.containerAllowed(c.Request.Context(), r, id)
+		case c.Param("projectName") != "":
+			allowed = allowsProject(r, c.Param("projectName"))
+		case composeBodyRoutes[route]:
+			allowed = s.composeBodyAllowed(c, r)
+		default:
+			allowed = auth.RequiredScope(c.Request.Method, c.FullPath()) == auth.ScopeRead
+		}
+		if !allowed {
+			s.logger.Warn("角色无权访问目标", zap.String("username", c.GetString("username")), zap.String("role", c.GetString("role")), zap.String("route", route))
+			c.AbortWithStatusJSON(http.StatusForbidden, NewErrorResCode(CodeUnauthorized, "当前角色无权操作该容器"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// containerAllowed 读取容器标签并检查是否在限制范围内
+func (s *Server) containerAllowed(ctx context.Context, r config.RoleRestriction, id string) bool {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	info, err := s.docker.InspectContainer(ctx, id)
+	if err != nil || info.Config == nil {
+		return false
+	}
+	return allowsContainer(r, info.Config.Labels)
+}
+
+// composeBodyAllowed 检查请求体中的 compose 项目，项目名与 compose 文件必须对应已知项目，防止借用项目名操作其他文件
+func (s *Server) composeBodyAllowed(c *gin.Context, r config.RoleRestriction) bool {
+	if s.composeClient == nil {
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	var req composecli.ComposeProject
+	if err := json.Unmarshal(body, &req); err != nil || !allowsProject(r, req.Name) {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Minute)
+	defer cancel()
+	projects, err := s.composeClient.ListProjects(ctx)
+	if err != nil {
+		return false
+	}
+	for _, p := range projects {
+		if p.Name == req.Name {
+			return p.ComposeFile == req.ComposeFile
+		}
+	}
+	return false
+}
+
+// visibleContainers 过滤出当前角色可以查看的容器
+func visibleContainers(c *gin.Context, statuses []scanner.ContainerStatus) []scanner.ContainerStatus {
+	r, restricted := restrictionOf(c)
+	if !restricted {
+		return statuses
+	}
+	out := make([]scanner.ContainerStatus, 0, len(statuses))
+	for _, st := range statuses {
+		if allowsContainer(r, st.Labels) {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// visibleProjects 过滤出当前角色可以查看的 compose 项目
+func visibleProjects(c *gin.Context, projects []composecli.ComposeProject) []composecli.ComposeProject {
+	r, restricted := restrictionOf(c)
+	if !restricted {
+		return projects
+	}
+	out := make([]composecli.ComposeProject, 0, len(projects))
+	for _, p := range projects {
+		if allowsProject(r, p.Name) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
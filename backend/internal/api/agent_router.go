@@ -0,0 +1,154 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/agent"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// agentUpgrader agent 不是浏览器，不校验 Origin
+var agentUpgrader = websocket.Upgrader{
+	ReadBufferSize:  32 * 1024,
+	WriteBufferSize: 32 * 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// setupAgentConnectRoutes 设置 agent 注册与连接路由，不使用登录认证
+func (s *Server) setupAgentConnectRoutes(api *gin.RouterGroup) {
+	api.POST("/agents/enroll", s.handleAgentEnroll())
+	api.GET("/agents/connect", s.handleAgentConnect())
+}
+
+// setupAgentRoutes 设置 agent 管理路由
+func (s *Server) setupAgentRoutes(protected *gin.RouterGroup) {
+	agents := protected.Group("/agents")
+	{
+		agents.GET("", s.handleListAgents())
+		agents.GET("/join-tokens", s.handleListJoinTokens())
+		agents.POST("/join-tokens", s.handleCreateJoinToken())
+		agents.DELETE("/:id", s.handleDeleteAgent())
+	}
+}
+
+// handleListAgents 返回已注册的 agent 及其版本、在线状态与最后心跳时间
+func (s *Server) handleListAgents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"agents": s.agents.List()}))
+	}
+}
+
+// handleListJoinTokens 返回未使用且未过期的加入令牌（不含明文）
+func (s *Server) handleListJoinTokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokens := s.agents.Store().JoinTokens()
+		list := make([]gin.H, 0, len(tokens))
+		for _, jt := range tokens {
+			list = append(list, gin.H{"name": jt.Name, "createdAt": jt.CreatedAt, "expiresAt": jt.ExpiresAt})
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"joinTokens": list}))
+	}
+}
+
+// handleCreateJoinToken 创建一次性加入令牌，明文只在此返回一次
+func (s *Server) handleCreateJoinToken() gin.HandlerFunc {
+	type reqBody struct {
+		Name     string `json:"name"`
+		TTLHours int    `json:"ttlHours"` // 有效期（小时），默认 24
+	}
+	return func(c *gin.Context) {
+		var body reqBody
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&body); err != nil {
+				c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "invalid request body"))
+				return
+			}
+		}
+		if body.TTLHours <= 0 {
+			body.TTLHours = 24
+		}
+		token, jt, err := s.agents.Store().CreateJoinToken(strings.TrimSpace(body.Name), time.Duration(body.TTLHours)*time.Hour)
+		if err != nil {
+			s.logger.Error("create join token", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "创建加入令牌失败"))
+			return
+		}
+		s.logger.Info("已创建 agent 加入令牌", zap.String("name", jt.Name), zap.Time("expiresAt", jt.ExpiresAt))
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"token": token, "expiresAt": jt.ExpiresAt}))
+	}
+}
+
+// handleDeleteAgent 删除 agent：断开连接、使其密钥失效并移除对应端点
+func (s *Server) handleDeleteAgent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if !s.agents.Store().Remove(id) {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "agent not found"))
+			return
+		}
+		s.agents.Disconnect(id)
+		if ep, ok := s.endpoints.Get(config.AgentEndpointPrefix + id); ok {
+			s.endpoints.Remove(ep.ID)
+			s.dropEndpointRouter(ep)
+		}
+		s.logger.Info("已删除 agent", zap.String("agent", id))
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"ok": true}))
+	}
+}
+
+// handleAgentEnroll agent 使用一次性加入令牌注册，返回 agent ID 与连接密钥
+func (s *Server) handleAgentEnroll() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body agent.EnrollRequest
+		if err := c.ShouldBindJSON(&body); err != nil || body.Token == "" {
+			c.JSON(http.StatusBadRequest, NewErrorResCode(CodeBadRequest, "invalid request body"))
+			return
+		}
+		a, secret, err := s.agents.Store().Enroll(body.Token, strings.TrimSpace(body.Name))
+		if err != nil {
+			if errors.Is(err, agent.ErrInvalidJoinToken) {
+				s.logger.Warn("agent 加入令牌无效", zap.String("ip", c.ClientIP()))
+				c.JSON(http.StatusUnauthorized, NewErrorResCode(CodeUnauthorized, "加入令牌无效或已过期"))
+				return
+			}
+			s.logger.Error("enroll agent", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "注册失败"))
+			return
+		}
+		endpointID := ""
+		if ep, err := s.endpoints.AddAgent(c.Request.Context(), a.ID, a.Name, s.agents.Dialer(a.ID), true); err != nil {
+			s.logger.Error("create agent endpoint", zap.String("agent", a.ID), zap.Error(err))
+		} else {
+			endpointID = ep.ID
+		}
+		s.logger.Info("agent 注册成功", zap.String("agent", a.ID), zap.String("name", a.Name), zap.String("ip", c.ClientIP()))
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"id": a.ID, "secret": secret, "endpointId": endpointID}))
+	}
+}
+
+// handleAgentConnect agent 使用 Authorization: Bearer <id>:<secret> 建立隧道连接
+func (s *Server) handleAgentConnect() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		id, secret, _ := strings.Cut(token, ":")
+		a, valid := s.agents.Store().Authenticate(id, secret)
+		if !ok || !valid {
+			s.logger.Warn("agent 认证失败", zap.String("agent", id), zap.String("ip", c.ClientIP()))
+			c.JSON(http.StatusUnauthorized, NewErrorResCode(CodeUnauthorized, "unauthorized"))
+			return
+		}
+		conn, err := agentUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			s.logger.Error("agent websocket upgrade", zap.Error(err))
+			return
+		}
+		s.agents.Serve(conn, a, c.ClientIP())
+	}
+}
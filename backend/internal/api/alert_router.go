@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/jianxcao/watch-docker/backend/internal/alerting"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupAlertRoutes 设置资源告警相关路由
+func (s *Server) setupAlertRoutes(rg *gin.RouterGroup) {
+	alerts := rg.Group("/alerts")
+	{
+		alerts.GET("", s.handleListAlerts())
+	}
+}
+
+// endpointAlert 资源告警，附带所属端点
+type endpointAlert struct {
+	EndpointID   string `json:"endpointId"`
+	EndpointName string `json:"endpointName"`
+	alerting.Alert
+}
+
+// handleListAlerts 返回所有端点当前的资源告警，firing=false 表示已超过阈值但尚未达到持续时间
+func (s *Server) handleListAlerts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		alerts := make([]endpointAlert, 0)
+		for _, ep := range s.endpoints.List() {
+			if !ep.Available() {
+				continue
+			}
+			for _, a := range ep.Alerts.Active() {
+				alerts = append(alerts, endpointAlert{EndpointID: ep.ID, EndpointName: ep.Name, Alert: a})
+			}
+		}
+		// 与单个端点一致：触发中的在前，其余按开始时间排序
+		sort.SliceStable(alerts, func(i, j int) bool {
+			if alerts[i].Firing != alerts[j].Firing {
+				return alerts[i].Firing
+			}
+			return alerts[i].Since.Before(alerts[j].Since)
+		})
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{
+			"enabled": config.Get().ResourceAlerts.Enabled,
+			"alerts":  alerts,
+		}))
+	}
+}
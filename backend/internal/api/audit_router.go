@@ -0,0 +1,361 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/audit"
+	"github.com/jianxcao/watch-docker/backend/internal/auth"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// auditBodyLimit 记录请求参数时读取的请求体上限，超过时只记录大小
+	auditBodyLimit = 64 << 10
+	// auditResponseLimit 判断操作结果时读取的响应体上限
+	auditResponseLimit = 4 << 10
+	// auditMaxString 参数中字符串的最大长度，超过时只记录长度（如文件内容）
+	auditMaxString = 256
+	// auditDefaultLimit 查询默认返回条数
+	auditDefaultLimit = 200
+)
+
+// auditSensitiveKeys 参数名包含这些词时不记录值
+var auditSensitiveKeys = []string{"password", "token", "secret", "code", "credential", "assertion", "privatekey", "tlskey"}
+
+// auditTargetParams 作为操作对象的路由参数，按顺序取第一个非空值
+var auditTargetParams = []string{"id", "containerID", "projectName", "name", "username", "token"}
+
+// setupAuditRoutes 设置审计日志查询路由（仅 admin）
+func (s *Server) setupAuditRoutes(protected *gin.RouterGroup) {
+	a := protected.Group("/audit")
+	{
+		a.GET("", s.handleQueryAudit())
+		a.GET("/export", s.handleExportAudit())
+	}
+}
+
+// audited 请求是否需要审计：所有修改请求，以及会修改容器或打开终端的 WebSocket
+func audited(method, path string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		switch auth.RequiredScope(method, path) {
+		case auth.ScopeShell, auth.ScopeUpdate, auth.ScopeContainer:
+			return true
+		}
+		return false
+	case http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// auditWriter 保存响应体开头部分，用于判断操作结果
+type auditWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if n := auditResponseLimit - w.buf.Len(); n > 0 {
+		w.buf.Write(b[:min(n, len(b))])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// auditMiddleware 记录修改操作的用户、IP、目标、参数与结果；WebSocket 会话在结束时记录，包含持续时间
+func (s *Server) auditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.FullPath()
+		// 端点转发由端点路由自行记录
+		if s.audit == nil || path == "" || path == endpointDispatchPath || !audited(c.Request.Method, path) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		route := auth.RouteOf(path)
+		params := auditParams(c, route)
+		w := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		c.Next()
+
+		e := audit.Entry{
+			Time:       start,
+			User:       c.GetString("username"),
+			Role:       c.GetString("role"),
+			IP:         c.ClientIP(),
+			Endpoint:   s.endpointID,
+			Method:     c.Request.Method,
+			Action:     route,
+			Target:     auditTarget(c, params),
+			Params:     params,
+			Status:     w.Status(),
+			Result:     audit.ResultSuccess,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if v, ok := c.Get("apiToken"); ok {
+			if t, ok := v.(*auth.APIToken); ok {
+				e.Via = "api-token:" + t.Name
+			}
+		}
+		if c.GetBool("proxyAuth") {
+			e.Via = "proxy-header"
+		}
+		// 登录等公开接口没有当前用户，使用请求中的用户名
+		if e.User == "" {
+			if u, ok := params["username"].(string); ok {
+				e.User = u
+			}
+		}
+		e.Result, e.Error = auditResult(w)
+		if err := s.audit.Record(e); err != nil {
+			s.logger.Error("写入审计日志失败", zap.Error(err))
+		}
+	}
+}
+
+// auditResult 根据状态码与响应中的 code 字段判断操作结果
+func auditResult(w *auditWriter) (string, string) {
+	var res struct {
+		Code    int    `json:"code"`
+		Msg     string `json:"msg"`
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(w.buf.Bytes(), &res)
+	msg := res.Msg
+	if msg == "" {
+		msg = res.Message
+	}
+	switch status := w.Status(); {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return audit.ResultDenied, msg
+	case status >= http.StatusBadRequest:
+		return audit.ResultFailure, msg
+	case res.Code != 0:
+		return audit.ResultFailure, msg
+	}
+	return audit.ResultSuccess, ""
+}
+
+// auditParams 收集路由参数、查询参数与 JSON 请求体，敏感字段与过长的值不记录
+func auditParams(c *gin.Context, route string) map[string]any {
+	params := make(map[string]any)
+	for _, p := range c.Params {
+		params[p.Key] = p.Value
+	}
+	for k, v := range c.Request.URL.Query() {
+		if len(v) > 0 {
+			params[k] = v[0]
+		}
+	}
+
+	body := c.Request.Body
+	if body == nil || body == http.NoBody {
+		return sanitizeParams(params)
+	}
+	if !strings.HasPrefix(c.ContentType(), "application/json") || c.Request.ContentLength > auditBodyLimit {
+		if c.Request.ContentLength > 0 {
+			params["bodySize"] = c.Request.ContentLength
+		}
+		return sanitizeParams(params)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, auditBodyLimit+1))
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), body))
+	if err != nil || len(data) > auditBodyLimit {
+		params["bodySize"] = len(data)
+		return sanitizeParams(params)
+	}
+	var m map[string]any
+	if json.Unmarshal(data, &m) != nil {
+		var v any
+		if json.Unmarshal(data, &v) == nil {
+			params["body"] = v
+		}
+		return sanitizeParams(params)
+	}
+	// 配置保存只记录变更的配置项，不记录内容
+	if route == "/config" {
+		params["changed"] = changedConfigSections(m)
+		return sanitizeParams(params)
+	}
+	for k, v := range m {
+		params[k] = v
+	}
+	return sanitizeParams(params)
+}
+
+// changedConfigSections 返回与当前配置不同的顶层配置项
+func changedConfigSections(next map[string]any) []string {
+	cur := make(map[string]json.RawMessage)
+	data, _ := json.Marshal(config.Get())
+	_ = json.Unmarshal(data, &cur)
+	changed := make([]string, 0)
+	for k, v := range next {
+		nv, _ := json.Marshal(v)
+		var a, b any
+		_ = json.Unmarshal(nv, &a)
+		_ = json.Unmarshal(cur[k], &b)
+		if fmt.Sprint(a) != fmt.Sprint(b) {
+			changed = append(changed, k)
+		}
+	}
+	return changed
+}
+
+func sanitizeParams(params map[string]any) map[string]any {
+	if len(params) == 0 {
+		return nil
+	}
+	return sanitize(params, 0).(map[string]any)
+}
+
+// sanitize 隐藏敏感字段，截断过长的字符串，嵌套过深的值只记录类型
+func sanitize(v any, depth int) any {
+	switch t := v.(type) {
+	case map[string]any:
+		if depth > 3 {
+			return "{...}"
+		}
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			if sensitiveKey(k) {
+				out[k] = "***"
+				continue
+			}
+			out[k] = sanitize(val, depth+1)
+		}
+		return out
+	case []any:
+		if depth > 3 || len(t) > 50 {
+			return fmt.Sprintf("[%d items]", len(t))
+		}
+		out := make([]any, len(t))
+		for i, val := range t {
+			out[i] = sanitize(val, depth+1)
+		}
+		return out
+	case string:
+		if len(t) > auditMaxString {
+			return fmt.Sprintf("<%d bytes>", len(t))
+		}
+	}
+	return v
+}
+
+func sensitiveKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, s := range auditSensitiveKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditTarget 操作对象：路由参数或请求体中的名称，文件操作附带路径
+func auditTarget(c *gin.Context, params map[string]any) string {
+	target := ""
+	for _, k := range auditTargetParams {
+		if v := c.Param(k); v != "" && !sensitiveKey(k) {
+			target = v
+			break
+		}
+	}
+	if target == "" {
+		if v, ok := params["name"].(string); ok {
+			target = v
+		}
+	}
+	if p, ok := params["path"].(string); ok && p != "" {
+		if target != "" {
+			return target + ":" + p
+		}
+		return p
+	}
+	return target
+}
+
+// auditFilter 从查询参数读取过滤条件：user、action、target、endpoint、result、since、until（RFC3339）、limit
+func auditFilter(c *gin.Context) (audit.Filter, error) {
+	f := audit.Filter{
+		User:     c.Query("user"),
+		Action:   c.Query("action"),
+		Target:   c.Query("target"),
+		Endpoint: c.Query("endpoint"),
+		Result:   c.Query("result"),
+	}
+	var err error
+	if v := c.Query("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid since")
+		}
+	}
+	if v := c.Query("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid until")
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+			return f, fmt.Errorf("invalid limit")
+		}
+	}
+	return f, nil
+}
+
+// handleQueryAudit 查询审计日志，按时间倒序返回，默认最多 200 条
+func (s *Server) handleQueryAudit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f, err := auditFilter(c)
+		if err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, err.Error()))
+			return
+		}
+		if f.Limit == 0 {
+			f.Limit = auditDefaultLimit
+		}
+		entries, err := s.audit.Query(f)
+		if err != nil {
+			s.logger.Error("query audit log", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "读取审计日志失败"))
+			return
+		}
+		if entries == nil {
+			entries = []audit.Entry{}
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"entries": entries}))
+	}
+}
+
+// handleExportAudit 以 JSONL 格式导出审计日志，按时间正序排列，支持与查询相同的过滤条件
+func (s *Server) handleExportAudit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f, err := auditFilter(c)
+		if err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, err.Error()))
+			return
+		}
+		filename := "audit-" + time.Now().Format("20060102-150405") + ".jsonl"
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Status(http.StatusOK)
+		if err := s.audit.Export(c.Writer, f); err != nil {
+			s.logger.Error("export audit log", zap.Error(err))
+		}
+	}
+}
@@ -38,7 +38,7 @@ func (s *Server) handleListComposeProjects() gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"projects": projects}))
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"projects": visibleProjects(c, projects)}))
 	}
 }
 
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"sync"
 	"time"
 
@@ -11,7 +12,9 @@ import (
 	"github.com/jianxcao/watch-docker/backend/internal/auth"
 	"github.com/jianxcao/watch-docker/backend/internal/config"
 	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	"github.com/jianxcao/watch-docker/backend/internal/history"
 	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/scanner"
 	"github.com/jianxcao/watch-docker/backend/internal/updater"
 	"go.uber.org/zap"
 )
@@ -27,6 +30,7 @@ type batchUpdateMessage struct {
 	Message       string `json:"message,omitempty"`
 	Success       *bool  `json:"success,omitempty"`
 	Error         string `json:"error,omitempty"`
+	RolledBack    bool   `json:"rolledBack,omitempty"` // 新容器校验失败，已回滚到旧容器
 
 	// pull progress fields
 	LayerID string `json:"layerId,omitempty"`
@@ -36,16 +40,23 @@ type batchUpdateMessage struct {
 
 	// scan result
 	Containers []batchUpdateContainerInfo `json:"containers,omitempty"`
+	Order      []string                   `json:"order,omitempty"` // 按依赖关系排序后的更新顺序（容器名称）
 
 	// complete result
 	Updated []string          `json:"updated,omitempty"`
 	Failed  map[string]string `json:"failed,omitempty"`
+
+	// plan result（计划模式）
+	Plan *updater.Plan `json:"plan,omitempty"`
 }
 
 type batchUpdateContainerInfo struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Image string `json:"image"`
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Image     string   `json:"image"`
+	DependsOn []string `json:"dependsOn,omitempty"` // 需先更新的容器
+	Compose   string   `json:"compose,omitempty"`   // 通过 docker compose 更新的服务（project/service）
+	Replicas  []string `json:"replicas,omitempty"`  // 随该服务一起更新的其他容器
 }
 
 var wsUpgrader = websocket.Upgrader{
@@ -105,33 +116,54 @@ func (s *Server) handleBatchUpdateWebSocket() gin.HandlerFunc {
 			return
 		}
 
-		type updateTarget struct {
-			ID    string
-			Name  string
-			Image string
+		decide := func(st scanner.ContainerStatus) (string, string) {
+			decision, reason := updater.StatusDecision(st)
+			if decision == updater.DecisionUpdate && !st.Running && !cfg.Docker.IncludeStopped {
+				return updater.DecisionNotifyOnly, "容器未运行"
+			}
+			return decision, reason
 		}
 
-		var targets []updateTarget
-		for _, st := range statuses {
-			if st.Skipped || st.Status != "UpdateAvailable" {
-				continue
+		// 计划模式（?plan=true）：只返回将要执行的操作，不更新任何容器
+		if c.Query("plan") == "true" || c.Query("plan") == "1" {
+			plan := s.updater.BuildPlan(ctx, statuses, decide)
+			plan.AutoUpdate = true
+			plan.InMaintenanceWindow = true
+			if (c.Query("notify") == "true" || c.Query("notify") == "1") && s.notificationManager != nil {
+				if err := s.notificationManager.NotifyUpdatePlan(ctx, plan); err != nil {
+					logger.Logger.Error("发送更新计划通知失败", zap.Error(err))
+				}
 			}
-			if !st.Running && !cfg.Docker.IncludeStopped {
+			sendMsg(batchUpdateMessage{Type: "plan", Total: plan.Updates(), Order: plan.Order, Plan: plan})
+			return
+		}
+
+		var targets []updater.Target
+		for _, st := range statuses {
+			if decision, _ := decide(st); decision != updater.DecisionUpdate {
 				continue
 			}
-			targets = append(targets, updateTarget{ID: st.ID, Name: st.Name, Image: st.Image})
+			targets = append(targets, updater.Target{ID: st.ID, Name: st.Name, Image: st.UpdateImage()})
 		}
+		// 按依赖关系排序，被依赖的容器先更新
+		targets = s.updater.OrderTargets(ctx, targets)
 
 		// build container info list
 		containerInfos := make([]batchUpdateContainerInfo, len(targets))
+		order := make([]string, len(targets))
 		for i, t := range targets {
-			containerInfos[i] = batchUpdateContainerInfo{ID: t.ID, Name: t.Name, Image: t.Image}
+			containerInfos[i] = batchUpdateContainerInfo{ID: t.ID, Name: t.Name, Image: t.Image, DependsOn: t.DependsOn, Replicas: t.Replicas}
+			if t.Compose != nil {
+				containerInfos[i].Compose = t.Compose.Key()
+			}
+			order[i] = t.Name
 		}
 
 		sendMsg(batchUpdateMessage{
 			Type:       "scan_complete",
 			Total:      len(targets),
 			Containers: containerInfos,
+			Order:      order,
 		})
 
 		if len(targets) == 0 {
@@ -160,7 +192,7 @@ func (s *Server) handleBatchUpdateWebSocket() gin.HandlerFunc {
 				Total:         len(targets),
 			})
 
-			updateCtx, updateCancel := context.WithTimeout(ctx, 5*time.Minute)
+			updateCtx, updateCancel := context.WithTimeout(updater.WithTrigger(ctx, history.TriggerBatch), 5*time.Minute)
 			updateErr := s.updater.UpdateContainerWithProgress(updateCtx, target.ID, target.Image, &updater.UpdateProgressCallback{
 				OnStep: func(step, message string) {
 					sendMsg(batchUpdateMessage{
@@ -199,6 +231,8 @@ func (s *Server) handleBatchUpdateWebSocket() gin.HandlerFunc {
 
 			if updateErr != nil {
 				completeMsg.Error = updateErr.Error()
+				var verr *updater.VerifyError
+				completeMsg.RolledBack = errors.As(updateErr, &verr) && verr.RolledBack()
 				failedMap[target.Name] = updateErr.Error()
 				logger.Logger.Error("batch update container failed",
 					zap.String("container", target.Name),
@@ -206,6 +240,7 @@ func (s *Server) handleBatchUpdateWebSocket() gin.HandlerFunc {
 					zap.Error(updateErr))
 			} else {
 				updatedList = append(updatedList, target.Name)
+				updatedList = append(updatedList, target.Replicas...)
 			}
 			sendMsg(completeMsg)
 		}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,6 +16,8 @@ import (
 	"github.com/jianxcao/watch-docker/backend/internal/auth"
 	"github.com/jianxcao/watch-docker/backend/internal/config"
 	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	"github.com/jianxcao/watch-docker/backend/internal/history"
+	"github.com/jianxcao/watch-docker/backend/internal/updater"
 	"github.com/jianxcao/watch-docker/backend/internal/wsstream"
 
 	"github.com/gin-gonic/gin"
@@ -29,9 +32,12 @@ func (s *Server) setupContainerRoutes(protected *gin.RouterGroup) {
 	protected.POST("/containers/stats", s.handleGetContainersStats())
 	protected.GET("/containers/stats/ws", s.handleStatsWebSocket())
 	protected.GET("/containers/:id/stats/ws", s.handleContainerDetailStatsWebSocket())
+	protected.GET("/containers/:id/stats/history", s.handleContainerStatsHistory())
 	protected.GET("/containers/logs/:containerID/ws", s.handleContainerLogsWebSocket())
 	protected.GET("/containers/:id/shell/ws", s.handleContainerShellWebSocket())
 	protected.POST("/containers/:id/update", s.handleUpdateContainer())
+	protected.GET("/containers/:id/history", s.handleContainerHistory())
+	protected.POST("/containers/:id/rollback", s.handleRollbackContainer())
 	protected.POST("/updates/run", s.handleBatchUpdate())
 	protected.GET("/updates/batch/ws", s.handleBatchUpdateWebSocket())
 	protected.POST("/containers/:id/stop", s.handleStopContainer())
@@ -547,6 +553,142 @@ func (s *Server) handleUpdateContainer() gin.HandlerFunc {
 	}
 }
 
+// handleContainerHistory 返回容器的更新历史（按容器名称匹配，最新的在前）
+func (s *Server) handleContainerHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		info, err := s.docker.InspectContainer(c.Request.Context(), id)
+		if err != nil {
+			s.logger.Error("inspect", zap.String("container", id), zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeDockerError, err.Error()))
+			return
+		}
+		name := strings.TrimPrefix(info.Name, "/")
+		records := make([]history.Record, 0)
+		if h := s.updater.History(); h != nil {
+			records = h.ListByContainer(name)
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"name": name, "history": records}))
+	}
+}
+
+// handleContainerStatsHistory 返回容器的资源使用历史（按容器名称记录，更新重建后仍连续）
+// query: from/to 为 unix 秒或 RFC3339 时间，默认最近 1 小时；step 为间隔，如 10s、5m、1h 或秒数，缺省时自动选择
+func (s *Server) handleContainerStatsHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.statsHistory == nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "stats history disabled"))
+			return
+		}
+		id := c.Param("id")
+		name := ""
+		if info, err := s.docker.InspectContainer(c.Request.Context(), id); err == nil {
+			name = strings.TrimPrefix(info.Name, "/")
+		} else if s.statsHistory.Has(id) {
+			// 容器已被删除时可直接使用名称查询
+			name = id
+		} else {
+			s.logger.Error("inspect", zap.String("container", id), zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeDockerError, err.Error()))
+			return
+		}
+
+		now := time.Now()
+		to, err := parseTimeParam(c.Query("to"), now)
+		if err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "invalid to: "+err.Error()))
+			return
+		}
+		from, err := parseTimeParam(c.Query("from"), to.Add(-time.Hour))
+		if err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "invalid from: "+err.Error()))
+			return
+		}
+		var step time.Duration
+		if raw := c.Query("step"); raw != "" {
+			if secs, err := strconv.Atoi(raw); err == nil {
+				step = time.Duration(secs) * time.Second
+			} else if step, err = time.ParseDuration(raw); err != nil {
+				c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "invalid step"))
+				return
+			}
+		}
+
+		series, err := s.statsHistory.Query(name, from, to, step)
+		if err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(series))
+	}
+}
+
+// parseTimeParam 解析 unix 秒或 RFC3339 时间，为空时返回 def
+func parseTimeParam(raw string, def time.Time) (time.Time, error) {
+	if raw == "" {
+		return def, nil
+	}
+	if secs, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// handleRollbackContainer 使用更新历史中的旧镜像重建容器
+// body: { "recordId": "可选，缺省为最近一次成功更新前的镜像" }
+func (s *Server) handleRollbackContainer() gin.HandlerFunc {
+	type reqBody struct {
+		RecordID string `json:"recordId"`
+	}
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var body reqBody
+		_ = c.ShouldBindJSON(&body)
+
+		h := s.updater.History()
+		if h == nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "update history disabled"))
+			return
+		}
+		info, err := s.docker.InspectContainer(c.Request.Context(), id)
+		if err != nil {
+			s.logger.Error("inspect", zap.String("container", id), zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeDockerError, err.Error()))
+			return
+		}
+		name := strings.TrimPrefix(info.Name, "/")
+
+		var rec history.Record
+		found := false
+		if body.RecordID != "" {
+			rec, found = h.Get(body.RecordID)
+			if found && rec.ContainerName != name {
+				found = false
+			}
+		} else {
+			for _, r := range h.ListByContainer(name) {
+				if r.Result == history.ResultSuccess && r.OldImageID != "" && r.OldImageID != info.Image {
+					rec, found = r, true
+					break
+				}
+			}
+		}
+		if !found {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "no rollback record found"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Minute)
+		defer cancel()
+		if err := s.updater.RollbackContainer(ctx, id, rec, nil); err != nil {
+			s.logger.Error("rollback container", zap.String("container", name), zap.String("record", rec.ID), zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeUpdateFailed, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"ok": true, "record": rec}))
+	}
+}
+
 // handleBatchUpdate 触发一次批量更新：
 // 1) 扫描当前状态
 // 2) 对需要更新的容器逐个执行更新（串行）
@@ -573,11 +715,11 @@ func (s *Server) handleBatchUpdate() gin.HandlerFunc {
 			if !st.Running && !cfg.Docker.IncludeStopped {
 				continue
 			}
-			uctx, cancelOne := context.WithTimeout(ctx, 5*time.Minute)
-			if err := s.updater.UpdateContainer(uctx, st.ID, st.Image); err != nil {
+			uctx, cancelOne := context.WithTimeout(updater.WithTrigger(ctx, history.TriggerBatch), 5*time.Minute)
+			if err := s.updater.UpdateContainer(uctx, st.ID, st.UpdateImage()); err != nil {
 				failed[st.Name] = err.Error()
 				failedCodes[st.Name] = codeForUpdateErr(err)
-				s.logger.Error("auto update failed", zap.String("container", st.Name), zap.String("image", st.Image), zap.Error(err))
+				s.logger.Error("auto update failed", zap.String("container", st.Name), zap.String("image", st.UpdateImage()), zap.Error(err))
 			} else {
 				updated = append(updated, st.Name)
 			}
@@ -643,7 +785,7 @@ func (s *Server) handleListContainers() gin.HandlerFunc {
 			c.JSON(http.StatusOK, NewErrorResCode(CodeScanFailed, "scan failed"))
 			return
 		}
-		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"containers": statuses}))
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"containers": visibleContainers(c, statuses)}))
 	}
 }
 
@@ -1317,9 +1459,32 @@ func (s *Server) handleDownloadContainerFile() gin.HandlerFunc {
 					return
 				}
 
-				// 验证 token
-				if _, err := auth.ValidateToken(token); err != nil {
-					c.JSON(http.StatusUnauthorized, NewErrorResCode(CodeUnauthorized, "无效的token"))
+				// 验证 token（API 令牌需要 files 权限）
+				var username string
+				if strings.HasPrefix(token, auth.APITokenPrefix) {
+					t, ok := auth.GetAPITokenStore().Authenticate(token, c.ClientIP())
+					if !ok || !t.HasScope(auth.ScopeFiles) {
+						c.JSON(http.StatusUnauthorized, NewErrorResCode(CodeUnauthorized, "无效的token"))
+						return
+					}
+					username = t.Username
+				} else {
+					claims, err := auth.ValidateSessionToken(token, c.ClientIP())
+					if err != nil {
+						c.JSON(http.StatusUnauthorized, NewErrorResCode(CodeUnauthorized, "无效的token"))
+						return
+					}
+					username = claims.Username
+				}
+
+				// 检查角色权限与容器访问限制
+				role, ok := auth.UserRole(username)
+				if !ok || !auth.RolePermits(role, c.Request.Method, c.FullPath()) {
+					c.JSON(http.StatusForbidden, NewErrorResCode(CodeUnauthorized, "当前角色无权下载文件"))
+					return
+				}
+				if r, ok := config.Get().RBAC.Roles[role]; ok && r.Restricted() && !s.containerAllowed(c.Request.Context(), r, containerID) {
+					c.JSON(http.StatusForbidden, NewErrorResCode(CodeUnauthorized, "当前角色无权操作该容器"))
 					return
 				}
 			}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
@@ -13,6 +14,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/jianxcao/watch-docker/backend/internal/auth"
 	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/recording"
 	"go.uber.org/zap"
 )
 
@@ -125,6 +127,14 @@ func (s *Server) handleContainerShellWebSocket() gin.HandlerFunc {
 			zap.String("execID", execID.ID),
 			zap.String("shell", shellType))
 
+		// 录制会话（recording.enabled 为 false 时 rec 为 nil），exec 默认终端大小为 80x24
+		rec := s.startRecording(c, recording.Meta{
+			Kind:      recording.KindContainer,
+			Container: strings.TrimPrefix(containerInfo.Name, "/"),
+			Shell:     shellType,
+		}, 80, 24)
+		defer rec.Close()
+
 		// 启动心跳检测
 		go func() {
 			ticker := time.NewTicker(30 * time.Second)
@@ -168,6 +178,7 @@ func (s *Server) handleContainerShellWebSocket() gin.HandlerFunc {
 					}
 
 					if n > 0 {
+						rec.Output(buf[:n])
 						conn.SetWriteDeadline(time.Now().Add(15 * time.Second))
 						if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
 							logger.Logger.Error("Failed to write message to WebSocket", zap.Error(err))
@@ -199,6 +210,7 @@ func (s *Server) handleContainerShellWebSocket() gin.HandlerFunc {
 					var msg ContainerShellMessage
 					if err := json.Unmarshal(message, &msg); err == nil && msg.Type == "resize" {
 						// 调整终端大小
+						rec.Resize(int(msg.Cols), int(msg.Rows))
 						resizeErr := s.docker.ContainerExecResize(execCtx, execID.ID, container.ResizeOptions{
 							Height: uint(msg.Rows),
 							Width:  uint(msg.Cols),
@@ -216,6 +228,7 @@ func (s *Server) handleContainerShellWebSocket() gin.HandlerFunc {
 							logger.Logger.Error("Failed to write to exec", zap.Error(err))
 							return
 						}
+						rec.Input(message)
 					}
 				case websocket.BinaryMessage:
 					// 二进制消息作为用户输入
@@ -223,6 +236,7 @@ func (s *Server) handleContainerShellWebSocket() gin.HandlerFunc {
 						logger.Logger.Error("Failed to write to exec", zap.Error(err))
 						return
 					}
+					rec.Input(message)
 				}
 			}
 		}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/auth"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/endpoint"
+	"github.com/jianxcao/watch-docker/backend/internal/scanner"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// endpointDispatchPath 端点转发路由的完整模板
+const endpointDispatchPath = "/api/v1/endpoints/:endpoint/*path"
+
+// setupEndpointRoutes 设置多端点路由：
+// GET /endpoints 返回各端点健康状态，GET /endpoints/containers 返回所有端点的容器列表，
+// /endpoints/:endpoint/... 转发到该端点的路由（与不带端点前缀的路由一致）。
+// 端点可在运行时增删（agent），因此每个端点的路由在首次访问时创建。
+func (s *Server) setupEndpointRoutes(api, protected *gin.RouterGroup) {
+	protected.GET("/endpoints", s.handleListEndpoints())
+	protected.GET("/endpoints/containers", s.handleListEndpointContainers())
+	// 身份验证由端点路由自行处理（文件下载使用临时 token）
+	api.Any("/endpoints/:endpoint/*path", s.handleEndpointDispatch())
+}
+
+// handleEndpointDispatch 把请求交给端点自己的路由处理
+func (s *Server) handleEndpointDispatch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ep, ok := s.endpoints.Get(c.Param("endpoint"))
+		if !ok || !ep.Available() {
+			c.AbortWithStatusJSON(http.StatusNotFound, NewErrorResCode(CodeBadRequest, "endpoint not found"))
+			return
+		}
+		s.endpointRouter(ep).ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
+
+// endpointRouter 返回端点的路由，路径与 /api/v1/endpoints/<id>/... 完全一致
+func (s *Server) endpointRouter(ep *endpoint.Endpoint) *gin.Engine {
+	s.endpointRoutersMu.Lock()
+	defer s.endpointRoutersMu.Unlock()
+	if r, ok := s.endpointRouters[ep]; ok {
+		return r
+	}
+	es := s
+	if ep.ID != s.endpointID {
+		es = newServer(s.logger, ep, s.registry, s.notificationManager, s.streamManagerString, s.streamManagerBytes)
+		es.audit = s.audit
+		es.recordings = s.recordings
+	}
+	r := gin.New()
+	g := r.Group("/api/v1/endpoints/" + ep.ID)
+	g.Use(es.auditMiddleware())
+	g.GET("/containers/:id/files/download", es.handleDownloadContainerFile())
+	protected := g.Group("")
+	protected.Use(auth.AuthMiddleware(), es.accessMiddleware())
+	es.setupDockerRoutes(protected)
+	s.endpointRouters[ep] = r
+	return r
+}
+
+// dropEndpointRouter 端点删除后释放其路由
+func (s *Server) dropEndpointRouter(ep *endpoint.Endpoint) {
+	s.endpointRoutersMu.Lock()
+	delete(s.endpointRouters, ep)
+	s.endpointRoutersMu.Unlock()
+}
+
+// handleListEndpoints 返回所有端点及其健康状态
+func (s *Server) handleListEndpoints() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"endpoints": s.endpoints.Health(c.Request.Context())}))
+	}
+}
+
+// endpointContainer 聚合列表中的容器，附带所属端点
+type endpointContainer struct {
+	EndpointID   string `json:"endpointId"`
+	EndpointName string `json:"endpointName"`
+	scanner.ContainerStatus
+}
+
+// endpointError 聚合列表时单个端点的错误
+type endpointError struct {
+	EndpointID string `json:"endpointId"`
+	Error      string `json:"error"`
+}
+
+// handleListEndpointContainers 并发读取所有端点的容器列表，单个端点失败不影响其他端点
+func (s *Server) handleListEndpointContainers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Get()
+		isUserCache := c.Query("isUserCache") == "true"
+		isHaveUpdate := c.Query("isHaveUpdate") == "true"
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Minute)
+		defer cancel()
+
+		eps := s.endpoints.List()
+		results := make([][]scanner.ContainerStatus, len(eps))
+		errs := make([]error, len(eps))
+		var wg sync.WaitGroup
+		for i, ep := range eps {
+			if !ep.Available() {
+				errs[i] = ep.Err
+				continue
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], errs[i] = eps[i].Scanner.ScanOnce(ctx, true, cfg.Scan.Concurrency, isUserCache, isHaveUpdate)
+			}(i)
+		}
+		wg.Wait()
+
+		containers := make([]endpointContainer, 0)
+		failed := make([]endpointError, 0)
+		for i, ep := range eps {
+			if errs[i] != nil {
+				s.logger.Warn("读取端点容器列表失败", zap.String("target", ep.ID), zap.Error(errs[i]))
+				failed = append(failed, endpointError{EndpointID: ep.ID, Error: errs[i].Error()})
+				continue
+			}
+			for _, st := range visibleContainers(c, results[i]) {
+				containers = append(containers, endpointContainer{EndpointID: ep.ID, EndpointName: ep.Name, ContainerStatus: st})
+			}
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"containers": containers, "errors": failed}))
+	}
+}
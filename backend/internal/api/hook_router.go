@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/hooks"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxHookBody webhook 请求体大小上限
+const maxHookBody = 1 << 20
+
+// setupHookRoutes 设置 CI webhook 路由，使用 hooks.tokens 中的令牌认证，与登录认证无关
+func (s *Server) setupHookRoutes(api *gin.RouterGroup) {
+	api.POST("/hooks/update/:token", s.handleHookUpdate())
+}
+
+// hookMatch webhook 匹配到的容器
+type hookMatch struct {
+	EndpointID string `json:"endpointId"`
+	Container  string `json:"container"`
+	Image      string `json:"image"`
+}
+
+// handleHookUpdate 解析推送的镜像，找出运行该镜像的容器，清除其远端 digest 缓存后在后台扫描并更新这些容器
+func (s *Server) handleHookUpdate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tok, ok := hooks.Authorize(c.Param("token"))
+		if !ok {
+			s.logger.Warn("webhook 令牌无效", zap.String("ip", c.ClientIP()))
+			c.JSON(http.StatusUnauthorized, NewErrorResCode(CodeUnauthorized, "invalid token"))
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxHookBody))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, NewErrorResCode(CodeBadRequest, "read body failed"))
+			return
+		}
+		images, err := hooks.ParsePayload(body)
+		if err != nil {
+			msg := "invalid payload"
+			if errors.Is(err, hooks.ErrUnknownPayload) {
+				msg = err.Error()
+			}
+			c.JSON(http.StatusBadRequest, NewErrorResCode(CodeBadRequest, msg))
+			return
+		}
+
+		allowed := images[:0]
+		for _, img := range images {
+			if hooks.ImageAllowed(tok, img) {
+				allowed = append(allowed, img)
+			} else {
+				s.logger.Warn("镜像不在 webhook 令牌的允许范围内", zap.String("hook", tok.Name), zap.String("image", img.String()))
+			}
+		}
+		if len(allowed) == 0 {
+			c.JSON(http.StatusForbidden, NewErrorResCode(CodeUnauthorized, "image not allowed for this token"))
+			return
+		}
+
+		// 推送后远端 digest 已变化，清除缓存以便扫描时重新查询
+		for _, img := range allowed {
+			if err := s.registry.InvalidateRepository(img.Repository); err != nil {
+				s.logger.Warn("清除镜像缓存失败", zap.String("image", img.Repository), zap.Error(err))
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+		defer cancel()
+		includeStopped := config.Get().Docker.IncludeStopped
+		matched := make([]hookMatch, 0)
+		for _, ep := range s.endpoints.List() {
+			if !ep.Available() || !hooks.EndpointAllowed(tok, ep.ID) {
+				continue
+			}
+			containers, err := ep.Docker.ListContainers(ctx, includeStopped)
+			if err != nil {
+				s.logger.Warn("webhook 读取端点容器列表失败", zap.String("target", ep.ID), zap.Error(err))
+				continue
+			}
+			names := make(map[string]bool)
+			for _, ct := range containers {
+				for _, img := range allowed {
+					if img.Matches(ct.Image) {
+						names[ct.Name] = true
+						matched = append(matched, hookMatch{EndpointID: ep.ID, Container: ct.Name, Image: ct.Image})
+						break
+					}
+				}
+			}
+			if len(names) > 0 {
+				// 更新可能耗时较长，在后台执行，结果通过通知与更新历史查看
+				go ep.Scheduler.RunHookUpdate(context.Background(), names)
+			}
+		}
+
+		imageNames := make([]string, 0, len(allowed))
+		for _, img := range allowed {
+			imageNames = append(imageNames, img.String())
+		}
+		s.logger.Info("收到 webhook 更新请求",
+			zap.String("hook", tok.Name),
+			zap.Strings("images", imageNames),
+			zap.Int("containers", len(matched)))
+		c.JSON(http.StatusAccepted, NewSuccessRes(gin.H{"images": imageNames, "containers": matched}))
+	}
+}
@@ -15,6 +15,7 @@ import (
 // setupImageRoutes 设置镜像相关的路由
 func (s *Server) setupImageRoutes(protected *gin.RouterGroup) {
 	protected.GET("/images", s.handleListImages())
+	protected.GET("/images/tags", s.handleListImageTags())
 	protected.DELETE("/images", s.handleDeleteImage())
 	protected.GET("/images/:id/download", s.handleDownloadImage())
 	protected.POST("/images/import", s.handleImportImage())
@@ -32,6 +33,25 @@ func (s *Server) handleListImages() gin.HandlerFunc {
 	}
 }
 
+// handleListImageTags 列出镜像在远端 registry 中的 tag
+// query: image=nginx:1.25
+func (s *Server) handleListImageTags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		image := strings.TrimSpace(c.Query("image"))
+		if image == "" {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeImageRequired, "image required"))
+			return
+		}
+		tags, err := s.registry.ListTags(c.Request.Context(), image)
+		if err != nil {
+			s.logger.Error("list image tags", zap.String("image", image), zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeRegistryError, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"image": image, "tags": tags}))
+	}
+}
+
 // handleDeleteImage 删除未使用的镜像（需前端确认未被使用）
 // body: { "ref": "imageID or repo:tag", "force": false }
 func (s *Server) handleDeleteImage() gin.HandlerFunc {
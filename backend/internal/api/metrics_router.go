@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// setupMetricsRoutes 设置 Prometheus 指标路由，不走登录认证，由 metrics.token 单独保护
+func (s *Server) setupMetricsRoutes(r *gin.Engine) {
+	r.GET("/metrics", s.handleMetrics())
+}
+
+// handleMetrics 以 Prometheus 文本格式输出指标；未开启时返回 404
+func (s *Server) handleMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Get().Metrics
+		if !cfg.Enabled {
+			c.String(http.StatusNotFound, "metrics disabled")
+			return
+		}
+		if !metricsTokenValid(c.GetHeader("Authorization"), cfg.Token) {
+			c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			c.String(http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+		s.collectMetrics(ctx)
+
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", metrics.ContentType)
+		if err := metrics.WriteText(c.Writer); err != nil {
+			s.logger.Warn("write metrics", zap.Error(err))
+		}
+	}
+}
+
+// metricsTokenValid 校验 Authorization: Bearer <token>，未配置 token 时不校验
+func metricsTokenValid(header, token string) bool {
+	if token == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(token)) == 1
+}
+
+// collectMetrics 在抓取时刷新限流状态与容器资源等快照类指标
+func (s *Server) collectMetrics(ctx context.Context) {
+	if s.registry != nil {
+		metrics.SetRateLimits(s.registry.GetRateLimitedRegistries())
+	}
+	if s.docker == nil {
+		return
+	}
+
+	// 首次抓取时开始持续采集容器资源统计（与统计 WebSocket 共用），CPU 使用率需要两次采样才能计算
+	s.metricsStatsOnce.Do(func() {
+		s.docker.AddStatsConnection(context.Background())
+	})
+	containers, err := s.docker.ListContainers(ctx, false)
+	if err != nil {
+		s.logger.Warn("list containers for metrics", zap.Error(err))
+		return
+	}
+	names := make(map[string]string, len(containers))
+	for _, ct := range containers {
+		names[ct.ID] = ct.Name
+	}
+	stats := s.docker.GetAllContainersStats()
+	running := stats[:0]
+	for _, st := range stats {
+		if name, ok := names[st.ID]; ok {
+			st.Name = name
+			running = append(running, st)
+		}
+	}
+	metrics.SetContainerStats(running)
+}
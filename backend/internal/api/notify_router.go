@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/notificationmanager"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// setupNotifyRoutes 设置通知相关路由
+func (s *Server) setupNotifyRoutes(rg *gin.RouterGroup) {
+	notify := rg.Group("/notify")
+	{
+		notify.GET("/channels", s.handleListNotifyChannels())
+		notify.POST("/channels/test", s.handleTestNotifyChannel())
+		notify.GET("/templates", s.handleGetNotifyTemplates())
+		notify.POST("/templates/preview", s.handlePreviewNotifyTemplate())
+	}
+}
+
+// handleListNotifyChannels 获取通知渠道及其最近的发送状态
+func (s *Server) handleListNotifyChannels() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.notificationManager == nil {
+			c.JSON(http.StatusOK, NewSuccessRes(gin.H{"channels": []any{}}))
+			return
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"channels": s.notificationManager.ChannelStatuses()}))
+	}
+}
+
+// handleTestNotifyChannel 向指定渠道发送测试通知
+func (s *Server) handleTestNotifyChannel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name string `json:"name" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "渠道名称不能为空"))
+			return
+		}
+		if s.notificationManager == nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "通知功能未初始化"))
+			return
+		}
+		if err := s.notificationManager.TestChannel(c.Request.Context(), req.Name); err != nil {
+			s.logger.Error("test notify channel failed", zap.String("channel", req.Name), zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "发送测试通知失败: "+err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"ok": true}))
+	}
+}
+
+// handleGetNotifyTemplates 获取内置默认模板（中文/英文）与当前自定义模板
+func (s *Server) handleGetNotifyTemplates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		notifyCfg := config.Get().Notify
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{
+			"events":   notificationmanager.EventTypes(),
+			"language": notifyCfg.Language,
+			"custom":   notifyCfg.Templates,
+			"defaults": gin.H{
+				notificationmanager.LangZh: notificationmanager.DefaultTemplates(notificationmanager.LangZh),
+				notificationmanager.LangEn: notificationmanager.DefaultTemplates(notificationmanager.LangEn),
+			},
+		}))
+	}
+}
+
+// handlePreviewNotifyTemplate 使用示例数据渲染模板，title/content 为空时使用对应语言的内置模板
+func (s *Server) handlePreviewNotifyTemplate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Type     string `json:"type" binding:"required"`
+			Title    string `json:"title"`
+			Content  string `json:"content"`
+			Language string `json:"language"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "事件类型不能为空"))
+			return
+		}
+		eventType := notificationmanager.NotificationEventType(req.Type)
+		if !slices.Contains(notificationmanager.EventTypes(), eventType) {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "不支持的事件类型: "+req.Type))
+			return
+		}
+
+		tpl := notificationmanager.DefaultTemplates(req.Language)[eventType]
+		if req.Title != "" {
+			tpl.Title = req.Title
+		}
+		if req.Content != "" {
+			tpl.Content = req.Content
+		}
+		data := notificationmanager.SampleTemplateData(eventType)
+		title, content, err := notificationmanager.RenderTemplate(tpl, data)
+		if err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "模板渲染失败: "+err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"title": title, "content": content, "data": data}))
+	}
+}
@@ -0,0 +1,316 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/oidc"
+	"github.com/jianxcao/watch-docker/backend/internal/users"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// oidcFlowTTL 从跳转到 IdP 到回调的最长时间
+	oidcFlowTTL = 10 * time.Minute
+	// oidcLoginTTL 回调后前端用一次性登录码换取 token 的最长时间
+	oidcLoginTTL = time.Minute
+	// oidcCallbackPath 回调路径，未配置 oidc.redirectUrl 时按请求地址拼接
+	oidcCallbackPath = "/api/v1/oidc/callback"
+	// oidcLoginPage 回调完成后跳转的前端登录页，带 oidc_code 或 oidc_error 参数
+	oidcLoginPage = "/login"
+	// oidcBindingCookie 保存 state 摘要，把回调与一次性登录码绑定到发起登录的浏览器，防止登录 CSRF
+	oidcBindingCookie = "wd_oidc_binding"
+	oidcCookiePath    = "/api/v1/oidc"
+)
+
+// oidcLogin 回调验证通过、等待前端换取 token 的登录
+type oidcLogin struct {
+	username string
+	mfa      bool
+	binding  string // 发起登录的浏览器 cookie 中的 state 摘要
+	expires  time.Time
+}
+
+// oidcSessions 保存进行中的登录流程与一次性登录码（仅内存，重启后需重新登录）
+type oidcSessions struct {
+	mu     sync.Mutex
+	flows  map[string]*oidc.Flow
+	logins map[string]oidcLogin
+}
+
+var (
+	oidcClient = oidc.NewClient(nil)
+	oidcStore  = &oidcSessions{flows: make(map[string]*oidc.Flow), logins: make(map[string]oidcLogin)}
+)
+
+func (o *oidcSessions) putFlow(f *oidc.Flow) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.expireLocked()
+	o.flows[f.State] = f
+}
+
+// takeFlow 取出并删除 state 对应的流程，每个 state 只能使用一次
+func (o *oidcSessions) takeFlow(state string) (*oidc.Flow, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	f, ok := o.flows[state]
+	delete(o.flows, state)
+	if !ok || time.Now().After(f.Expires) {
+		return nil, false
+	}
+	return f, true
+}
+
+func (o *oidcSessions) putLogin(l oidcLogin) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(b)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.expireLocked()
+	o.logins[code] = l
+	return code, nil
+}
+
+// takeLogin 取出并删除一次性登录码
+func (o *oidcSessions) takeLogin(code string) (oidcLogin, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	l, ok := o.logins[code]
+	delete(o.logins, code)
+	if !ok || time.Now().After(l.expires) {
+		return oidcLogin{}, false
+	}
+	return l, true
+}
+
+func (o *oidcSessions) expireLocked() {
+	now := time.Now()
+	for k, f := range o.flows {
+		if now.After(f.Expires) {
+			delete(o.flows, k)
+		}
+	}
+	for k, l := range o.logins {
+		if now.After(l.expires) {
+			delete(o.logins, k)
+		}
+	}
+}
+
+// setupOIDCRoutes 设置 OIDC 单点登录路由（公开接口）
+func (s *Server) setupOIDCRoutes(api *gin.RouterGroup) {
+	o := api.Group("/oidc")
+	{
+		o.GET("/login", s.handleOIDCLogin())
+		o.GET("/callback", s.handleOIDCCallback())
+		o.POST("/exchange", s.handleOIDCExchange())
+	}
+}
+
+// oidcRedirectURL 回调地址：优先使用 oidc.redirectUrl，否则按请求的协议与主机拼接
+func oidcRedirectURL(c *gin.Context, cfg config.OIDCConfig) string {
+	if cfg.RedirectURL != "" {
+		return cfg.RedirectURL
+	}
+	scheme := c.GetHeader("X-Forwarded-Proto")
+	if scheme == "" {
+		scheme = "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+	}
+	host := c.GetHeader("X-Forwarded-Host")
+	if host == "" {
+		host = c.Request.Host
+	}
+	return scheme + "://" + host + oidcCallbackPath
+}
+
+// redirectLoginPage 跳转回前端登录页
+func redirectLoginPage(c *gin.Context, key, value string) {
+	c.Redirect(http.StatusFound, oidcLoginPage+"?"+url.Values{key: {value}}.Encode())
+}
+
+// oidcStateBinding 返回 state 的摘要，作为绑定 cookie 的值
+func oidcStateBinding(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// setOIDCBinding 写入绑定 cookie（HttpOnly、SameSite=Lax，IdP 跳转回来的顶级 GET 请求会携带），ttl 为 0 时删除
+func setOIDCBinding(c *gin.Context, value string, ttl time.Duration) {
+	maxAge := int(ttl.Seconds())
+	if ttl == 0 {
+		maxAge = -1
+	}
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, value, maxAge, oidcCookiePath, "", secure, true)
+}
+
+// oidcBindingMatches 检查请求中的绑定 cookie 是否与 binding 一致
+func oidcBindingMatches(c *gin.Context, binding string) bool {
+	v, err := c.Cookie(oidcBindingCookie)
+	return err == nil && v != "" && subtle.ConstantTimeCompare([]byte(v), []byte(binding)) == 1
+}
+
+// handleOIDCLogin 生成 state、nonce 与 PKCE verifier 并跳转到 IdP
+func (s *Server) handleOIDCLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Get().OIDC
+		if !cfg.Enabled {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "未启用 OIDC 登录"))
+			return
+		}
+		f, err := oidc.NewFlow(oidcRedirectURL(c, cfg), oidcFlowTTL)
+		if err != nil {
+			s.logger.Error("create oidc flow", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "创建登录流程失败"))
+			return
+		}
+		target, err := oidcClient.AuthCodeURL(c.Request.Context(), cfg, f)
+		if err != nil {
+			s.logger.Error("oidc discovery failed", zap.String("issuer", cfg.Issuer), zap.Error(err))
+			redirectLoginPage(c, "oidc_error", "无法连接身份提供方")
+			return
+		}
+		oidcStore.putFlow(f)
+		setOIDCBinding(c, oidcStateBinding(f.State), oidcFlowTTL)
+		c.Redirect(http.StatusFound, target)
+	}
+}
+
+// handleOIDCCallback 校验授权码与 ID Token，映射本地用户后以一次性登录码跳转回登录页
+func (s *Server) handleOIDCCallback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Get().OIDC
+		if !cfg.Enabled {
+			redirectLoginPage(c, "oidc_error", "未启用 OIDC 登录")
+			return
+		}
+		// 回调只接受由本浏览器发起的登录，成功时 cookie 改为绑定一次性登录码，失败时删除
+		binding := oidcStateBinding(c.Query("state"))
+		if !oidcBindingMatches(c, binding) {
+			s.logger.Warn("oidc callback without matching binding cookie")
+			setOIDCBinding(c, "", 0)
+			redirectLoginPage(c, "oidc_error", "登录会话无效，请在本浏览器重新发起登录")
+			return
+		}
+		setOIDCBinding(c, "", 0)
+		if e := c.Query("error"); e != "" {
+			s.logger.Warn("oidc provider returned error", zap.String("error", e), zap.String("description", c.Query("error_description")))
+			redirectLoginPage(c, "oidc_error", "身份提供方拒绝登录: "+e)
+			return
+		}
+		f, ok := oidcStore.takeFlow(c.Query("state"))
+		if !ok {
+			redirectLoginPage(c, "oidc_error", "登录已过期，请重试")
+			return
+		}
+		claims, err := oidcClient.Exchange(c.Request.Context(), cfg, f, c.Query("code"))
+		if err != nil {
+			s.logger.Warn("oidc code exchange failed", zap.Error(err))
+			redirectLoginPage(c, "oidc_error", "身份验证失败")
+			return
+		}
+		username, err := s.oidcUser(cfg, claims)
+		if err != nil {
+			s.logger.Warn("oidc login rejected", zap.String("username", claims.Username), zap.String("subject", claims.Subject), zap.Error(err))
+			redirectLoginPage(c, "oidc_error", oidcErrorMessage(err))
+			return
+		}
+		code, err := oidcStore.putLogin(oidcLogin{
+			username: username,
+			mfa:      cfg.TrustMFA && oidc.MFA(claims),
+			binding:  binding,
+			expires:  time.Now().Add(oidcLoginTTL),
+		})
+		if err != nil {
+			s.logger.Error("create oidc login code", zap.Error(err))
+			redirectLoginPage(c, "oidc_error", "登录失败")
+			return
+		}
+		setOIDCBinding(c, binding, oidcLoginTTL)
+		redirectLoginPage(c, "oidc_code", code)
+	}
+}
+
+// oidcUser 检查登录限制并按 issuer|subject 把 IdP 用户映射为本地用户：按 roleGroups 同步 OIDC 用户的角色，
+// 新用户使用 defaultRole 创建；同名的本地账号只有开启 linkExistingUsers 时才关联
+func (s *Server) oidcUser(cfg config.OIDCConfig, claims *oidc.Claims) (string, error) {
+	if err := oidc.Allowed(cfg, claims); err != nil {
+		return "", err
+	}
+	u, err := users.GetStore().EnsureExternal(users.ExternalIdentity{
+		Username:     claims.Username,
+		Source:       "oidc",
+		ID:           oidc.ExternalID(claims),
+		Role:         oidc.MappedRole(cfg, claims.Groups),
+		DefaultRole:  cfg.DefaultRole,
+		LinkExisting: cfg.LinkExisting,
+	})
+	if err != nil {
+		return "", err
+	}
+	return u.Username, nil
+}
+
+func oidcErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, oidc.ErrNotAllowed):
+		return "该账号不允许登录"
+	case errors.Is(err, oidc.ErrNoUsername), errors.Is(err, users.ErrInvalidUsername):
+		return "无法从身份提供方获取有效的用户名"
+	case errors.Is(err, users.ErrBuiltinUser):
+		return "内置管理员只能使用密码登录"
+	case errors.Is(err, users.ErrIdentityConflict):
+		return "已存在同名账号，请联系管理员"
+	case errors.Is(err, users.ErrUserNotFound):
+		return "账号未分配角色，请联系管理员"
+	}
+	return "登录失败"
+}
+
+// handleOIDCExchange 使用一次性登录码换取 token，响应与 /login 相同；IdP 已完成多因素认证时跳过二次验证。
+// 登录码只能由完成回调的同一浏览器（携带绑定 cookie）使用。
+func (s *Server) handleOIDCExchange() gin.HandlerFunc {
+	type exchangeRequest struct {
+		Code string `json:"code" binding:"required"`
+	}
+	return func(c *gin.Context) {
+		var req exchangeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "code is required"))
+			return
+		}
+		l, ok := oidcStore.takeLogin(req.Code)
+		matched := ok && oidcBindingMatches(c, l.binding)
+		setOIDCBinding(c, "", 0)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, NewErrorResCode(CodeUnauthorized, "登录已过期，请重试"))
+			return
+		}
+		if !matched {
+			s.logger.Warn("oidc login code used without matching binding cookie", zap.String("username", l.username))
+			c.JSON(http.StatusUnauthorized, NewErrorResCode(CodeUnauthorized, "登录会话无效，请重新登录"))
+			return
+		}
+		// 审计日志记录登录用户
+		c.Set("username", l.username)
+		s.completeLogin(c, l.username, l.mfa)
+	}
+}
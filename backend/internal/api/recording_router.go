@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jianxcao/watch-docker/backend/internal/recording"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// setupRecordingRoutes 设置终端录像路由（仅 admin）
+func (s *Server) setupRecordingRoutes(protected *gin.RouterGroup) {
+	r := protected.Group("/recordings")
+	{
+		r.GET("", s.handleListRecordings())
+		r.GET("/:id/download", s.handleDownloadRecording())
+		r.DELETE("/:id", s.handleDeleteRecording())
+	}
+}
+
+// startRecording 开始录制当前请求的终端会话；未开启录制或创建失败时返回 nil，不影响终端使用
+func (s *Server) startRecording(c *gin.Context, meta recording.Meta, width, height int) *recording.Recorder {
+	if s.recordings == nil {
+		return nil
+	}
+	meta.User = c.GetString("username")
+	meta.IP = c.ClientIP()
+	meta.Endpoint = s.endpointID
+	rec, err := s.recordings.Start(meta, width, height)
+	if err != nil {
+		s.logger.Error("开始录制终端会话失败", zap.Error(err))
+		return nil
+	}
+	if rec != nil {
+		s.logger.Info("开始录制终端会话", zap.String("recording", rec.ID()), zap.String("user", meta.User))
+	}
+	return rec
+}
+
+// handleListRecordings 列出终端录像，按开始时间倒序排列，支持 user、kind、endpoint 过滤
+func (s *Server) handleListRecordings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := s.recordings.List()
+		if err != nil {
+			s.logger.Error("list recordings", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "读取录像列表失败"))
+			return
+		}
+		user, kind, endpoint := c.Query("user"), c.Query("kind"), c.Query("endpoint")
+		out := make([]recording.Meta, 0, len(list))
+		for _, m := range list {
+			if (user != "" && m.User != user) || (kind != "" && m.Kind != kind) || (endpoint != "" && m.Endpoint != endpoint) {
+				continue
+			}
+			out = append(out, m)
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"recordings": out}))
+	}
+}
+
+// handleDownloadRecording 下载 asciinema v2 格式的录像
+func (s *Server) handleDownloadRecording() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		p, err := s.recordings.Path(id)
+		if err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "recording not found"))
+			return
+		}
+		c.Header("Content-Type", "application/x-asciicast")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.cast"`, id))
+		c.File(p)
+	}
+}
+
+// handleDeleteRecording 删除录像
+func (s *Server) handleDeleteRecording() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.recordings.Delete(c.Param("id")); err != nil {
+			if errors.Is(err, recording.ErrNotFound) {
+				c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "recording not found"))
+				return
+			}
+			s.logger.Error("delete recording", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "删除录像失败"))
+			return
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(nil))
+	}
+}
@@ -1,22 +1,30 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
+	"github.com/jianxcao/watch-docker/backend/internal/agent"
+	"github.com/jianxcao/watch-docker/backend/internal/audit"
 	"github.com/jianxcao/watch-docker/backend/internal/auth"
 	"github.com/jianxcao/watch-docker/backend/internal/composecli"
 	"github.com/jianxcao/watch-docker/backend/internal/conf"
 	"github.com/jianxcao/watch-docker/backend/internal/config"
 	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	"github.com/jianxcao/watch-docker/backend/internal/endpoint"
 	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/notificationmanager"
+	"github.com/jianxcao/watch-docker/backend/internal/recording"
 	"github.com/jianxcao/watch-docker/backend/internal/registry"
 	"github.com/jianxcao/watch-docker/backend/internal/scanner"
 	"github.com/jianxcao/watch-docker/backend/internal/scheduler"
+	"github.com/jianxcao/watch-docker/backend/internal/statshistory"
 	"github.com/jianxcao/watch-docker/backend/internal/twofa"
 	"github.com/jianxcao/watch-docker/backend/internal/updater"
 	"github.com/jianxcao/watch-docker/backend/internal/wsstream"
@@ -30,18 +38,28 @@ import (
 
 type Server struct {
 	logger              *zap.Logger
+	endpointID          string
+	endpoints           *endpoint.Manager // 仅顶层 Server 持有
+	endpointRoutersMu   sync.Mutex
+	endpointRouters     map[*endpoint.Endpoint]*gin.Engine
 	docker              *dockercli.Client
 	registry            *registry.Client
 	scanner             *scanner.Scanner
 	updater             *updater.Updater
 	scheduler           *scheduler.Scheduler
+	notificationManager *notificationmanager.Manager
 	wsStatsManager      *StatsWebSocketManager
 	composeClient       *composecli.Client
 	streamManagerString *wsstream.StreamManager[string] // 用于 container stats (JSON 文本)
 	streamManagerBytes  *wsstream.StreamManager[[]byte] // 用于 compose logs (二进制流)
+	metricsStatsOnce    sync.Once                       // 首次抓取 /metrics 时开始采集容器资源统计
+	statsHistory        *statshistory.Store
+	agents              *agent.Hub
+	audit               *audit.Log
+	recordings          *recording.Store
 }
 
-func NewRouter(logger *zap.Logger, docker *dockercli.Client, reg *registry.Client, sc *scanner.Scanner, sch *scheduler.Scheduler) *gin.Engine {
+func NewRouter(logger *zap.Logger, eps *endpoint.Manager, reg *registry.Client, nm *notificationmanager.Manager, agents *agent.Hub, auditLog *audit.Log, recordings *recording.Store) *gin.Engine {
 	r := gin.New()
 	r.Use(gin.Recovery())
 	r.Use(auth.SecurityHeadersMiddleware())
@@ -52,39 +70,38 @@ func NewRouter(logger *zap.Logger, docker *dockercli.Client, reg *registry.Clien
 	// 创建 WebSocket 管理器（[]byte 类型用于日志流）
 	streamManagerBytes := wsstream.NewStreamManager[[]byte]()
 
-	// 创建 WebSocket 管理器（使用 wsstream 框架）
-	wsStatsManager := NewStatsWebSocketManager(docker, sc, streamManagerString)
-
-	// 创建 Compose 客户端
-	cfg := config.Get()
-	var composeClient *composecli.Client
-	if cfg.Compose.Enabled {
-		composeClient = composecli.NewClient(docker.GetDockerClient())
-	}
-
-	s := &Server{
-		logger:              logger,
-		docker:              docker,
-		registry:            reg,
-		scanner:             sc,
-		updater:             updater.New(docker),
-		scheduler:           sch,
-		wsStatsManager:      wsStatsManager,
-		composeClient:       composeClient,
-		streamManagerString: streamManagerString,
-		streamManagerBytes:  streamManagerBytes,
-	}
+	// 不带端点 ID 的路由使用本机端点
+	s := newServer(logger, eps.Local(), reg, nm, streamManagerString, streamManagerBytes)
+	s.logger = logger
+	s.agents = agents
+	s.endpoints = eps
+	s.endpointRouters = make(map[*endpoint.Endpoint]*gin.Engine)
+	s.audit = auditLog
+	s.recordings = recordings
 
 	api := r.Group("/api/v1")
+	// 审计修改操作（包括登录与二次验证）
+	api.Use(s.auditMiddleware())
 	{
 		// 公开接口（不需要身份验证）
 		api.GET("/healthz", func(c *gin.Context) { c.JSON(http.StatusOK, NewSuccessRes(nil)) })
 		api.POST("/login", s.handleLogin())
+		api.POST("/auth/proxy", s.handleProxyLogin())
+		api.POST("/auth/refresh", s.handleRefreshToken())
 		api.POST("/logout", s.handleLogout())
 		api.GET("/auth/status", s.handleAuthStatus())
 		api.GET("/info", s.handleGetInfo())
 	}
 
+	// agent 注册与连接（使用加入令牌与 agent 密钥认证）
+	s.setupAgentConnectRoutes(api)
+
+	// CI webhook 触发更新（使用 hooks.tokens 认证）
+	s.setupHookRoutes(api)
+
+	// OIDC 单点登录（公开接口）
+	s.setupOIDCRoutes(api)
+
 	// 二次验证相关路由（允许临时 token）
 	twofa := api.Group("/2fa")
 	twofa.Use(auth.TempTokenMiddleware())
@@ -105,24 +122,37 @@ func NewRouter(logger *zap.Logger, docker *dockercli.Client, reg *registry.Clien
 
 	// 需要身份验证的接口
 	protected := api.Group("")
-	protected.Use(auth.AuthMiddleware())
+	protected.Use(auth.AuthMiddleware(), s.accessMiddleware())
 	{
-		// 设置容器相关路由
-		s.setupContainerRoutes(protected)
+		// 设置本机端点的 Docker 相关路由
+		s.setupDockerRoutes(protected)
 
-		// 设置镜像相关路由
-		s.setupImageRoutes(protected)
+		// 设置多端点路由：/endpoints 与 /endpoints/:id/...
+		s.setupEndpointRoutes(api, protected)
 
-		// 设置 Compose 相关路由
-		if s.composeClient != nil {
-			s.setupComposeRoutes(protected)
-		}
+		// 设置 agent 管理路由
+		s.setupAgentRoutes(protected)
+
+		// 设置通知相关路由
+		s.setupNotifyRoutes(protected)
+
+		// 设置资源告警相关路由
+		s.setupAlertRoutes(protected)
+
+		// 设置 API 令牌管理路由
+		s.setupAPITokenRoutes(protected)
+
+		// 设置用户管理与个人信息路由
+		s.setupUserRoutes(protected)
 
-		// 设置 Volume 相关路由
-		s.setupVolumeRoutes(protected)
+		// 设置登录会话管理路由
+		s.setupSessionRoutes(protected)
 
-		// 设置网络相关路由
-		s.setupNetworkRoutes(protected)
+		// 设置审计日志路由
+		s.setupAuditRoutes(protected)
+
+		// 设置终端录像路由
+		s.setupRecordingRoutes(protected)
 
 		// 其他路由
 		protected.GET("/config", s.handleGetConfig())
@@ -132,11 +162,67 @@ func NewRouter(logger *zap.Logger, docker *dockercli.Client, reg *registry.Clien
 		// Shell WebSocket
 		protected.GET("/shell", s.handleShellWebSocket())
 	}
+
+	// Prometheus 指标（独立的 Bearer token 认证）
+	s.setupMetricsRoutes(r)
+
 	s.setupStaticRoutes(r)
 
 	return r
 }
 
+// newServer 创建绑定到指定端点的 Server
+func newServer(logger *zap.Logger, ep *endpoint.Endpoint, reg *registry.Client, nm *notificationmanager.Manager, streamManagerString *wsstream.StreamManager[string], streamManagerBytes *wsstream.StreamManager[[]byte]) *Server {
+	// 创建 Compose 客户端（compose 项目目录只在本机可用）
+	var composeClient *composecli.Client
+	if config.Get().Compose.Enabled && ep.ID == config.LocalEndpointID {
+		composeClient = composecli.NewClient(ep.Docker.GetDockerClient())
+	}
+
+	return &Server{
+		logger:              logger.With(zap.String("endpoint", ep.ID)),
+		endpointID:          ep.ID,
+		docker:              ep.Docker,
+		registry:            reg,
+		scanner:             ep.Scanner,
+		updater:             ep.Updater,
+		scheduler:           ep.Scheduler,
+		statsHistory:        ep.StatsHistory,
+		notificationManager: nm,
+		// 创建 WebSocket 管理器（使用 wsstream 框架）
+		wsStatsManager:      NewStatsWebSocketManager(ep.Docker, ep.Scanner, streamManagerString),
+		composeClient:       composeClient,
+		streamManagerString: streamManagerString,
+		streamManagerBytes:  streamManagerBytes,
+	}
+}
+
+// setupDockerRoutes 设置与单个 Docker 端点相关的路由
+func (s *Server) setupDockerRoutes(rg *gin.RouterGroup) {
+	// 设置容器相关路由
+	s.setupContainerRoutes(rg)
+
+	// 设置镜像相关路由
+	s.setupImageRoutes(rg)
+
+	// 设置 Compose 相关路由
+	if s.composeClient != nil {
+		s.setupComposeRoutes(rg)
+	}
+
+	// 设置 Volume 相关路由
+	s.setupVolumeRoutes(rg)
+
+	// 设置网络相关路由
+	s.setupNetworkRoutes(rg)
+
+	// 设置调度器相关路由
+	s.setupSchedulerRoutes(rg)
+
+	// 设置更新计划相关路由
+	s.setupUpdateRoutes(rg)
+}
+
 // handleGetInfo 获取系统信息
 func (s *Server) handleGetInfo() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -204,11 +290,11 @@ func (s *Server) handleSaveConfig() gin.HandlerFunc {
 			s.logger.Info("registry credentials updated")
 		}
 
-		// 重启调度器以应用新的配置
-		if s.scheduler != nil {
+		// 重启所有端点的调度器以应用新的配置（docker.endpoints 的变更需要重启服务）
+		if s.endpoints != nil {
 			s.logger.Info("restarting scheduler to apply new configuration")
-			s.scheduler.Stop()
-			s.scheduler.Start()
+			s.endpoints.StopSchedulers()
+			s.endpoints.StartSchedulers()
 		}
 
 		s.logger.Info("config updated successfully")
@@ -252,77 +338,135 @@ func (s *Server) handleLogin() gin.HandlerFunc {
 		}
 
 		limiter.RecordSuccess(clientIP)
+		s.completeLogin(c, req.Username, false)
+	}
+}
 
-		// 检查是否启用二次验证
-		envCfg := conf.EnvCfg
-		if envCfg.IS_SECONDARY_VERIFICATION {
-			// 检查用户是否已设置二次验证
-			userConfig, err := twofa.GetUserConfig(req.Username)
-			if err != nil {
-				s.logger.Error("get user twofa config failed", zap.Error(err))
-				c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "获取配置失败"))
-				return
-			}
-
-			// 提取 RPID（用于 WebAuthn 检查）
-			rpid, _ := extractRPIDAndOrigin(c)
+// completeLogin 凭据验证通过后发放 token：启用二次验证且未跳过时发放临时 token，否则发放完整 token
+func (s *Server) completeLogin(c *gin.Context, username string, skipTwoFA bool) {
+	// 检查是否启用二次验证
+	envCfg := conf.EnvCfg
+	if envCfg.IS_SECONDARY_VERIFICATION && !skipTwoFA {
+		// 检查用户是否已设置二次验证
+		userConfig, err := twofa.GetUserConfig(username)
+		if err != nil {
+			s.logger.Error("get user twofa config failed", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "获取配置失败"))
+			return
+		}
 
-			// 检查当前域名/方法是否已设置
-			isSetup, err := twofa.IsUserSetupForMethod(req.Username, userConfig.Method, rpid)
-			if err != nil {
-				s.logger.Error("check user setup status failed", zap.Error(err))
-				c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "检查设置状态失败"))
-				return
-			}
+		// 提取 RPID（用于 WebAuthn 检查）
+		rpid, _ := extractRPIDAndOrigin(c)
 
-			// 生成临时 token
-			tempToken, err := auth.GenerateTempToken(req.Username)
-			if err != nil {
-				s.logger.Error("generate temp token failed", zap.Error(err))
-				c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "生成token失败"))
-				return
-			}
-
-			s.logger.Info("user login, need 2fa", zap.String("username", req.Username), zap.Bool("isSetup", isSetup), zap.String("method", string(userConfig.Method)), zap.String("rpid", rpid))
-			c.JSON(http.StatusOK, NewSuccessRes(gin.H{
-				"needTwoFA": true,
-				"isSetup":   isSetup,
-				"method":    userConfig.Method,
-				"tempToken": tempToken,
-				"username":  req.Username,
-			}))
+		// 检查当前域名/方法是否已设置
+		isSetup, err := twofa.IsUserSetupForMethod(username, userConfig.Method, rpid)
+		if err != nil {
+			s.logger.Error("check user setup status failed", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "检查设置状态失败"))
 			return
 		}
 
-		// 未启用二次验证，直接生成完整 token
-		token, err := auth.GenerateToken(req.Username)
+		// 生成临时 token
+		tempToken, err := auth.GenerateTempToken(username)
 		if err != nil {
-			s.logger.Error("generate token failed", zap.Error(err))
-			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "生成token失败"))
+			s.logger.Error("generate temp token failed", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "生成token失败"))
 			return
 		}
 
-		s.logger.Info("user logged in", zap.String("username", req.Username))
+		s.logger.Info("user login, need 2fa", zap.String("username", username), zap.Bool("isSetup", isSetup), zap.String("method", string(userConfig.Method)), zap.String("rpid", rpid))
 		c.JSON(http.StatusOK, NewSuccessRes(gin.H{
-			"token":    token,
-			"username": req.Username,
+			"needTwoFA": true,
+			"isSetup":   isSetup,
+			"method":    userConfig.Method,
+			"tempToken": tempToken,
+			"username":  username,
 		}))
+		return
+	}
+
+	// 未启用二次验证，直接创建会话并生成完整 token
+	pair, err := auth.NewSession(username, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		s.logger.Error("generate token failed", zap.Error(err))
+		c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "生成token失败"))
+		return
+	}
+
+	s.logger.Info("user logged in", zap.String("username", username), zap.String("session", pair.SessionID))
+	c.JSON(http.StatusOK, NewSuccessRes(gin.H{
+		"token":        pair.Token,
+		"refreshToken": pair.RefreshToken,
+		"expiresIn":    pair.ExpiresIn,
+		"username":     username,
+	}))
+}
+
+// handleProxyLogin 可信反向代理已认证的用户直接获取 token，无需再次登录（代理已完成二次验证）
+func (s *Server) handleProxyLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username, ok := auth.ProxyUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, NewErrorResCode(CodeUnauthorized, "未通过反向代理认证"))
+			return
+		}
+		if _, ok := auth.ResolveProxyUser(username); !ok {
+			s.logger.Warn("proxy login rejected, unknown user", zap.String("username", username), zap.String("remote", c.RemoteIP()))
+			c.JSON(http.StatusUnauthorized, NewErrorResCode(CodeUnauthorized, "用户不存在"))
+			return
+		}
+		c.Set("username", username)
+		c.Set("proxyAuth", true)
+		s.completeLogin(c, username, true)
 	}
 }
 
-// handleLogout 登出接口
+// handleLogout 登出接口：吊销当前 token 对应的会话，客户端仍需删除本地存储的 token
 func (s *Server) handleLogout() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 简单的登出响应，客户端需要删除本地存储的token
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if claims, err := auth.ValidateToken(token); err == nil && claims.ID != "" {
+			if err := auth.GetSessionStore().Revoke(claims.ID); err == nil {
+				c.Set("username", claims.Username)
+				s.logger.Info("user logged out", zap.String("username", claims.Username), zap.String("session", claims.ID))
+			}
+		}
 		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"message": "登出成功"}))
 	}
 }
 
+// handleRefreshToken 使用刷新令牌换取新的访问令牌与刷新令牌（刷新令牌每次使用后轮换）
+func (s *Server) handleRefreshToken() gin.HandlerFunc {
+	type refreshRequest struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	return func(c *gin.Context) {
+		var req refreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "refreshToken is required"))
+			return
+		}
+		pair, err := auth.RefreshSession(req.RefreshToken, c.ClientIP())
+		if err != nil {
+			if errors.Is(err, auth.ErrRefreshReused) {
+				s.logger.Warn("refresh token reused, session revoked", zap.String("ip", c.ClientIP()))
+			}
+			c.JSON(http.StatusUnauthorized, NewErrorResCode(CodeUnauthorized, "会话已失效，请重新登录"))
+			return
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(pair))
+	}
+}
+
 // handleAuthStatus 检查身份验证状态
 func (s *Server) handleAuthStatus() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		oidcCfg := config.Get().OIDC
 		c.JSON(http.StatusOK, NewSuccessRes(gin.H{
-			"authEnabled": auth.IsAuthEnabled(),
+			"authEnabled":      auth.IsAuthEnabled(),
+			"oidcEnabled":      oidcCfg.Enabled,
+			"oidcButtonText":   oidcCfg.ButtonText,
+			"proxyAuthEnabled": config.Get().ProxyAuth.Enabled,
 		}))
 	}
 }
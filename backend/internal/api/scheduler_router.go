@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupSchedulerRoutes 设置调度器相关路由
+func (s *Server) setupSchedulerRoutes(rg *gin.RouterGroup) {
+	sch := rg.Group("/scheduler")
+	{
+		sch.GET("/jobs", s.handleListSchedulerJobs())
+	}
+}
+
+// handleListSchedulerJobs 获取定时任务及下次执行时间、维护窗口状态与排队中的更新
+func (s *Server) handleListSchedulerJobs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.scheduler == nil {
+			c.JSON(http.StatusOK, NewSuccessRes(gin.H{"jobs": []any{}, "queue": []any{}}))
+			return
+		}
+		windows := config.Get().Schedule.MaintenanceWindows
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{
+			"running":             s.scheduler.IsRunning(),
+			"jobs":                s.scheduler.GetTaskInfo(),
+			"queue":               s.scheduler.QueuedUpdates(),
+			"maintenanceWindows":  windows,
+			"inMaintenanceWindow": scheduler.InMaintenanceWindow(windows, time.Now()),
+		}))
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jianxcao/watch-docker/backend/internal/auth"
+	"github.com/jianxcao/watch-docker/backend/internal/users"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// setupSessionRoutes 设置登录会话管理路由：所有角色可以管理自己的会话，admin 可以管理全部会话
+func (s *Server) setupSessionRoutes(protected *gin.RouterGroup) {
+	sessions := protected.Group("/auth/sessions")
+	// API 令牌不能查看或吊销登录会话
+	sessions.Use(requireLoginSession())
+	{
+		sessions.GET("", s.handleListSessions())
+		sessions.DELETE("", s.handleRevokeSessions())
+		sessions.DELETE("/:id", s.handleRevokeSession())
+	}
+}
+
+// visibleSessions admin 传入 all=true 时返回全部会话，否则只返回当前用户的会话
+func visibleSessions(c *gin.Context) []auth.Session {
+	if c.GetString("role") == users.RoleAdmin && c.Query("all") == "true" {
+		return auth.GetSessionStore().List("")
+	}
+	return auth.GetSessionStore().List(c.GetString("username"))
+}
+
+// handleListSessions 返回登录会话的设备、IP、创建与最近活动时间，current 为当前请求所用的会话
+func (s *Server) handleListSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{
+			"sessions": visibleSessions(c),
+			"current":  c.GetString("sessionId"),
+		}))
+	}
+}
+
+// handleRevokeSession 吊销一个会话，其访问令牌与刷新令牌立即失效
+func (s *Server) handleRevokeSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		sess, ok := auth.GetSessionStore().Get(id)
+		if !ok || (sess.Username != c.GetString("username") && c.GetString("role") != users.RoleAdmin) {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "session not found"))
+			return
+		}
+		if err := auth.GetSessionStore().Revoke(id); err != nil {
+			if errors.Is(err, auth.ErrSessionNotFound) {
+				c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "session not found"))
+				return
+			}
+			s.logger.Error("revoke session", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "吊销会话失败"))
+			return
+		}
+		s.logger.Info("已吊销登录会话", zap.String("id", id), zap.String("username", sess.Username), zap.String("by", c.GetString("username")))
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"ok": true}))
+	}
+}
+
+// handleRevokeSessions 吊销当前用户除当前会话外的全部会话；admin 可以通过 username 参数吊销指定用户的全部会话
+func (s *Server) handleRevokeSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username, except := c.GetString("username"), c.GetString("sessionId")
+		if target := c.Query("username"); target != "" && target != username {
+			if c.GetString("role") != users.RoleAdmin {
+				c.JSON(http.StatusForbidden, NewErrorResCode(CodeUnauthorized, "当前角色无权执行此操作"))
+				return
+			}
+			username, except = target, ""
+		}
+		n, err := auth.GetSessionStore().RevokeUser(username, except)
+		if err != nil {
+			s.logger.Error("revoke sessions", zap.String("username", username), zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "吊销会话失败"))
+			return
+		}
+		s.logger.Info("已吊销用户的登录会话", zap.String("username", username), zap.Int("count", n), zap.String("by", c.GetString("username")))
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"revoked": n}))
+	}
+}
+
+// revokeUserSessions 吊销用户的全部登录会话（删除用户或重置密码后）
+func (s *Server) revokeUserSessions(username string) {
+	if n, err := auth.GetSessionStore().RevokeUser(username, ""); err != nil {
+		s.logger.Error("revoke user sessions", zap.String("username", username), zap.Error(err))
+	} else if n > 0 {
+		s.logger.Info("已吊销用户的登录会话", zap.String("username", username), zap.Int("count", n))
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/jianxcao/watch-docker/backend/internal/auth"
 	"github.com/jianxcao/watch-docker/backend/internal/conf"
 	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/recording"
 	"go.uber.org/zap"
 )
 
@@ -105,6 +106,10 @@ func (s *Server) handleShellWebSocket() gin.HandlerFunc {
 			Cols: 80,
 		})
 
+		// 录制会话（recording.enabled 为 false 时 rec 为 nil）
+		rec := s.startRecording(c, recording.Meta{Kind: recording.KindHost, Shell: shell}, 80, 24)
+		defer rec.Close()
+
 		// 启动心跳检测
 		go func() {
 			ticker := time.NewTicker(30 * time.Second)
@@ -143,6 +148,7 @@ func (s *Server) handleShellWebSocket() gin.HandlerFunc {
 					}
 
 					if n > 0 {
+						rec.Output(buf[:n])
 						conn.SetWriteDeadline(time.Now().Add(15 * time.Second))
 						if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
 							logger.Logger.Error("Failed to write message to WebSocket", zap.Error(err))
@@ -175,12 +181,14 @@ func (s *Server) handleShellWebSocket() gin.HandlerFunc {
 						logger.Logger.Error("Failed to write to PTY", zap.Error(err))
 						return
 					}
+					rec.Input(message)
 				case websocket.BinaryMessage:
 					// 处理二进制消息（也作为用户输入）
 					if _, err := ptmx.Write(message); err != nil {
 						logger.Logger.Error("Failed to write to PTY", zap.Error(err))
 						return
 					}
+					rec.Input(message)
 				}
 			}
 		}
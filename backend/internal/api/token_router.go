@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/auth"
+	"github.com/jianxcao/watch-docker/backend/internal/users"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// setupAPITokenRoutes 设置 API 令牌管理路由
+func (s *Server) setupAPITokenRoutes(protected *gin.RouterGroup) {
+	tokens := protected.Group("/api-tokens")
+	// API 令牌不能用来创建或吊销令牌，只允许登录会话管理
+	tokens.Use(requireLoginSession())
+	{
+		tokens.GET("", s.handleListAPITokens())
+		tokens.POST("", s.handleCreateAPIToken())
+		tokens.DELETE("/:id", s.handleRevokeAPIToken())
+	}
+}
+
+// requireLoginSession 拒绝使用 API 令牌的请求
+func requireLoginSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("apiToken"); ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, NewErrorResCode(CodeUnauthorized, "API 令牌不能管理令牌，请登录后操作"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// visibleAPITokens admin 可以看到全部令牌，其他角色只能看到自己的令牌
+func visibleAPITokens(c *gin.Context) []auth.APIToken {
+	all := auth.GetAPITokenStore().List()
+	if c.GetString("role") == users.RoleAdmin {
+		return all
+	}
+	username := c.GetString("username")
+	list := make([]auth.APIToken, 0, len(all))
+	for _, t := range all {
+		if t.Username == username {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+// handleListAPITokens 返回 API 令牌及其权限、最后使用时间与 IP（不含令牌明文）
+func (s *Server) handleListAPITokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{
+			"tokens": visibleAPITokens(c),
+			"scopes": auth.Scopes,
+		}))
+	}
+}
+
+// handleCreateAPIToken 创建 API 令牌，明文只在此返回一次
+func (s *Server) handleCreateAPIToken() gin.HandlerFunc {
+	type reqBody struct {
+		Name    string   `json:"name" binding:"required"`
+		Scopes  []string `json:"scopes"`
+		TTLDays int      `json:"ttlDays"` // 有效期（天），0 表示永不过期
+	}
+	return func(c *gin.Context) {
+		var body reqBody
+		if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.Name) == "" || body.TTLDays < 0 {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "invalid request body"))
+			return
+		}
+		username := c.GetString("username")
+		token, t, err := auth.GetAPITokenStore().Create(username, strings.TrimSpace(body.Name), body.Scopes, time.Duration(body.TTLDays)*24*time.Hour)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidScope) {
+				c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, err.Error()))
+				return
+			}
+			s.logger.Error("create api token", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "创建令牌失败"))
+			return
+		}
+		s.logger.Info("已创建 API 令牌", zap.String("id", t.ID), zap.String("name", t.Name), zap.String("username", username), zap.Strings("scopes", t.Scopes))
+		t.Hash = ""
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"token": token, "apiToken": t}))
+	}
+}
+
+// handleRevokeAPIToken 吊销 API 令牌，立即生效
+func (s *Server) handleRevokeAPIToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if !slices.ContainsFunc(visibleAPITokens(c), func(t auth.APIToken) bool { return t.ID == id }) {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "token not found"))
+			return
+		}
+		if err := auth.GetAPITokenStore().Revoke(id); err != nil {
+			if errors.Is(err, auth.ErrTokenNotFound) {
+				c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "token not found"))
+				return
+			}
+			s.logger.Error("revoke api token", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "吊销令牌失败"))
+			return
+		}
+		s.logger.Info("已吊销 API 令牌", zap.String("id", id))
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"ok": true}))
+	}
+}
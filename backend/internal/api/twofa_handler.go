@@ -201,7 +201,7 @@ func (s *Server) handleOTPSetupVerify() gin.HandlerFunc {
 		}
 
 		tempToken := authHeader[7:] // 去掉 "Bearer "
-		fullToken, err := auth.UpgradeTempToken(tempToken)
+		pair, err := auth.UpgradeTempToken(tempToken, c.Request.UserAgent(), c.ClientIP())
 		if err != nil {
 			s.logger.Error("upgrade temp token failed", zap.Error(err))
 			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "升级token失败"))
@@ -209,9 +209,7 @@ func (s *Server) handleOTPSetupVerify() gin.HandlerFunc {
 		}
 
 		s.logger.Info("user setup otp successfully", zap.String("username", username.(string)))
-		c.JSON(http.StatusOK, NewSuccessRes(gin.H{
-			"token": fullToken,
-		}))
+		c.JSON(http.StatusOK, NewSuccessRes(pair))
 	}
 }
 
@@ -268,7 +266,7 @@ func (s *Server) handleVerifyOTP() gin.HandlerFunc {
 		}
 
 		tempToken := authHeader[7:] // 去掉 "Bearer "
-		fullToken, err := auth.UpgradeTempToken(tempToken)
+		pair, err := auth.UpgradeTempToken(tempToken, c.Request.UserAgent(), c.ClientIP())
 		if err != nil {
 			s.logger.Error("upgrade temp token failed", zap.Error(err))
 			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "升级token失败"))
@@ -276,9 +274,7 @@ func (s *Server) handleVerifyOTP() gin.HandlerFunc {
 		}
 
 		s.logger.Info("user verify otp successfully", zap.String("username", username.(string)))
-		c.JSON(http.StatusOK, NewSuccessRes(gin.H{
-			"token": fullToken,
-		}))
+		c.JSON(http.StatusOK, NewSuccessRes(pair))
 	}
 }
 
@@ -436,7 +432,7 @@ func (s *Server) handleWebAuthnRegisterFinish() gin.HandlerFunc {
 		}
 
 		tempToken := authHeader[7:] // 去掉 "Bearer "
-		fullToken, err := auth.UpgradeTempToken(tempToken)
+		pair, err := auth.UpgradeTempToken(tempToken, c.Request.UserAgent(), c.ClientIP())
 		if err != nil {
 			s.logger.Error("upgrade temp token failed", zap.Error(err))
 			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "升级token失败"))
@@ -444,9 +440,7 @@ func (s *Server) handleWebAuthnRegisterFinish() gin.HandlerFunc {
 		}
 
 		s.logger.Info("user setup webauthn successfully", zap.String("username", username.(string)))
-		c.JSON(http.StatusOK, NewSuccessRes(gin.H{
-			"token": fullToken,
-		}))
+		c.JSON(http.StatusOK, NewSuccessRes(pair))
 	}
 }
 
@@ -605,7 +599,7 @@ func (s *Server) handleWebAuthnLoginFinish() gin.HandlerFunc {
 		}
 
 		tempToken := authHeader[7:] // 去掉 "Bearer "
-		fullToken, err := auth.UpgradeTempToken(tempToken)
+		pair, err := auth.UpgradeTempToken(tempToken, c.Request.UserAgent(), c.ClientIP())
 		if err != nil {
 			s.logger.Error("upgrade temp token failed", zap.Error(err))
 			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "升级token失败"))
@@ -613,9 +607,7 @@ func (s *Server) handleWebAuthnLoginFinish() gin.HandlerFunc {
 		}
 
 		s.logger.Info("user verify webauthn successfully", zap.String("username", username.(string)))
-		c.JSON(http.StatusOK, NewSuccessRes(gin.H{
-			"token": fullToken,
-		}))
+		c.JSON(http.StatusOK, NewSuccessRes(pair))
 	}
 }
 
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// setupUpdateRoutes 设置更新计划相关路由
+func (s *Server) setupUpdateRoutes(rg *gin.RouterGroup) {
+	updates := rg.Group("/updates")
+	{
+		updates.POST("/plan", s.handleUpdatePlan())
+	}
+}
+
+// handleUpdatePlan 以计划模式执行一次扫描更新，返回每个容器的决策、更新顺序与待清理资源，不修改任何容器。
+// 请求体可选：{"notify": true} 同时将计划作为通知发送。
+func (s *Server) handleUpdatePlan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Notify bool `json:"notify"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "invalid request body"))
+				return
+			}
+		}
+		if s.scheduler == nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "scheduler not available"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
+		defer cancel()
+		plan, err := s.scheduler.PlanScanAndUpdate(ctx)
+		if err != nil {
+			s.logger.Error("生成更新计划失败", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeScanFailed, err.Error()))
+			return
+		}
+
+		if req.Notify && s.notificationManager != nil {
+			if err := s.notificationManager.NotifyUpdatePlan(ctx, plan); err != nil {
+				s.logger.Error("发送更新计划通知失败", zap.Error(err))
+			}
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(plan))
+	}
+}
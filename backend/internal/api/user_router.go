@@ -0,0 +1,193 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jianxcao/watch-docker/backend/internal/auth"
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/users"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// setupUserRoutes 设置用户管理路由（仅 admin）与个人信息路由
+func (s *Server) setupUserRoutes(protected *gin.RouterGroup) {
+	protected.GET("/auth/me", s.handleCurrentUser())
+	protected.POST("/auth/password", requireLoginSession(), s.handleChangePassword())
+
+	u := protected.Group("/users")
+	{
+		u.GET("", s.handleListUsers())
+		u.POST("", s.handleCreateUser())
+		u.PUT("/:username", s.handleUpdateUser())
+		u.DELETE("/:username", s.handleDeleteUser())
+	}
+}
+
+// userErrorMessage 把用户存储的校验错误转换为响应消息
+func userErrorMessage(err error) (string, bool) {
+	switch {
+	case errors.Is(err, users.ErrUserExists),
+		errors.Is(err, users.ErrUserNotFound),
+		errors.Is(err, users.ErrInvalidRole),
+		errors.Is(err, users.ErrInvalidUsername),
+		errors.Is(err, users.ErrWeakPassword),
+		errors.Is(err, users.ErrBuiltinUser),
+		errors.Is(err, users.ErrLastAdmin):
+		return err.Error(), true
+	}
+	return "", false
+}
+
+// handleCurrentUser 返回当前用户与角色
+func (s *Server) handleCurrentUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.GetString("username")
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{
+			"username": username,
+			"role":     c.GetString("role"),
+			"builtin":  username != "" && username == conf.EnvCfg.USER_NAME,
+		}))
+	}
+}
+
+// handleChangePassword 修改当前用户的密码，内置管理员的密码在 app.yaml 或环境变量中修改
+func (s *Server) handleChangePassword() gin.HandlerFunc {
+	type reqBody struct {
+		OldPassword string `json:"oldPassword" binding:"required"`
+		NewPassword string `json:"newPassword" binding:"required"`
+	}
+	return func(c *gin.Context) {
+		var body reqBody
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "invalid request body"))
+			return
+		}
+		username := c.GetString("username")
+		if _, ok := users.GetStore().Get(username); !ok {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "内置管理员的密码请在 app.yaml 或环境变量中修改"))
+			return
+		}
+		if _, ok := users.GetStore().Authenticate(username, body.OldPassword); !ok {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "原密码错误"))
+			return
+		}
+		if _, err := users.GetStore().Update(username, body.NewPassword, ""); err != nil {
+			if msg, ok := userErrorMessage(err); ok {
+				c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, msg))
+				return
+			}
+			s.logger.Error("change password", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "修改密码失败"))
+			return
+		}
+		// 密码修改后旧 token 已失效，同时清理该用户的会话
+		s.revokeUserSessions(username)
+		s.logger.Info("用户已修改密码", zap.String("username", username))
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"ok": true}))
+	}
+}
+
+// handleListUsers 返回全部用户（内置管理员在前）
+func (s *Server) handleListUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list := make([]gin.H, 0)
+		if conf.EnvCfg.USER_NAME != "" && conf.EnvCfg.USER_PASSWORD != "" {
+			list = append(list, gin.H{"username": conf.EnvCfg.USER_NAME, "role": users.RoleAdmin, "builtin": true})
+		}
+		for _, u := range users.GetStore().List() {
+			list = append(list, gin.H{"username": u.Username, "role": u.Role, "createdAt": u.CreatedAt, "updatedAt": u.UpdatedAt})
+		}
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"users": list, "roles": users.Roles}))
+	}
+}
+
+// handleCreateUser 创建用户
+func (s *Server) handleCreateUser() gin.HandlerFunc {
+	type reqBody struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Role     string `json:"role" binding:"required"`
+	}
+	return func(c *gin.Context) {
+		var body reqBody
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "invalid request body"))
+			return
+		}
+		u, err := users.GetStore().Create(strings.TrimSpace(body.Username), body.Password, body.Role)
+		if err != nil {
+			if msg, ok := userErrorMessage(err); ok {
+				c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, msg))
+				return
+			}
+			s.logger.Error("create user", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "创建用户失败"))
+			return
+		}
+		s.logger.Info("已创建用户", zap.String("username", u.Username), zap.String("role", u.Role), zap.String("by", c.GetString("username")))
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"user": u}))
+	}
+}
+
+// handleUpdateUser 修改用户的角色或重置密码，字段为空表示不修改
+func (s *Server) handleUpdateUser() gin.HandlerFunc {
+	type reqBody struct {
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}
+	return func(c *gin.Context) {
+		var body reqBody
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "invalid request body"))
+			return
+		}
+		username := c.Param("username")
+		u, err := users.GetStore().Update(username, body.Password, body.Role)
+		if err != nil {
+			if msg, ok := userErrorMessage(err); ok {
+				c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, msg))
+				return
+			}
+			s.logger.Error("update user", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "修改用户失败"))
+			return
+		}
+		if body.Password != "" {
+			s.revokeUserSessions(username)
+		}
+		s.logger.Info("已修改用户", zap.String("username", username), zap.String("role", u.Role), zap.Bool("passwordReset", body.Password != ""), zap.String("by", c.GetString("username")))
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"user": u}))
+	}
+}
+
+// handleDeleteUser 删除用户并吊销其 API 令牌与登录会话，不能删除当前登录的用户
+func (s *Server) handleDeleteUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.Param("username")
+		if username == c.GetString("username") {
+			c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, "不能删除当前登录的用户"))
+			return
+		}
+		if err := users.GetStore().Delete(username); err != nil {
+			if msg, ok := userErrorMessage(err); ok {
+				c.JSON(http.StatusOK, NewErrorResCode(CodeBadRequest, msg))
+				return
+			}
+			s.logger.Error("delete user", zap.Error(err))
+			c.JSON(http.StatusOK, NewErrorResCode(CodeInternalError, "删除用户失败"))
+			return
+		}
+		if n, err := auth.GetAPITokenStore().RevokeUser(username); err != nil {
+			s.logger.Error("revoke user api tokens", zap.String("username", username), zap.Error(err))
+		} else if n > 0 {
+			s.logger.Info("已吊销用户的 API 令牌", zap.String("username", username), zap.Int("count", n))
+		}
+		s.revokeUserSessions(username)
+		s.logger.Info("已删除用户", zap.String("username", username), zap.String("by", c.GetString("username")))
+		c.JSON(http.StatusOK, NewSuccessRes(gin.H{"ok": true}))
+	}
+}
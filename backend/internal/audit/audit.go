@@ -0,0 +1,244 @@
+// Package audit 记录修改操作的审计日志。
+// 每条记录为一行 JSON，只追加写入 CONFIG_PATH/audit/audit.jsonl；
+// 文件超过 audit.maxSizeMB 时轮转为 audit-<时间>.jsonl，保留 audit.maxBackups 个历史文件。
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+)
+
+// 操作结果
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultDenied  = "denied" // 未认证或无权限
+)
+
+const currentFile = "audit.jsonl"
+
+// Entry 一条审计记录
+type Entry struct {
+	Time       time.Time      `json:"time"`
+	User       string         `json:"user,omitempty"`
+	Role       string         `json:"role,omitempty"`
+	Via        string         `json:"via,omitempty"` // 认证方式：api-token 时为令牌名称
+	IP         string         `json:"ip"`
+	Endpoint   string         `json:"endpoint,omitempty"`
+	Method     string         `json:"method"`
+	Action     string         `json:"action"` // 路由模板，如 /containers/:id/stop
+	Target     string         `json:"target,omitempty"`
+	Params     map[string]any `json:"params,omitempty"`
+	Status     int            `json:"status"`
+	Result     string         `json:"result"`
+	Error      string         `json:"error,omitempty"`
+	DurationMs int64          `json:"durationMs"`
+}
+
+// Filter 查询条件，字段为空表示不限制
+type Filter struct {
+	User     string
+	Action   string // 子串匹配
+	Target   string // 子串匹配
+	Endpoint string
+	Result   string
+	Since    time.Time
+	Until    time.Time
+	Limit    int // Query 最多返回的条数（最新的），0 表示不限制；导出时忽略
+}
+
+// Match 记录是否满足查询条件
+func (f Filter) Match(e *Entry) bool {
+	switch {
+	case f.User != "" && e.User != f.User,
+		f.Endpoint != "" && e.Endpoint != f.Endpoint,
+		f.Result != "" && e.Result != f.Result,
+		f.Action != "" && !strings.Contains(e.Action, f.Action),
+		f.Target != "" && !strings.Contains(e.Target, f.Target),
+		!f.Since.IsZero() && e.Time.Before(f.Since),
+		!f.Until.IsZero() && e.Time.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// Log 审计日志
+type Log struct {
+	dir  string
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// New 创建审计日志，目录不存在时在首次写入时创建
+func New(dir string) *Log {
+	return &Log{dir: dir}
+}
+
+// Record 追加一条记录；audit.enabled 为 false 时忽略
+func (l *Log) Record(e Entry) error {
+	cfg := config.Get().Audit
+	if !cfg.Enabled {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.openLocked(); err != nil {
+		return err
+	}
+	if max := int64(cfg.MaxSizeMB) << 20; max > 0 && l.size > 0 && l.size+int64(len(line)) > max {
+		if err := l.rotateLocked(cfg.MaxBackups); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write audit entry: %w", err)
+	}
+	return nil
+}
+
+func (l *Log) openLocked() error {
+	if l.file != nil {
+		return nil
+	}
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		return fmt.Errorf("create audit dir: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(l.dir, currentFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat audit log: %w", err)
+	}
+	l.file, l.size = f, st.Size()
+	return nil
+}
+
+// rotateLocked 把当前文件重命名为带时间的历史文件，并删除超出数量的旧文件
+func (l *Log) rotateLocked(maxBackups int) error {
+	l.file.Close()
+	l.file = nil
+	name := "audit-" + time.Now().Format("20060102-150405.000") + ".jsonl"
+	if err := os.Rename(filepath.Join(l.dir, currentFile), filepath.Join(l.dir, name)); err != nil {
+		return fmt.Errorf("rotate audit log: %w", err)
+	}
+	backups := l.backups()
+	for len(backups) > maxBackups && len(backups) > 0 {
+		_ = os.Remove(backups[0])
+		backups = backups[1:]
+	}
+	return l.openLocked()
+}
+
+// backups 返回历史文件，按时间从旧到新排列
+func (l *Log) backups() []string {
+	files, _ := filepath.Glob(filepath.Join(l.dir, "audit-*.jsonl"))
+	sort.Strings(files)
+	return files
+}
+
+// files 返回全部日志文件，按时间从旧到新排列
+func (l *Log) files() []string {
+	return append(l.backups(), filepath.Join(l.dir, currentFile))
+}
+
+// Query 按条件查询记录，返回最新的 Limit 条，按时间倒序排列
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	var out []Entry
+	err := l.scan(f, func(e *Entry) error {
+		out = append(out, *e)
+		if f.Limit > 0 && len(out) > f.Limit {
+			out = out[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+// Export 按条件把记录以 JSONL 格式写入 w，按时间正序排列
+func (l *Log) Export(w io.Writer, f Filter) error {
+	enc := json.NewEncoder(w)
+	return l.scan(f, func(e *Entry) error { return enc.Encode(e) })
+}
+
+// scan 按时间正序遍历满足条件的记录，跳过无法解析的行
+func (l *Log) scan(f Filter, fn func(*Entry) error) error {
+	for _, name := range l.files() {
+		file, err := os.Open(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("open audit log: %w", err)
+		}
+		err = scanFile(file, f, fn)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanFile(r io.Reader, f Filter, fn func(*Entry) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var e Entry
+			if json.Unmarshal(line, &e) == nil && f.Match(&e) {
+				if err := fn(&e); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read audit log: %w", err)
+		}
+	}
+}
+
+// Close 关闭日志文件
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+)
+
+func setAuditConfig(t *testing.T, c config.AuditConfig) {
+	t.Helper()
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	old := config.Get()
+	t.Cleanup(func() { config.SetGlobal(old) })
+	cfg := *old
+	cfg.Audit = c
+	config.SetGlobal(&cfg)
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	setAuditConfig(t, config.AuditConfig{Enabled: true})
+	l := New(filepath.Join(t.TempDir(), "audit"))
+	defer l.Close()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		user := "alice"
+		if i%2 == 1 {
+			user = "bob"
+		}
+		if err := l.Record(Entry{Time: base.Add(time.Duration(i) * time.Minute), User: user, Method: "POST", Action: "/containers/:id/stop", Target: fmt.Sprintf("web-%d", i), Result: ResultSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := l.Query(Filter{})
+	if err != nil || len(all) != 5 || all[0].Target != "web-4" {
+		t.Fatalf("query all = %d entries, first %+v, err %v", len(all), all[0], err)
+	}
+	bob, _ := l.Query(Filter{User: "bob"})
+	if len(bob) != 2 {
+		t.Fatalf("bob entries = %d", len(bob))
+	}
+	latest, _ := l.Query(Filter{Limit: 2})
+	if len(latest) != 2 || latest[0].Target != "web-4" || latest[1].Target != "web-3" {
+		t.Fatalf("limit = %+v", latest)
+	}
+	ranged, _ := l.Query(Filter{Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute), Target: "web"})
+	if len(ranged) != 3 {
+		t.Fatalf("time range entries = %d", len(ranged))
+	}
+
+	var buf bytes.Buffer
+	if err := l.Export(&buf, Filter{User: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	sc := bufio.NewScanner(&buf)
+	n := 0
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil || e.User != "alice" {
+			t.Fatalf("export line %q: %v", sc.Text(), err)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Fatalf("exported %d lines", n)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	setAuditConfig(t, config.AuditConfig{Enabled: false})
+	dir := filepath.Join(t.TempDir(), "audit")
+	l := New(dir)
+	if err := l.Record(Entry{Action: "/config"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatal("disabled audit log wrote files")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	setAuditConfig(t, config.AuditConfig{Enabled: true, MaxSizeMB: 1, MaxBackups: 1})
+	dir := filepath.Join(t.TempDir(), "audit")
+	l := New(dir)
+	defer l.Close()
+
+	big := Entry{Action: "/containers/:id/files/content", Params: map[string]any{"pad": strings.Repeat("a", 200<<10)}}
+	for i := 0; i < 12; i++ {
+		big.Target = fmt.Sprint(i)
+		if err := l.Record(big); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "audit-*.jsonl"))
+	if len(backups) != 1 {
+		t.Fatalf("backups = %v", backups)
+	}
+	entries, _ := l.Query(Filter{})
+	if len(entries) == 0 || len(entries) >= 12 || entries[0].Target != "11" {
+		t.Fatalf("entries after rotation = %d", len(entries))
+	}
+}
@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+)
+
+// APITokenPrefix API 令牌前缀，用于与登录 JWT 区分
+const APITokenPrefix = "wd_"
+
+// 令牌权限范围
+const (
+	ScopeRead      = "read"      // 只读：查看容器、镜像、日志、统计等
+	ScopeUpdate    = "update"    // 触发镜像更新与回滚
+	ScopeContainer = "container" // 容器、镜像、卷、网络与 compose 的启停、创建与删除
+	ScopeFiles     = "files"     // 容器文件浏览、读写与下载
+	ScopeShell     = "shell"     // 主机与容器终端
+	ScopeAdmin     = "admin"     // 全部权限，包括配置、通知与 agent 管理
+)
+
+// Scopes 全部可用的权限范围
+var Scopes = []string{ScopeRead, ScopeUpdate, ScopeContainer, ScopeFiles, ScopeShell, ScopeAdmin}
+
+var (
+	ErrInvalidScope  = errors.New("invalid scope")
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// APIToken 持久化的 API 令牌，只保存 SHA256 哈希
+type APIToken struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Username   string    `json:"username"` // 创建者，请求以该用户身份执行
+	Hint       string    `json:"hint"`     // 令牌末尾几位，便于识别
+	Hash       string    `json:"hash"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt,omitempty"` // 零值表示永不过期
+	LastUsedAt time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIP string    `json:"lastUsedIp,omitempty"`
+}
+
+// Expired 令牌是否已过期
+func (t *APIToken) Expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
+// HasScope 令牌是否具有指定权限，admin 包含全部权限
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, ScopeAdmin) || slices.Contains(t.Scopes, scope)
+}
+
+// lastUsedFlushInterval 最后使用时间写盘的最小间隔，避免每个请求都写文件
+const lastUsedFlushInterval = time.Minute
+
+// APITokenStore API 令牌存储
+type APITokenStore struct {
+	path      string
+	mu        sync.Mutex
+	tokens    []*APIToken
+	flushedAt time.Time
+}
+
+var (
+	apiTokenStore     *APITokenStore
+	apiTokenStoreOnce sync.Once
+)
+
+// GetAPITokenStore 获取 API 令牌存储实例，数据保存在 CONFIG_PATH/api-tokens.json
+func GetAPITokenStore() *APITokenStore {
+	apiTokenStoreOnce.Do(func() {
+		apiTokenStore = NewAPITokenStore(filepath.Join(conf.EnvCfg.CONFIG_PATH, "api-tokens.json"))
+	})
+	return apiTokenStore
+}
+
+// NewAPITokenStore 从文件加载 API 令牌，文件不存在时为空
+func NewAPITokenStore(path string) *APITokenStore {
+	s := &APITokenStore{path: path}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &s.tokens)
+	}
+	return s
+}
+
+// ValidateScopes 校验并去重权限范围
+func ValidateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	out := make([]string, 0, len(scopes))
+	for _, sc := range scopes {
+		if !slices.Contains(Scopes, sc) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, sc)
+		}
+		if !slices.Contains(out, sc) {
+			out = append(out, sc)
+		}
+	}
+	return out, nil
+}
+
+// Create 创建令牌，明文只在此返回一次；ttl 为 0 表示永不过期
+func (s *APITokenStore) Create(username, name string, scopes []string, ttl time.Duration) (string, *APIToken, error) {
+	scopes, err := ValidateScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("generate token: %w", err)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, fmt.Errorf("generate token id: %w", err)
+	}
+	plain := APITokenPrefix + hex.EncodeToString(buf)
+	now := time.Now()
+	t := &APIToken{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Username:  username,
+		Hint:      plain[len(plain)-4:],
+		Hash:      hashAPIToken(plain),
+		Scopes:    scopes,
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		t.ExpiresAt = now.Add(ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = append(s.tokens, t)
+	if err := s.saveLocked(); err != nil {
+		s.tokens = s.tokens[:len(s.tokens)-1]
+		return "", nil, err
+	}
+	c := *t
+	return plain, &c, nil
+}
+
+// Authenticate 校验令牌并记录最后使用时间与 IP，返回令牌副本
+func (s *APITokenStore) Authenticate(token, ip string) (*APIToken, bool) {
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		return nil, false
+	}
+	// 按哈希查找，比较的是哈希值，不会泄露令牌内容
+	hash := hashAPIToken(token)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tokens {
+		if t.Hash != hash {
+			continue
+		}
+		if t.Expired() {
+			return nil, false
+		}
+		now := time.Now()
+		t.LastUsedAt = now
+		t.LastUsedIP = ip
+		if now.Sub(s.flushedAt) >= lastUsedFlushInterval {
+			_ = s.saveLocked()
+		}
+		c := *t
+		return &c, true
+	}
+	return nil, false
+}
+
+// List 返回全部令牌（不含哈希）
+func (s *APITokenStore) List() []APIToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]APIToken, 0, len(s.tokens))
+	for _, t := range s.tokens {
+		c := *t
+		c.Hash = ""
+		list = append(list, c)
+	}
+	return list
+}
+
+// Revoke 吊销令牌
+func (s *APITokenStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, t := range s.tokens {
+		if t.ID == id {
+			s.tokens = append(s.tokens[:i:i], s.tokens[i+1:]...)
+			return s.saveLocked()
+		}
+	}
+	return ErrTokenNotFound
+}
+
+// RevokeUser 吊销用户的全部令牌，返回吊销数量
+func (s *APITokenStore) RevokeUser(username string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.tokens[:0:0]
+	for _, t := range s.tokens {
+		if t.Username != username {
+			kept = append(kept, t)
+		}
+	}
+	n := len(s.tokens) - len(kept)
+	if n == 0 {
+		return 0, nil
+	}
+	s.tokens = kept
+	return n, s.saveLocked()
+}
+
+// Flush 保存尚未写盘的最后使用时间
+func (s *APITokenStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked()
+}
+
+func (s *APITokenStore) saveLocked() error {
+	data, err := json.MarshalIndent(s.tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal api tokens: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("create token dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write api tokens: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("rename api tokens: %w", err)
+	}
+	s.flushedAt = time.Now()
+	return nil
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+)
+
+func TestAPITokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-tokens.json")
+	s := NewAPITokenStore(path)
+
+	if _, _, err := s.Create("admin", "ci", []string{"root"}, 0); err == nil {
+		t.Fatal("unknown scope accepted")
+	}
+	plain, tok, err := s.Create("admin", "ci", []string{ScopeRead, ScopeUpdate, ScopeRead}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(plain, APITokenPrefix) || len(tok.Scopes) != 2 {
+		t.Fatalf("token = %q, scopes = %v", plain, tok.Scopes)
+	}
+
+	// 重新加载后仍然有效，且文件中不含明文
+	s = NewAPITokenStore(path)
+	got, ok := s.Authenticate(plain, "10.0.0.1")
+	if !ok || got.Username != "admin" || got.LastUsedIP != "10.0.0.1" {
+		t.Fatalf("authenticate = %+v, %v", got, ok)
+	}
+	if !got.HasScope(ScopeUpdate) || got.HasScope(ScopeShell) {
+		t.Fatalf("scopes = %v", got.Scopes)
+	}
+	for _, l := range s.List() {
+		if l.Hash != "" {
+			t.Fatal("list exposes hash")
+		}
+	}
+	if _, ok := s.Authenticate(plain+"x", ""); ok {
+		t.Fatal("wrong token accepted")
+	}
+
+	if err := s.Revoke(tok.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Authenticate(plain, ""); ok {
+		t.Fatal("revoked token accepted")
+	}
+
+	expired, _, _ := s.Create("admin", "old", []string{ScopeRead}, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, ok := s.Authenticate(expired, ""); ok {
+		t.Fatal("expired token accepted")
+	}
+}
+
+func TestAuthMiddlewareAPIToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := *conf.EnvCfg
+	t.Cleanup(func() { *conf.EnvCfg = old })
+	conf.EnvCfg.USER_NAME = "admin"
+	conf.EnvCfg.USER_PASSWORD = "secret"
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+
+	plain, _, err := GetAPITokenStore().Create("admin", "test", []string{ScopeRead}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	g := r.Group("/api/v1", AuthMiddleware())
+	g.GET("/containers", func(c *gin.Context) { c.String(http.StatusOK, c.GetString("username")) })
+	g.POST("/containers/:id/stop", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := do("GET", "/api/v1/containers", plain); w.Code != http.StatusOK || w.Body.String() != "admin" {
+		t.Fatalf("read: %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/api/v1/containers/abc/stop", plain); w.Code != http.StatusForbidden {
+		t.Fatalf("stop without container scope: %d", w.Code)
+	}
+	if w := do("GET", "/api/v1/containers", APITokenPrefix+"bogus"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("bogus token: %d", w.Code)
+	}
+}
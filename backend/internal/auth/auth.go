@@ -17,6 +17,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/users"
 )
 
 var (
@@ -24,6 +26,11 @@ var (
 	jwtSecretOnce   sync.Once
 	ErrInvalidToken = errors.New("invalid token")
 	ErrTokenExpired = errors.New("token expired")
+	ErrUnknownUser  = errors.New("unknown user")
+	// ErrSessionRevoked token 对应的会话已吊销或过期
+	ErrSessionRevoked = errors.New("session revoked")
+	// ErrPasswordChanged token 签发后用户密码已修改
+	ErrPasswordChanged = errors.New("password changed")
 )
 
 func initJWTSecret() {
@@ -67,33 +74,146 @@ func hashPassword(password string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// GenerateToken 生成JWT token
-func GenerateToken(username string) (string, error) {
+// isBuiltinAdmin 是否为 USER_NAME/USER_PASSWORD 配置的内置管理员
+func isBuiltinAdmin(username string) bool {
 	envCfg := conf.EnvCfg
-	expirationTime := time.Now().Add(30 * 24 * time.Hour)
+	return envCfg.USER_NAME != "" && envCfg.USER_PASSWORD != "" && username == envCfg.USER_NAME
+}
+
+// lookupUser 返回用户的角色与密码指纹；密码修改后指纹随之变化，旧 token 失效
+func lookupUser(username string) (role, fingerprint string, ok bool) {
+	if isBuiltinAdmin(username) {
+		return users.RoleAdmin, hashPassword(conf.EnvCfg.USER_PASSWORD), true
+	}
+	u, ok := users.GetStore().Get(username)
+	if !ok {
+		return "", "", false
+	}
+	return u.Role, hashPassword(u.PasswordHash), true
+}
+
+// UserRole 返回用户的角色
+func UserRole(username string) (string, bool) {
+	role, _, ok := lookupUser(username)
+	return role, ok
+}
+
+// TokenPair 登录后发放的访问令牌与刷新令牌
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"` // 访问令牌有效期（秒）
+	SessionID    string `json:"sessionId"`
+}
+
+// accessTokenTTL 访问令牌有效期
+func accessTokenTTL() time.Duration {
+	return time.Duration(config.Get().Session.AccessTokenMinutes) * time.Minute
+}
+
+// refreshTokenTTL 刷新令牌（会话）有效期
+func refreshTokenTTL() time.Duration {
+	return time.Duration(config.Get().Session.RefreshTokenDays) * 24 * time.Hour
+}
+
+// NewSession 创建登录会话并发放令牌，device 为 User-Agent
+func NewSession(username, device, ip string) (*TokenPair, error) {
+	_, fingerprint, ok := lookupUser(username)
+	if !ok {
+		return nil, ErrUnknownUser
+	}
+	sess, refresh, err := GetSessionStore().Create(username, fingerprint, device, ip, refreshTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	return issueTokenPair(sess.ID, username, fingerprint, refresh)
+}
+
+// RefreshSession 使用刷新令牌换取新的令牌对，刷新令牌随之轮换；
+// 密码修改后刷新令牌失效，会话被吊销
+func RefreshSession(refreshToken, ip string) (*TokenPair, error) {
+	id, _, _ := strings.Cut(strings.TrimPrefix(refreshToken, RefreshTokenPrefix), ".")
+	sess, ok := GetSessionStore().Get(id)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	_, fingerprint, ok := lookupUser(sess.Username)
+	if !ok {
+		_ = GetSessionStore().Revoke(id)
+		return nil, ErrUnknownUser
+	}
+	sess, refresh, err := GetSessionStore().Refresh(refreshToken, fingerprint, ip, refreshTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	return issueTokenPair(sess.ID, sess.Username, fingerprint, refresh)
+}
+
+// issueTokenPair 签发会话的访问令牌，jti 为会话 ID
+func issueTokenPair(sessionID, username, fingerprint, refresh string) (*TokenPair, error) {
+	ttl := accessTokenTTL()
+	now := time.Now()
 	claims := &Claims{
 		Username:      username,
-		PasswordHash:  hashPassword(envCfg.USER_PASSWORD),
+		PasswordHash:  fingerprint,
 		TwoFAVerified: true,
 		IsTempToken:   false,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        sessionID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(getJWTSecret())
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(getJWTSecret())
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{Token: token, RefreshToken: refresh, ExpiresIn: int(ttl.Seconds()), SessionID: sessionID}, nil
+}
+
+// ValidateSessionToken 验证访问令牌及其会话：拒绝临时 token、没有 jti 的旧 token、密码修改前签发的 token
+// 与已吊销会话的 token，并记录会话的最近活动时间与 IP
+func ValidateSessionToken(tokenString, ip string) (*Claims, error) {
+	claims, err := ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IsTempToken || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+	if err := checkFingerprint(claims); err != nil {
+		return nil, err
+	}
+	if !GetSessionStore().Touch(claims.ID, claims.Username, ip) {
+		return nil, ErrSessionRevoked
+	}
+	return claims, nil
+}
+
+// checkFingerprint 检查 token 中的用户是否存在且签发后未修改密码
+func checkFingerprint(claims *Claims) error {
+	_, fingerprint, ok := lookupUser(claims.Username)
+	if !ok {
+		return ErrUnknownUser
+	}
+	if claims.PasswordHash != fingerprint {
+		return ErrPasswordChanged
+	}
+	return nil
 }
 
 // GenerateTempToken 生成临时 token（需要二次验证）
 func GenerateTempToken(username string) (string, error) {
-	envCfg := conf.EnvCfg
+	_, fingerprint, ok := lookupUser(username)
+	if !ok {
+		return "", ErrUnknownUser
+	}
 	// 临时 token 有效期较短，15分钟
 	expirationTime := time.Now().Add(15 * time.Minute)
 	claims := &Claims{
 		Username:      username,
-		PasswordHash:  hashPassword(envCfg.USER_PASSWORD), // 存储密码哈希
+		PasswordHash:  fingerprint, // 存储密码指纹
 		TwoFAVerified: false,
 		IsTempToken:   true,
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -106,19 +226,23 @@ func GenerateTempToken(username string) (string, error) {
 	return token.SignedString(getJWTSecret())
 }
 
-// UpgradeTempToken 将临时 token 升级为完整 token
-func UpgradeTempToken(tempToken string) (string, error) {
+// UpgradeTempToken 二次验证通过后将临时 token 升级为登录会话
+func UpgradeTempToken(tempToken, device, ip string) (*TokenPair, error) {
 	claims, err := ValidateToken(tempToken)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if !claims.IsTempToken {
-		return "", errors.New("not a temp token")
+		return nil, errors.New("not a temp token")
+	}
+	// 临时 token 签发后修改了密码，需要重新登录
+	if err := checkFingerprint(claims); err != nil {
+		return nil, err
 	}
 
-	// 生成完整 token
-	return GenerateToken(claims.Username)
+	// 创建会话并生成完整 token
+	return NewSession(claims.Username, device, ip)
 }
 
 // ValidateTempToken 验证临时 token
@@ -162,9 +286,9 @@ func ValidateToken(tokenString string) (*Claims, error) {
 // AuthMiddleware 授权中间件
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 检查是否配置了用户名和密码，如果没有配置则跳过验证
-		envCfg := conf.EnvCfg
-		if envCfg.USER_NAME == "" || envCfg.USER_PASSWORD == "" {
+		// 未配置任何账号时跳过验证
+		if !IsAuthEnabled() {
+			c.Set("role", users.RoleAdmin)
 			c.Next()
 			return
 		}
@@ -173,6 +297,11 @@ func AuthMiddleware() gin.HandlerFunc {
 			// 获取 Authorization header
 			authHeader := c.GetHeader("Authorization")
 			if authHeader == "" {
+				// 没有 token 时使用可信反向代理转发的身份
+				if username, ok := ProxyUser(c); ok {
+					proxyHeaderAuth(c, username)
+					return
+				}
 				c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "需要登录"})
 				c.Abort()
 				return
@@ -192,6 +321,11 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		// API 令牌（wd_ 前缀）
+		if strings.HasPrefix(token, APITokenPrefix) {
+			apiTokenAuth(c, token)
+			return
+		}
 		// 验证 token
 		claims, err := ValidateToken(token)
 		if err != nil {
@@ -211,47 +345,114 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 验证 token 中的用户名和密码是否与配置的用户名密码匹配
-		if claims.Username != envCfg.USER_NAME {
+		// 检查会话是否已吊销
+		if claims.ID == "" || !GetSessionStore().Touch(claims.ID, claims.Username, c.ClientIP()) {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "会话已失效，请重新登录"})
+			c.Abort()
+			return
+		}
+
+		// 验证 token 中的用户是否存在
+		role, fingerprint, ok := lookupUser(claims.Username)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "无效的token"})
 			c.Abort()
 			return
 		}
 
-		// 验证密码哈希，确保密码未被修改
-		currentPasswordHash := hashPassword(envCfg.USER_PASSWORD)
-		if claims.PasswordHash != currentPasswordHash {
+		// 验证密码指纹，确保密码未被修改
+		if claims.PasswordHash != fingerprint {
 			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "token已失效，请重新登录"})
 			c.Abort()
 			return
 		}
 
+		// 检查角色是否允许访问该路由
+		if !permitted(c, role) {
+			return
+		}
+
 		// 将用户信息存储到上下文中
 		c.Set("username", claims.Username)
+		c.Set("role", role)
+		c.Set("sessionId", claims.ID)
 		c.Next()
 	}
 }
 
-// ValidateCredentials 验证用户凭据（时序安全比较，防止时序攻击）
+// apiTokenAuth 使用 API 令牌认证，并检查令牌是否具有访问该路由所需的权限
+func apiTokenAuth(c *gin.Context, token string) {
+	t, ok := GetAPITokenStore().Authenticate(token, c.ClientIP())
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "无效的token"})
+		c.Abort()
+		return
+	}
+	// 令牌的权限不超过创建者角色的权限
+	role, ok := UserRole(t.Username)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "无效的token"})
+		c.Abort()
+		return
+	}
+	scope := RequiredScope(c.Request.Method, routePath(c))
+	if !t.HasScope(scope) {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "令牌缺少权限: " + scope})
+		c.Abort()
+		return
+	}
+	if !permitted(c, role) {
+		return
+	}
+	c.Set("username", t.Username)
+	c.Set("role", role)
+	c.Set("apiToken", t)
+	c.Next()
+}
+
+// permitted 检查角色是否允许访问当前路由，不允许时返回 403
+func permitted(c *gin.Context, role string) bool {
+	if RolePermits(role, c.Request.Method, routePath(c)) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "当前角色无权执行此操作"})
+	c.Abort()
+	return false
+}
+
+// routePath 返回匹配的路由模板，未匹配时使用请求路径
+func routePath(c *gin.Context) string {
+	if p := c.FullPath(); p != "" {
+		return p
+	}
+	return c.Request.URL.Path
+}
+
+// ValidateCredentials 验证用户凭据：内置管理员使用时序安全比较，其余用户校验 bcrypt 哈希
 func ValidateCredentials(username, password string) bool {
 	envCfg := conf.EnvCfg
-	usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(envCfg.USER_NAME)) == 1
-	passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(envCfg.USER_PASSWORD)) == 1
-	return usernameMatch && passwordMatch
+	if envCfg.USER_NAME != "" && envCfg.USER_PASSWORD != "" {
+		usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(envCfg.USER_NAME)) == 1
+		passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(envCfg.USER_PASSWORD)) == 1
+		if usernameMatch {
+			return passwordMatch
+		}
+	}
+	_, ok := users.GetStore().Authenticate(username, password)
+	return ok
 }
 
-// IsAuthEnabled 检查是否启用了身份验证
+// IsAuthEnabled 检查是否启用了身份验证：配置了内置管理员或存在用户
 func IsAuthEnabled() bool {
 	envCfg := conf.EnvCfg
-	return envCfg.USER_NAME != "" && envCfg.USER_PASSWORD != ""
+	return (envCfg.USER_NAME != "" && envCfg.USER_PASSWORD != "") || users.GetStore().Count() > 0
 }
 
 // TempTokenMiddleware 临时 token 中间件（允许临时 token）
 func TempTokenMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 检查是否配置了用户名和密码，如果没有配置则跳过验证
-		envCfg := conf.EnvCfg
-		if envCfg.USER_NAME == "" || envCfg.USER_PASSWORD == "" {
+		// 未配置任何账号时跳过验证
+		if !IsAuthEnabled() {
 			c.Next()
 			return
 		}
@@ -294,23 +495,31 @@ func TempTokenMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 验证 token 中的用户名和密码是否与配置的用户名密码匹配
-		if claims.Username != envCfg.USER_NAME {
+		// 验证 token 中的用户是否存在
+		role, fingerprint, ok := lookupUser(claims.Username)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "无效的token"})
 			c.Abort()
 			return
 		}
 
-		// 验证密码哈希，确保密码未被修改
-		currentPasswordHash := hashPassword(envCfg.USER_PASSWORD)
-		if claims.PasswordHash != currentPasswordHash {
+		// 验证密码指纹，确保密码未被修改
+		if claims.PasswordHash != fingerprint {
 			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "token已失效，请重新登录"})
 			c.Abort()
 			return
 		}
 
+		// 完整 token 需要会话未被吊销
+		if !claims.IsTempToken && (claims.ID == "" || !GetSessionStore().Touch(claims.ID, claims.Username, c.ClientIP())) {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "会话已失效，请重新登录"})
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存储到上下文中
 		c.Set("username", claims.Username)
+		c.Set("role", role)
 		c.Set("isTempToken", claims.IsTempToken)
 		c.Next()
 	}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/users"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// trustedProxy 请求的直接来源（TCP 对端地址，不使用 X-Forwarded-For）是否在 proxyAuth.trustedProxies 中
+func trustedProxy(c *gin.Context, cfg config.ProxyAuthConfig) bool {
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, s := range cfg.TrustedProxies {
+		if p, err := config.ParseCIDROrIP(s); err == nil && p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProxyUser 返回可信反向代理通过 proxyAuth.header 转发的用户名；
+// 未开启、请求头为空或来源不是可信代理时返回 false，来自其他地址的身份头被忽略
+func ProxyUser(c *gin.Context) (string, bool) {
+	cfg := config.Get().ProxyAuth
+	if !cfg.Enabled || cfg.Header == "" {
+		return "", false
+	}
+	username := strings.TrimSpace(c.GetHeader(cfg.Header))
+	if username == "" {
+		return "", false
+	}
+	if !trustedProxy(c, cfg) {
+		logger.Logger.Warn("忽略来自不可信地址的身份头", zap.String("header", cfg.Header), zap.String("remote", c.RemoteIP()))
+		return "", false
+	}
+	return username, true
+}
+
+// ResolveProxyUser 把代理转发的用户名映射为本地用户，返回角色；
+// 本地不存在的用户在配置了 proxyAuth.defaultRole 时自动创建
+func ResolveProxyUser(username string) (string, bool) {
+	if role, ok := UserRole(username); ok {
+		return role, true
+	}
+	defaultRole := config.Get().ProxyAuth.DefaultRole
+	if defaultRole == "" {
+		return "", false
+	}
+	u, err := users.GetStore().EnsureExternal(users.ExternalIdentity{Username: username, Source: "proxy", DefaultRole: defaultRole})
+	if err != nil {
+		logger.Logger.Warn("创建代理认证用户失败", zap.String("username", username), zap.Error(err))
+		return "", false
+	}
+	return u.Role, true
+}
+
+// proxyHeaderAuth 使用可信代理转发的身份认证
+func proxyHeaderAuth(c *gin.Context, username string) {
+	role, ok := ResolveProxyUser(username)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": "用户不存在"})
+		c.Abort()
+		return
+	}
+	if !permitted(c, role) {
+		return
+	}
+	c.Set("username", username)
+	c.Set("role", role)
+	c.Set("proxyAuth", true)
+	c.Next()
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestAuthMiddlewareProxyHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Logger = zap.NewNop()
+	oldEnv := *conf.EnvCfg
+	t.Cleanup(func() { *conf.EnvCfg = oldEnv })
+	conf.EnvCfg.USER_NAME = "admin"
+	conf.EnvCfg.USER_PASSWORD = "secret"
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	oldCfg := config.Get()
+	t.Cleanup(func() { config.SetGlobal(oldCfg) })
+
+	r := gin.New()
+	r.GET("/api/v1/containers", AuthMiddleware(), func(c *gin.Context) { c.String(http.StatusOK, c.GetString("username")) })
+	do := func(remote string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/containers", nil)
+		req.RemoteAddr = remote
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 默认关闭：即使来自代理地址也不使用身份头
+	if w := do("10.1.2.3:5000", map[string]string{"Remote-User": "admin"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("disabled: %d", w.Code)
+	}
+
+	cfg := *oldCfg
+	cfg.ProxyAuth = config.ProxyAuthConfig{Enabled: true, Header: "Remote-User", TrustedProxies: []string{"10.0.0.0/8", "192.168.1.5"}}
+	config.SetGlobal(&cfg)
+
+	cases := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		code    int
+	}{
+		{"trusted cidr", "10.1.2.3:5000", map[string]string{"Remote-User": "admin"}, http.StatusOK},
+		{"trusted ip", "192.168.1.5:5000", map[string]string{"Remote-User": "admin"}, http.StatusOK},
+		{"ipv4-mapped ipv6", "[::ffff:10.1.2.3]:5000", map[string]string{"Remote-User": "admin"}, http.StatusOK},
+		{"untrusted", "203.0.113.9:5000", map[string]string{"Remote-User": "admin"}, http.StatusUnauthorized},
+		// X-Forwarded-For 可以伪造，只看 TCP 对端地址
+		{"spoofed forwarded-for", "203.0.113.9:5000", map[string]string{"Remote-User": "admin", "X-Forwarded-For": "10.1.2.3", "X-Real-IP": "10.1.2.3"}, http.StatusUnauthorized},
+		{"neighbour ip", "192.168.1.6:5000", map[string]string{"Remote-User": "admin"}, http.StatusUnauthorized},
+		{"other header", "10.1.2.3:5000", map[string]string{"X-Forwarded-User": "admin"}, http.StatusUnauthorized},
+		{"unknown user", "10.1.2.3:5000", map[string]string{"Remote-User": "mallory"}, http.StatusUnauthorized},
+		// 显式携带的 token 优先于身份头
+		{"invalid token wins", "10.1.2.3:5000", map[string]string{"Remote-User": "admin", "Authorization": "Bearer bogus"}, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		w := do(tc.remote, tc.headers)
+		if w.Code != tc.code {
+			t.Errorf("%s: got %d, want %d", tc.name, w.Code, tc.code)
+		}
+		if tc.code == http.StatusOK && w.Body.String() != "admin" {
+			t.Errorf("%s: username %q", tc.name, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jianxcao/watch-docker/backend/internal/users"
+)
+
+// updateRoutes 触发更新的路由（去掉 /api/v1 与端点前缀后的路由模板）
+var updateRoutes = map[string]bool{
+	"/containers/:id/update":   true,
+	"/containers/:id/rollback": true,
+	"/updates/run":             true,
+	"/updates/batch/ws":        true,
+	"/update/all":              true,
+}
+
+// readOnlyPosts 使用 POST 但不修改任何状态的路由
+var readOnlyPosts = map[string]bool{
+	"/containers/stats":         true,
+	"/updates/plan":             true,
+	"/notify/templates/preview": true,
+}
+
+// mutatingGets 使用 GET（WebSocket）但会修改容器的路由
+var mutatingGets = map[string]bool{
+	"/compose/pull/:projectName/ws": true,
+	"/compose/up/:projectName/ws":   true,
+	"/compose/create-and-up/ws":     true,
+}
+
+// adminPrefixes 只有 admin 权限可以访问的路由，无论请求方法。
+// /logs 为服务端日志，/notify/channels 会返回渠道的发送错误，需排在 /notify 之前
+var adminPrefixes = []string{"/config", "/agents", "/logs", "/notify/channels", "/notify", "/api-tokens", "/users", "/audit", "/recordings"}
+
+// selfServicePrefixes 所有角色都可以访问的个人路由（个人信息、修改密码、个人 API 令牌）
+var selfServicePrefixes = []string{"/auth", "/api-tokens"}
+
+// RequiredScope 返回访问路由所需的权限范围，path 为 gin 路由模板（c.FullPath()）
+func RequiredScope(method, path string) string {
+	route := RouteOf(path)
+	switch {
+	case route == "/shell" || strings.Contains(route, "/shell/"):
+		return ScopeShell
+	case strings.Contains(route, "/files") || route == "/containers/:id/export":
+		return ScopeFiles
+	case updateRoutes[route]:
+		return ScopeUpdate
+	}
+	for _, p := range adminPrefixes {
+		if route == p || strings.HasPrefix(route, p+"/") {
+			if method == http.MethodGet && p == "/notify" {
+				return ScopeRead
+			}
+			return ScopeAdmin
+		}
+	}
+	if readOnlyPosts[route] {
+		return ScopeRead
+	}
+	if (method == http.MethodGet || method == http.MethodHead) && !mutatingGets[route] {
+		return ScopeRead
+	}
+	return ScopeContainer
+}
+
+// RolePermits 角色是否允许访问路由：admin 不受限制，operator 可以使用除管理功能与主机终端外的全部功能，viewer 只读
+func RolePermits(role, method, path string) bool {
+	if role == users.RoleAdmin {
+		return true
+	}
+	route := RouteOf(path)
+	for _, p := range selfServicePrefixes {
+		if route == p || strings.HasPrefix(route, p+"/") {
+			return true
+		}
+	}
+	// 主机终端等同于主机 root 权限
+	if route == "/shell" {
+		return false
+	}
+	switch scope := RequiredScope(method, path); role {
+	case users.RoleOperator:
+		return scope != ScopeAdmin
+	case users.RoleViewer:
+		return scope == ScopeRead
+	}
+	return false
+}
+
+// RouteOf 去掉 /api/v1 与 /endpoints/<id> 前缀，使各端点的路由按相同规则判断
+func RouteOf(path string) string {
+	route := strings.TrimPrefix(path, "/api/v1")
+	if rest, ok := strings.CutPrefix(route, "/endpoints/"); ok {
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			return rest[i:]
+		}
+	}
+	return route
+}
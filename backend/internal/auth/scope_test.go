@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/jianxcao/watch-docker/backend/internal/users"
+)
+
+func TestRequiredScope(t *testing.T) {
+	cases := []struct {
+		method, path, want string
+	}{
+		{"GET", "/api/v1/containers", ScopeRead},
+		{"GET", "/api/v1/endpoints/nas/containers/:id", ScopeRead},
+		{"POST", "/api/v1/containers/stats", ScopeRead},
+		{"POST", "/api/v1/containers/:id/stop", ScopeContainer},
+		{"DELETE", "/api/v1/endpoints/nas/images", ScopeContainer},
+		{"GET", "/api/v1/compose/up/:projectName/ws", ScopeContainer},
+		{"POST", "/api/v1/containers/:id/update", ScopeUpdate},
+		{"POST", "/api/v1/updates/run", ScopeUpdate},
+		{"GET", "/api/v1/containers/:id/files/content", ScopeFiles},
+		{"PUT", "/api/v1/endpoints/nas/containers/:id/files/content", ScopeFiles},
+		{"GET", "/api/v1/shell", ScopeShell},
+		{"GET", "/api/v1/containers/:id/shell/ws", ScopeShell},
+		{"GET", "/api/v1/config", ScopeAdmin},
+		{"POST", "/api/v1/agents/join-tokens", ScopeAdmin},
+		{"GET", "/api/v1/recordings/:id/download", ScopeAdmin},
+		{"GET", "/api/v1/notify/templates", ScopeRead},
+		{"GET", "/api/v1/notify/channels", ScopeAdmin},
+		{"GET", "/api/v1/logs", ScopeAdmin},
+	}
+	for _, c := range cases {
+		if got := RequiredScope(c.method, c.path); got != c.want {
+			t.Errorf("RequiredScope(%s %s) = %s, want %s", c.method, c.path, got, c.want)
+		}
+	}
+}
+
+func TestRolePermits(t *testing.T) {
+	cases := []struct {
+		role, method, path string
+		want               bool
+	}{
+		{users.RoleViewer, "GET", "/api/v1/containers", true},
+		{users.RoleViewer, "POST", "/api/v1/containers/:id/restart", false},
+		{users.RoleViewer, "GET", "/api/v1/containers/:id/files/content", false},
+		{users.RoleViewer, "GET", "/api/v1/auth/me", true},
+		{users.RoleViewer, "POST", "/api/v1/api-tokens", true},
+		{users.RoleOperator, "POST", "/api/v1/endpoints/nas/containers/:id/update", true},
+		{users.RoleOperator, "GET", "/api/v1/containers/:id/shell/ws", true},
+		{users.RoleOperator, "GET", "/api/v1/shell", false},
+		{users.RoleOperator, "POST", "/api/v1/config", false},
+		{users.RoleOperator, "GET", "/api/v1/users", false},
+		{users.RoleOperator, "GET", "/api/v1/logs", false},
+		{users.RoleViewer, "GET", "/api/v1/notify/channels", false},
+		{users.RoleAdmin, "GET", "/api/v1/shell", true},
+		{"", "GET", "/api/v1/containers", false},
+	}
+	for _, c := range cases {
+		if got := RolePermits(c.role, c.method, c.path); got != c.want {
+			t.Errorf("RolePermits(%q, %s %s) = %v, want %v", c.role, c.method, c.path, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+)
+
+// RefreshTokenPrefix 刷新令牌前缀，格式为 wdr_<会话 ID>.<随机串>
+const RefreshTokenPrefix = "wdr_"
+
+// refreshGracePeriod 刷新令牌轮换后，上一个令牌仍可使用的时间（多个标签页同时刷新）；
+// 超过该时间再使用旧令牌视为令牌被盗用，吊销整个会话
+const refreshGracePeriod = time.Minute
+
+// maxDeviceLength 设备描述（User-Agent）的最大长度
+const maxDeviceLength = 256
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrRefreshReused   = errors.New("refresh token reused, session revoked")
+)
+
+// Session 登录会话：每个访问令牌的 jti 为会话 ID，吊销会话后其访问令牌与刷新令牌立即失效
+type Session struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	Device      string    `json:"device"` // 登录时的 User-Agent
+	IP          string    `json:"ip"`     // 最近一次请求的 IP
+	CreatedAt   time.Time `json:"createdAt"`
+	LastSeenAt  time.Time `json:"lastSeenAt"`
+	ExpiresAt   time.Time `json:"expiresAt"` // 刷新令牌过期时间，每次刷新后顺延
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	RefreshHash string    `json:"refreshHash,omitempty"`
+	// 上一个刷新令牌，轮换后 refreshGracePeriod 内仍然有效
+	PrevRefreshHash  string    `json:"prevRefreshHash,omitempty"`
+	PrevRefreshUntil time.Time `json:"prevRefreshUntil,omitzero"`
+}
+
+// Expired 会话是否已过期
+func (s *Session) Expired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
+
+// SessionStore 会话存储
+type SessionStore struct {
+	path      string
+	mu        sync.Mutex
+	sessions  []*Session
+	flushedAt time.Time
+}
+
+var (
+	sessionStore     *SessionStore
+	sessionStoreOnce sync.Once
+)
+
+// GetSessionStore 获取会话存储实例，数据保存在 CONFIG_PATH/sessions.json
+func GetSessionStore() *SessionStore {
+	sessionStoreOnce.Do(func() {
+		sessionStore = NewSessionStore(filepath.Join(conf.EnvCfg.CONFIG_PATH, "sessions.json"))
+	})
+	return sessionStore
+}
+
+// NewSessionStore 从文件加载会话，文件不存在时为空
+func NewSessionStore(path string) *SessionStore {
+	s := &SessionStore{path: path}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &s.sessions)
+	}
+	return s
+}
+
+// Create 创建会话，返回会话副本与刷新令牌明文
+func (s *SessionStore) Create(username, fingerprint, device, ip string, ttl time.Duration) (*Session, string, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, "", fmt.Errorf("generate session id: %w", err)
+	}
+	refresh, hash, err := newRefreshToken(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(device) > maxDeviceLength {
+		device = device[:maxDeviceLength]
+	}
+	now := time.Now()
+	sess := &Session{
+		ID:          id,
+		Username:    username,
+		Device:      device,
+		IP:          ip,
+		CreatedAt:   now,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(ttl),
+		Fingerprint: fingerprint,
+		RefreshHash: hash,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	s.sessions = append(s.sessions, sess)
+	if err := s.saveLocked(); err != nil {
+		s.sessions = s.sessions[:len(s.sessions)-1]
+		return nil, "", err
+	}
+	return sess.copy(), refresh, nil
+}
+
+// Touch 检查会话是否有效并记录最近一次请求的时间与 IP
+func (s *SessionStore) Touch(id, username, ip string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.findLocked(id)
+	if sess == nil || sess.Username != username || sess.Expired() {
+		return false
+	}
+	now := time.Now()
+	sess.LastSeenAt = now
+	sess.IP = ip
+	if now.Sub(s.flushedAt) >= lastUsedFlushInterval {
+		_ = s.saveLocked()
+	}
+	return true
+}
+
+// Refresh 校验刷新令牌并轮换，返回会话副本与新的刷新令牌；fingerprint 为用户当前的密码指纹，
+// 与创建会话时不一致（密码已修改）或过了宽限期仍使用旧刷新令牌时吊销会话
+func (s *SessionStore) Refresh(token, fingerprint, ip string, ttl time.Duration) (*Session, string, error) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(token, RefreshTokenPrefix), ".")
+	if !strings.HasPrefix(token, RefreshTokenPrefix) || !ok {
+		return nil, "", ErrSessionNotFound
+	}
+	hash := hashAPIToken(token)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.findLocked(id)
+	if sess == nil || sess.Expired() {
+		return nil, "", ErrSessionNotFound
+	}
+	if sess.Fingerprint != fingerprint {
+		s.removeLocked(id)
+		_ = s.saveLocked()
+		return nil, "", ErrSessionNotFound
+	}
+	now := time.Now()
+	current := subtle.ConstantTimeCompare([]byte(hash), []byte(sess.RefreshHash)) == 1
+	prev := sess.PrevRefreshHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(sess.PrevRefreshHash)) == 1
+	switch {
+	case current, prev && now.Before(sess.PrevRefreshUntil):
+	case prev:
+		s.removeLocked(id)
+		_ = s.saveLocked()
+		return nil, "", ErrRefreshReused
+	default:
+		return nil, "", ErrSessionNotFound
+	}
+
+	refresh, newHash, err := newRefreshToken(id)
+	if err != nil {
+		return nil, "", err
+	}
+	old := *sess
+	// 被替换的令牌进入宽限期
+	sess.PrevRefreshHash, sess.PrevRefreshUntil = sess.RefreshHash, now.Add(refreshGracePeriod)
+	sess.RefreshHash = newHash
+	sess.LastSeenAt = now
+	sess.IP = ip
+	sess.ExpiresAt = now.Add(ttl)
+	if err := s.saveLocked(); err != nil {
+		*sess = old
+		return nil, "", err
+	}
+	return sess.copy(), refresh, nil
+}
+
+// Get 按 ID 查找会话
+func (s *SessionStore) Get(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.findLocked(id)
+	if sess == nil || sess.Expired() {
+		return nil, false
+	}
+	return sess.copy(), true
+}
+
+// List 返回未过期的会话（不含令牌哈希），username 为空时返回全部，按最近活动时间倒序排列
+func (s *SessionStore) List(username string) []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		if sess.Expired() || (username != "" && sess.Username != username) {
+			continue
+		}
+		list = append(list, *sess.copy())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LastSeenAt.After(list[j].LastSeenAt) })
+	return list
+}
+
+// Revoke 吊销会话
+func (s *SessionStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.removeLocked(id) {
+		return ErrSessionNotFound
+	}
+	return s.saveLocked()
+}
+
+// RevokeUser 吊销用户的全部会话，except 非空时保留该会话，返回吊销数量
+func (s *SessionStore) RevokeUser(username, except string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.sessions[:0:0]
+	for _, sess := range s.sessions {
+		if sess.Username != username || sess.ID == except {
+			kept = append(kept, sess)
+		}
+	}
+	n := len(s.sessions) - len(kept)
+	if n == 0 {
+		return 0, nil
+	}
+	s.sessions = kept
+	return n, s.saveLocked()
+}
+
+// Flush 保存尚未写盘的最近活动时间
+func (s *SessionStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked()
+}
+
+func (s *SessionStore) findLocked(id string) *Session {
+	for _, sess := range s.sessions {
+		if sess.ID == id {
+			return sess
+		}
+	}
+	return nil
+}
+
+func (s *SessionStore) removeLocked(id string) bool {
+	for i, sess := range s.sessions {
+		if sess.ID == id {
+			s.sessions = append(s.sessions[:i:i], s.sessions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// pruneLocked 删除已过期的会话
+func (s *SessionStore) pruneLocked() {
+	kept := s.sessions[:0:0]
+	for _, sess := range s.sessions {
+		if !sess.Expired() {
+			kept = append(kept, sess)
+		}
+	}
+	s.sessions = kept
+}
+
+func (s *SessionStore) saveLocked() error {
+	data, err := json.MarshalIndent(s.sessions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal sessions: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("create session dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write sessions: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("rename sessions: %w", err)
+	}
+	s.flushedAt = time.Now()
+	return nil
+}
+
+// copy 返回不含令牌哈希与密码指纹的副本
+func (s *Session) copy() *Session {
+	c := *s
+	c.Fingerprint, c.RefreshHash, c.PrevRefreshHash, c.PrevRefreshUntil = "", "", "", time.Time{}
+	return &c
+}
+
+func newRefreshToken(sessionID string) (token, hash string, err error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", "", fmt.Errorf("generate refresh token: %w", err)
+	}
+	token = RefreshTokenPrefix + sessionID + "." + secret
+	return token, hashAPIToken(token), nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSessionStoreRefresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	s := NewSessionStore(path)
+	sess, refresh, err := s.Create("alice", "fp", "Firefox", "10.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.RefreshHash != "" || sess.Fingerprint != "" {
+		t.Fatal("session copy leaks secrets")
+	}
+
+	// 轮换：旧令牌在宽限期内仍可使用（如多个标签页同时刷新）
+	if _, _, err := s.Refresh(refresh, "fp", "10.0.0.2", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	_, refresh2, err := s.Refresh(refresh, "fp", "10.0.0.2", time.Hour)
+	if err != nil {
+		t.Fatalf("previous token within grace: %v", err)
+	}
+	if _, _, err := s.Refresh(refresh+"x", "fp", "10.0.0.2", time.Hour); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("bogus token: %v", err)
+	}
+
+	// 宽限期过后再次使用旧令牌，视为被盗用并吊销会话
+	_, refresh3, err := s.Refresh(refresh2, "fp", "10.0.0.2", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.sessions[0].PrevRefreshUntil = time.Now().Add(-time.Second)
+	if _, _, err := s.Refresh(refresh2, "fp", "10.0.0.2", time.Hour); !errors.Is(err, ErrRefreshReused) {
+		t.Fatalf("reused token: %v", err)
+	}
+	if _, _, err := s.Refresh(refresh3, "fp", "10.0.0.2", time.Hour); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("session should be revoked: %v", err)
+	}
+
+	// 密码指纹变化后刷新令牌失效
+	_, refresh, _ = s.Create("alice", "fp", "Firefox", "10.0.0.1", time.Hour)
+	if _, _, err := s.Refresh(refresh, "fp2", "10.0.0.1", time.Hour); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("fingerprint mismatch: %v", err)
+	}
+	if len(s.List("")) != 0 {
+		t.Fatal("session with stale fingerprint not revoked")
+	}
+
+	// 吊销其他会话并从文件重新加载
+	a, _, _ := s.Create("alice", "fp", "Firefox", "10.0.0.1", time.Hour)
+	s.Create("alice", "fp", "Chrome", "10.0.0.3", time.Hour)
+	s.Create("bob", "fp", "curl", "10.0.0.4", time.Hour)
+	if n, err := s.RevokeUser("alice", a.ID); err != nil || n != 1 {
+		t.Fatalf("revoke user: %d %v", n, err)
+	}
+	reloaded := NewSessionStore(path)
+	if len(reloaded.List("alice")) != 1 || len(reloaded.List("")) != 2 {
+		t.Fatalf("reloaded sessions = %+v", reloaded.List(""))
+	}
+}
+
+func TestAuthMiddlewareSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldEnv := *conf.EnvCfg
+	t.Cleanup(func() { *conf.EnvCfg = oldEnv })
+	conf.EnvCfg.USER_NAME = "admin"
+	conf.EnvCfg.USER_PASSWORD = "secret"
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	oldCfg := config.Get()
+	t.Cleanup(func() { config.SetGlobal(oldCfg) })
+	cfg := *oldCfg
+	cfg.Session = config.SessionConfig{AccessTokenMinutes: 15, RefreshTokenDays: 30}
+	config.SetGlobal(&cfg)
+
+	r := gin.New()
+	r.GET("/api/v1/containers", AuthMiddleware(), func(c *gin.Context) { c.String(http.StatusOK, c.GetString("sessionId")) })
+	do := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/containers", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	pair, err := NewSession("admin", "Firefox", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.ExpiresIn != 15*60 {
+		t.Fatalf("expiresIn = %d", pair.ExpiresIn)
+	}
+	if w := do(pair.Token); w.Code != http.StatusOK || w.Body.String() != pair.SessionID {
+		t.Fatalf("valid session: %d %s", w.Code, w.Body.String())
+	}
+
+	// 刷新后新旧访问令牌属于同一会话
+	refreshed, err := RefreshSession(pair.RefreshToken, "10.0.0.1")
+	if err != nil || refreshed.SessionID != pair.SessionID {
+		t.Fatalf("refresh: %+v %v", refreshed, err)
+	}
+	if w := do(refreshed.Token); w.Code != http.StatusOK {
+		t.Fatalf("refreshed token: %d", w.Code)
+	}
+
+	// 没有 jti 的旧版 token 被拒绝
+	_, fingerprint, _ := lookupUser("admin")
+	legacy, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		Username: "admin", PasswordHash: fingerprint, TwoFAVerified: true,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString(getJWTSecret())
+	if w := do(legacy); w.Code != http.StatusUnauthorized {
+		t.Fatalf("legacy token: %d", w.Code)
+	}
+
+	// 密码修改后，访问令牌与二次验证的临时 token 均失效
+	temp, err := GenerateTempToken("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.EnvCfg.USER_PASSWORD = "changed"
+	if _, err := ValidateSessionToken(refreshed.Token, "10.0.0.1"); !errors.Is(err, ErrPasswordChanged) {
+		t.Fatalf("stale access token: %v", err)
+	}
+	if _, err := UpgradeTempToken(temp, "Firefox", "10.0.0.1"); !errors.Is(err, ErrPasswordChanged) {
+		t.Fatalf("stale temp token: %v", err)
+	}
+	conf.EnvCfg.USER_PASSWORD = "secret"
+	if _, err := ValidateSessionToken(refreshed.Token, "10.0.0.1"); err != nil {
+		t.Fatalf("access token: %v", err)
+	}
+
+	// 吊销后访问令牌与刷新令牌立即失效
+	if err := GetSessionStore().Revoke(pair.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(refreshed.Token); w.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked session: %d", w.Code)
+	}
+	if _, err := RefreshSession(refreshed.RefreshToken, "10.0.0.1"); err == nil {
+		t.Fatal("refresh after revoke succeeded")
+	}
+}
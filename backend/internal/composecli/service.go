@@ -0,0 +1,129 @@
+package composecli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// compose 写入容器的标签
+const (
+	LabelProject     = "com.docker.compose.project"
+	LabelService     = "com.docker.compose.service"
+	LabelWorkingDir  = "com.docker.compose.project.working_dir"
+	LabelConfigFiles = "com.docker.compose.project.config_files"
+)
+
+// ServiceRef 由容器标签确定的 compose 服务
+type ServiceRef struct {
+	Project     string   `json:"project"`
+	Service     string   `json:"service"`
+	WorkingDir  string   `json:"workingDir"`
+	ConfigFiles []string `json:"configFiles,omitempty"`
+}
+
+// ServiceFromLabels 从容器标签中读取 compose 服务信息，非 compose 管理或缺少工作目录时返回 false
+func ServiceFromLabels(labels map[string]string) (ServiceRef, bool) {
+	ref := ServiceRef{
+		Project:    labels[LabelProject],
+		Service:    labels[LabelService],
+		WorkingDir: labels[LabelWorkingDir],
+	}
+	if ref.Project == "" || ref.Service == "" || ref.WorkingDir == "" {
+		return ServiceRef{}, false
+	}
+	for _, f := range strings.Split(labels[LabelConfigFiles], ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			ref.ConfigFiles = append(ref.ConfigFiles, f)
+		}
+	}
+	return ref, true
+}
+
+// Key 返回 project/service，用于分组
+func (r ServiceRef) Key() string {
+	return r.Project + "/" + r.Service
+}
+
+// baseArgs 返回指定项目名与 compose 文件的公共参数；compose 文件在本机不存在时交给 compose 按工作目录查找
+func (r ServiceRef) baseArgs() []string {
+	args := []string{"-p", r.Project}
+	for _, f := range r.ConfigFiles {
+		if _, err := os.Stat(f); err == nil {
+			args = append(args, "-f", f)
+		}
+	}
+	return args
+}
+
+// UpdateService 在项目工作目录中依次执行 pull <service> 与 up -d --no-deps <service>，
+// 命令输出逐行回调给 onOutput（可以为 nil），step 为 pulling 或 recreating
+func (c *Client) UpdateService(ctx context.Context, ref ServiceRef, onOutput func(step, line string)) error {
+	if _, err := os.Stat(ref.WorkingDir); err != nil {
+		return fmt.Errorf("compose 项目目录不可用: %w", err)
+	}
+	steps := []struct {
+		step string
+		args []string
+	}{
+		{"pulling", []string{"pull", ref.Service}},
+		{"recreating", []string{"up", "-d", "--no-deps", ref.Service}},
+	}
+	for _, s := range steps {
+		args := append(ref.baseArgs(), s.args...)
+		if err := runStream(ctx, ref.WorkingDir, args, s.step+" "+ref.Key(), func(line string) {
+			if onOutput != nil {
+				onOutput(s.step, line)
+			}
+		}); err != nil {
+			return fmt.Errorf("compose %s: %w", s.args[0], err)
+		}
+	}
+	return nil
+}
+
+// RecreateService 不拉取镜像，只执行 up -d --no-deps <service>，使用本地已有的镜像重建服务容器（用于回滚）
+func (c *Client) RecreateService(ctx context.Context, ref ServiceRef) error {
+	if _, err := os.Stat(ref.WorkingDir); err != nil {
+		return fmt.Errorf("compose 项目目录不可用: %w", err)
+	}
+	args := append(ref.baseArgs(), "up", "-d", "--no-deps", "--pull", "never", ref.Service)
+	if err := runStream(ctx, ref.WorkingDir, args, "recreating "+ref.Key(), func(string) {}); err != nil {
+		return fmt.Errorf("compose up: %w", err)
+	}
+	return nil
+}
+
+// runStream 执行 docker compose 命令并逐行读取输出，退出码非 0 时返回包含最后一行输出的错误
+func runStream(ctx context.Context, dir string, args []string, operation string, onLine func(string)) error {
+	res := ExecuteDockerComposeCommandStream(ctx, ExecDockerComposeStreamOptions{
+		ExecPath:      dir,
+		Args:          args,
+		OperationName: operation,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	defer res.Reader.Close()
+
+	var last string
+	sc := bufio.NewScanner(res.Reader)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		last = line
+		onLine(line)
+	}
+	code, ok := <-res.ExitCode
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if !ok || code != 0 {
+		return fmt.Errorf("exit code %d: %s", code, last)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package composecli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestServiceFromLabels(t *testing.T) {
+	ref, ok := ServiceFromLabels(map[string]string{
+		LabelProject:     "media",
+		LabelService:     "jellyfin",
+		LabelWorkingDir:  "/opt/media",
+		LabelConfigFiles: "/opt/media/compose.yaml, /opt/media/compose.override.yaml",
+	})
+	if !ok {
+		t.Fatalf("expected compose service to be detected")
+	}
+	if ref.Key() != "media/jellyfin" || ref.WorkingDir != "/opt/media" {
+		t.Fatalf("unexpected ref %+v", ref)
+	}
+	want := []string{"/opt/media/compose.yaml", "/opt/media/compose.override.yaml"}
+	if !reflect.DeepEqual(ref.ConfigFiles, want) {
+		t.Fatalf("config files = %v, want %v", ref.ConfigFiles, want)
+	}
+
+	if _, ok := ServiceFromLabels(map[string]string{LabelProject: "media", LabelService: "jellyfin"}); ok {
+		t.Fatalf("expected labels without working dir to be rejected")
+	}
+}
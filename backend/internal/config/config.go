@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"os"
 	"path"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -53,11 +55,36 @@ type ServerConfig struct {
 // DockerConfig Docker 连接与容器发现相关配置
 // host: Docker API 访问地址（空则走环境变量 DOCKER_HOST / 默认本地）
 // includeStopped: 是否包含已停止容器
+// endpoints: 额外管理的 Docker 主机，host 对应的本机端点 ID 固定为 local
 type DockerConfig struct {
-	Host           string `mapstructure:"host" json:"host"`
-	IncludeStopped bool   `mapstructure:"includeStopped" json:"includeStopped"`
+	Host           string           `mapstructure:"host" json:"host"`
+	IncludeStopped bool             `mapstructure:"includeStopped" json:"includeStopped"`
+	Endpoints      []DockerEndpoint `mapstructure:"endpoints" json:"endpoints"`
 }
 
+// DockerEndpoint 一个 Docker 主机端点
+// id: 端点 ID，用于 API 路径 /api/v1/endpoints/:id/...，只能包含字母、数字、- 和 _
+// name: 显示名称
+// host: unix:///var/run/docker.sock、tcp://host:2376 或 ssh://user@host
+// tlsCA/tlsCert/tlsKey: tcp 连接使用的 CA 证书与客户端证书、私钥路径
+type DockerEndpoint struct {
+	ID      string `mapstructure:"id" json:"id"`
+	Name    string `mapstructure:"name" json:"name"`
+	Host    string `mapstructure:"host" json:"host"`
+	TLSCA   string `mapstructure:"tlsCA" json:"tlsCA"`
+	TLSCert string `mapstructure:"tlsCert" json:"tlsCert"`
+	TLSKey  string `mapstructure:"tlsKey" json:"tlsKey"`
+}
+
+// LocalEndpointID docker.host 对应的默认端点 ID
+const LocalEndpointID = "local"
+
+// AgentEndpointPrefix agent 端点的 ID 前缀，端点 ID 为 agent-<agent ID>，配置中的端点不能使用
+const AgentEndpointPrefix = "agent-"
+
+// reservedEndpointIDs 与 /api/v1/endpoints 下的固定路由冲突的端点 ID
+var reservedEndpointIDs = map[string]bool{LocalEndpointID: true, "containers": true}
+
 // ScanConfig 扫描相关配置
 // interval: 周期扫描间隔（与 cron 二选一）
 // cron: 使用 cron 表达式触发扫描
@@ -76,6 +103,8 @@ type ScanConfig struct {
 // skip* 相关开关用于定义默认跳过规则
 // onlyLabels 为包含/排除的 label 过滤
 // floatingTags 指定哪些 tag 被视为"浮动"，仅这些会被检查更新
+// semverMode 语义化版本升级模式（patch/minor/major），为空表示关闭；
+// 开启后严格语义化版本 tag 不再跳过，而是升级到 registry 中满足幅度的最高 tag
 type PolicyConfig struct {
 	SkipLabels       []string `mapstructure:"skipLabels" json:"skipLabels"`
 	OnlyLabels       []string `mapstructure:"onlyLabels" json:"onlyLabels"`
@@ -83,16 +112,23 @@ type PolicyConfig struct {
 	SkipPinnedDigest bool     `mapstructure:"skipPinnedDigest" json:"skipPinnedDigest"`
 	SkipSemverPinned bool     `mapstructure:"skipSemverPinned" json:"skipSemverPinned"`
 	FloatingTags     []string `mapstructure:"floatingTags" json:"floatingTags"`
+	SemverMode       string   `mapstructure:"semverMode" json:"semverMode"`
 }
 
 // RegistryAuth per-registry 凭据配置
 // host: registry 主机地址，支持 "dockerhub"/"docker.io"、"ghcr.io" 或自定义私有仓库
 // username: 用户名
 // token: 访问令牌或密码
+// insecure: 使用 HTTP 而非 HTTPS 访问（仅用于内网自建 registry）
+// skipTLSVerify: 跳过 TLS 证书校验
+// caCert: 自定义 CA 证书文件路径（PEM），用于自签名证书的私有仓库
 type RegistryAuth struct {
-	Host     string `mapstructure:"host" json:"host"`
-	Username string `mapstructure:"username" json:"username"`
-	Token    string `mapstructure:"token" json:"token"`
+	Host          string `mapstructure:"host" json:"host"`
+	Username      string `mapstructure:"username" json:"username"`
+	Token         string `mapstructure:"token" json:"token"`
+	Insecure      bool   `mapstructure:"insecure" json:"insecure"`
+	SkipTLSVerify bool   `mapstructure:"skipTLSVerify" json:"skipTLSVerify"`
+	CACert        string `mapstructure:"caCert" json:"caCert"`
 }
 
 // RegistryMirror Docker Hub 镜像加速器配置
@@ -116,6 +152,46 @@ type RegistryConfig struct {
 	Mirrors []RegistryMirror `mapstructure:"mirrors" json:"mirrors"`
 }
 
+// UpdateConfig 容器更新相关配置
+// verify: 新容器启动后是否校验其运行状态，校验失败自动回滚到旧容器；默认关闭，
+// 开启后更新会多等待 stableSeconds 或 healthTimeout 秒
+// healthTimeout: 镜像定义了 HEALTHCHECK 时，等待其变为 healthy 的最长时间（秒）
+// stableSeconds: 未定义 HEALTHCHECK 时，要求新容器持续运行且没有重启的时间（秒）
+// keepImages: 每个容器保留最近 N 个旧镜像用于回滚，0 表示更新后立即删除旧镜像
+type UpdateConfig struct {
+	Verify        bool `mapstructure:"verify" json:"verify"`
+	HealthTimeout int  `mapstructure:"healthTimeout" json:"healthTimeout"`
+	StableSeconds int  `mapstructure:"stableSeconds" json:"stableSeconds"`
+	KeepImages    int  `mapstructure:"keepImages" json:"keepImages"`
+}
+
+// ScheduleConfig 自动更新的调度配置
+// rules: 按标签指定容器的更新计划（cron），容器自身的 watchdocker.schedule 标签优先；
+// 有独立更新计划的容器不再由全局 scan.cron 任务自动更新
+// maintenanceWindows: 允许自动更新的维护窗口，为空表示任何时间都允许；
+// 在窗口外到期的更新会排队，等到窗口打开后再执行
+type ScheduleConfig struct {
+	Rules              []ScheduleRule      `mapstructure:"rules" json:"rules"`
+	MaintenanceWindows []MaintenanceWindow `mapstructure:"maintenanceWindows" json:"maintenanceWindows"`
+}
+
+// ScheduleRule 按标签指定更新计划
+// label: 标签，格式 key 或 key=value
+// cron: cron 表达式，支持 5 段（分 时 日 月 周）或 6 段（带秒）
+type ScheduleRule struct {
+	Label string `mapstructure:"label" json:"label"`
+	Cron  string `mapstructure:"cron" json:"cron"`
+}
+
+// MaintenanceWindow 维护窗口
+// days: 星期几（mon/tue/wed/thu/fri/sat/sun），为空表示每天
+// start/end: 开始与结束时间（HH:MM，本地时间），end 早于 start 表示跨越午夜
+type MaintenanceWindow struct {
+	Days  []string `mapstructure:"days" json:"days"`
+	Start string   `mapstructure:"start" json:"start"`
+	End   string   `mapstructure:"end" json:"end"`
+}
+
 // ProxyConfig 代理相关配置
 // url: 代理服务器完整地址，支持以下格式：
 //   - HTTP 代理: http://proxy.example.com:8080
@@ -133,12 +209,93 @@ type LoggingConfig struct {
 }
 
 // NotificationConfig 通知相关配置
-// url: 通知地址，仅支持配置一个；允许使用占位符 {title}/{content}/{text}
+// url: 旧版单一通知地址，允许使用占位符 {title}/{content}/{url}/{image}，作为名为 default 的 webhook 渠道
 // method: 请求方法，仅允许 GET 或 POST
+// isEnable: 通知总开关
+// channels: 通知渠道列表，每个渠道单独启用并可按事件类型过滤
+// language: 内置消息模板的语言（zh/en），默认 zh
+// templates: 按事件类型自定义的消息模板（Go text/template），未配置的事件使用内置模板
 type NotificationConfig struct {
-	URL      string `mapstructure:"url" json:"url"`
-	Method   string `mapstructure:"method" json:"method"`
-	IsEnable bool   `mapstructure:"isEnable" json:"isEnable"`
+	URL       string                    `mapstructure:"url" json:"url"`
+	Method    string                    `mapstructure:"method" json:"method"`
+	IsEnable  bool                      `mapstructure:"isEnable" json:"isEnable"`
+	Channels  []NotifyChannel           `mapstructure:"channels" json:"channels"`
+	Language  string                    `mapstructure:"language" json:"language"`
+	Templates map[string]NotifyTemplate `mapstructure:"templates" json:"templates,omitempty"`
+
+	ContainerEvents ContainerEventsConfig `mapstructure:"containerEvents" json:"containerEvents"`
+}
+
+// ContainerEventsConfig 容器生命周期事件通知（订阅 Docker events）
+// enabled: 是否发送容器生命周期事件通知
+// restartLoopCount/restartLoopMinutes: restartLoopMinutes 分钟内启动 restartLoopCount 次视为重启循环
+// cooldownMinutes: 同一容器的同一类事件在该时间内只通知一次
+// died/oom/unhealthy/restartLoop/removed: 各事件类型的开关与过滤规则
+type ContainerEventsConfig struct {
+	Enabled            bool               `mapstructure:"enabled" json:"enabled"`
+	RestartLoopCount   int                `mapstructure:"restartLoopCount" json:"restartLoopCount"`
+	RestartLoopMinutes int                `mapstructure:"restartLoopMinutes" json:"restartLoopMinutes"`
+	CooldownMinutes    int                `mapstructure:"cooldownMinutes" json:"cooldownMinutes"`
+	Died               ContainerEventRule `mapstructure:"died" json:"died"`
+	OOM                ContainerEventRule `mapstructure:"oom" json:"oom"`
+	Unhealthy          ContainerEventRule `mapstructure:"unhealthy" json:"unhealthy"`
+	RestartLoop        ContainerEventRule `mapstructure:"restartLoop" json:"restartLoop"`
+	Removed            ContainerEventRule `mapstructure:"removed" json:"removed"`
+}
+
+// ContainerEventRule 单个事件类型的开关与过滤规则
+// names/excludeNames: 容器名称，支持通配符（如 web-*）；names 为空表示全部容器
+// labels/excludeLabels: 容器标签，格式 key 或 key=value；labels 为空表示全部容器，配置多个时满足任意一个即可
+type ContainerEventRule struct {
+	Enabled       bool     `mapstructure:"enabled" json:"enabled"`
+	Names         []string `mapstructure:"names" json:"names"`
+	ExcludeNames  []string `mapstructure:"excludeNames" json:"excludeNames"`
+	Labels        []string `mapstructure:"labels" json:"labels"`
+	ExcludeLabels []string `mapstructure:"excludeLabels" json:"excludeLabels"`
+}
+
+// NotifyTemplate 通知消息模板，title 与 content 均为 Go text/template，留空的部分使用内置模板
+type NotifyTemplate struct {
+	Title   string `mapstructure:"title" json:"title"`
+	Content string `mapstructure:"content" json:"content"`
+}
+
+// NotifyChannel 单个通知渠道，不同类型使用的字段不同：
+//   - webhook: url/method/headers/body（body 为 JSON 模板，可使用 {title}/{content}/{url}/{image}）
+//   - telegram: token（Bot Token）/chatId，url 可选（自建 Bot API 地址）
+//   - bark: token（设备 key），url 可选（自建服务器）
+//   - serverchan: token（SendKey）
+//   - wecom/dingtalk/feishu: url（机器人 webhook 地址）或 token，secret（钉钉/飞书加签密钥，可选）
+//   - gotify: url/token（应用 token）
+//   - ntfy: topic，url 可选（默认 ntfy.sh），token 或 username/password 可选
+//   - email: host/port/username/password/from/to
+//   - apprise: url（Apprise 风格 URL，如 tgram://{botToken}/{chatId}）
+//
+// events: 订阅的事件类型（如 update_available/update_failed），为空表示全部
+// format: 消息格式 text/markdown/html，支持的渠道（telegram/email/wecom/dingtalk/ntfy）按该格式发送
+// language/templates: 覆盖全局的模板语言与自定义模板
+type NotifyChannel struct {
+	Name      string                    `mapstructure:"name" json:"name"`
+	Type      string                    `mapstructure:"type" json:"type"`
+	Enabled   bool                      `mapstructure:"enabled" json:"enabled"`
+	Events    []string                  `mapstructure:"events" json:"events"`
+	Format    string                    `mapstructure:"format" json:"format,omitempty"`
+	Language  string                    `mapstructure:"language" json:"language,omitempty"`
+	Templates map[string]NotifyTemplate `mapstructure:"templates" json:"templates,omitempty"`
+	URL       string                    `mapstructure:"url" json:"url,omitempty"`
+	Method    string                    `mapstructure:"method" json:"method,omitempty"`
+	Headers   map[string]string         `mapstructure:"headers" json:"headers,omitempty"`
+	Body      string                    `mapstructure:"body" json:"body,omitempty"`
+	Token     string                    `mapstructure:"token" json:"token,omitempty"`
+	ChatID    string                    `mapstructure:"chatId" json:"chatId,omitempty"`
+	Topic     string                    `mapstructure:"topic" json:"topic,omitempty"`
+	Secret    string                    `mapstructure:"secret" json:"secret,omitempty"`
+	Host      string                    `mapstructure:"host" json:"host,omitempty"`
+	Port      int                       `mapstructure:"port" json:"port,omitempty"`
+	Username  string                    `mapstructure:"username" json:"username,omitempty"`
+	Password  string                    `mapstructure:"password" json:"password,omitempty"`
+	From      string                    `mapstructure:"from" json:"from,omitempty"`
+	To        []string                  `mapstructure:"to" json:"to,omitempty"`
 }
 
 // ComposeConfig Docker Compose 相关配置
@@ -151,6 +308,108 @@ type ComposeConfig struct {
 	LogLines     int  `mapstructure:"logLines" json:"logLines"`
 }
 
+// MetricsConfig Prometheus 指标接口（/metrics）配置
+// enabled: 是否开启指标接口
+// token: 抓取时需携带的 Bearer token，与登录认证相互独立；为空则不校验
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled" json:"enabled"`
+	Token   string `mapstructure:"token" json:"token"`
+}
+
+// StatsHistoryConfig 容器资源历史配置
+// enabled: 是否记录资源历史（开启后持续采集容器资源统计）
+// retention10s / retention1m / retention1h: 10 秒、1 分钟、1 小时精度数据的保留时长（小时），0 表示不保留该精度
+type StatsHistoryConfig struct {
+	Enabled      bool `mapstructure:"enabled" json:"enabled"`
+	Retention10s int  `mapstructure:"retention10s" json:"retention10s"`
+	Retention1m  int  `mapstructure:"retention1m" json:"retention1m"`
+	Retention1h  int  `mapstructure:"retention1h" json:"retention1h"`
+}
+
+// HooksConfig CI webhook 触发更新（POST /api/v1/hooks/update/:token）
+// tokens: 访问令牌，独立于登录认证
+type HooksConfig struct {
+	Tokens []HookToken `mapstructure:"tokens" json:"tokens"`
+}
+
+// HookToken webhook 令牌
+// name: 名称，用于日志
+// token: 令牌值，至少 16 个字符
+// images: 允许触发更新的镜像仓库，支持通配符，如 ghcr.io/acme/*，留空表示不限制
+// endpoints: 允许更新的端点 ID，留空表示全部端点
+// revoked: 已吊销的令牌不再生效
+type HookToken struct {
+	Name      string   `mapstructure:"name" json:"name"`
+	Token     string   `mapstructure:"token" json:"token"`
+	Images    []string `mapstructure:"images" json:"images"`
+	Endpoints []string `mapstructure:"endpoints" json:"endpoints"`
+	Revoked   bool     `mapstructure:"revoked" json:"revoked"`
+}
+
+// AuditConfig 审计日志配置（记录保存在 CONFIG_PATH/audit 下）
+// enabled: 是否记录修改操作、终端会话、登录与配置变更
+// maxSizeMB: 单个文件的大小上限，超过后轮转，0 表示不轮转
+// maxBackups: 轮转后保留的历史文件数
+type AuditConfig struct {
+	Enabled    bool `mapstructure:"enabled" json:"enabled"`
+	MaxSizeMB  int  `mapstructure:"maxSizeMB" json:"maxSizeMB"`
+	MaxBackups int  `mapstructure:"maxBackups" json:"maxBackups"`
+}
+
+// RecordingConfig 终端会话录制配置（asciinema v2 格式，保存在 CONFIG_PATH/recordings 下）
+// enabled: 是否录制主机与容器终端会话
+// recordInput: 是否记录用户输入（可能包含在终端中输入的密码）
+// maxAgeDays: 录像保留天数，0 表示不按时间清理
+// maxTotalMB: 录像总大小上限，超过时删除最旧的录像，0 表示不限制
+type RecordingConfig struct {
+	Enabled     bool `mapstructure:"enabled" json:"enabled"`
+	RecordInput bool `mapstructure:"recordInput" json:"recordInput"`
+	MaxAgeDays  int  `mapstructure:"maxAgeDays" json:"maxAgeDays"`
+	MaxTotalMB  int  `mapstructure:"maxTotalMB" json:"maxTotalMB"`
+}
+
+// RBACConfig 角色访问限制（用户与角色保存在 CONFIG_PATH/users.json）
+// roles: 按角色限制可以查看和操作的容器，键为 operator 或 viewer，admin 不受限制
+type RBACConfig struct {
+	Roles map[string]RoleRestriction `mapstructure:"roles" json:"roles"`
+}
+
+// RoleRestriction 角色可以访问的容器：匹配任一标签或属于任一 compose 项目，两者都为空表示不限制
+// labels: 容器标签，格式 key 或 key=value
+// composeProjects: compose 项目名
+// 受限角色不能执行镜像、卷、网络等不属于单个容器的变更操作
+type RoleRestriction struct {
+	Labels          []string `mapstructure:"labels" json:"labels"`
+	ComposeProjects []string `mapstructure:"composeProjects" json:"composeProjects"`
+}
+
+// Restricted 是否配置了限制
+func (r RoleRestriction) Restricted() bool {
+	return len(r.Labels) > 0 || len(r.ComposeProjects) > 0
+}
+
+// ResourceAlertsConfig 容器资源告警配置
+// enabled: 是否开启资源告警（开启后持续采集容器资源统计）
+// rules: 告警规则；label 为空的规则对所有容器生效，容器匹配到带 label 的规则时，同一指标以带 label 的规则为准
+type ResourceAlertsConfig struct {
+	Enabled bool                `mapstructure:"enabled" json:"enabled"`
+	Rules   []ResourceAlertRule `mapstructure:"rules" json:"rules"`
+}
+
+// ResourceAlertRule 资源告警规则
+// name: 规则名称（为空时按指标与阈值生成）
+// label: 生效的容器标签，格式 key 或 key=value，为空表示全局规则
+// metric: cpu（CPU 使用率）| memory（内存占限制的百分比）| pids（进程数占 PidsLimit 的百分比）
+// threshold: 阈值（百分比），超过时触发
+// duration: 持续超过阈值多少秒后告警，0 表示立即告警
+type ResourceAlertRule struct {
+	Name      string  `mapstructure:"name" json:"name"`
+	Label     string  `mapstructure:"label" json:"label"`
+	Metric    string  `mapstructure:"metric" json:"metric"`
+	Threshold float64 `mapstructure:"threshold" json:"threshold"`
+	Duration  int     `mapstructure:"duration" json:"duration"`
+}
+
 // TwoFAUserConfig 用户二次验证配置
 type TwoFAUserConfig struct {
 	Method              string   `mapstructure:"method" json:"method"`
@@ -158,6 +417,52 @@ type TwoFAUserConfig struct {
 	WebAuthnCredentials []string `mapstructure:"webauthnCredentials" json:"webauthnCredentials,omitempty"` // Base64 编码的凭据
 }
 
+// OIDCConfig OIDC 单点登录配置（授权码 + PKCE）
+// issuer: IdP 地址，使用 <issuer>/.well-known/openid-configuration 发现端点
+// redirectUrl: 在 IdP 登记的回调地址，为空时按请求地址生成 <scheme>://<host>/api/v1/oidc/callback
+// usernameClaim: 作为本地用户名的声明，为空或缺失时使用 email
+// allowedGroups/allowedEmails: 允许登录的组与邮箱（@example.com 表示整个域名），都为空时允许所有 IdP 用户
+// roleGroups: 角色到组的映射，匹配多个时取最高角色；只同步 OIDC 创建的用户，未匹配时保留已有角色，新用户使用 defaultRole
+// defaultRole: 新用户未匹配任何组时的角色，为空表示拒绝登录
+// linkExistingUsers: 允许 IdP 用户关联同名的本地账号（或升级前创建、未记录 issuer/subject 的 OIDC 账号），关联后保留原角色
+// trustMFA: IdP 的 amr 声明表明已完成多因素认证时跳过本地二次验证
+type OIDCConfig struct {
+	Enabled       bool                `mapstructure:"enabled" json:"enabled"`
+	Issuer        string              `mapstructure:"issuer" json:"issuer"`
+	ClientID      string              `mapstructure:"clientId" json:"clientId"`
+	ClientSecret  string              `mapstructure:"clientSecret" json:"clientSecret"`
+	RedirectURL   string              `mapstructure:"redirectUrl" json:"redirectUrl"`
+	Scopes        []string            `mapstructure:"scopes" json:"scopes"`
+	UsernameClaim string              `mapstructure:"usernameClaim" json:"usernameClaim"`
+	GroupsClaim   string              `mapstructure:"groupsClaim" json:"groupsClaim"`
+	AllowedGroups []string            `mapstructure:"allowedGroups" json:"allowedGroups"`
+	AllowedEmails []string            `mapstructure:"allowedEmails" json:"allowedEmails"`
+	RoleGroups    map[string][]string `mapstructure:"roleGroups" json:"roleGroups"`
+	DefaultRole   string              `mapstructure:"defaultRole" json:"defaultRole"`
+	LinkExisting  bool                `mapstructure:"linkExistingUsers" json:"linkExistingUsers"`
+	TrustMFA      bool                `mapstructure:"trustMFA" json:"trustMFA"`
+	ButtonText    string              `mapstructure:"buttonText" json:"buttonText"`
+}
+
+// ProxyAuthConfig 反向代理身份头认证（Authelia、oauth2-proxy 等 forward auth）
+// header: 代理转发的用户名请求头，如 Remote-User 或 X-Forwarded-User
+// trustedProxies: 可信代理的 CIDR 或 IP，只有直接来自这些地址的请求才使用身份头
+// defaultRole: 本地不存在的用户自动创建时使用的角色，为空表示拒绝
+type ProxyAuthConfig struct {
+	Enabled        bool     `mapstructure:"enabled" json:"enabled"`
+	Header         string   `mapstructure:"header" json:"header"`
+	TrustedProxies []string `mapstructure:"trustedProxies" json:"trustedProxies"`
+	DefaultRole    string   `mapstructure:"defaultRole" json:"defaultRole"`
+}
+
+// SessionConfig 登录会话配置（会话保存在 CONFIG_PATH/sessions.json）
+// accessTokenMinutes: 访问令牌有效期（分钟），过期后前端使用刷新令牌换取新的访问令牌
+// refreshTokenDays: 刷新令牌有效期（天），期间没有刷新则需要重新登录
+type SessionConfig struct {
+	AccessTokenMinutes int `mapstructure:"accessTokenMinutes" json:"accessTokenMinutes"`
+	RefreshTokenDays   int `mapstructure:"refreshTokenDays" json:"refreshTokenDays"`
+}
+
 // TwoFAConfig 二次验证配置
 type TwoFAConfig struct {
 	Users map[string]TwoFAUserConfig `mapstructure:"users" json:"users"`
@@ -165,16 +470,28 @@ type TwoFAConfig struct {
 
 // Config 顶层配置聚合
 type Config struct {
-	Server      ServerConfig       `mapstructure:"server" json:"server"`
-	Docker      DockerConfig       `mapstructure:"docker" json:"docker"`
-	Scan        ScanConfig         `mapstructure:"scan" json:"scan"`
-	Policy      PolicyConfig       `mapstructure:"policy" json:"policy"`
-	Registry    RegistryConfig     `mapstructure:"registry" json:"registry"`
-	Proxy       ProxyConfig        `mapstructure:"proxy" json:"proxy"`
-	Logging     LoggingConfig      `mapstructure:"logging" json:"logging"`
-	Notify      NotificationConfig `mapstructure:"notify" json:"notify"`
-	Compose     ComposeConfig      `mapstructure:"compose" json:"compose"`
-	TwoFAConfig TwoFAConfig        `mapstructure:"twofaConfig" json:"twofaConfig"`
+	Server         ServerConfig         `mapstructure:"server" json:"server"`
+	Docker         DockerConfig         `mapstructure:"docker" json:"docker"`
+	Scan           ScanConfig           `mapstructure:"scan" json:"scan"`
+	Policy         PolicyConfig         `mapstructure:"policy" json:"policy"`
+	Update         UpdateConfig         `mapstructure:"update" json:"update"`
+	Schedule       ScheduleConfig       `mapstructure:"schedule" json:"schedule"`
+	Registry       RegistryConfig       `mapstructure:"registry" json:"registry"`
+	Proxy          ProxyConfig          `mapstructure:"proxy" json:"proxy"`
+	Logging        LoggingConfig        `mapstructure:"logging" json:"logging"`
+	Notify         NotificationConfig   `mapstructure:"notify" json:"notify"`
+	Compose        ComposeConfig        `mapstructure:"compose" json:"compose"`
+	Metrics        MetricsConfig        `mapstructure:"metrics" json:"metrics"`
+	StatsHistory   StatsHistoryConfig   `mapstructure:"statsHistory" json:"statsHistory"`
+	ResourceAlerts ResourceAlertsConfig `mapstructure:"resourceAlerts" json:"resourceAlerts"`
+	Hooks          HooksConfig          `mapstructure:"hooks" json:"hooks"`
+	RBAC           RBACConfig           `mapstructure:"rbac" json:"rbac"`
+	Audit          AuditConfig          `mapstructure:"audit" json:"audit"`
+	Recording      RecordingConfig      `mapstructure:"recording" json:"recording"`
+	OIDC           OIDCConfig           `mapstructure:"oidc" json:"oidc"`
+	ProxyAuth      ProxyAuthConfig      `mapstructure:"proxyAuth" json:"proxyAuth"`
+	Session        SessionConfig        `mapstructure:"session" json:"session"`
+	TwoFAConfig    TwoFAConfig          `mapstructure:"twofaConfig" json:"twofaConfig"`
 }
 
 var (
@@ -223,14 +540,63 @@ func defaults() *Config {
 			SkipSemverPinned: true,
 			FloatingTags:     []string{"latest", "main", "stable"},
 		},
+		Update: UpdateConfig{
+			Verify:        false,
+			HealthTimeout: 60,
+			StableSeconds: 10,
+		},
 		Proxy:   ProxyConfig{}, // 默认不使用代理
 		Logging: LoggingConfig{Level: "info"},
-		Notify:  NotificationConfig{Method: http.MethodGet, IsEnable: true},
+		Notify: NotificationConfig{
+			Method:   http.MethodGet,
+			IsEnable: true,
+			ContainerEvents: ContainerEventsConfig{
+				RestartLoopCount:   3,
+				RestartLoopMinutes: 5,
+				CooldownMinutes:    30,
+				Died:               ContainerEventRule{Enabled: true},
+				OOM:                ContainerEventRule{Enabled: true},
+				Unhealthy:          ContainerEventRule{Enabled: true},
+				RestartLoop:        ContainerEventRule{Enabled: true},
+				Removed:            ContainerEventRule{Enabled: true},
+			},
+		},
 		Compose: ComposeConfig{
 			Enabled:      true,
 			ScanInterval: 30,
 			LogLines:     100,
 		},
+		StatsHistory: StatsHistoryConfig{
+			Enabled:      true,
+			Retention10s: 24,
+			Retention1m:  24 * 7,
+			Retention1h:  24 * 90,
+		},
+		Audit: AuditConfig{
+			Enabled:    true,
+			MaxSizeMB:  50,
+			MaxBackups: 5,
+		},
+		Recording: RecordingConfig{
+			Enabled:     false,
+			RecordInput: true,
+			MaxAgeDays:  30,
+			MaxTotalMB:  1024,
+		},
+		OIDC: OIDCConfig{
+			Scopes:        []string{"openid", "profile", "email", "groups"},
+			UsernameClaim: "preferred_username",
+			GroupsClaim:   "groups",
+			TrustMFA:      true,
+			ButtonText:    "SSO 登录",
+		},
+		ProxyAuth: ProxyAuthConfig{
+			Header: "Remote-User",
+		},
+		Session: SessionConfig{
+			AccessTokenMinutes: 15,
+			RefreshTokenDays:   30,
+		},
 		TwoFAConfig: TwoFAConfig{
 			Users: make(map[string]TwoFAUserConfig),
 		},
@@ -273,6 +639,130 @@ func validate(cfg *Config) error {
 	if cfg.Scan.Concurrency <= 0 {
 		return fmt.Errorf("scan.concurrency must be > 0")
 	}
+	switch mode := strings.ToLower(strings.TrimSpace(cfg.Policy.SemverMode)); mode {
+	case "", "patch", "minor", "major":
+		cfg.Policy.SemverMode = mode
+	default:
+		return fmt.Errorf("policy.semverMode must be one of patch/minor/major")
+	}
+	if cfg.Update.HealthTimeout < 0 || cfg.Update.StableSeconds < 0 || cfg.Update.KeepImages < 0 {
+		return fmt.Errorf("update.healthTimeout, update.stableSeconds and update.keepImages must be >= 0")
+	}
+	if h := cfg.StatsHistory; h.Retention10s < 0 || h.Retention1m < 0 || h.Retention1h < 0 {
+		return fmt.Errorf("statsHistory retention must be >= 0")
+	}
+	for i, r := range cfg.ResourceAlerts.Rules {
+		switch r.Metric {
+		case "cpu", "memory", "pids":
+		default:
+			return fmt.Errorf("resourceAlerts.rules[%d].metric must be one of cpu/memory/pids", i)
+		}
+		if r.Threshold <= 0 || r.Duration < 0 {
+			return fmt.Errorf("resourceAlerts.rules[%d] requires threshold > 0 and duration >= 0", i)
+		}
+	}
+	seen := make(map[string]bool)
+	for i, ep := range cfg.Docker.Endpoints {
+		if !validEndpointID(ep.ID) || reservedEndpointIDs[ep.ID] || strings.HasPrefix(ep.ID, AgentEndpointPrefix) {
+			return fmt.Errorf("docker.endpoints[%d].id %q is invalid or reserved", i, ep.ID)
+		}
+		if seen[ep.ID] {
+			return fmt.Errorf("docker.endpoints[%d].id %q is duplicated", i, ep.ID)
+		}
+		seen[ep.ID] = true
+		if strings.TrimSpace(ep.Host) == "" {
+			return fmt.Errorf("docker.endpoints[%d].host is required", i)
+		}
+		if (ep.TLSCert == "") != (ep.TLSKey == "") {
+			return fmt.Errorf("docker.endpoints[%d] requires both tlsCert and tlsKey", i)
+		}
+	}
+	tokens := make(map[string]bool)
+	for i, t := range cfg.Hooks.Tokens {
+		if len(t.Token) < 16 {
+			return fmt.Errorf("hooks.tokens[%d].token must be at least 16 characters", i)
+		}
+		if tokens[t.Token] {
+			return fmt.Errorf("hooks.tokens[%d].token is duplicated", i)
+		}
+		tokens[t.Token] = true
+	}
+	if cfg.Audit.MaxSizeMB < 0 || cfg.Audit.MaxBackups < 0 {
+		return fmt.Errorf("audit.maxSizeMB and audit.maxBackups must be >= 0")
+	}
+	if cfg.Recording.MaxAgeDays < 0 || cfg.Recording.MaxTotalMB < 0 {
+		return fmt.Errorf("recording.maxAgeDays and recording.maxTotalMB must be >= 0")
+	}
+	if cfg.OIDC.Enabled {
+		if cfg.OIDC.Issuer == "" || cfg.OIDC.ClientID == "" {
+			return fmt.Errorf("oidc.issuer and oidc.clientId are required when oidc is enabled")
+		}
+		if !slices.Contains(cfg.OIDC.Scopes, "openid") {
+			return fmt.Errorf("oidc.scopes must include openid")
+		}
+		if cfg.OIDC.GroupsClaim == "" {
+			cfg.OIDC.GroupsClaim = "groups"
+		}
+	}
+	for role := range cfg.OIDC.RoleGroups {
+		if role != "admin" && role != "operator" && role != "viewer" {
+			return fmt.Errorf("oidc.roleGroups.%s: unknown role", role)
+		}
+	}
+	switch cfg.OIDC.DefaultRole {
+	case "", "admin", "operator", "viewer":
+	default:
+		return fmt.Errorf("oidc.defaultRole: unknown role %q", cfg.OIDC.DefaultRole)
+	}
+	if cfg.ProxyAuth.Enabled {
+		if cfg.ProxyAuth.Header == "" || len(cfg.ProxyAuth.TrustedProxies) == 0 {
+			return fmt.Errorf("proxyAuth.header and proxyAuth.trustedProxies are required when proxyAuth is enabled")
+		}
+		for _, p := range cfg.ProxyAuth.TrustedProxies {
+			if _, err := ParseCIDROrIP(p); err != nil {
+				return fmt.Errorf("proxyAuth.trustedProxies: invalid CIDR or IP %q", p)
+			}
+		}
+	}
+	switch cfg.ProxyAuth.DefaultRole {
+	case "", "admin", "operator", "viewer":
+	default:
+		return fmt.Errorf("proxyAuth.defaultRole: unknown role %q", cfg.ProxyAuth.DefaultRole)
+	}
+	if cfg.Session.AccessTokenMinutes <= 0 || cfg.Session.RefreshTokenDays <= 0 {
+		return fmt.Errorf("session.accessTokenMinutes and session.refreshTokenDays must be > 0")
+	}
+	for role := range cfg.RBAC.Roles {
+		if role != "operator" && role != "viewer" {
+			return fmt.Errorf("rbac.roles.%s: only operator and viewer can be restricted", role)
+		}
+	}
+	for i, r := range cfg.Schedule.Rules {
+		if strings.TrimSpace(r.Label) == "" || strings.TrimSpace(r.Cron) == "" {
+			return fmt.Errorf("schedule.rules[%d] requires label and cron", i)
+		}
+	}
+	for i := range cfg.Schedule.MaintenanceWindows {
+		w := &cfg.Schedule.MaintenanceWindows[i]
+		if _, err := time.Parse("15:04", w.Start); err != nil {
+			return fmt.Errorf("schedule.maintenanceWindows[%d].start must be HH:MM", i)
+		}
+		if _, err := time.Parse("15:04", w.End); err != nil {
+			return fmt.Errorf("schedule.maintenanceWindows[%d].end must be HH:MM", i)
+		}
+		for j, d := range w.Days {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if len(d) > 3 {
+				d = d[:3]
+			}
+			switch d {
+			case "sun", "mon", "tue", "wed", "thu", "fri", "sat":
+			default:
+				return fmt.Errorf("schedule.maintenanceWindows[%d].days has invalid day %q", i, w.Days[j])
+			}
+			w.Days[j] = d
+		}
+	}
 	if strings.TrimSpace(cfg.Notify.URL) != "" {
 		method := strings.ToUpper(strings.TrimSpace(cfg.Notify.Method))
 		switch method {
@@ -284,6 +774,38 @@ func validate(cfg *Config) error {
 			return fmt.Errorf("notify.method must be GET or POST")
 		}
 	}
+	names := make(map[string]bool)
+	for i := range cfg.Notify.Channels {
+		ch := &cfg.Notify.Channels[i]
+		ch.Type = strings.ToLower(strings.TrimSpace(ch.Type))
+		switch ch.Type {
+		case "webhook", "telegram", "bark", "serverchan", "wecom", "dingtalk", "feishu", "gotify", "ntfy", "email", "apprise":
+		default:
+			return fmt.Errorf("notify.channels[%d].type %q is not supported", i, ch.Type)
+		}
+		ch.Name = strings.TrimSpace(ch.Name)
+		if ch.Name == "" {
+			ch.Name = fmt.Sprintf("%s-%d", ch.Type, i+1)
+		}
+		if names[ch.Name] {
+			return fmt.Errorf("notify.channels name %q is duplicated", ch.Name)
+		}
+		names[ch.Name] = true
+		switch ch.Format = strings.ToLower(strings.TrimSpace(ch.Format)); ch.Format {
+		case "", "text", "markdown", "html":
+		default:
+			return fmt.Errorf("notify.channels[%d].format must be one of text/markdown/html", i)
+		}
+	}
+	if ev := cfg.Notify.ContainerEvents; ev.RestartLoopCount < 0 || ev.RestartLoopMinutes < 0 || ev.CooldownMinutes < 0 {
+		return fmt.Errorf("notify.containerEvents.restartLoopCount, restartLoopMinutes and cooldownMinutes must be >= 0")
+	}
+	switch lang := strings.ToLower(strings.TrimSpace(cfg.Notify.Language)); lang {
+	case "", "zh", "en":
+		cfg.Notify.Language = lang
+	default:
+		return fmt.Errorf("notify.language must be zh or en")
+	}
 	return nil
 }
 
@@ -310,3 +832,30 @@ func Save() error {
 
 	return nil
 }
+
+// validEndpointID 端点 ID 只能包含字母、数字、- 和 _
+func validEndpointID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseCIDROrIP 解析 CIDR 或单个 IP（视为 /32 或 /128）
+func ParseCIDROrIP(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		return p.Masked(), err
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
@@ -2,7 +2,10 @@ package dockercli
 
 import (
 	"context"
+	"net"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
@@ -11,6 +14,9 @@ import (
 type Client struct {
 	docker       *client.Client
 	statsManager *StatsManager
+
+	expectMu sync.Mutex
+	expected map[string]time.Time // 本程序主动停止/删除的容器，用于忽略对应的 Docker 事件
 }
 
 func New(ctx context.Context, host string) (*Client, error) {
@@ -23,6 +29,44 @@ func NewWithStatsConfig(ctx context.Context, host string, statsConfig StatsManag
 	if strings.TrimSpace(host) != "" {
 		opts = append(opts, client.WithHost(host))
 	}
+	return newClient(opts, statsConfig)
+}
+
+// EndpointOptions 远程 Docker 主机的连接参数
+// Host 支持 unix://、tcp:// 与 ssh://；TLSCA/TLSCert/TLSKey 用于 tcp 的 TLS 客户端证书认证。
+// 设置 Dialer 时忽略 Host，所有连接由 Dialer 建立（如经 agent 隧道）。
+type EndpointOptions struct {
+	Host    string
+	TLSCA   string
+	TLSCert string
+	TLSKey  string
+	Dialer  func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// NewEndpoint 按端点参数创建 Docker 客户端，不读取 DOCKER_* 环境变量。
+// ssh:// 通过本机 ssh 命令在远端执行 docker system dial-stdio 建立连接。
+func NewEndpoint(ctx context.Context, ep EndpointOptions) (*Client, error) {
+	opts := []client.Opt{client.WithAPIVersionNegotiation()}
+	dialer := ep.Dialer
+	if dialer == nil && strings.HasPrefix(ep.Host, "ssh://") {
+		var err error
+		if dialer, err = sshDialer(ep.Host); err != nil {
+			return nil, err
+		}
+	}
+	if dialer != nil {
+		// 实际连接由 dialer 建立，这里的地址只用于构造请求 URL
+		opts = append(opts, client.WithHost("http://docker.example.com"), client.WithDialContext(dialer))
+	} else {
+		opts = append(opts, client.WithHost(ep.Host))
+		if ep.TLSCA != "" || ep.TLSCert != "" {
+			opts = append(opts, client.WithTLSClientConfig(ep.TLSCA, ep.TLSCert, ep.TLSKey))
+		}
+	}
+	return newClient(opts, StatsManagerConfig{})
+}
+
+func newClient(opts []client.Opt, statsConfig StatsManagerConfig) (*Client, error) {
 	dockerClient, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, err
@@ -68,6 +112,11 @@ func (c *Client) GetContainerStats(ctx context.Context, id string) (*ContainerSt
 	return c.statsManager.GetContainerStats(ctx, id), nil
 }
 
+// GetAllContainersStats 获取缓存中所有容器的统计信息
+func (c *Client) GetAllContainersStats() []ContainerStats {
+	return c.statsManager.GetAllStats()
+}
+
 // GetContainersStats 获取多个容器统计信息
 func (c *Client) GetContainersStats(ctx context.Context, containerIDs []string) (map[string]*ContainerStats, error) {
 	return c.statsManager.GetContainersStats(ctx, containerIDs)
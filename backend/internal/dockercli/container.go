@@ -187,6 +187,39 @@ func hasNamedRepoTag(tags []string) bool {
 	return false
 }
 
+// ListContainerLabels 返回容器名称到标签的映射，只调用一次 ContainerList，开销远小于 ListContainers
+func (c *Client) ListContainerLabels(ctx context.Context, includeStopped bool) (map[string]map[string]string, error) {
+	containers, err := c.docker.ContainerList(ctx, container.ListOptions{All: includeStopped})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]map[string]string, len(containers))
+	for _, ct := range containers {
+		if len(ct.Names) == 0 {
+			continue
+		}
+		result[strings.TrimPrefix(ct.Names[0], "/")] = ct.Labels
+	}
+	return result, nil
+}
+
+// InspectContainers 返回全部容器的详细信息，inspect 失败的容器会被跳过
+func (c *Client) InspectContainers(ctx context.Context, includeStopped bool) ([]container.InspectResponse, error) {
+	containers, err := c.docker.ContainerList(ctx, container.ListOptions{All: includeStopped})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]container.InspectResponse, 0, len(containers))
+	for _, ct := range containers {
+		info, err := c.docker.ContainerInspect(ctx, ct.ID)
+		if err != nil {
+			continue
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
 // InspectContainer 返回容器的详细信息
 func (c *Client) InspectContainer(ctx context.Context, id string) (container.InspectResponse, error) {
 	return c.docker.ContainerInspect(ctx, id)
@@ -194,6 +227,7 @@ func (c *Client) InspectContainer(ctx context.Context, id string) (container.Ins
 
 // StopContainer 停止容器（可选超时时间，单位秒）
 func (c *Client) StopContainer(ctx context.Context, id string, timeoutSeconds int) error {
+	c.expectEvents(id)
 	var timeout *int
 	if timeoutSeconds > 0 {
 		t := timeoutSeconds
@@ -205,6 +239,7 @@ func (c *Client) StopContainer(ctx context.Context, id string, timeoutSeconds in
 
 // RestartContainer 重启容器（可选超时时间，单位秒）
 func (c *Client) RestartContainer(ctx context.Context, id string, timeoutSeconds int) error {
+	c.expectEvents(id)
 	var timeout *int
 	if timeoutSeconds > 0 {
 		t := timeoutSeconds
@@ -235,11 +270,13 @@ func (c *Client) StartContainer(ctx context.Context, id string) error {
 
 // RemoveContainer 删除容器
 func (c *Client) RemoveContainer(ctx context.Context, id string, force bool) error {
+	c.expectEvents(id)
 	return c.docker.ContainerRemove(ctx, id, container.RemoveOptions{Force: force, RemoveVolumes: false, RemoveLinks: false})
 }
 
 // RemoveContainerWithVolumes 删除容器并清理关联的匿名卷
 func (c *Client) RemoveContainerWithVolumes(ctx context.Context, id string, force bool) error {
+	c.expectEvents(id)
 	return c.docker.ContainerRemove(ctx, id, container.RemoveOptions{
 		Force:         force,
 		RemoveVolumes: true,
@@ -441,6 +478,48 @@ func (c *Client) CleanupContainerResources(ctx context.Context, containerInfo co
 	return nil
 }
 
+// CleanupPlan CleanupContainerResources 将会删除的资源
+type CleanupPlan struct {
+	Image   string   `json:"image,omitempty"`
+	Volumes []string `json:"volumes,omitempty"`
+}
+
+// PlanCleanupResources 返回删除该容器后 CleanupContainerResources 会删除的资源，不做任何修改。
+// 判断规则与 SafeRemoveImage/SafeRemoveVolumes 一致，容器自身不算作使用者。
+func (c *Client) PlanCleanupResources(ctx context.Context, containerInfo container.InspectResponse) (CleanupPlan, error) {
+	var plan CleanupPlan
+	containers, err := c.docker.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return plan, err
+	}
+
+	imageInUse := false
+	volumesInUse := make(map[string]bool)
+	for _, ct := range containers {
+		if ct.ID == containerInfo.ID {
+			continue
+		}
+		if ct.ImageID == containerInfo.Image {
+			imageInUse = true
+		}
+		for _, m := range ct.Mounts {
+			if m.Name != "" {
+				volumesInUse[m.Name] = true
+			}
+		}
+	}
+	if containerInfo.Image != "" && !imageInUse {
+		plan.Image = containerInfo.Image
+	}
+	for _, mount := range containerInfo.Mounts {
+		// 与 SafeRemoveVolumes 一致：只删除未被其他容器使用的匿名卷
+		if mount.Type == "volume" && len(mount.Name) >= 40 && !volumesInUse[mount.Name] {
+			plan.Volumes = append(plan.Volumes, mount.Name)
+		}
+	}
+	return plan, nil
+}
+
 // ContainerLogs 获取容器日志流
 func (c *Client) ContainerLogs(ctx context.Context, containerID string, since string, timestamps bool, tail string, follow bool) (io.ReadCloser, error) {
 	options := container.LogsOptions{
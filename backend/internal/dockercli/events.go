@@ -0,0 +1,216 @@
+package dockercli
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"go.uber.org/zap"
+)
+
+// ContainerEventType 需要通知的容器生命周期事件
+type ContainerEventType string
+
+const (
+	ContainerDied        ContainerEventType = "died"         // 容器以非 0 退出码退出
+	ContainerOOM         ContainerEventType = "oom"          // 容器因内存不足被杀死
+	ContainerUnhealthy   ContainerEventType = "unhealthy"    // 健康检查变为 unhealthy
+	ContainerRestartLoop ContainerEventType = "restart_loop" // 短时间内反复重启
+	ContainerRemoved     ContainerEventType = "removed"      // 容器被删除
+)
+
+const (
+	// expectedEventTTL 本程序主动停止/删除容器后，在该时间内忽略对应的退出与删除事件
+	expectedEventTTL = 2 * time.Minute
+	// eventsRetryDelay 事件流断开后的重连间隔
+	eventsRetryDelay = 5 * time.Second
+)
+
+// ContainerEvent 从 Docker 事件流中识别出的容器生命周期事件
+type ContainerEvent struct {
+	Type        ContainerEventType
+	ContainerID string
+	Name        string
+	Image       string
+	Labels      map[string]string
+	ExitCode    string // 仅 died 事件
+	Restarts    int    // 仅 restart_loop 事件：窗口内的启动次数
+	Time        time.Time
+}
+
+// expectEvents 标记容器即将被本程序停止或删除，对应的退出与删除事件不再通知
+func (c *Client) expectEvents(id string) {
+	c.expectMu.Lock()
+	defer c.expectMu.Unlock()
+	if c.expected == nil {
+		c.expected = make(map[string]time.Time)
+	}
+	now := time.Now()
+	for k, t := range c.expected {
+		if now.Sub(t) > expectedEventTTL {
+			delete(c.expected, k)
+		}
+	}
+	c.expected[id] = now
+}
+
+// ExpectEvents 标记容器即将被外部命令（如 docker compose）停止或删除，对应事件不再通知
+func (c *Client) ExpectEvents(id string) {
+	c.expectEvents(id)
+}
+
+// isExpected 判断事件是否由本程序的操作引起（按完整 ID、ID 前缀或容器名匹配）
+func (c *Client) isExpected(id, name string) bool {
+	c.expectMu.Lock()
+	defer c.expectMu.Unlock()
+	now := time.Now()
+	for k, t := range c.expected {
+		if now.Sub(t) > expectedEventTTL {
+			continue
+		}
+		if k == name || strings.HasPrefix(id, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchContainerEvents 订阅 Docker /events 容器事件，识别出生命周期事件后调用 handler。
+// 阻塞直到 ctx 结束，事件流断开时自动重连。
+func (c *Client) WatchContainerEvents(ctx context.Context, handler func(ContainerEvent)) {
+	tracker := newEventTracker()
+	opts := events.ListOptions{Filters: filters.NewArgs(filters.Arg("type", string(events.ContainerEventType)))}
+	for {
+		msgs, errs := c.docker.Events(ctx, opts)
+		logger.Logger.Debug("已订阅 Docker 事件流")
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errs:
+				if ctx.Err() != nil {
+					return
+				}
+				logger.Logger.Warn("Docker 事件流断开，稍后重连", zap.Error(err))
+				break loop
+			case msg := <-msgs:
+				ev, ok := tracker.process(msg)
+				if !ok {
+					continue
+				}
+				if (ev.Type == ContainerDied || ev.Type == ContainerRemoved || ev.Type == ContainerRestartLoop) && c.isExpected(ev.ContainerID, ev.Name) {
+					logger.Logger.Debug("忽略由本程序操作引起的容器事件",
+						zap.String("container", ev.Name),
+						zap.String("type", string(ev.Type)))
+					continue
+				}
+				handler(ev)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventsRetryDelay):
+		}
+	}
+}
+
+// eventTracker 将 Docker 原始事件转换为 ContainerEvent，并记录启动时间用于识别重启循环
+type eventTracker struct {
+	mu     sync.Mutex
+	starts map[string][]time.Time
+}
+
+func newEventTracker() *eventTracker {
+	return &eventTracker{starts: make(map[string][]time.Time)}
+}
+
+func (t *eventTracker) process(msg events.Message) (ContainerEvent, bool) {
+	if msg.Type != events.ContainerEventType {
+		return ContainerEvent{}, false
+	}
+	attrs := msg.Actor.Attributes
+	ev := ContainerEvent{
+		ContainerID: msg.Actor.ID,
+		Name:        attrs["name"],
+		Image:       attrs["image"],
+		Labels:      make(map[string]string, len(attrs)),
+		Time:        time.Unix(0, msg.TimeNano),
+	}
+	if msg.TimeNano == 0 {
+		ev.Time = time.Now()
+	}
+	// 容器事件的 Attributes 中除 name/image/exitCode 等字段外，其余均为容器标签
+	for k, v := range attrs {
+		switch k {
+		case "name", "image", "exitCode", "signal", "execDuration":
+		default:
+			ev.Labels[k] = v
+		}
+	}
+
+	action := string(msg.Action)
+	switch {
+	case msg.Action == events.ActionDie:
+		if code := attrs["exitCode"]; code != "" && code != "0" {
+			ev.Type = ContainerDied
+			ev.ExitCode = code
+			return ev, true
+		}
+	case msg.Action == events.ActionOOM:
+		ev.Type = ContainerOOM
+		return ev, true
+	case strings.HasPrefix(action, string(events.ActionHealthStatus)):
+		if strings.TrimSpace(strings.TrimPrefix(action, string(events.ActionHealthStatus)+":")) == "unhealthy" {
+			ev.Type = ContainerUnhealthy
+			return ev, true
+		}
+	case msg.Action == events.ActionDestroy:
+		t.mu.Lock()
+		delete(t.starts, ev.ContainerID)
+		t.mu.Unlock()
+		ev.Type = ContainerRemoved
+		return ev, true
+	case msg.Action == events.ActionStart:
+		if n, ok := t.recordStart(ev.ContainerID, ev.Time); ok {
+			ev.Type = ContainerRestartLoop
+			ev.Restarts = n
+			return ev, true
+		}
+	}
+	return ContainerEvent{}, false
+}
+
+// recordStart 记录一次启动，窗口内启动次数达到阈值时返回 true 并重新计数
+func (t *eventTracker) recordStart(id string, at time.Time) (int, bool) {
+	cfg := config.Get().Notify.ContainerEvents
+	threshold := cfg.RestartLoopCount
+	window := time.Duration(cfg.RestartLoopMinutes) * time.Minute
+	if threshold <= 0 || window <= 0 {
+		return 0, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	starts := t.starts[id][:0]
+	for _, s := range t.starts[id] {
+		if at.Sub(s) <= window {
+			starts = append(starts, s)
+		}
+	}
+	starts = append(starts, at)
+	if len(starts) >= threshold {
+		delete(t.starts, id)
+		return len(starts), true
+	}
+	t.starts[id] = starts
+	return 0, false
+}
@@ -0,0 +1,84 @@
+package dockercli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"github.com/docker/docker/api/types/events"
+	"go.uber.org/zap"
+)
+
+func containerMsg(action events.Action, attrs map[string]string, at time.Time) events.Message {
+	return events.Message{
+		Type:     events.ContainerEventType,
+		Action:   action,
+		Actor:    events.Actor{ID: "abc123", Attributes: attrs},
+		TimeNano: at.UnixNano(),
+	}
+}
+
+func TestEventTrackerClassifiesLifecycleEvents(t *testing.T) {
+	tr := newEventTracker()
+	now := time.Now()
+	attrs := map[string]string{"name": "web", "image": "nginx", "exitCode": "1", "com.example.team": "ops"}
+
+	ev, ok := tr.process(containerMsg(events.ActionDie, attrs, now))
+	if !ok || ev.Type != ContainerDied || ev.ExitCode != "1" || ev.Labels["com.example.team"] != "ops" {
+		t.Fatalf("expected died event with labels, got %+v ok=%v", ev, ok)
+	}
+	if _, ok := ev.Labels["exitCode"]; ok {
+		t.Fatal("exitCode should not be treated as a label")
+	}
+
+	clean := map[string]string{"name": "web", "exitCode": "0"}
+	if _, ok := tr.process(containerMsg(events.ActionDie, clean, now)); ok {
+		t.Fatal("exit code 0 should not be reported")
+	}
+
+	if ev, ok := tr.process(containerMsg("health_status: unhealthy", attrs, now)); !ok || ev.Type != ContainerUnhealthy {
+		t.Fatalf("expected unhealthy event, got %+v ok=%v", ev, ok)
+	}
+	if _, ok := tr.process(containerMsg("health_status: healthy", attrs, now)); ok {
+		t.Fatal("healthy status should not be reported")
+	}
+	if ev, ok := tr.process(containerMsg(events.ActionOOM, attrs, now)); !ok || ev.Type != ContainerOOM {
+		t.Fatalf("expected oom event, got %+v ok=%v", ev, ok)
+	}
+	if ev, ok := tr.process(containerMsg(events.ActionDestroy, attrs, now)); !ok || ev.Type != ContainerRemoved {
+		t.Fatalf("expected removed event, got %+v ok=%v", ev, ok)
+	}
+}
+
+func TestEventTrackerDetectsRestartLoop(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	old := config.Get()
+	t.Cleanup(func() { config.SetGlobal(old) })
+	cfg := *old
+	cfg.Notify.ContainerEvents.RestartLoopCount = 3
+	cfg.Notify.ContainerEvents.RestartLoopMinutes = 5
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	config.SetGlobal(&cfg)
+
+	tr := newEventTracker()
+	base := time.Now()
+	attrs := map[string]string{"name": "web"}
+
+	// 窗口外的旧启动不计入
+	tr.process(containerMsg(events.ActionStart, attrs, base.Add(-10*time.Minute)))
+	tr.process(containerMsg(events.ActionStart, attrs, base))
+	if _, ok := tr.process(containerMsg(events.ActionStart, attrs, base.Add(time.Minute))); ok {
+		t.Fatal("two starts inside the window should not be a restart loop")
+	}
+	ev, ok := tr.process(containerMsg(events.ActionStart, attrs, base.Add(2*time.Minute)))
+	if !ok || ev.Type != ContainerRestartLoop || ev.Restarts != 3 {
+		t.Fatalf("expected restart loop with 3 starts, got %+v ok=%v", ev, ok)
+	}
+	// 告警后重新计数
+	if _, ok := tr.process(containerMsg(events.ActionStart, attrs, base.Add(3*time.Minute))); ok {
+		t.Fatal("counter should reset after a restart loop is reported")
+	}
+}
@@ -195,6 +195,11 @@ func (c *Client) RemoveImage(ctx context.Context, ref string, force bool, pruneC
 	return err
 }
 
+// TagImage 为镜像（ID 或引用）打上新的标签，标签已存在时改为指向该镜像
+func (c *Client) TagImage(ctx context.Context, source, target string) error {
+	return c.docker.ImageTag(ctx, source, target)
+}
+
 // ExportImage 导出镜像为 tar 包流
 func (c *Client) ExportImage(ctx context.Context, ref string) (io.ReadCloser, error) {
 	return c.docker.ImageSave(ctx, []string{ref})
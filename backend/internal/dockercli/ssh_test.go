@@ -0,0 +1,14 @@
+package dockercli
+
+import "testing"
+
+func TestSSHDialerValidatesHost(t *testing.T) {
+	for _, host := range []string{"ssh://", "ssh://user@host/var/run/docker.sock"} {
+		if _, err := sshDialer(host); err == nil {
+			t.Fatalf("sshDialer(%q) should fail", host)
+		}
+	}
+	if _, err := sshDialer("ssh://deploy@docker-2:2222"); err != nil {
+		t.Fatalf("sshDialer: %v", err)
+	}
+}
@@ -192,6 +192,18 @@ func (sm *StatsManager) GetContainersStats(ctx context.Context, containerIDs []s
 	return statsMap, nil
 }
 
+// GetAllStats 返回缓存中所有容器的统计信息副本，尚未采集到数据的容器不包含在内
+func (sm *StatsManager) GetAllStats() []ContainerStats {
+	sm.statsMutex.RLock()
+	defer sm.statsMutex.RUnlock()
+
+	all := make([]ContainerStats, 0, len(sm.statsCache))
+	for _, stats := range sm.statsCache {
+		all = append(all, *stats)
+	}
+	return all
+}
+
 // statsMonitoringLoop 后台统计监控循环
 func (sm *StatsManager) statsMonitoringLoop(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Second)
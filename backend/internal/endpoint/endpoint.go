@@ -0,0 +1,327 @@
+// Package endpoint 管理多个 Docker 主机端点。
+// 每个端点有独立的 Docker 客户端、扫描器、更新器、调度器、资源历史与资源告警，并各自订阅容器事件；
+// registry 客户端与通知管理器在端点间共用。
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/alerting"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	"github.com/jianxcao/watch-docker/backend/internal/history"
+	"github.com/jianxcao/watch-docker/backend/internal/notificationmanager"
+	"github.com/jianxcao/watch-docker/backend/internal/registry"
+	"github.com/jianxcao/watch-docker/backend/internal/scanner"
+	"github.com/jianxcao/watch-docker/backend/internal/scheduler"
+	"github.com/jianxcao/watch-docker/backend/internal/statshistory"
+	"github.com/jianxcao/watch-docker/backend/internal/updater"
+
+	"go.uber.org/zap"
+)
+
+// healthTimeout 单个端点健康检查的超时时间
+const healthTimeout = 5 * time.Second
+
+// Endpoint 一个 Docker 主机端点
+type Endpoint struct {
+	ID        string
+	Name      string
+	Host      string
+	Docker    *dockercli.Client
+	Scanner   *scanner.Scanner
+	Updater   *updater.Updater
+	Scheduler *scheduler.Scheduler
+	// StatsHistory 资源历史（是否记录由 statsHistory.enabled 控制）
+	StatsHistory *statshistory.Store
+	// Alerts 资源告警（是否评估由 resourceAlerts.enabled 控制）
+	Alerts *alerting.Evaluator
+	Err    error // 创建客户端失败的原因，此时上面的组件均为 nil
+
+	monitorMu     sync.Mutex
+	cancelMonitor context.CancelFunc
+}
+
+// Available 端点的组件是否已创建
+func (e *Endpoint) Available() bool { return e.Err == nil && e.Docker != nil }
+
+// Health 端点健康状态
+type Health struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Host          string    `json:"host"`
+	Healthy       bool      `json:"healthy"`
+	Error         string    `json:"error,omitempty"`
+	DockerVersion string    `json:"dockerVersion,omitempty"`
+	Containers    int       `json:"containers"`
+	Running       int       `json:"running"`
+	CheckedAt     time.Time `json:"checkedAt"`
+}
+
+// Manager 端点集合，第一个端点为本机端点 local。
+// 配置中的端点在启动时创建，agent 端点可在运行时增删。
+type Manager struct {
+	logger   *zap.Logger
+	registry *registry.Client
+	notifier *notificationmanager.Manager
+	dataDir  string
+
+	mu        sync.RWMutex
+	endpoints []*Endpoint
+	byID      map[string]*Endpoint
+}
+
+// Open 按配置创建所有端点：local 使用 docker.host（未配置时读取 DOCKER_* 环境变量），其余来自 docker.endpoints。
+// 本机端点创建失败返回错误；远程端点失败时记录在 Endpoint.Err 中，不影响其他端点。
+// 各端点的更新历史与资源历史保存在 dataDir 下，本机端点沿用 update-history.json 与 stats-history。
+func Open(ctx context.Context, logger *zap.Logger, reg *registry.Client, nm *notificationmanager.Manager, dataDir string) (*Manager, error) {
+	cfg := config.Get()
+	m := &Manager{logger: logger, registry: reg, notifier: nm, dataDir: dataDir, byID: make(map[string]*Endpoint)}
+
+	localDocker, err := dockercli.New(ctx, cfg.Docker.Host)
+	if err != nil {
+		return nil, fmt.Errorf("create local docker client: %w", err)
+	}
+	local := &Endpoint{ID: config.LocalEndpointID, Name: "本机", Host: cfg.Docker.Host}
+	local.build(logger, localDocker, reg, nm, path.Join(dataDir, "update-history.json"), path.Join(dataDir, "stats-history"))
+	m.add(local)
+
+	for _, c := range cfg.Docker.Endpoints {
+		ep := &Endpoint{ID: c.ID, Name: c.Name, Host: c.Host}
+		if ep.Name == "" {
+			ep.Name = c.ID
+		}
+		d, err := dockercli.NewEndpoint(ctx, dockercli.EndpointOptions{
+			Host:    c.Host,
+			TLSCA:   c.TLSCA,
+			TLSCert: c.TLSCert,
+			TLSKey:  c.TLSKey,
+		})
+		if err != nil {
+			logger.Error("创建 Docker 端点客户端失败", zap.String("endpoint", c.ID), zap.Error(err))
+			ep.Err = err
+		} else {
+			ep.build(logger, d, reg, nm, path.Join(dataDir, "update-history-"+c.ID+".json"), path.Join(dataDir, "stats-history-"+c.ID))
+			// compose 项目目录不在本机，远程端点的 compose 容器按普通容器重建
+			ep.Updater.WithoutCompose()
+		}
+		m.add(ep)
+	}
+	return m, nil
+}
+
+func (e *Endpoint) build(logger *zap.Logger, d *dockercli.Client, reg *registry.Client, nm *notificationmanager.Manager, historyPath, statsDir string) {
+	e.Docker = d
+	e.Scanner = scanner.New(d, reg)
+	e.Updater = updater.New(d, history.New(historyPath))
+	e.Scheduler = scheduler.New(logger.With(zap.String("endpoint", e.ID)), e.Scanner, e.Updater, nm)
+	e.StatsHistory = statshistory.New(statsDir)
+	var notifier alerting.Notifier
+	if nm != nil {
+		notifier = nm
+	}
+	e.Alerts = alerting.New(notifier)
+}
+
+// startMonitoring 订阅端点的 Docker 事件流发送容器生命周期通知（是否通知由 notify.containerEvents 控制），
+// 并开始记录资源历史与评估资源告警
+func (e *Endpoint) startMonitoring(logger *zap.Logger, nm *notificationmanager.Manager) {
+	e.monitorMu.Lock()
+	defer e.monitorMu.Unlock()
+	if e.cancelMonitor != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancelMonitor = cancel
+	if nm != nil {
+		go e.Docker.WatchContainerEvents(ctx, func(ev dockercli.ContainerEvent) {
+			if err := nm.NotifyContainerEvent(ctx, ev); err != nil {
+				logger.Error("容器事件通知失败", zap.String("endpoint", e.ID), zap.Error(err))
+			}
+		})
+	}
+	go e.StatsHistory.Run(ctx, e.Docker)
+	go e.Alerts.Run(ctx, e.Docker)
+}
+
+// stopMonitoring 停止订阅事件、记录资源历史与评估告警
+func (e *Endpoint) stopMonitoring() {
+	e.monitorMu.Lock()
+	defer e.monitorMu.Unlock()
+	if e.cancelMonitor != nil {
+		e.cancelMonitor()
+		e.cancelMonitor = nil
+	}
+}
+
+func (m *Manager) add(ep *Endpoint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.endpoints = append(m.endpoints, ep)
+	m.byID[ep.ID] = ep
+}
+
+// AddAgent 为 agent 创建端点，Docker 连接经 dial 建立；started 为 true 时立即启动其调度器与事件、资源监控
+func (m *Manager) AddAgent(ctx context.Context, agentID, name string, dial func(ctx context.Context, network, addr string) (net.Conn, error), started bool) (*Endpoint, error) {
+	id := config.AgentEndpointPrefix + agentID
+	if _, ok := m.Get(id); ok {
+		return nil, fmt.Errorf("endpoint %s already exists", id)
+	}
+	d, err := dockercli.NewEndpoint(ctx, dockercli.EndpointOptions{Dialer: dial})
+	if err != nil {
+		return nil, err
+	}
+	ep := &Endpoint{ID: id, Name: name, Host: "agent://" + agentID}
+	ep.build(m.logger, d, m.registry, m.notifier, path.Join(m.dataDir, "update-history-"+id+".json"), path.Join(m.dataDir, "stats-history-"+id))
+	ep.Updater.WithoutCompose()
+	m.add(ep)
+	if started {
+		ep.Scheduler.Start()
+		ep.startMonitoring(m.logger, m.notifier)
+	}
+	return ep, nil
+}
+
+// Remove 删除运行时添加的端点，停止其调度器与监控并关闭 Docker 客户端
+func (m *Manager) Remove(id string) bool {
+	m.mu.Lock()
+	ep, ok := m.byID[id]
+	if !ok || ep == m.endpoints[0] {
+		m.mu.Unlock()
+		return false
+	}
+	delete(m.byID, id)
+	for i, e := range m.endpoints {
+		if e == ep {
+			m.endpoints = append(m.endpoints[:i:i], m.endpoints[i+1:]...)
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	if ep.Available() {
+		ep.Scheduler.Stop()
+		ep.stopMonitoring()
+		if err := ep.Docker.Close(); err != nil {
+			m.logger.Warn("关闭 Docker 端点客户端失败", zap.String("endpoint", ep.ID), zap.Error(err))
+		}
+	}
+	return true
+}
+
+// Local 返回本机端点
+func (m *Manager) Local() *Endpoint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.endpoints[0]
+}
+
+// Get 按 ID 查找端点
+func (m *Manager) Get(id string) (*Endpoint, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ep, ok := m.byID[id]
+	return ep, ok
+}
+
+// List 返回全部端点，本机端点在前
+func (m *Manager) List() []*Endpoint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]*Endpoint(nil), m.endpoints...)
+}
+
+// StartSchedulers 启动所有可用端点的调度器
+func (m *Manager) StartSchedulers() {
+	for _, ep := range m.List() {
+		if ep.Available() {
+			ep.Scheduler.Start()
+		}
+	}
+}
+
+// StopSchedulers 停止所有可用端点的调度器
+func (m *Manager) StopSchedulers() {
+	for _, ep := range m.List() {
+		if ep.Available() {
+			ep.Scheduler.Stop()
+		}
+	}
+}
+
+// StartMonitors 为所有可用端点订阅容器事件，并开始记录资源历史与评估资源告警
+func (m *Manager) StartMonitors() {
+	for _, ep := range m.List() {
+		if ep.Available() {
+			ep.startMonitoring(m.logger, m.notifier)
+		}
+	}
+}
+
+// StopMonitors 停止所有端点的事件订阅、资源历史记录与资源告警评估
+func (m *Manager) StopMonitors() {
+	for _, ep := range m.List() {
+		ep.stopMonitoring()
+	}
+}
+
+// Close 关闭远程端点的 Docker 客户端（本机客户端由调用方管理）
+func (m *Manager) Close() {
+	for _, ep := range m.List()[1:] {
+		if ep.Available() {
+			if err := ep.Docker.Close(); err != nil {
+				m.logger.Warn("关闭 Docker 端点客户端失败", zap.String("endpoint", ep.ID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// Health 并发检查所有端点的连接状态
+func (m *Manager) Health(ctx context.Context) []Health {
+	eps := m.List()
+	list := make([]Health, len(eps))
+	var wg sync.WaitGroup
+	for i, ep := range eps {
+		wg.Add(1)
+		go func(i int, ep *Endpoint) {
+			defer wg.Done()
+			list[i] = ep.health(ctx)
+		}(i, ep)
+	}
+	wg.Wait()
+	return list
+}
+
+func (e *Endpoint) health(ctx context.Context) Health {
+	h := Health{ID: e.ID, Name: e.Name, Host: e.Host, CheckedAt: time.Now()}
+	if !e.Available() {
+		h.Error = e.Err.Error()
+		return h
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	v, err := e.Docker.GetVersion(ctx)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	h.DockerVersion = v.Version
+	containers, err := e.Docker.ListContainers(ctx, true)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	h.Healthy = true
+	h.Containers = len(containers)
+	for _, ct := range containers {
+		if ct.State == "running" {
+			h.Running++
+		}
+	}
+	return h
+}
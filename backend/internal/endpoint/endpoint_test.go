@@ -0,0 +1,48 @@
+package endpoint
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/registry"
+
+	"go.uber.org/zap"
+)
+
+func TestOpenKeepsFailedEndpoints(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	old := config.Get()
+	t.Cleanup(func() { config.SetGlobal(old) })
+	cfg := *old
+	cfg.Docker.Host = "unix:///nonexistent/docker.sock"
+	cfg.Docker.Endpoints = []config.DockerEndpoint{
+		{ID: "web", Name: "Web", Host: "tcp://10.0.0.2:2375"},
+		{ID: "db", Host: "tcp://10.0.0.3:2376", TLSCert: filepath.Join(t.TempDir(), "missing.pem"), TLSKey: "missing-key.pem"},
+	}
+	config.SetGlobal(&cfg)
+
+	m, err := Open(context.Background(), zap.NewNop(), registry.New(), nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer m.Close()
+
+	if got := len(m.List()); got != 3 || m.Local().ID != config.LocalEndpointID {
+		t.Fatalf("unexpected endpoints: %d, local=%s", got, m.Local().ID)
+	}
+	if web, ok := m.Get("web"); !ok || !web.Available() || web.Scheduler == nil || web.Name != "Web" {
+		t.Fatalf("web endpoint should be available: %+v", web)
+	}
+	db, ok := m.Get("db")
+	if !ok || db.Available() || db.Name != "db" {
+		t.Fatalf("db endpoint with missing certs should be unavailable: %+v", db)
+	}
+	if h := db.health(context.Background()); h.Healthy || h.Error == "" {
+		t.Fatalf("unavailable endpoint must report error: %+v", h)
+	}
+}
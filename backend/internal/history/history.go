@@ -0,0 +1,153 @@
+// Package history 记录容器更新历史（更新日志），持久化到 CONFIG_PATH 下的 JSON 文件，
+// 用于查看容器曾经运行过的镜像以及回滚到旧镜像。
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"go.uber.org/zap"
+)
+
+// maxRecords 最多保留的历史记录条数，超出后丢弃最旧的记录
+const maxRecords = 1000
+
+// Trigger 触发更新的来源
+type Trigger string
+
+const (
+	TriggerCron     Trigger = "cron"     // 定时任务自动更新
+	TriggerManual   Trigger = "manual"   // 单个容器手动更新
+	TriggerBatch    Trigger = "batch"    // 批量更新
+	TriggerRollback Trigger = "rollback" // 回滚到历史镜像
+	TriggerWebhook  Trigger = "webhook"  // CI webhook 触发
+)
+
+// Result 更新结果
+type Result string
+
+const (
+	ResultSuccess    Result = "success"
+	ResultFailed     Result = "failed"
+	ResultRolledBack Result = "rolled_back" // 新容器校验失败，已自动恢复旧容器
+)
+
+// Record 一次更新的记录
+type Record struct {
+	ID             string    `json:"id"`
+	ContainerName  string    `json:"containerName"`
+	OldContainerID string    `json:"oldContainerId"`
+	NewContainerID string    `json:"newContainerId,omitempty"`
+	ImageRef       string    `json:"imageRef"`             // 本次更新使用的镜像引用
+	OldImage       string    `json:"oldImage"`             // 旧容器配置中的镜像引用
+	OldImageID     string    `json:"oldImageId"`           // 旧镜像 ID，回滚时使用
+	NewImageID     string    `json:"newImageId,omitempty"` // 新镜像 ID
+	OldDigests     []string  `json:"oldDigests,omitempty"`
+	NewDigests     []string  `json:"newDigests,omitempty"`
+	Trigger        Trigger   `json:"trigger"`
+	Result         Result    `json:"result"`
+	Error          string    `json:"error,omitempty"`
+	Time           time.Time `json:"time"`
+}
+
+// Store 更新历史存储
+type Store struct {
+	path    string
+	mu      sync.RWMutex
+	records []Record
+}
+
+// New 创建历史存储并加载已有记录
+func New(path string) *Store {
+	s := &Store{path: path}
+	s.load()
+	return s
+}
+
+// Add 追加一条记录并持久化，返回带 ID 的记录
+func (s *Store) Add(r Record) Record {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	if r.ID == "" {
+		r.ID = fmt.Sprintf("%d", r.Time.UnixNano())
+	}
+
+	s.mu.Lock()
+	s.records = append(s.records, r)
+	if len(s.records) > maxRecords {
+		s.records = s.records[len(s.records)-maxRecords:]
+	}
+	s.saveLocked()
+	s.mu.Unlock()
+	return r
+}
+
+// ListByContainer 返回某个容器（按名称）的历史记录，最新的在前
+func (s *Store) ListByContainer(name string) []Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]Record, 0)
+	for _, r := range s.records {
+		if r.ContainerName == name {
+			result = append(result, r)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time.After(result[j].Time) })
+	return result
+}
+
+// Get 按 ID 查找记录
+func (s *Store) Get(id string) (Record, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, r := range s.records {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return Record{}, false
+}
+
+func (s *Store) load() {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		logger.Logger.Debug("无法读取更新历史文件", zap.String("path", s.path), zap.Error(err))
+		return
+	}
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		logger.Logger.Error("解析更新历史文件失败", zap.String("path", s.path), zap.Error(err))
+		return
+	}
+	s.records = records
+}
+
+// saveLocked 持久化记录（需要在持有写锁的情况下调用）
+func (s *Store) saveLocked() {
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		logger.Logger.Error("创建更新历史目录失败", zap.String("dir", dir), zap.Error(err))
+		return
+	}
+	data, err := json.MarshalIndent(s.records, "", "  ")
+	if err != nil {
+		logger.Logger.Error("序列化更新历史失败", zap.Error(err))
+		return
+	}
+	// 先写临时文件再重命名，写入中途崩溃不会截断已有的历史
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		logger.Logger.Error("保存更新历史文件失败", zap.String("path", tmp), zap.Error(err))
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		logger.Logger.Error("保存更新历史文件失败", zap.String("path", s.path), zap.Error(err))
+	}
+}
@@ -0,0 +1,40 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"go.uber.org/zap"
+)
+
+func TestStorePersistsAndListsNewestFirst(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	path := filepath.Join(t.TempDir(), "update-history.json")
+
+	s := New(path)
+	base := time.Now()
+	s.Add(Record{ContainerName: "web", OldImageID: "sha256:a", Trigger: TriggerCron, Result: ResultSuccess, Time: base})
+	s.Add(Record{ContainerName: "db", OldImageID: "sha256:x", Trigger: TriggerManual, Result: ResultFailed, Time: base.Add(time.Second)})
+	latest := s.Add(Record{ContainerName: "web", OldImageID: "sha256:b", Trigger: TriggerBatch, Result: ResultSuccess, Time: base.Add(2 * time.Second)})
+
+	reloaded := New(path)
+	got := reloaded.ListByContainer("web")
+	if len(got) != 2 {
+		t.Fatalf("expected 2 records for web, got %d", len(got))
+	}
+	if got[0].ID != latest.ID || got[0].OldImageID != "sha256:b" {
+		t.Fatalf("expected newest record first, got %+v", got[0])
+	}
+	if _, ok := reloaded.Get(latest.ID); !ok {
+		t.Fatalf("expected record %s to be found after reload", latest.ID)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("history file mode: %v %v", fi, err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temp file left behind: %v", err)
+	}
+}
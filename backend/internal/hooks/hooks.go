@@ -0,0 +1,220 @@
+// Package hooks 处理 CI 推送镜像后的 webhook：识别 Docker Hub、GHCR、Harbor 与通用格式的请求体，
+// 校验令牌及其作用范围，并找出运行该镜像的容器。
+package hooks
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+
+	"github.com/distribution/reference"
+)
+
+// ErrUnknownPayload 无法从请求体中识别镜像
+var ErrUnknownPayload = errors.New("no image found in payload")
+
+// Image 推送的镜像，Tag 为空表示仓库的任意 tag
+type Image struct {
+	Repository string `json:"repository"` // 规范化的仓库名，如 docker.io/library/nginx
+	Tag        string `json:"tag,omitempty"`
+}
+
+// String 返回 repository[:tag]
+func (i Image) String() string {
+	if i.Tag == "" {
+		return i.Repository
+	}
+	return i.Repository + ":" + i.Tag
+}
+
+// payload 兼容各来源的请求体字段
+type payload struct {
+	// 通用格式 {image, tag}
+	Image string `json:"image"`
+	Tag   string `json:"tag"`
+
+	// Docker Hub
+	PushData *struct {
+		Tag string `json:"tag"`
+	} `json:"push_data"`
+	Repository *struct {
+		RepoName string `json:"repo_name"`
+	} `json:"repository"`
+
+	// GHCR（GitHub registry_package / package 事件）
+	RegistryPackage *githubPackage `json:"registry_package"`
+	Package         *githubPackage `json:"package"`
+
+	// Harbor
+	EventData *struct {
+		Resources []struct {
+			ResourceURL string `json:"resource_url"`
+			Tag         string `json:"tag"`
+		} `json:"resources"`
+		Repository struct {
+			RepoFullName string `json:"repo_full_name"`
+		} `json:"repository"`
+	} `json:"event_data"`
+}
+
+type githubPackage struct {
+	Name           string `json:"name"`
+	Namespace      string `json:"namespace"`
+	PackageType    string `json:"package_type"`
+	PackageVersion struct {
+		PackageURL        string `json:"package_url"`
+		ContainerMetadata struct {
+			Tag struct {
+				Name string `json:"name"`
+			} `json:"tag"`
+		} `json:"container_metadata"`
+	} `json:"package_version"`
+	Owner struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// ParsePayload 从 webhook 请求体中解析推送的镜像
+func ParsePayload(body []byte) ([]Image, error) {
+	var p payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	switch {
+	case p.Image != "":
+		refs = append(refs, withTag(p.Image, p.Tag))
+	case p.PushData != nil && p.Repository != nil && p.Repository.RepoName != "":
+		refs = append(refs, withTag(p.Repository.RepoName, p.PushData.Tag))
+	case p.RegistryPackage != nil || p.Package != nil:
+		pkg := p.RegistryPackage
+		if pkg == nil {
+			pkg = p.Package
+		}
+		refs = append(refs, pkg.imageRef())
+	case p.EventData != nil:
+		for _, r := range p.EventData.Resources {
+			if r.ResourceURL != "" {
+				refs = append(refs, r.ResourceURL)
+			}
+		}
+	}
+
+	images := make([]Image, 0, len(refs))
+	for _, ref := range refs {
+		if ref == "" {
+			continue
+		}
+		img, err := ParseImage(ref)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	if len(images) == 0 {
+		return nil, ErrUnknownPayload
+	}
+	return images, nil
+}
+
+// imageRef GHCR 优先使用 package_url，否则按 ghcr.io/<owner>/<name>:<tag> 拼接
+func (p *githubPackage) imageRef() string {
+	if u := p.PackageVersion.PackageURL; u != "" {
+		return u
+	}
+	owner := p.Namespace
+	if owner == "" {
+		owner = p.Owner.Login
+	}
+	if owner == "" || p.Name == "" {
+		return ""
+	}
+	return withTag("ghcr.io/"+strings.ToLower(owner)+"/"+p.Name, p.PackageVersion.ContainerMetadata.Tag.Name)
+}
+
+func withTag(repo, tag string) string {
+	if tag == "" {
+		return repo
+	}
+	return repo + ":" + tag
+}
+
+// ParseImage 规范化镜像引用，未指定 tag 时 Tag 为空
+func ParseImage(ref string) (Image, error) {
+	named, err := reference.ParseNormalizedNamed(strings.TrimSpace(ref))
+	if err != nil {
+		return Image{}, err
+	}
+	img := Image{Repository: named.Name()}
+	if t, ok := named.(reference.NamedTagged); ok {
+		img.Tag = t.Tag()
+	}
+	return img, nil
+}
+
+// Matches 容器镜像是否为推送的镜像：仓库相同，且推送的 tag 为空或与容器的 tag（默认 latest）相同
+func (i Image) Matches(containerImage string) bool {
+	named, err := reference.ParseNormalizedNamed(containerImage)
+	if err != nil || named.Name() != i.Repository {
+		return false
+	}
+	if i.Tag == "" {
+		return true
+	}
+	tag := "latest"
+	if t, ok := named.(reference.NamedTagged); ok {
+		tag = t.Tag()
+	}
+	return tag == i.Tag
+}
+
+// Authorize 按令牌查找未吊销的 webhook 配置
+func Authorize(token string) (config.HookToken, bool) {
+	if token == "" {
+		return config.HookToken{}, false
+	}
+	var found config.HookToken
+	ok := false
+	// 遍历全部令牌，避免比较次数泄露匹配位置
+	for _, t := range config.Get().Hooks.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 && !t.Revoked {
+			found, ok = t, true
+		}
+	}
+	return found, ok
+}
+
+// ImageAllowed 镜像仓库是否在令牌的作用范围内
+func ImageAllowed(t config.HookToken, img Image) bool {
+	if len(t.Images) == 0 {
+		return true
+	}
+	for _, pattern := range t.Images {
+		// 模式同样按镜像引用规范化，nginx 等价于 docker.io/library/nginx；含通配符时按原样匹配
+		if p, err := ParseImage(pattern); err == nil && p.Repository == img.Repository {
+			return true
+		}
+		if ok, _ := path.Match(pattern, img.Repository); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// EndpointAllowed 端点是否在令牌的作用范围内
+func EndpointAllowed(t config.HookToken, endpointID string) bool {
+	if len(t.Endpoints) == 0 {
+		return true
+	}
+	for _, id := range t.Endpoints {
+		if id == endpointID {
+			return true
+		}
+	}
+	return false
+}
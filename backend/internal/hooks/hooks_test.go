@@ -0,0 +1,93 @@
+package hooks
+
+import (
+	"testing"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+func TestParsePayloadFormats(t *testing.T) {
+	cases := map[string]struct {
+		body string
+		want string
+	}{
+		"generic":        {`{"image": "nginx", "tag": "1.25"}`, "docker.io/library/nginx:1.25"},
+		"generic-no-tag": {`{"image": "registry.example.com:5000/team/api"}`, "registry.example.com:5000/team/api"},
+		"dockerhub":      {`{"push_data": {"tag": "latest"}, "repository": {"repo_name": "acme/web"}}`, "docker.io/acme/web:latest"},
+		"ghcr":           {`{"action": "published", "registry_package": {"name": "api", "namespace": "Acme", "package_type": "CONTAINER", "package_version": {"container_metadata": {"tag": {"name": "v2"}}}}}`, "ghcr.io/acme/api:v2"},
+		"ghcr-url":       {`{"package": {"package_version": {"package_url": "ghcr.io/acme/api:sha-1"}}}`, "ghcr.io/acme/api:sha-1"},
+		"harbor":         {`{"type": "PUSH_ARTIFACT", "event_data": {"resources": [{"resource_url": "harbor.example.com/library/redis:7", "tag": "7"}], "repository": {"repo_full_name": "library/redis"}}}`, "harbor.example.com/library/redis:7"},
+	}
+	for name, tc := range cases {
+		images, err := ParsePayload([]byte(tc.body))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(images) != 1 || images[0].String() != tc.want {
+			t.Fatalf("%s: got %v, want %s", name, images, tc.want)
+		}
+	}
+	if _, err := ParsePayload([]byte(`{"hello": "world"}`)); err != ErrUnknownPayload {
+		t.Fatalf("unknown payload: %v", err)
+	}
+}
+
+func TestImageMatches(t *testing.T) {
+	img, _ := ParseImage("nginx:latest")
+	for ref, want := range map[string]bool{
+		"nginx":                          true,
+		"docker.io/library/nginx:latest": true,
+		"nginx:1.25":                     false,
+		"ghcr.io/library/nginx":          false,
+	} {
+		if got := img.Matches(ref); got != want {
+			t.Fatalf("Matches(%q) = %v", ref, got)
+		}
+	}
+	anyTag, _ := ParseImage("ghcr.io/acme/api")
+	if !anyTag.Matches("ghcr.io/acme/api:v3") {
+		t.Fatal("push without tag should match every tag")
+	}
+}
+
+func TestAuthorizeAndScope(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	old := config.Get()
+	t.Cleanup(func() { config.SetGlobal(old) })
+	cfg := *old
+	cfg.Hooks.Tokens = []config.HookToken{
+		{Name: "ci", Token: "0123456789abcdef", Images: []string{"ghcr.io/acme/*", "nginx"}, Endpoints: []string{"local"}},
+		{Name: "old", Token: "fedcba9876543210", Revoked: true},
+	}
+	config.SetGlobal(&cfg)
+
+	if _, ok := Authorize("fedcba9876543210"); ok {
+		t.Fatal("revoked token must not authorize")
+	}
+	if _, ok := Authorize("wrong"); ok {
+		t.Fatal("unknown token must not authorize")
+	}
+	tok, ok := Authorize("0123456789abcdef")
+	if !ok || tok.Name != "ci" {
+		t.Fatalf("token should authorize: %+v", tok)
+	}
+	for ref, want := range map[string]bool{
+		"ghcr.io/acme/api:v1": true,
+		"nginx:1.25":          true,
+		"redis":               false,
+		"ghcr.io/other/api":   false,
+	} {
+		img, _ := ParseImage(ref)
+		if got := ImageAllowed(tok, img); got != want {
+			t.Fatalf("ImageAllowed(%s) = %v", ref, got)
+		}
+	}
+	if !EndpointAllowed(tok, "local") || EndpointAllowed(tok, "web") {
+		t.Fatal("endpoint scope not applied")
+	}
+}
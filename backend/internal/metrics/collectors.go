@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+)
+
+var (
+	registryBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	updateBuckets   = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800}
+)
+
+// 扫描结果（最近一次远程扫描的快照）
+var (
+	scanContainers = newGauge("watchdocker_scan_containers",
+		"Containers by status in the last scan (UpToDate, UpdateAvailable, Skipped, Error).", "status")
+	scanSkipped = newGauge("watchdocker_scan_skipped_containers",
+		"Skipped containers by skip reason in the last scan.", "reason")
+	scanErrors = newGauge("watchdocker_scan_error_containers",
+		"Containers whose update check failed in the last scan, by error type.", "error_type")
+	scanTimestamp = newGauge("watchdocker_scan_last_timestamp_seconds",
+		"Unix time of the last scan.")
+	scansTotal = newCounter("watchdocker_scans_total",
+		"Total number of scans that queried registries.")
+)
+
+// registry 请求
+var (
+	registryRequests = newCounter("watchdocker_registry_requests_total",
+		"Registry requests by registry and result: HTTP status code or error for direct requests; ok, not_found, rate_limited or general for batched manifest lookups.", "registry", "result")
+	registryDuration = newHistogram("watchdocker_registry_request_duration_seconds",
+		"Registry request latency by registry; batched manifest lookups report the batch duration.", registryBuckets, "registry")
+	registryRateLimited = newGauge("watchdocker_registry_rate_limit_cooldown_seconds",
+		"Remaining rate-limit cooldown per registry; registries not in cooldown are omitted.", "registry")
+	cacheLookups = newCounter("watchdocker_registry_cache_lookups_total",
+		"Remote digest cache lookups by result (hit, miss).", "result")
+	cacheHitRatio = newGauge("watchdocker_registry_cache_hit_ratio",
+		"Remote digest cache hit ratio since start.")
+)
+
+// 容器更新
+var (
+	updatesTotal = newCounter("watchdocker_updates_total",
+		"Container update attempts by trigger and outcome (success, failed, rolled_back).", "trigger", "result")
+	updateDuration = newHistogram("watchdocker_update_duration_seconds",
+		"Container update duration by outcome.", updateBuckets, "result")
+)
+
+// 容器资源
+var (
+	containerCPU = newGauge("watchdocker_container_cpu_percent",
+		"Container CPU usage percent.", "id", "name")
+	containerMemUsage = newGauge("watchdocker_container_memory_usage_bytes",
+		"Container memory usage in bytes, excluding inactive file cache.", "id", "name")
+	containerMemLimit = newGauge("watchdocker_container_memory_limit_bytes",
+		"Container memory limit in bytes.", "id", "name")
+	containerNetRx = newGauge("watchdocker_container_network_receive_bytes",
+		"Total bytes received by the container on all networks.", "id", "name")
+	containerNetTx = newGauge("watchdocker_container_network_transmit_bytes",
+		"Total bytes transmitted by the container on all networks.", "id", "name")
+	containerBlockRead = newGauge("watchdocker_container_block_read_bytes",
+		"Total bytes read from block devices by the container.", "id", "name")
+	containerBlockWrite = newGauge("watchdocker_container_block_write_bytes",
+		"Total bytes written to block devices by the container.", "id", "name")
+)
+
+// ScanResult 一次扫描的统计结果
+type ScanResult struct {
+	ByStatus    map[string]int
+	BySkip      map[string]int
+	ByErrorType map[string]int
+}
+
+// ObserveScan 记录最近一次扫描的结果，替换上一次的快照
+func ObserveScan(r ScanResult) {
+	scanContainers.reset()
+	scanSkipped.reset()
+	scanErrors.reset()
+	for status, n := range r.ByStatus {
+		scanContainers.set(float64(n), status)
+	}
+	for reason, n := range r.BySkip {
+		scanSkipped.set(float64(n), reason)
+	}
+	for errType, n := range r.ByErrorType {
+		scanErrors.set(float64(n), errType)
+	}
+	scanTimestamp.set(float64(time.Now().Unix()))
+	scansTotal.add(1)
+}
+
+// ObserveRegistryRequest 记录一次 registry 请求，result 为 HTTP 状态码或错误类型
+func ObserveRegistryRequest(registry, result string, d time.Duration) {
+	registryRequests.add(1, registry, result)
+	registryDuration.observe(d.Seconds(), registry)
+}
+
+// ObserveRegistryResponse 按 HTTP 响应记录一次 registry 请求，err 不为 nil 表示请求未得到响应
+func ObserveRegistryResponse(registry string, status int, err error, d time.Duration) {
+	result := "error"
+	if err == nil && status > 0 {
+		result = strconv.Itoa(status)
+	}
+	ObserveRegistryRequest(registry, result, d)
+}
+
+// ObserveCacheLookup 记录一次远程 digest 缓存查询
+func ObserveCacheLookup(hit bool) {
+	if hit {
+		cacheLookups.add(1, "hit")
+	} else {
+		cacheLookups.add(1, "miss")
+	}
+	hits, misses := cacheLookups.value("hit"), cacheLookups.value("miss")
+	cacheHitRatio.set(hits / (hits + misses))
+}
+
+// SetRateLimits 刷新各 registry 的限流剩余冷却时间
+func SetRateLimits(cooldowns map[string]time.Duration) {
+	registryRateLimited.reset()
+	for registry, d := range cooldowns {
+		registryRateLimited.set(d.Seconds(), registry)
+	}
+}
+
+// ObserveUpdate 记录一次容器更新（含回滚）的结果与耗时
+func ObserveUpdate(trigger, result string, d time.Duration) {
+	updatesTotal.add(1, trigger, result)
+	updateDuration.observe(d.Seconds(), result)
+}
+
+// SetContainerStats 刷新容器资源指标，stats 中的 Name 应为容器名称
+func SetContainerStats(stats []dockercli.ContainerStats) {
+	for _, f := range []*family{containerCPU, containerMemUsage, containerMemLimit, containerNetRx, containerNetTx, containerBlockRead, containerBlockWrite} {
+		f.reset()
+	}
+	for _, st := range stats {
+		id := st.ID
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		containerCPU.set(st.CPUPercent, id, st.Name)
+		containerMemUsage.set(float64(st.MemoryUsage), id, st.Name)
+		containerMemLimit.set(float64(st.MemoryLimit), id, st.Name)
+		containerNetRx.set(float64(st.NetworkRx), id, st.Name)
+		containerNetTx.set(float64(st.NetworkTx), id, st.Name)
+		containerBlockRead.set(float64(st.BlockRead), id, st.Name)
+		containerBlockWrite.set(float64(st.BlockWrite), id, st.Name)
+	}
+}
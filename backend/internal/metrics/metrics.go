@@ -0,0 +1,217 @@
+// Package metrics 以 Prometheus 文本格式导出运行指标。
+// 指标在进程内累计，由 /metrics 接口在抓取时输出；不依赖 prometheus 客户端库。
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// family 一组同名指标，按标签值区分序列
+type family struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64 // 仅 histogram 使用，升序
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	counts []uint64 // 各桶的观测次数（非累计）
+	sum    float64
+	count  uint64
+}
+
+var (
+	familiesMu sync.Mutex
+	families   []*family
+)
+
+func newFamily(kind, name, help string, buckets []float64, labels ...string) *family {
+	f := &family{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	familiesMu.Lock()
+	families = append(families, f)
+	familiesMu.Unlock()
+	return f
+}
+
+func newCounter(name, help string, labels ...string) *family {
+	return newFamily(kindCounter, name, help, nil, labels...)
+}
+
+func newGauge(name, help string, labels ...string) *family {
+	return newFamily(kindGauge, name, help, nil, labels...)
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *family {
+	return newFamily(kindHistogram, name, help, buckets, labels...)
+}
+
+// get 返回标签值对应的序列，不存在时创建；调用方需持有 f.mu
+func (f *family) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) add(delta float64, values ...string) {
+	f.mu.Lock()
+	f.get(values).value += delta
+	f.mu.Unlock()
+}
+
+func (f *family) set(v float64, values ...string) {
+	f.mu.Lock()
+	f.get(values).value = v
+	f.mu.Unlock()
+}
+
+func (f *family) observe(v float64, values ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.get(values)
+	s.sum += v
+	s.count++
+	for i, b := range f.buckets {
+		if v <= b {
+			s.counts[i]++
+			break
+		}
+	}
+}
+
+// reset 清空所有序列，用于每次整体刷新的快照类 gauge
+func (f *family) reset() {
+	f.mu.Lock()
+	f.series = make(map[string]*series)
+	f.mu.Unlock()
+}
+
+// value 返回序列当前值，不存在时为 0
+func (f *family) value(values ...string) float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.series[strings.Join(values, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.WriteString("# HELP " + f.name + " " + escapeHelp(f.help) + "\n")
+	w.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := f.series[k]
+		if f.kind != kindHistogram {
+			w.WriteString(f.name + labelString(f.labels, s.values, "") + " " + formatFloat(s.value) + "\n")
+			continue
+		}
+		var cumulative uint64
+		for i, b := range f.buckets {
+			cumulative += s.counts[i]
+			w.WriteString(f.name + "_bucket" + labelString(f.labels, s.values, formatFloat(b)) + " " + strconv.FormatUint(cumulative, 10) + "\n")
+		}
+		w.WriteString(f.name + "_bucket" + labelString(f.labels, s.values, "+Inf") + " " + strconv.FormatUint(s.count, 10) + "\n")
+		w.WriteString(f.name + "_sum" + labelString(f.labels, s.values, "") + " " + formatFloat(s.sum) + "\n")
+		w.WriteString(f.name + "_count" + labelString(f.labels, s.values, "") + " " + strconv.FormatUint(s.count, 10) + "\n")
+	}
+}
+
+// labelString 生成 {a="x",b="y"}，le 不为空时追加 histogram 的 le 标签
+func labelString(names, values []string, le string) string {
+	if len(names) == 0 && le == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name + `="` + escapeLabel(values[i]) + `"`)
+	}
+	if le != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(`le="` + le + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// ContentType Prometheus 文本格式的 Content-Type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteText 按注册顺序以 Prometheus 文本格式输出全部指标
+func WriteText(w io.Writer) error {
+	familiesMu.Lock()
+	list := append([]*family(nil), families...)
+	familiesMu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range list {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+)
+
+func TestWriteTextFormatsFamilies(t *testing.T) {
+	ObserveUpdate("cron", "success", 12*time.Second)
+	ObserveUpdate("cron", "success", 3*time.Second)
+	ObserveRegistryRequest("docker.io", "ok", 200*time.Millisecond)
+	ObserveCacheLookup(true)
+	ObserveCacheLookup(false)
+	SetRateLimits(map[string]time.Duration{"ghcr.io": 90 * time.Second})
+	SetContainerStats([]dockercli.ContainerStats{{ID: "0123456789abcdef", Name: `we"b`, CPUPercent: 1.5, MemoryUsage: 1024}})
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE watchdocker_updates_total counter\n",
+		`watchdocker_updates_total{trigger="cron",result="success"} 2` + "\n",
+		`watchdocker_update_duration_seconds_bucket{result="success",le="5"} 1` + "\n",
+		`watchdocker_update_duration_seconds_bucket{result="success",le="30"} 2` + "\n",
+		`watchdocker_update_duration_seconds_bucket{result="success",le="+Inf"} 2` + "\n",
+		`watchdocker_update_duration_seconds_sum{result="success"} 15` + "\n",
+		`watchdocker_registry_requests_total{registry="docker.io",result="ok"} 1` + "\n",
+		"watchdocker_registry_cache_hit_ratio 0.5\n",
+		`watchdocker_registry_rate_limit_cooldown_seconds{registry="ghcr.io"} 90` + "\n",
+		`watchdocker_container_cpu_percent{id="0123456789ab",name="we\"b"} 1.5` + "\n",
+		`watchdocker_container_memory_usage_bytes{id="0123456789ab",name="we\"b"} 1024` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestObserveScanReplacesSnapshot(t *testing.T) {
+	ObserveScan(ScanResult{ByStatus: map[string]int{"Error": 1}, ByErrorType: map[string]int{"rate_limited": 1}})
+	ObserveScan(ScanResult{ByStatus: map[string]int{"UpToDate": 3}})
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `watchdocker_scan_containers{status="UpToDate"} 3`) {
+		t.Fatalf("missing current scan result:\n%s", out)
+	}
+	if strings.Contains(out, `status="Error"`) || strings.Contains(out, `error_type="rate_limited"`) {
+		t.Fatalf("stale scan result kept:\n%s", out)
+	}
+}
@@ -0,0 +1,122 @@
+package notificationmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/notify"
+
+	"go.uber.org/zap"
+)
+
+// send 按各渠道的模板渲染消息，并发发送到所有启用且订阅了该事件类型的渠道，单个渠道失败不影响其他渠道
+func (m *Manager) send(ctx context.Context, data TemplateData) error {
+	notifyCfg := config.Get().Notify
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, ch := range notify.Channels(notifyCfg) {
+		if !ch.Enabled || !notify.Accepts(ch, string(data.Type)) {
+			continue
+		}
+		title, content := render(notifyCfg, ch, data)
+		msg := notify.Message{Title: title, Content: content, Format: ch.Format}
+		wg.Add(1)
+		go func(ch config.NotifyChannel) {
+			defer wg.Done()
+			if err := m.sendToChannel(ctx, ch, msg); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", ch.Name, err))
+				mu.Unlock()
+			}
+		}(ch)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// sendToChannel 发送到单个渠道并记录发送状态
+func (m *Manager) sendToChannel(ctx context.Context, ch config.NotifyChannel, msg notify.Message) error {
+	n, err := m.newNotifier(ch)
+	if err == nil {
+		err = n.Send(ctx, msg)
+	}
+	// 错误中的请求地址可能包含令牌，记录前去掉
+	if err != nil {
+		err = notify.RedactError(err)
+	}
+	m.recordChannelResult(ch, err)
+	if err != nil {
+		logger.Logger.Warn("通知渠道发送失败",
+			zap.String("channel", ch.Name),
+			zap.String("type", ch.Type),
+			zap.Error(err))
+	}
+	return err
+}
+
+// TestChannel 向指定渠道发送一条测试通知（不受渠道开关和事件过滤影响）
+func (m *Manager) TestChannel(ctx context.Context, name string) error {
+	for _, ch := range notify.Channels(config.Get().Notify) {
+		if ch.Name != name {
+			continue
+		}
+		return m.sendToChannel(ctx, ch, notify.Message{
+			Format:  ch.Format,
+			Title:   "🔔 Watch Docker 测试通知",
+			Content: fmt.Sprintf("这是一条来自渠道 %s 的测试通知\n⏰ 发送时间: %s", name, time.Now().Format("2006-01-02 15:04:05")),
+		})
+	}
+	return fmt.Errorf("通知渠道 %s 不存在", name)
+}
+
+func (m *Manager) recordChannelResult(ch config.NotifyChannel, err error) {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+
+	st, ok := m.channelStatus[ch.Name]
+	if !ok {
+		st = &ChannelStatus{Name: ch.Name}
+		m.channelStatus[ch.Name] = st
+	}
+	st.Type = ch.Type
+	now := time.Now()
+	if err != nil {
+		st.FailedCount++
+		st.ConsecutiveFailures++
+		st.LastError = err.Error()
+		st.LastErrorAt = now
+		return
+	}
+	st.SentCount++
+	st.ConsecutiveFailures = 0
+	st.LastSuccessAt = now
+}
+
+// ChannelStatuses 返回当前配置中所有渠道的发送状态，按配置顺序排列
+func (m *Manager) ChannelStatuses() []ChannelStatus {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+
+	channels := notify.Channels(config.Get().Notify)
+	result := make([]ChannelStatus, 0, len(channels))
+	for _, ch := range channels {
+		st := ChannelStatus{Name: ch.Name}
+		if s, ok := m.channelStatus[ch.Name]; ok {
+			st = *s
+		}
+		st.Type = ch.Type
+		st.Enabled = ch.Enabled
+		st.Events = ch.Events
+		result = append(result, st)
+	}
+	return result
+}
@@ -0,0 +1,137 @@
+package notificationmanager
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/notify"
+	"go.uber.org/zap"
+)
+
+type fakeNotifier struct {
+	name string
+	err  error
+	mu   *sync.Mutex
+	sent map[string]int
+}
+
+func (f fakeNotifier) Send(ctx context.Context, msg notify.Message) error {
+	f.mu.Lock()
+	f.sent[f.name]++
+	f.mu.Unlock()
+	return f.err
+}
+
+func TestSendFansOutByChannelAndRecordsStatus(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	old := config.Get()
+	t.Cleanup(func() { config.SetGlobal(old) })
+
+	cfg := *old
+	cfg.Notify = config.NotificationConfig{
+		IsEnable: true,
+		Channels: []config.NotifyChannel{
+			{Name: "all", Type: "webhook", Enabled: true},
+			{Name: "failures", Type: "webhook", Enabled: true, Events: []string{string(EventUpdateFailed)}},
+			{Name: "off", Type: "webhook", Enabled: false},
+			{Name: "broken", Type: "webhook", Enabled: true},
+		},
+	}
+	config.SetGlobal(&cfg)
+
+	var mu sync.Mutex
+	sent := make(map[string]int)
+	m := New(filepath.Join(t.TempDir(), "history.json"))
+	m.newNotifier = func(ch config.NotifyChannel) (notify.Notifier, error) {
+		var err error
+		if ch.Name == "broken" {
+			err = errors.New("boom")
+		}
+		return fakeNotifier{name: ch.Name, err: err, mu: &mu, sent: sent}, nil
+	}
+
+	if err := m.send(context.Background(), newTemplateData(EventUpdateSuccess, nil, time.Now())); err == nil {
+		t.Fatal("expected error from broken channel")
+	}
+	if err := m.send(context.Background(), newTemplateData(EventUpdateFailed, nil, time.Now())); err == nil {
+		t.Fatal("expected error from broken channel")
+	}
+
+	if sent["all"] != 2 || sent["failures"] != 1 || sent["off"] != 0 || sent["broken"] != 2 {
+		t.Fatalf("unexpected fan-out counts: %v", sent)
+	}
+
+	statuses := m.ChannelStatuses()
+	if len(statuses) != 4 {
+		t.Fatalf("expected 4 channel statuses, got %d", len(statuses))
+	}
+	for _, st := range statuses {
+		switch st.Name {
+		case "broken":
+			if st.ConsecutiveFailures != 2 || st.LastError == "" {
+				t.Errorf("expected broken channel to record errors, got %+v", st)
+			}
+		case "all":
+			if st.SentCount != 2 || st.LastError != "" {
+				t.Errorf("unexpected status for all: %+v", st)
+			}
+		}
+	}
+}
+
+func TestMatchContainerEventRule(t *testing.T) {
+	labels := map[string]string{"team": "ops", "tier": "db"}
+	cases := []struct {
+		rule config.ContainerEventRule
+		name string
+		want bool
+	}{
+		{config.ContainerEventRule{}, "web", true},
+		{config.ContainerEventRule{Names: []string{"web-*"}}, "web-1", true},
+		{config.ContainerEventRule{Names: []string{"web-*"}}, "db", false},
+		{config.ContainerEventRule{ExcludeNames: []string{"tmp-*"}}, "tmp-1", false},
+		{config.ContainerEventRule{Labels: []string{"tier=db"}}, "pg", true},
+		{config.ContainerEventRule{Labels: []string{"tier=web"}}, "pg", false},
+		{config.ContainerEventRule{Labels: []string{"team"}, ExcludeLabels: []string{"tier=db"}}, "pg", false},
+	}
+	for i, tc := range cases {
+		if got := matchContainerEventRule(tc.rule, tc.name, labels); got != tc.want {
+			t.Errorf("case %d: got %v, want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestNotifyContainerEventAppliesRuleAndCooldown(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	old := config.Get()
+	t.Cleanup(func() { config.SetGlobal(old) })
+
+	cfg := *old
+	cfg.Notify.ContainerEvents.Enabled = true
+	cfg.Notify.ContainerEvents.CooldownMinutes = 30
+	cfg.Notify.ContainerEvents.Removed.Enabled = false
+	config.SetGlobal(&cfg)
+
+	m := New(filepath.Join(t.TempDir(), "history.json"))
+	m.SetBatchDelay(time.Hour)
+	t.Cleanup(func() { m.batchTimer.Stop() })
+
+	died := dockercli.ContainerEvent{Type: dockercli.ContainerDied, Name: "web", ExitCode: "1"}
+	_ = m.NotifyContainerEvent(context.Background(), died)
+	_ = m.NotifyContainerEvent(context.Background(), died)
+	_ = m.NotifyContainerEvent(context.Background(), dockercli.ContainerEvent{Type: dockercli.ContainerRemoved, Name: "web"})
+
+	if len(m.pendingEvents) != 1 || m.pendingEvents[0].Type != EventContainerDied || m.pendingEvents[0].ExitCode != "1" {
+		t.Fatalf("expected a single died event, got %+v", m.pendingEvents)
+	}
+}
@@ -0,0 +1,128 @@
+package notificationmanager
+
+import (
+	"context"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// containerEventTypes 容器生命周期事件类型，按发送顺序排列
+var containerEventTypes = []NotificationEventType{
+	EventContainerDied,
+	EventContainerOOM,
+	EventContainerUnhealthy,
+	EventContainerRestartLoop,
+	EventContainerRemoved,
+}
+
+func isContainerEvent(eventType NotificationEventType) bool {
+	return slices.Contains(containerEventTypes, eventType)
+}
+
+// containerEventRule 返回 Docker 事件对应的通知事件类型与过滤规则
+func containerEventRule(cfg config.ContainerEventsConfig, t dockercli.ContainerEventType) (NotificationEventType, config.ContainerEventRule, bool) {
+	switch t {
+	case dockercli.ContainerDied:
+		return EventContainerDied, cfg.Died, true
+	case dockercli.ContainerOOM:
+		return EventContainerOOM, cfg.OOM, true
+	case dockercli.ContainerUnhealthy:
+		return EventContainerUnhealthy, cfg.Unhealthy, true
+	case dockercli.ContainerRestartLoop:
+		return EventContainerRestartLoop, cfg.RestartLoop, true
+	case dockercli.ContainerRemoved:
+		return EventContainerRemoved, cfg.Removed, true
+	}
+	return "", config.ContainerEventRule{}, false
+}
+
+// NotifyContainerEvent 通知容器生命周期事件，经过规则过滤与冷却去重后加入批量发送队列
+func (m *Manager) NotifyContainerEvent(ctx context.Context, ev dockercli.ContainerEvent) error {
+	cfg := config.Get().Notify.ContainerEvents
+	if !cfg.Enabled {
+		return nil
+	}
+	eventType, rule, ok := containerEventRule(cfg, ev.Type)
+	if !ok || !rule.Enabled || !matchContainerEventRule(rule, ev.Name, ev.Labels) {
+		return nil
+	}
+	if m.shouldSkipNotification(ev.Name, ev.Image, "", eventType) {
+		logger.Logger.Debug("跳过冷却时间内的重复容器事件通知",
+			zap.String("container", ev.Name),
+			zap.String("type", string(eventType)))
+		return nil
+	}
+	m.markAsNotified(ev.Name, ev.Image, "", eventType)
+
+	cn := ContainerNotification{
+		Type:          eventType,
+		ContainerID:   ev.ContainerID,
+		ContainerName: ev.Name,
+		Image:         ev.Image,
+		ExitCode:      ev.ExitCode,
+		Restarts:      ev.Restarts,
+		Timestamp:     ev.Time,
+	}
+	if cn.Timestamp.IsZero() {
+		cn.Timestamp = time.Now()
+	}
+
+	m.mu.Lock()
+	m.pendingEvents = append(m.pendingEvents, cn)
+	m.scheduleFlush()
+	m.mu.Unlock()
+	return nil
+}
+
+// sendContainerEventNotification 发送容器生命周期事件通知
+func (m *Manager) sendContainerEventNotification(ctx context.Context, eventType NotificationEventType, events []ContainerNotification, timestamp time.Time) error {
+	if len(events) == 0 {
+		return nil
+	}
+	logger.Logger.Info("发送容器事件通知", zap.String("type", string(eventType)), zap.Any("events", events))
+	return m.send(ctx, newTemplateData(eventType, events, timestamp))
+}
+
+// matchContainerEventRule 判断容器是否满足过滤规则：排除规则优先，包含规则为空时匹配全部
+func matchContainerEventRule(rule config.ContainerEventRule, name string, labels map[string]string) bool {
+	name = strings.TrimPrefix(name, "/")
+	if matchAnyName(rule.ExcludeNames, name) || matchAnyLabel(rule.ExcludeLabels, labels) {
+		return false
+	}
+	if len(rule.Names) > 0 && !matchAnyName(rule.Names, name) {
+		return false
+	}
+	if len(rule.Labels) > 0 && !matchAnyLabel(rule.Labels, labels) {
+		return false
+	}
+	return true
+}
+
+func matchAnyName(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, err := path.Match(strings.TrimSpace(p), name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyLabel 标签格式为 key 或 key=value
+func matchAnyLabel(selectors []string, labels map[string]string) bool {
+	for _, sel := range selectors {
+		key, value, hasValue := strings.Cut(strings.TrimSpace(sel), "=")
+		v, ok := labels[key]
+		if ok && (!hasValue || v == value) {
+			return true
+		}
+	}
+	return false
+}
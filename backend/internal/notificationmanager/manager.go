@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
@@ -20,26 +19,33 @@ import (
 
 // Manager 通知管理器
 type Manager struct {
-	notifier      *notify.Notifier
+	newNotifier   func(config.NotifyChannel) (notify.Notifier, error)
 	history       *NotificationHistory
 	historyPath   string
 	pendingEvents []ContainerNotification
 	mu            sync.RWMutex
 	batchTimer    *time.Timer
 	batchDelay    time.Duration // 批量延迟时间，用于合并通知
+
+	statusMu      sync.Mutex
+	channelStatus map[string]*ChannelStatus // 按渠道名称记录最近的发送状态
+
+	eventSentAt map[string]time.Time // 容器生命周期事件最近一次通知的时间，key 为 容器名|事件类型
 }
 
 // New 创建新的通知管理器
-func New(notifier *notify.Notifier, historyPath string) *Manager {
+func New(historyPath string) *Manager {
 	if historyPath == "" {
 		historyPath = "/tmp/watch-docker-notification-history.json"
 	}
 
 	m := &Manager{
-		notifier:      notifier,
+		newNotifier:   notify.New,
 		historyPath:   historyPath,
 		pendingEvents: make([]ContainerNotification, 0),
 		batchDelay:    60 * time.Second, // 30秒内的通知会被合并
+		channelStatus: make(map[string]*ChannelStatus),
+		eventSentAt:   make(map[string]time.Time),
 	}
 
 	m.loadHistory()
@@ -130,6 +136,24 @@ func (m *Manager) NotifyUpdateFailed(ctx context.Context, containerName, image,
 	return nil
 }
 
+// NotifyUpdateRolledBack 通知更新后校验失败并已回滚到旧容器
+func (m *Manager) NotifyUpdateRolledBack(ctx context.Context, containerName, image, reason string) error {
+	cn := ContainerNotification{
+		Type:          EventUpdateRolledBack,
+		ContainerName: containerName,
+		Image:         image,
+		Error:         reason,
+		Timestamp:     time.Now(),
+	}
+
+	m.mu.Lock()
+	m.pendingEvents = append(m.pendingEvents, cn)
+	m.scheduleFlush()
+	m.mu.Unlock()
+
+	return nil
+}
+
 // scheduleFlush 调度批量发送（需要在持有锁的情况下调用）
 func (m *Manager) scheduleFlush() {
 	if m.batchTimer != nil {
@@ -185,6 +209,27 @@ func (m *Manager) flushPendingEvents() {
 			logger.Logger.Error("发送更新失败通知失败", zap.Error(err))
 		}
 	}
+
+	// 发送更新回滚通知
+	if len(batch.UpdateRolledBack) > 0 {
+		if err := m.sendUpdateRolledBackNotification(ctx, batch.UpdateRolledBack, batch.Timestamp); err != nil {
+			logger.Logger.Error("发送更新回滚通知失败", zap.Error(err))
+		}
+	}
+
+	// 发送容器生命周期事件通知
+	for _, eventType := range containerEventTypes {
+		if err := m.sendContainerEventNotification(ctx, eventType, batch.ContainerEvents[eventType], batch.Timestamp); err != nil {
+			logger.Logger.Error("发送容器事件通知失败", zap.String("type", string(eventType)), zap.Error(err))
+		}
+	}
+
+	// 发送资源告警通知
+	for _, eventType := range resourceAlertTypes {
+		if err := m.sendResourceAlertNotification(ctx, eventType, batch.ContainerEvents[eventType], batch.Timestamp); err != nil {
+			logger.Logger.Error("发送资源告警通知失败", zap.String("type", string(eventType)), zap.Error(err))
+		}
+	}
 }
 
 // groupEventsByType 按事件类型分组
@@ -201,6 +246,13 @@ func (m *Manager) groupEventsByType(events []ContainerNotification) Notification
 			batch.UpdateSuccess = append(batch.UpdateSuccess, event)
 		case EventUpdateFailed:
 			batch.UpdateFailed = append(batch.UpdateFailed, event)
+		case EventUpdateRolledBack:
+			batch.UpdateRolledBack = append(batch.UpdateRolledBack, event)
+		default:
+			if batch.ContainerEvents == nil {
+				batch.ContainerEvents = make(map[NotificationEventType][]ContainerNotification)
+			}
+			batch.ContainerEvents[event.Type] = append(batch.ContainerEvents[event.Type], event)
 		}
 	}
 
@@ -213,24 +265,7 @@ func (m *Manager) sendUpdateAvailableNotification(ctx context.Context, events []
 		return nil
 	}
 	logger.Logger.Info("发送更新可用通知", zap.Any("events", events))
-	var title string
-	if len(events) == 1 {
-		title = "📦 有容器更新可用"
-	} else {
-		title = fmt.Sprintf("📦 有 %d 个容器更新可用", len(events))
-	}
-
-	var contentBuilder strings.Builder
-	contentBuilder.WriteString("发现以下容器有新版本可用:\n")
-
-	for _, event := range events {
-		contentBuilder.WriteString(fmt.Sprintf("🔸 %s\n", event.ContainerName))
-		contentBuilder.WriteString(fmt.Sprintf("   镜像: %s\n", event.Image))
-	}
-
-	contentBuilder.WriteString(fmt.Sprintf("⏰ 检测时间: %s", timestamp.Format("2006-01-02 15:04:05")))
-
-	return m.notifier.Send(ctx, title, contentBuilder.String(), "", "")
+	return m.send(ctx, newTemplateData(EventUpdateAvailable, events, timestamp))
 }
 
 // sendUpdateSuccessNotification 发送更新成功通知
@@ -239,24 +274,7 @@ func (m *Manager) sendUpdateSuccessNotification(ctx context.Context, events []Co
 		return nil
 	}
 	logger.Logger.Info("发送更新成功通知", zap.Any("events", events))
-	var title string
-	if len(events) == 1 {
-		title = "✅ 容器更新成功"
-	} else {
-		title = fmt.Sprintf("✅ %d 个容器更新成功", len(events))
-	}
-
-	var contentBuilder strings.Builder
-	contentBuilder.WriteString("以下容器已成功更新到最新版本:\n")
-
-	for _, event := range events {
-		contentBuilder.WriteString(fmt.Sprintf("🔸 %s\n", event.ContainerName))
-		contentBuilder.WriteString(fmt.Sprintf("   镜像: %s\n", event.Image))
-	}
-
-	contentBuilder.WriteString(fmt.Sprintf("⏰ 更新时间: %s", timestamp.Format("2006-01-02 15:04:05")))
-
-	return m.notifier.Send(ctx, title, contentBuilder.String(), "", "")
+	return m.send(ctx, newTemplateData(EventUpdateSuccess, events, timestamp))
 }
 
 // sendUpdateFailedNotification 发送更新失败通知
@@ -265,32 +283,29 @@ func (m *Manager) sendUpdateFailedNotification(ctx context.Context, events []Con
 		return nil
 	}
 	logger.Logger.Info("发送更新失败通知", zap.Any("events", events))
-	var title string
-	if len(events) == 1 {
-		title = "⭕ 容器更新失败"
-	} else {
-		title = fmt.Sprintf("⭕ %d 个容器更新失败", len(events))
-	}
-
-	var contentBuilder strings.Builder
-	contentBuilder.WriteString("以下容器更新失败，请检查:\n")
+	return m.send(ctx, newTemplateData(EventUpdateFailed, events, timestamp))
+}
 
-	for _, event := range events {
-		contentBuilder.WriteString(fmt.Sprintf("🔸 %s\n", event.ContainerName))
-		contentBuilder.WriteString(fmt.Sprintf("   镜像: %s\n", event.Image))
-		if event.Error != "" {
-			contentBuilder.WriteString(fmt.Sprintf("   错误: %s\n", event.Error))
-		}
+// sendUpdateRolledBackNotification 发送更新回滚通知
+func (m *Manager) sendUpdateRolledBackNotification(ctx context.Context, events []ContainerNotification, timestamp time.Time) error {
+	if len(events) == 0 {
+		return nil
 	}
-
-	contentBuilder.WriteString(fmt.Sprintf("⏰ 失败时间: %s", timestamp.Format("2006-01-02 15:04:05")))
-
-	return m.notifier.Send(ctx, title, contentBuilder.String(), "", "")
+	logger.Logger.Info("发送更新回滚通知", zap.Any("events", events))
+	return m.send(ctx, newTemplateData(EventUpdateRolledBack, events, timestamp))
 }
 
 // shouldSkipNotification 检查是否应该跳过通知（去重逻辑）
+// UpdateAvailable 事件按 容器|镜像|摘要 每天只通知一次；容器生命周期事件在冷却时间内只通知一次
 func (m *Manager) shouldSkipNotification(containerName, image, digest string, eventType NotificationEventType) bool {
-	// 只对 UpdateAvailable 事件进行去重
+	if isContainerEvent(eventType) {
+		cooldown := time.Duration(config.Get().Notify.ContainerEvents.CooldownMinutes) * time.Minute
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		last, ok := m.eventSentAt[containerName+"|"+string(eventType)]
+		return ok && time.Since(last) < cooldown
+	}
+	// 其余事件中只对 UpdateAvailable 事件进行去重
 	if eventType != EventUpdateAvailable {
 		return false
 	}
@@ -310,7 +325,13 @@ func (m *Manager) shouldSkipNotification(containerName, image, digest string, ev
 
 // markAsNotified 标记为已通知
 func (m *Manager) markAsNotified(containerName, image, digest string, eventType NotificationEventType) {
-	// 只对 UpdateAvailable 事件进行标记
+	if isContainerEvent(eventType) {
+		m.mu.Lock()
+		m.eventSentAt[containerName+"|"+string(eventType)] = time.Now()
+		m.mu.Unlock()
+		return
+	}
+	// 其余事件中只对 UpdateAvailable 事件进行标记
 	if eventType != EventUpdateAvailable {
 		return
 	}
@@ -0,0 +1,49 @@
+package notificationmanager
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/updater"
+
+	"go.uber.org/zap"
+)
+
+// NotifyUpdatePlan 立即发送更新计划，只列出有可用更新的容器，按更新顺序排列
+func (m *Manager) NotifyUpdatePlan(ctx context.Context, plan *updater.Plan) error {
+	events := make([]ContainerNotification, 0)
+	for _, c := range plan.Containers {
+		if c.PullImage == "" {
+			continue
+		}
+		cn := ContainerNotification{
+			Type:          EventUpdatePlan,
+			ContainerID:   c.ID,
+			ContainerName: c.Name,
+			Image:         c.PullImage,
+			CurrentDigest: c.CurrentDigest,
+			RemoteDigest:  c.RemoteDigest,
+			Timestamp:     plan.GeneratedAt,
+			Decision:      c.Decision,
+			Reason:        c.Reason,
+			Order:         c.Order,
+		}
+		for _, d := range c.Dependants {
+			cn.Dependants = append(cn.Dependants, d.Name+"("+d.Action+")")
+		}
+		events = append(events, cn)
+	}
+	// 有更新顺序的排在前面，其余保持原顺序
+	sort.SliceStable(events, func(i, j int) bool {
+		oi, oj := events[i].Order, events[j].Order
+		if oi == 0 || oj == 0 {
+			return oi != 0 && oj == 0
+		}
+		return oi < oj
+	})
+
+	logger.Logger.Info("发送更新计划通知", zap.Int("count", len(events)))
+	return m.send(ctx, newTemplateData(EventUpdatePlan, events, time.Now()))
+}
@@ -0,0 +1,55 @@
+package notificationmanager
+
+import (
+	"context"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/alerting"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// resourceAlertTypes 资源告警事件类型，按发送顺序排列
+var resourceAlertTypes = []NotificationEventType{
+	EventResourceAlert,
+	EventResourceResolved,
+}
+
+// NotifyResourceAlert 通知资源告警的触发或解除，加入批量发送队列
+func (m *Manager) NotifyResourceAlert(ctx context.Context, a alerting.Alert, resolved bool) error {
+	cn := ContainerNotification{
+		Type:          EventResourceAlert,
+		ContainerID:   a.ContainerID,
+		ContainerName: a.ContainerName,
+		Image:         a.Image,
+		Timestamp:     a.FiredAt,
+		Rule:          a.Rule,
+		Metric:        a.Metric,
+		Value:         a.Value,
+		Threshold:     a.Threshold,
+		Since:         a.Since,
+	}
+	if resolved {
+		cn.Type = EventResourceResolved
+		cn.Timestamp = a.ResolvedAt
+	}
+	if cn.Timestamp.IsZero() {
+		cn.Timestamp = time.Now()
+	}
+
+	m.mu.Lock()
+	m.pendingEvents = append(m.pendingEvents, cn)
+	m.scheduleFlush()
+	m.mu.Unlock()
+	return nil
+}
+
+// sendResourceAlertNotification 发送资源告警通知
+func (m *Manager) sendResourceAlertNotification(ctx context.Context, eventType NotificationEventType, events []ContainerNotification, timestamp time.Time) error {
+	if len(events) == 0 {
+		return nil
+	}
+	logger.Logger.Info("发送资源告警通知", zap.String("type", string(eventType)), zap.Int("count", len(events)))
+	return m.send(ctx, newTemplateData(eventType, events, timestamp))
+}
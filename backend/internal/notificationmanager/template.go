@@ -0,0 +1,411 @@
+package notificationmanager
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// 内置模板语言
+const (
+	LangZh = "zh"
+	LangEn = "en"
+)
+
+// TemplateData 渲染通知模板时可以使用的数据
+//
+//	.Type       事件类型
+//	.Count      本批事件数量
+//	.Events     本批事件（ContainerNotification，包含 ContainerName/Image/CurrentDigest/RemoteDigest/NewTag/Error/Timestamp 等）
+//	.Timestamp  本批通知的发送时间
+type TemplateData struct {
+	Type      NotificationEventType   `json:"type"`
+	Count     int                     `json:"count"`
+	Events    []ContainerNotification `json:"events"`
+	Timestamp time.Time               `json:"timestamp"`
+}
+
+// Template 一个事件类型的通知标题与正文模板
+type Template struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// templateFuncs 模板中可用的函数
+var templateFuncs = template.FuncMap{
+	// formatTime 按 2006-01-02 15:04:05 格式化时间
+	"formatTime": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+	// date 按指定 layout 格式化时间，如 {{date "15:04" .Timestamp}}
+	"date": func(layout string, t time.Time) string { return t.Format(layout) },
+	// shortDigest 截取 sha256 摘要的前 12 位
+	"shortDigest": func(d string) string {
+		d = strings.TrimPrefix(d, "sha256:")
+		if i := strings.LastIndex(d, "@sha256:"); i >= 0 {
+			d = d[i+len("@sha256:"):]
+		}
+		if len(d) > 12 {
+			return d[:12]
+		}
+		return d
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// EventTypes 返回支持模板的事件类型
+func EventTypes() []NotificationEventType {
+	types := append([]NotificationEventType{EventUpdateAvailable, EventUpdateSuccess, EventUpdateFailed, EventUpdateRolledBack}, containerEventTypes...)
+	types = append(types, EventUpdatePlan)
+	return append(types, resourceAlertTypes...)
+}
+
+// defaultTemplates 内置默认模板，按语言与事件类型索引
+var defaultTemplates = map[string]map[NotificationEventType]Template{
+	LangZh: {
+		EventUpdateAvailable: {
+			Title: `📦 有{{if gt .Count 1}} {{.Count}} 个{{end}}容器更新可用`,
+			Content: `发现以下容器有新版本可用:
+{{range .Events}}🔸 {{.ContainerName}}
+   镜像: {{.Image}}
+{{if .NewTag}}   新版本: {{.NewTag}}
+{{end}}{{end}}⏰ 检测时间: {{formatTime .Timestamp}}`,
+		},
+		EventUpdateSuccess: {
+			Title: `✅ {{if gt .Count 1}}{{.Count}} 个{{end}}容器更新成功`,
+			Content: `以下容器已成功更新到最新版本:
+{{range .Events}}🔸 {{.ContainerName}}
+   镜像: {{.Image}}
+{{end}}⏰ 更新时间: {{formatTime .Timestamp}}`,
+		},
+		EventUpdateFailed: {
+			Title: `⭕ {{if gt .Count 1}}{{.Count}} 个{{end}}容器更新失败`,
+			Content: `以下容器更新失败，请检查:
+{{range .Events}}🔸 {{.ContainerName}}
+   镜像: {{.Image}}
+{{if .Error}}   错误: {{.Error}}
+{{end}}{{end}}⏰ 失败时间: {{formatTime .Timestamp}}`,
+		},
+		EventUpdateRolledBack: {
+			Title: `↩️ {{if gt .Count 1}}{{.Count}} 个{{end}}容器更新已回滚`,
+			Content: `以下容器更新后校验失败，已恢复为旧容器:
+{{range .Events}}🔸 {{.ContainerName}}
+   镜像: {{.Image}}
+{{if .Error}}   原因: {{.Error}}
+{{end}}{{end}}⏰ 回滚时间: {{formatTime .Timestamp}}`,
+		},
+		EventContainerDied: {
+			Title: `💥 {{if gt .Count 1}}{{.Count}} 个{{end}}容器异常退出`,
+			Content: `以下容器以非 0 退出码退出:
+{{range .Events}}🔸 {{.ContainerName}}
+   镜像: {{.Image}}
+   退出码: {{.ExitCode}}
+   时间: {{formatTime .Timestamp}}
+{{end}}`,
+		},
+		EventContainerOOM: {
+			Title: `🧠 {{if gt .Count 1}}{{.Count}} 个{{end}}容器内存不足被终止`,
+			Content: `以下容器因内存不足（OOM）被终止:
+{{range .Events}}🔸 {{.ContainerName}}
+   镜像: {{.Image}}
+   时间: {{formatTime .Timestamp}}
+{{end}}`,
+		},
+		EventContainerUnhealthy: {
+			Title: `🩺 {{if gt .Count 1}}{{.Count}} 个{{end}}容器健康检查失败`,
+			Content: `以下容器的健康状态变为 unhealthy:
+{{range .Events}}🔸 {{.ContainerName}}
+   镜像: {{.Image}}
+   时间: {{formatTime .Timestamp}}
+{{end}}`,
+		},
+		EventContainerRestartLoop: {
+			Title: `🔁 {{if gt .Count 1}}{{.Count}} 个{{end}}容器反复重启`,
+			Content: `以下容器短时间内反复重启:
+{{range .Events}}🔸 {{.ContainerName}}
+   镜像: {{.Image}}
+   启动次数: {{.Restarts}}
+   时间: {{formatTime .Timestamp}}
+{{end}}`,
+		},
+		EventContainerRemoved: {
+			Title: `🗑️ {{if gt .Count 1}}{{.Count}} 个{{end}}容器已被删除`,
+			Content: `以下容器已被删除:
+{{range .Events}}🔸 {{.ContainerName}}
+   镜像: {{.Image}}
+   时间: {{formatTime .Timestamp}}
+{{end}}`,
+		},
+		EventUpdatePlan: {
+			Title: `📝 更新计划：{{if .Count}}{{.Count}} 个容器有可用更新{{else}}没有可用更新{{end}}`,
+			Content: `{{range .Events}}{{if .Order}}{{.Order}}. {{else}}- {{end}}{{.ContainerName}} [{{.Decision}}]
+   镜像: {{.Image}}
+{{if .Reason}}   说明: {{.Reason}}
+{{end}}{{if .Dependants}}   依赖容器: {{join .Dependants ", "}}
+{{end}}{{else}}没有需要更新的容器
+{{end}}⏰ 生成时间: {{formatTime .Timestamp}}`,
+		},
+		EventResourceAlert: {
+			Title: `🔥 {{if gt .Count 1}}{{.Count}} 个{{end}}容器资源告警`,
+			Content: `以下容器的资源使用超过阈值:
+{{range .Events}}🔸 {{.ContainerName}}
+   规则: {{.Rule}}
+   当前值: {{printf "%.1f" .Value}}%（阈值 {{printf "%g" .Threshold}}%）
+   开始时间: {{formatTime .Since}}
+{{end}}⏰ 告警时间: {{formatTime .Timestamp}}`,
+		},
+		EventResourceResolved: {
+			Title: `🟢 {{if gt .Count 1}}{{.Count}} 个{{end}}容器资源告警已解除`,
+			Content: `以下容器的资源告警已解除:
+{{range .Events}}🔸 {{.ContainerName}}
+   规则: {{.Rule}}
+   开始时间: {{formatTime .Since}}
+{{end}}⏰ 解除时间: {{formatTime .Timestamp}}`,
+		},
+	},
+	LangEn: {
+		EventUpdateAvailable: {
+			Title: `📦 {{if gt .Count 1}}{{.Count}} container updates{{else}}Container update{{end}} available`,
+			Content: `New versions are available for:
+{{range .Events}}🔸 {{.ContainerName}}
+   Image: {{.Image}}
+{{if .NewTag}}   New tag: {{.NewTag}}
+{{end}}{{end}}⏰ Checked at: {{formatTime .Timestamp}}`,
+		},
+		EventUpdateSuccess: {
+			Title: `✅ {{if gt .Count 1}}{{.Count}} containers{{else}}Container{{end}} updated`,
+			Content: `The following containers were updated successfully:
+{{range .Events}}🔸 {{.ContainerName}}
+   Image: {{.Image}}
+{{end}}⏰ Updated at: {{formatTime .Timestamp}}`,
+		},
+		EventUpdateFailed: {
+			Title: `⭕ {{if gt .Count 1}}{{.Count}} container updates{{else}}Container update{{end}} failed`,
+			Content: `The following containers failed to update:
+{{range .Events}}🔸 {{.ContainerName}}
+   Image: {{.Image}}
+{{if .Error}}   Error: {{.Error}}
+{{end}}{{end}}⏰ Failed at: {{formatTime .Timestamp}}`,
+		},
+		EventUpdateRolledBack: {
+			Title: `↩️ {{if gt .Count 1}}{{.Count}} container updates{{else}}Container update{{end}} rolled back`,
+			Content: `The following containers failed verification after updating and were restored:
+{{range .Events}}🔸 {{.ContainerName}}
+   Image: {{.Image}}
+{{if .Error}}   Reason: {{.Error}}
+{{end}}{{end}}⏰ Rolled back at: {{formatTime .Timestamp}}`,
+		},
+		EventContainerDied: {
+			Title: `💥 {{if gt .Count 1}}{{.Count}} containers{{else}}Container{{end}} exited unexpectedly`,
+			Content: `The following containers exited with a non-zero code:
+{{range .Events}}🔸 {{.ContainerName}}
+   Image: {{.Image}}
+   Exit code: {{.ExitCode}}
+   Time: {{formatTime .Timestamp}}
+{{end}}`,
+		},
+		EventContainerOOM: {
+			Title: `🧠 {{if gt .Count 1}}{{.Count}} containers{{else}}Container{{end}} killed by OOM`,
+			Content: `The following containers ran out of memory and were killed:
+{{range .Events}}🔸 {{.ContainerName}}
+   Image: {{.Image}}
+   Time: {{formatTime .Timestamp}}
+{{end}}`,
+		},
+		EventContainerUnhealthy: {
+			Title: `🩺 {{if gt .Count 1}}{{.Count}} containers{{else}}Container{{end}} unhealthy`,
+			Content: `The following containers became unhealthy:
+{{range .Events}}🔸 {{.ContainerName}}
+   Image: {{.Image}}
+   Time: {{formatTime .Timestamp}}
+{{end}}`,
+		},
+		EventContainerRestartLoop: {
+			Title: `🔁 {{if gt .Count 1}}{{.Count}} containers{{else}}Container{{end}} in a restart loop`,
+			Content: `The following containers keep restarting:
+{{range .Events}}🔸 {{.ContainerName}}
+   Image: {{.Image}}
+   Starts: {{.Restarts}}
+   Time: {{formatTime .Timestamp}}
+{{end}}`,
+		},
+		EventContainerRemoved: {
+			Title: `🗑️ {{if gt .Count 1}}{{.Count}} containers{{else}}Container{{end}} removed`,
+			Content: `The following containers were removed:
+{{range .Events}}🔸 {{.ContainerName}}
+   Image: {{.Image}}
+   Time: {{formatTime .Timestamp}}
+{{end}}`,
+		},
+		EventUpdatePlan: {
+			Title: `📝 Update plan: {{if .Count}}{{.Count}} container updates available{{else}}no updates available{{end}}`,
+			Content: `{{range .Events}}{{if .Order}}{{.Order}}. {{else}}- {{end}}{{.ContainerName}} [{{.Decision}}]
+   Image: {{.Image}}
+{{if .Reason}}   Note: {{.Reason}}
+{{end}}{{if .Dependants}}   Dependants: {{join .Dependants ", "}}
+{{end}}{{else}}No containers need updating
+{{end}}⏰ Generated at: {{formatTime .Timestamp}}`,
+		},
+		EventResourceAlert: {
+			Title: `🔥 {{if gt .Count 1}}{{.Count}} container resource alerts{{else}}Container resource alert{{end}}`,
+			Content: `The following containers exceeded a resource threshold:
+{{range .Events}}🔸 {{.ContainerName}}
+   Rule: {{.Rule}}
+   Value: {{printf "%.1f" .Value}}% (threshold {{printf "%g" .Threshold}}%)
+   Since: {{formatTime .Since}}
+{{end}}⏰ Fired at: {{formatTime .Timestamp}}`,
+		},
+		EventResourceResolved: {
+			Title: `🟢 {{if gt .Count 1}}{{.Count}} container resource alerts{{else}}Container resource alert{{end}} resolved`,
+			Content: `The following resource alerts have been resolved:
+{{range .Events}}🔸 {{.ContainerName}}
+   Rule: {{.Rule}}
+   Since: {{formatTime .Since}}
+{{end}}⏰ Resolved at: {{formatTime .Timestamp}}`,
+		},
+	},
+}
+
+// DefaultTemplates 返回指定语言的内置模板，未知语言返回中文模板
+func DefaultTemplates(lang string) map[NotificationEventType]Template {
+	if tpls, ok := defaultTemplates[normalizeLang(lang)]; ok {
+		return tpls
+	}
+	return defaultTemplates[LangZh]
+}
+
+func normalizeLang(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if strings.HasPrefix(lang, LangEn) {
+		return LangEn
+	}
+	return LangZh
+}
+
+// newTemplateData 构造一批事件的模板数据
+func newTemplateData(eventType NotificationEventType, events []ContainerNotification, timestamp time.Time) TemplateData {
+	return TemplateData{Type: eventType, Count: len(events), Events: events, Timestamp: timestamp}
+}
+
+// RenderTemplate 使用给定数据渲染模板
+func RenderTemplate(tpl Template, data TemplateData) (title, content string, err error) {
+	if title, err = renderText("title", tpl.Title, data); err != nil {
+		return "", "", err
+	}
+	if content, err = renderText("content", tpl.Content, data); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(title), strings.TrimSpace(content), nil
+}
+
+func renderText(name, text string, data TemplateData) (string, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// templateFor 返回渠道使用的模板：渠道自定义模板 > 全局自定义模板 > 内置默认模板。
+// 自定义模板只填写了标题或正文时，另一部分使用内置默认模板。
+func templateFor(cfg config.NotificationConfig, ch config.NotifyChannel, eventType NotificationEventType) Template {
+	lang := cfg.Language
+	if ch.Language != "" {
+		lang = ch.Language
+	}
+	tpl := DefaultTemplates(lang)[eventType]
+	for _, custom := range []map[string]config.NotifyTemplate{cfg.Templates, ch.Templates} {
+		c, ok := custom[string(eventType)]
+		if !ok {
+			continue
+		}
+		if strings.TrimSpace(c.Title) != "" {
+			tpl.Title = c.Title
+		}
+		if strings.TrimSpace(c.Content) != "" {
+			tpl.Content = c.Content
+		}
+	}
+	return tpl
+}
+
+// render 渲染渠道使用的模板，自定义模板出错时记录日志并回退到内置默认模板，保证通知仍能发出
+func render(cfg config.NotificationConfig, ch config.NotifyChannel, data TemplateData) (string, string) {
+	title, content, err := RenderTemplate(templateFor(cfg, ch, data.Type), data)
+	if err == nil {
+		return title, content
+	}
+	logger.Logger.Warn("渲染通知模板失败，使用默认模板",
+		zap.String("channel", ch.Name),
+		zap.String("type", string(data.Type)),
+		zap.Error(err))
+	lang := cfg.Language
+	if ch.Language != "" {
+		lang = ch.Language
+	}
+	title, content, err = RenderTemplate(DefaultTemplates(lang)[data.Type], data)
+	if err != nil {
+		// 内置模板不应出错，兜底发送事件类型
+		return string(data.Type), ""
+	}
+	return title, content
+}
+
+// SampleTemplateData 返回用于预览模板的示例数据
+func SampleTemplateData(eventType NotificationEventType) TemplateData {
+	now := time.Now()
+	events := []ContainerNotification{
+		{
+			Type:          eventType,
+			ContainerID:   "3f4e8a1b2c7d",
+			ContainerName: "nginx",
+			Image:         "nginx:1.25",
+			CurrentDigest: []string{"nginx@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac"},
+			RemoteDigest:  "sha256:9a8a1ef05d3b6e9a0c4b1d8e23f7c5b60e2d8a7f4b1c3e5d6f7a8b9c0d1e2f3a",
+			NewTag:        "1.26",
+			Timestamp:     now,
+		},
+		{
+			Type:          eventType,
+			ContainerID:   "8b9c0d1e2f3a",
+			ContainerName: "redis",
+			Image:         "redis:7",
+			CurrentDigest: []string{"redis@sha256:1b7c17d0a6e2f0e6f5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2"},
+			RemoteDigest:  "sha256:5e4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e0d9c8b7a6f5e4d",
+			Timestamp:     now,
+		},
+	}
+	switch eventType {
+	case EventUpdateFailed:
+		events[0].Error = "pull image: manifest unknown"
+	case EventUpdateRolledBack:
+		events[0].Error = "容器健康检查未通过: unhealthy"
+	case EventContainerDied:
+		events[0].ExitCode = "1"
+		events[1].ExitCode = "137"
+	case EventContainerRestartLoop:
+		events[0].Restarts = 3
+		events[1].Restarts = 5
+	case EventResourceAlert, EventResourceResolved:
+		for i := range events {
+			events[i].Rule = "cpu>90% for 300s"
+			events[i].Metric = "cpu"
+			events[i].Threshold = 90
+			events[i].Since = now.Add(-5 * time.Minute)
+		}
+		events[0].Value = 97.3
+		events[1].Value = 92.8
+	}
+	return newTemplateData(eventType, events, now)
+}
@@ -0,0 +1,100 @@
+package notificationmanager
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"go.uber.org/zap"
+)
+
+func TestDefaultTemplatesRenderEveryEvent(t *testing.T) {
+	for _, lang := range []string{LangZh, LangEn} {
+		for _, eventType := range EventTypes() {
+			tpl, ok := DefaultTemplates(lang)[eventType]
+			if !ok {
+				t.Fatalf("missing %s template for %s", lang, eventType)
+			}
+			title, content, err := RenderTemplate(tpl, SampleTemplateData(eventType))
+			if err != nil {
+				t.Fatalf("%s/%s: %v", lang, eventType, err)
+			}
+			if title == "" || !strings.Contains(content, "nginx") {
+				t.Errorf("%s/%s rendered unexpected output: %q / %q", lang, eventType, title, content)
+			}
+		}
+	}
+}
+
+func TestDefaultZhTemplateMatchesLegacyText(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local)
+	events := []ContainerNotification{{ContainerName: "web", Image: "nginx:1.25", NewTag: "1.26"}}
+	title, content, err := RenderTemplate(DefaultTemplates(LangZh)[EventUpdateAvailable], newTemplateData(EventUpdateAvailable, events, ts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "📦 有容器更新可用" {
+		t.Errorf("unexpected title %q", title)
+	}
+	want := "发现以下容器有新版本可用:\n🔸 web\n   镜像: nginx:1.25\n   新版本: 1.26\n⏰ 检测时间: 2024-05-01 08:30:00"
+	if content != want {
+		t.Errorf("unexpected content:\n%s\nwant:\n%s", content, want)
+	}
+}
+
+func TestTemplateForPrefersChannelThenGlobalAndFallsBack(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	cfg := config.NotificationConfig{
+		Language: LangEn,
+		Templates: map[string]config.NotifyTemplate{
+			string(EventUpdateFailed): {Title: "global {{.Count}}"},
+		},
+	}
+	data := SampleTemplateData(EventUpdateFailed)
+
+	title, content := render(cfg, config.NotifyChannel{Name: "a"}, data)
+	if title != "global 2" || !strings.Contains(content, "failed to update") {
+		t.Errorf("expected global title with english content, got %q / %q", title, content)
+	}
+
+	ch := config.NotifyChannel{Name: "b", Templates: map[string]config.NotifyTemplate{
+		string(EventUpdateFailed): {Content: "{{range .Events}}*{{.ContainerName}}* {{.Error}}{{end}}"},
+	}}
+	if _, content := render(cfg, ch, data); content != "*nginx* pull image: manifest unknown*redis*" {
+		t.Errorf("expected channel content template, got %q", content)
+	}
+
+	broken := config.NotifyChannel{Name: "c", Templates: map[string]config.NotifyTemplate{
+		string(EventUpdateFailed): {Title: "{{.Missing"},
+	}}
+	if title, _ := render(cfg, broken, data); !strings.Contains(title, "failed") {
+		t.Errorf("expected fallback to default template, got %q", title)
+	}
+}
+
+func TestUpdatePlanTemplateListsOrderAndDependants(t *testing.T) {
+	now := time.Date(2024, 5, 1, 3, 0, 0, 0, time.Local)
+	events := []ContainerNotification{
+		{ContainerName: "vpn", Image: "wireguard:latest", Decision: "update", Order: 1, Dependants: []string{"torrent(recreate)"}},
+		{ContainerName: "db", Image: "postgres:16", Decision: "notify_only", Reason: "自动更新开关关闭"},
+	}
+	_, content, err := RenderTemplate(DefaultTemplates(LangZh)[EventUpdatePlan], newTemplateData(EventUpdatePlan, events, now))
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	for _, want := range []string{"1. vpn [update]", "依赖容器: torrent(recreate)", "- db [notify_only]", "说明: 自动更新开关关闭"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("expected %q in content:\n%s", want, content)
+		}
+	}
+
+	title, content, err := RenderTemplate(DefaultTemplates(LangEn)[EventUpdatePlan], newTemplateData(EventUpdatePlan, nil, now))
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(title, "no updates available") || !strings.Contains(content, "No containers need updating") {
+		t.Fatalf("unexpected empty plan rendering: %q / %q", title, content)
+	}
+}
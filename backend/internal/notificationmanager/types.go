@@ -13,6 +13,22 @@ const (
 	EventUpdateAvailable NotificationEventType = "update_available"
 	EventUpdateSuccess   NotificationEventType = "update_success"
 	EventUpdateFailed    NotificationEventType = "update_failed"
+	// EventUpdateRolledBack 新容器启动后校验失败，已自动回滚到旧容器
+	EventUpdateRolledBack NotificationEventType = "update_rolled_back"
+
+	// 以下为从 Docker 事件流识别出的容器生命周期事件
+	EventContainerDied        NotificationEventType = "container_died"         // 容器以非 0 退出码退出
+	EventContainerOOM         NotificationEventType = "container_oom"          // 容器因内存不足被杀死
+	EventContainerUnhealthy   NotificationEventType = "container_unhealthy"    // 健康检查变为 unhealthy
+	EventContainerRestartLoop NotificationEventType = "container_restart_loop" // 短时间内反复重启
+	EventContainerRemoved     NotificationEventType = "container_removed"      // 容器被删除
+
+	// EventUpdatePlan 计划模式生成的更新计划
+	EventUpdatePlan NotificationEventType = "update_plan"
+
+	// 资源告警
+	EventResourceAlert    NotificationEventType = "resource_alert"    // 资源使用持续超过阈值
+	EventResourceResolved NotificationEventType = "resource_resolved" // 资源告警已解除
 )
 
 // ContainerNotification 表示一个容器的通知事件
@@ -23,16 +39,33 @@ type ContainerNotification struct {
 	Image         string                `json:"image"`
 	CurrentDigest []string              `json:"current_digest"`
 	RemoteDigest  string                `json:"remote_digest"`
+	NewTag        string                `json:"new_tag,omitempty"` // 语义化版本升级时的新 tag
 	Timestamp     time.Time             `json:"timestamp"`
-	Error         string                `json:"error,omitempty"` // 只有在失败时才有
+	Error         string                `json:"error,omitempty"`     // 只有在失败时才有
+	ExitCode      string                `json:"exit_code,omitempty"` // 容器退出码（container_died）
+	Restarts      int                   `json:"restarts,omitempty"`  // 窗口内的启动次数（container_restart_loop）
+	// 以下字段仅用于 update_plan
+	Decision   string   `json:"decision,omitempty"`   // update/queued/notify_only
+	Reason     string   `json:"reason,omitempty"`     // 决策说明
+	Order      int      `json:"order,omitempty"`      // 更新顺序，从 1 开始
+	Dependants []string `json:"dependants,omitempty"` // 更新后需要重建或重启的依赖容器
+	// 以下字段仅用于 resource_alert / resource_resolved
+	Rule      string    `json:"rule,omitempty"`      // 告警规则名称
+	Metric    string    `json:"metric,omitempty"`    // cpu/memory/pids
+	Value     float64   `json:"value,omitempty"`     // 触发或解除前的值（百分比）
+	Threshold float64   `json:"threshold,omitempty"` // 阈值（百分比）
+	Since     time.Time `json:"since,omitempty"`     // 开始超过阈值的时间
 }
 
 // NotificationBatch 表示一批通知事件
 type NotificationBatch struct {
-	UpdateAvailable []ContainerNotification `json:"update_available"`
-	UpdateSuccess   []ContainerNotification `json:"update_success"`
-	UpdateFailed    []ContainerNotification `json:"update_failed"`
-	Timestamp       time.Time               `json:"timestamp"`
+	UpdateAvailable  []ContainerNotification `json:"update_available"`
+	UpdateSuccess    []ContainerNotification `json:"update_success"`
+	UpdateFailed     []ContainerNotification `json:"update_failed"`
+	UpdateRolledBack []ContainerNotification `json:"update_rolled_back"`
+	// ContainerEvents 容器生命周期事件，按事件类型分组
+	ContainerEvents map[NotificationEventType][]ContainerNotification `json:"container_events,omitempty"`
+	Timestamp       time.Time                                         `json:"timestamp"`
 }
 
 // DeduplicationKey 用于去重的键
@@ -58,5 +91,20 @@ func (cn *ContainerNotification) FromContainerStatus(cs scanner.ContainerStatus,
 	cn.Image = cs.Image
 	cn.CurrentDigest = cs.CurrentDigest
 	cn.RemoteDigest = cs.RemoteDigest
+	cn.NewTag = cs.NewTag
 	cn.Timestamp = time.Now()
 }
+
+// ChannelStatus 通知渠道的发送状态
+type ChannelStatus struct {
+	Name                string    `json:"name"`
+	Type                string    `json:"type"`
+	Enabled             bool      `json:"enabled"`
+	Events              []string  `json:"events"`
+	SentCount           int       `json:"sent_count"`
+	FailedCount         int       `json:"failed_count"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastErrorAt         time.Time `json:"last_error_at"`
+	LastSuccessAt       time.Time `json:"last_success_at"`
+}
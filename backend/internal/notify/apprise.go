@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+)
+
+// ParseAppriseURL 将 Apprise 风格的通知 URL 解析为渠道配置，支持：
+//
+//	tgram://{botToken}/{chatId}
+//	bark://{host}/{deviceKey}          barks:// 使用 https
+//	gotify://{host}/{token}            gotifys:// 使用 https
+//	ntfy://{host}/{topic}              ntfys:// 使用 https；ntfy://{topic} 使用 ntfy.sh
+//	schan://{sendKey}
+//	wecombot://{key}
+//	dingtalk://{secret}@{token}        secret 可省略
+//	feishu://{secret}@{token}          secret 可省略
+//	mailto://{user}:{pass}@{host}:{port}?to=a@x.com,b@x.com&from=...   mailtos:// 默认使用 465 端口
+//	json://{host}/{path}               jsons:// 使用 https，以 POST JSON 方式发送
+func ParseAppriseURL(raw string) (config.NotifyChannel, error) {
+	var ch config.NotifyChannel
+	raw = strings.TrimSpace(raw)
+	// Telegram 的 bot token 中含有冒号，无法按 host:port 解析，单独处理
+	if rest, ok := strings.CutPrefix(raw, "tgram://"); ok {
+		token, chatID, _ := strings.Cut(strings.Trim(rest, "/"), "/")
+		chatID, _, _ = strings.Cut(chatID, "/")
+		if token == "" || chatID == "" {
+			return ch, fmt.Errorf("apprise url tgram://{botToken}/{chatId} requires bot token and chat id")
+		}
+		ch.Type = TypeTelegram
+		ch.Token = token
+		ch.ChatID = chatID
+		return ch, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ch, fmt.Errorf("invalid apprise url: %w", err)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	secure := strings.HasSuffix(scheme, "s") && scheme != "schan"
+	httpScheme := "http"
+	if secure {
+		httpScheme = "https"
+	}
+	// 路径按 / 拆分，去掉空段
+	var parts []string
+	for _, p := range strings.Split(u.Path, "/") {
+		if p != "" {
+			if unescaped, err := url.PathUnescape(p); err == nil {
+				p = unescaped
+			}
+			parts = append(parts, p)
+		}
+	}
+	password, _ := u.User.Password()
+
+	switch scheme {
+	case "bark", "barks":
+		if len(parts) < 1 {
+			return ch, fmt.Errorf("apprise url %s://{host}/{deviceKey} requires device key", scheme)
+		}
+		ch.Type = TypeBark
+		ch.URL = httpScheme + "://" + u.Host
+		ch.Token = parts[0]
+	case "gotify", "gotifys":
+		if len(parts) < 1 {
+			return ch, fmt.Errorf("apprise url %s://{host}/{token} requires token", scheme)
+		}
+		ch.Type = TypeGotify
+		ch.URL = httpScheme + "://" + u.Host + "/" + strings.Join(parts[:len(parts)-1], "/")
+		ch.Token = parts[len(parts)-1]
+	case "ntfy", "ntfys":
+		ch.Type = TypeNtfy
+		if len(parts) == 0 {
+			ch.URL = defaultNtfyServer
+			ch.Topic = u.Host
+		} else {
+			ch.URL = httpScheme + "://" + u.Host
+			ch.Topic = parts[0]
+		}
+		if u.User != nil {
+			if password == "" {
+				ch.Token = u.User.Username()
+			} else {
+				ch.Username = u.User.Username()
+				ch.Password = password
+			}
+		}
+	case "schan":
+		ch.Type = TypeServerChan
+		ch.Token = u.Host
+	case "wecombot":
+		ch.Type = TypeWeCom
+		ch.Token = u.Host
+	case "dingtalk", "feishu":
+		ch.Type = scheme
+		ch.Token = u.Host
+		if u.User != nil {
+			ch.Secret = u.User.Username()
+		}
+	case "mailto", "mailtos":
+		ch.Type = TypeEmail
+		ch.Host = u.Hostname()
+		if p := u.Port(); p != "" {
+			port, err := strconv.Atoi(p)
+			if err != nil {
+				return ch, fmt.Errorf("invalid smtp port: %s", p)
+			}
+			ch.Port = port
+		} else if secure {
+			ch.Port = 465
+		}
+		if u.User != nil {
+			ch.Username = u.User.Username()
+			ch.Password = password
+		}
+		q := u.Query()
+		ch.From = q.Get("from")
+		for _, to := range strings.Split(q.Get("to"), ",") {
+			if to = strings.TrimSpace(to); to != "" {
+				ch.To = append(ch.To, to)
+			}
+		}
+		if len(ch.To) == 0 && ch.Username != "" {
+			if strings.Contains(ch.Username, "@") {
+				ch.To = []string{ch.Username}
+			} else {
+				ch.To = []string{ch.Username + "@" + ch.Host}
+			}
+		}
+	case "json", "jsons":
+		ch.Type = TypeWebhook
+		ch.Method = "POST"
+		target := url.URL{Scheme: httpScheme, Host: u.Host, Path: u.Path, RawQuery: u.RawQuery, User: u.User}
+		ch.URL = target.String()
+	default:
+		return ch, fmt.Errorf("unsupported apprise url scheme: %s", u.Scheme)
+	}
+	return ch, nil
+}
@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+)
+
+// email 通过 SMTP 发送邮件。端口为 465 时使用 SMTPS（隐式 TLS），其他端口在服务器支持时使用 STARTTLS。
+type email struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func newEmail(ch config.NotifyChannel) (Notifier, error) {
+	if err := requireFields(TypeEmail, "host", ch.Host); err != nil {
+		return nil, err
+	}
+	if len(ch.To) == 0 {
+		return nil, fmt.Errorf("%s channel requires to", TypeEmail)
+	}
+	port := ch.Port
+	if port == 0 {
+		port = 587
+	}
+	from := strings.TrimSpace(ch.From)
+	if from == "" {
+		from = ch.Username
+	}
+	if from == "" {
+		return nil, fmt.Errorf("%s channel requires from", TypeEmail)
+	}
+	return &email{
+		host:     strings.TrimSpace(ch.Host),
+		port:     port,
+		username: ch.Username,
+		password: ch.Password,
+		from:     from,
+		to:       ch.To,
+	}, nil
+}
+
+func (e *email) Send(ctx context.Context, msg Message) error {
+	content := msg.Content
+	if msg.URL != "" {
+		content += "\r\n\r\n" + msg.URL
+	}
+	var b strings.Builder
+	b.WriteString("From: " + e.from + "\r\n")
+	b.WriteString("To: " + strings.Join(e.to, ", ") + "\r\n")
+	b.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", msg.Title) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if msg.Format == FormatHTML {
+		b.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	} else {
+		b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	}
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\n", "\r\n"))
+
+	addr := net.JoinHostPort(e.host, strconv.Itoa(e.port))
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if e.port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: e.host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connect smtp server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && e.port != 465 {
+		if err := c.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if e.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(e.from); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	for _, rcpt := range e.to {
+		if err := c.Rcpt(strings.TrimSpace(rcpt)); err != nil {
+			return fmt.Errorf("smtp rcpt %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	return c.Quit()
+}
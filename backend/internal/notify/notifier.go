@@ -3,7 +3,9 @@ package notify
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,119 +17,168 @@ import (
 	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
 )
 
-// Notifier 根据配置发送通知
-type Notifier struct {
-	client *http.Client
-}
+// 通知渠道类型
+const (
+	TypeWebhook    = "webhook"
+	TypeTelegram   = "telegram"
+	TypeBark       = "bark"
+	TypeServerChan = "serverchan"
+	TypeWeCom      = "wecom"
+	TypeDingTalk   = "dingtalk"
+	TypeFeishu     = "feishu"
+	TypeGotify     = "gotify"
+	TypeNtfy       = "ntfy"
+	TypeEmail      = "email"
+	TypeApprise    = "apprise"
+)
 
-// New 创建一个新的 Notifier，使用给定的配置加载器
-func New(cfgLoader func() *config.Config) *Notifier {
-	return &Notifier{
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-	}
-}
+// LegacyChannelName 旧版 notify.url 配置对应的渠道名称
+const LegacyChannelName = "default"
 
-// Send 发送通知
-func (n *Notifier) Send(ctx context.Context, title, content, url, image string) error {
-	cfg := config.Get()
-	notifyCfg := cfg.Notify
+// 消息格式
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
 
-	rawURL := strings.TrimSpace(notifyCfg.URL)
-	if rawURL == "" {
-		return nil
-	}
+// Message 一条待发送的通知
+type Message struct {
+	Title   string
+	Content string
+	URL     string
+	Image   string
+	Format  string // text/markdown/html，为空表示纯文本；不支持该格式的渠道按纯文本发送
+}
 
-	rawURL = strings.TrimPrefix(rawURL, "@")
+// Notifier 通知渠道，每种渠道类型各有一个实现
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
 
-	method := strings.ToUpper(strings.TrimSpace(notifyCfg.Method))
-	if method == "" {
-		method = http.MethodGet
-	}
+var defaultClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
 
-	switch method {
-	case http.MethodGet:
-		return n.sendGet(ctx, rawURL, title, content, url, image)
-	case http.MethodPost:
-		return n.sendPost(ctx, rawURL, title, content, url, image)
+// New 根据渠道配置创建对应的 Notifier
+func New(ch config.NotifyChannel) (Notifier, error) {
+	switch strings.ToLower(strings.TrimSpace(ch.Type)) {
+	case TypeWebhook, "":
+		return newWebhook(ch)
+	case TypeTelegram:
+		return newTelegram(ch)
+	case TypeBark:
+		return newBark(ch)
+	case TypeServerChan:
+		return newServerChan(ch)
+	case TypeWeCom, TypeDingTalk, TypeFeishu:
+		return newRobot(ch)
+	case TypeGotify:
+		return newGotify(ch)
+	case TypeNtfy:
+		return newNtfy(ch)
+	case TypeEmail:
+		return newEmail(ch)
+	case TypeApprise:
+		parsed, err := ParseAppriseURL(ch.URL)
+		if err != nil {
+			return nil, err
+		}
+		return New(parsed)
 	default:
-		return fmt.Errorf("unsupported notify method: %s", method)
+		return nil, fmt.Errorf("unsupported notify channel type: %s", ch.Type)
 	}
 }
 
-func (n *Notifier) sendGet(ctx context.Context, rawURL, title, content, url, image string) error {
-	formattedURL := replacePlaceholders(rawURL, title, content, url, image)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, formattedURL, nil)
-	if err != nil {
-		return err
+// Channels 返回配置中的全部通知渠道。
+// 旧版的 notify.url/method 作为名为 default 的 webhook 渠道加在最前面，保持兼容。
+func Channels(cfg config.NotificationConfig) []config.NotifyChannel {
+	channels := make([]config.NotifyChannel, 0, len(cfg.Channels)+1)
+	if strings.TrimSpace(cfg.URL) != "" {
+		channels = append(channels, config.NotifyChannel{
+			Name:    LegacyChannelName,
+			Type:    TypeWebhook,
+			Enabled: true,
+			URL:     cfg.URL,
+			Method:  cfg.Method,
+		})
 	}
+	return append(channels, cfg.Channels...)
+}
 
-	resp, err := n.client.Do(req)
-	if err != nil {
-		return err
+// Accepts 判断渠道是否订阅了该事件类型，未配置 events 时接收全部事件
+func Accepts(ch config.NotifyChannel, eventType string) bool {
+	if len(ch.Events) == 0 {
+		return true
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= http.StatusBadRequest {
-		logger.Logger.Warn("notify get request failed", logger.ZapField("status", resp.StatusCode))
-		io.Copy(io.Discard, resp.Body)
-		return fmt.Errorf("notify get request failed: %s", resp.Status)
+	for _, e := range ch.Events {
+		if e == eventType || e == "*" {
+			return true
+		}
 	}
-
-	io.Copy(io.Discard, resp.Body)
-	return nil
+	return false
 }
 
-func (n *Notifier) sendPost(ctx context.Context, rawURL, title, content, url, image string) error {
-	payload := map[string]string{
-		"title":   title,
-		"content": content,
-		"url":     url,
-		"image":   image,
-	}
-
+// postJSON 以 JSON 格式发送 POST 请求
+func postJSON(ctx context.Context, rawURL string, payload any, headers map[string]string) ([]byte, error) {
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
 	if err != nil {
-		return err
+		return nil, RedactError(err)
 	}
-
 	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return do(req)
+}
 
-	resp, err := n.client.Do(req)
+// do 发送请求并返回响应体，状态码 >= 400 视为失败
+func do(req *http.Request) ([]byte, error) {
+	resp, err := defaultClient.Do(req)
 	if err != nil {
-		return err
+		return nil, RedactError(err)
 	}
 	defer resp.Body.Close()
 
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
 	if resp.StatusCode >= http.StatusBadRequest {
-		logger.Logger.Warn("notify post request failed", logger.ZapField("status", resp.StatusCode))
-		io.Copy(io.Discard, resp.Body)
-		return fmt.Errorf("notify post request failed: %s", resp.Status)
+		logger.Logger.Warn("notify request failed",
+			logger.ZapField("method", req.Method),
+			logger.ZapField("host", req.URL.Host),
+			logger.ZapField("status", resp.StatusCode))
+		return nil, fmt.Errorf("notify %s request failed: %s", strings.ToLower(req.Method), resp.Status)
 	}
-
-	io.Copy(io.Discard, resp.Body)
-	return nil
+	return respBody, nil
 }
 
-func replacePlaceholders(rawURL, title, content, link, image string) string {
-	replacements := map[string]string{
-		"{title}":   url.QueryEscape(title),
-		"{content}": url.QueryEscape(content),
-		"{url}":     url.QueryEscape(link),
-		"{image}":   url.QueryEscape(image),
+// RedactError 去掉 *url.Error 中的完整请求地址，只保留主机名：
+// Telegram 等渠道的令牌在 URL 路径中，webhook 地址本身也可能是凭据，不能出现在日志与渠道状态里
+func RedactError(err error) error {
+	var ue *url.Error
+	if !errors.As(err, &ue) {
+		return err
 	}
+	host := "<invalid url>"
+	if u, perr := url.Parse(ue.URL); perr == nil && u.Host != "" {
+		host = u.Host
+	}
+	return fmt.Errorf("%s %s: %w", strings.ToLower(ue.Op), host, ue.Err)
+}
 
-	formatted := rawURL
-	for placeholder, value := range replacements {
-		formatted = strings.ReplaceAll(formatted, placeholder, value)
+// requireFields 检查必填字段，参数为 "字段名", 值 成对出现
+func requireFields(typ string, pairs ...string) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if strings.TrimSpace(pairs[i+1]) == "" {
+			return fmt.Errorf("%s channel requires %s", typ, pairs[i])
+		}
 	}
+	return nil
+}
 
-	return formatted
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
 }
@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"go.uber.org/zap"
+)
+
+func TestWebhookBodyTemplateEscapesValues(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "abc" {
+			t.Errorf("expected custom header, got %q", r.Header.Get("X-Token"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("body is not valid json: %s", body)
+		}
+	}))
+	defer srv.Close()
+
+	n, err := New(config.NotifyChannel{
+		Type:    TypeWebhook,
+		URL:     srv.URL,
+		Headers: map[string]string{"X-Token": "abc"},
+		Body:    `{"text": "{title}: {content}"}`,
+	})
+	if err != nil {
+		t.Fatalf("new webhook: %v", err)
+	}
+	if err := n.Send(context.Background(), Message{Title: `say "hi"`, Content: "line1\nline2"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got["text"] != "say \"hi\": line1\nline2" {
+		t.Fatalf("unexpected text %q", got["text"])
+	}
+}
+
+func TestRobotChecksErrcode(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"errcode":310000,"errmsg":"sign not match"}`))
+	}))
+	defer srv.Close()
+
+	n, err := New(config.NotifyChannel{Type: TypeDingTalk, URL: srv.URL + "/robot/send?access_token=x", Secret: "s"})
+	if err != nil {
+		t.Fatalf("new robot: %v", err)
+	}
+	if err := n.Send(context.Background(), Message{Title: "t", Content: "c"}); err == nil {
+		t.Fatal("expected error for non-zero errcode")
+	}
+}
+
+func TestParseAppriseURL(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want config.NotifyChannel
+	}{
+		{"tgram://123:abc/456", config.NotifyChannel{Type: TypeTelegram, Token: "123:abc", ChatID: "456"}},
+		{"ntfy://alerts", config.NotifyChannel{Type: TypeNtfy, URL: defaultNtfyServer, Topic: "alerts"}},
+		{"ntfys://tok@ntfy.example.com/alerts", config.NotifyChannel{Type: TypeNtfy, URL: "https://ntfy.example.com", Topic: "alerts", Token: "tok"}},
+		{"gotifys://push.example.com/AbC", config.NotifyChannel{Type: TypeGotify, URL: "https://push.example.com/", Token: "AbC"}},
+		{"dingtalk://sec@tok", config.NotifyChannel{Type: TypeDingTalk, Token: "tok", Secret: "sec"}},
+		{"mailtos://bot:pw@smtp.example.com?to=a@example.com", config.NotifyChannel{Type: TypeEmail, Host: "smtp.example.com", Port: 465, Username: "bot", Password: "pw", To: []string{"a@example.com"}}},
+	}
+	for _, tc := range cases {
+		got, err := ParseAppriseURL(tc.raw)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.raw, err)
+		}
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(tc.want)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("%s:\n got  %s\n want %s", tc.raw, gotJSON, wantJSON)
+		}
+	}
+
+	if _, err := ParseAppriseURL("unknown://x"); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
+
+func TestSendErrorHidesToken(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close() // 连接被拒绝，错误来自 http.Client
+
+	n, err := New(config.NotifyChannel{Type: TypeTelegram, URL: srv.URL, Token: "123456:SECRET", ChatID: "1"})
+	if err != nil {
+		t.Fatalf("new telegram: %v", err)
+	}
+	err = n.Send(context.Background(), Message{Title: "t", Content: "c"})
+	if err == nil {
+		t.Fatal("expected connection error")
+	}
+	if strings.Contains(err.Error(), "SECRET") {
+		t.Fatalf("error leaks token: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+)
+
+const (
+	defaultBarkServer = "https://api.day.app"
+	defaultNtfyServer = "https://ntfy.sh"
+)
+
+// bark iOS Bark 推送，url 为服务器地址（默认官方服务器），token 为设备 key
+type bark struct {
+	server    string
+	deviceKey string
+}
+
+func newBark(ch config.NotifyChannel) (Notifier, error) {
+	if err := requireFields(TypeBark, "token", ch.Token); err != nil {
+		return nil, err
+	}
+	return &bark{server: serverOrDefault(ch.URL, defaultBarkServer), deviceKey: strings.TrimSpace(ch.Token)}, nil
+}
+
+func (b *bark) Send(ctx context.Context, msg Message) error {
+	payload := map[string]any{
+		"device_key": b.deviceKey,
+		"title":      msg.Title,
+		"body":       msg.Content,
+		"group":      "watch-docker",
+	}
+	if msg.URL != "" {
+		payload["url"] = msg.URL
+	}
+	if msg.Image != "" {
+		payload["icon"] = msg.Image
+	}
+	body, err := postJSON(ctx, b.server+"/push", payload, nil)
+	if err != nil {
+		return err
+	}
+	return checkResponseCode(body, "code", 200)
+}
+
+// serverChan Server 酱推送，token 为 SendKey（兼容 Server 酱³ 的 sctp 开头的 key）
+type serverChan struct {
+	sendKey string
+}
+
+var sctpKeyPattern = regexp.MustCompile(`^sctp(\d+)t`)
+
+func newServerChan(ch config.NotifyChannel) (Notifier, error) {
+	if err := requireFields(TypeServerChan, "token", ch.Token); err != nil {
+		return nil, err
+	}
+	return &serverChan{sendKey: strings.TrimSpace(ch.Token)}, nil
+}
+
+func (s *serverChan) Send(ctx context.Context, msg Message) error {
+	endpoint := "https://sctapi.ftqq.com/" + s.sendKey + ".send"
+	if m := sctpKeyPattern.FindStringSubmatch(s.sendKey); m != nil {
+		endpoint = fmt.Sprintf("https://%s.push.ft07.com/send/%s.send", m[1], s.sendKey)
+	}
+	content := msg.Content
+	if msg.URL != "" {
+		content += "\n\n" + msg.URL
+	}
+	body, err := postJSON(ctx, endpoint, map[string]string{"title": msg.Title, "desp": content}, nil)
+	if err != nil {
+		return err
+	}
+	return checkResponseCode(body, "code", 0)
+}
+
+// gotify 推送到自建 Gotify 服务，url 为服务器地址，token 为应用 token
+type gotify struct {
+	server string
+	token  string
+}
+
+func newGotify(ch config.NotifyChannel) (Notifier, error) {
+	if err := requireFields(TypeGotify, "url", ch.URL, "token", ch.Token); err != nil {
+		return nil, err
+	}
+	return &gotify{server: serverOrDefault(ch.URL, ""), token: strings.TrimSpace(ch.Token)}, nil
+}
+
+func (g *gotify) Send(ctx context.Context, msg Message) error {
+	payload := map[string]any{
+		"title":    msg.Title,
+		"message":  msg.Content,
+		"priority": 5,
+	}
+	if msg.URL != "" {
+		payload["extras"] = map[string]any{
+			"client::notification": map[string]any{"click": map[string]string{"url": msg.URL}},
+		}
+	}
+	_, err := postJSON(ctx, g.server+"/message", payload, map[string]string{"X-Gotify-Key": g.token})
+	return err
+}
+
+// ntfy 推送到 ntfy 主题，url 为服务器地址（默认 ntfy.sh），
+// 需要认证时配置 token（Bearer）或 username/password（Basic）
+type ntfy struct {
+	server   string
+	topic    string
+	token    string
+	username string
+	password string
+}
+
+func newNtfy(ch config.NotifyChannel) (Notifier, error) {
+	if err := requireFields(TypeNtfy, "topic", ch.Topic); err != nil {
+		return nil, err
+	}
+	return &ntfy{
+		server:   serverOrDefault(ch.URL, defaultNtfyServer),
+		topic:    strings.TrimSpace(ch.Topic),
+		token:    strings.TrimSpace(ch.Token),
+		username: ch.Username,
+		password: ch.Password,
+	}, nil
+}
+
+func (n *ntfy) Send(ctx context.Context, msg Message) error {
+	// 使用 JSON 方式发布，避免标题中的中文放在请求头里
+	payload := map[string]any{
+		"topic":   n.topic,
+		"title":   msg.Title,
+		"message": msg.Content,
+	}
+	if msg.URL != "" {
+		payload["click"] = msg.URL
+	}
+	if msg.Image != "" {
+		payload["icon"] = msg.Image
+	}
+	if msg.Format == FormatMarkdown {
+		payload["markdown"] = true
+	}
+	headers := map[string]string{}
+	if n.token != "" {
+		headers["Authorization"] = "Bearer " + n.token
+	} else if n.username != "" {
+		headers["Authorization"] = "Basic " + basicAuth(n.username, n.password)
+	}
+	_, err := postJSON(ctx, n.server, payload, headers)
+	return err
+}
+
+// serverOrDefault 去除服务器地址末尾的斜杠，为空时使用默认地址
+func serverOrDefault(server, def string) string {
+	server = strings.TrimRight(strings.TrimSpace(server), "/")
+	if server == "" {
+		return def
+	}
+	return server
+}
+
+// checkResponseCode 检查响应 JSON 中的业务状态码，部分服务在 HTTP 200 时仍可能返回失败
+func checkResponseCode(body []byte, field string, want int) error {
+	var resp map[string]any
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil
+	}
+	code, ok := resp[field].(float64)
+	if !ok || int(code) == want {
+		return nil
+	}
+	for _, key := range []string{"errmsg", "msg", "message"} {
+		if msg, ok := resp[key].(string); ok && msg != "" {
+			return fmt.Errorf("notify failed: %s=%d, %s", field, int(code), msg)
+		}
+	}
+	return fmt.Errorf("notify failed: %s=%d", field, int(code))
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+)
+
+// robot 企业微信、钉钉、飞书群机器人。
+// url 为机器人完整的 webhook 地址，也可以只配置 token（企业微信 key / 钉钉 access_token / 飞书 hook id）；
+// secret 为钉钉、飞书的加签密钥，可选。
+type robot struct {
+	typ     string
+	webhook string
+	secret  string
+}
+
+func newRobot(ch config.NotifyChannel) (Notifier, error) {
+	typ := strings.ToLower(strings.TrimSpace(ch.Type))
+	webhook := strings.TrimSpace(ch.URL)
+	token := strings.TrimSpace(ch.Token)
+	if webhook == "" && token != "" {
+		switch typ {
+		case TypeWeCom:
+			webhook = "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=" + url.QueryEscape(token)
+		case TypeDingTalk:
+			webhook = "https://oapi.dingtalk.com/robot/send?access_token=" + url.QueryEscape(token)
+		case TypeFeishu:
+			webhook = "https://open.feishu.cn/open-apis/bot/v2/hook/" + url.PathEscape(token)
+		}
+	}
+	if err := requireFields(typ, "url", webhook); err != nil {
+		return nil, err
+	}
+	return &robot{typ: typ, webhook: webhook, secret: strings.TrimSpace(ch.Secret)}, nil
+}
+
+func (r *robot) Send(ctx context.Context, msg Message) error {
+	text := joinMessage(msg)
+	switch r.typ {
+	case TypeWeCom:
+		payload := map[string]any{
+			"msgtype": "text",
+			"text":    map[string]string{"content": text},
+		}
+		if msg.Format == FormatMarkdown {
+			payload = map[string]any{
+				"msgtype":  "markdown",
+				"markdown": map[string]string{"content": text},
+			}
+		}
+		body, err := postJSON(ctx, r.webhook, payload, nil)
+		if err != nil {
+			return err
+		}
+		return checkResponseCode(body, "errcode", 0)
+	case TypeDingTalk:
+		endpoint := r.webhook
+		if r.secret != "" {
+			ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			sign := hmacSign([]byte(r.secret), ts+"\n"+r.secret)
+			sep := "?"
+			if strings.Contains(endpoint, "?") {
+				sep = "&"
+			}
+			endpoint += fmt.Sprintf("%stimestamp=%s&sign=%s", sep, ts, url.QueryEscape(sign))
+		}
+		payload := map[string]any{
+			"msgtype": "text",
+			"text":    map[string]string{"content": text},
+		}
+		if msg.Format == FormatMarkdown {
+			payload = map[string]any{
+				"msgtype":  "markdown",
+				"markdown": map[string]string{"title": msg.Title, "text": text},
+			}
+		}
+		body, err := postJSON(ctx, endpoint, payload, nil)
+		if err != nil {
+			return err
+		}
+		return checkResponseCode(body, "errcode", 0)
+	default:
+		payload := map[string]any{
+			"msg_type": "text",
+			"content":  map[string]string{"text": text},
+		}
+		if r.secret != "" {
+			// 飞书签名：以 timestamp + "\n" + secret 为密钥对空串做 HmacSHA256
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			payload["timestamp"] = ts
+			payload["sign"] = hmacSign([]byte(ts+"\n"+r.secret), "")
+		}
+		body, err := postJSON(ctx, r.webhook, payload, nil)
+		if err != nil {
+			return err
+		}
+		return checkResponseCode(body, "code", 0)
+	}
+}
+
+func hmacSign(key []byte, data string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
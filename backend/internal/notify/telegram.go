@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+)
+
+const defaultTelegramAPI = "https://api.telegram.org"
+
+// telegram 通过 Telegram Bot API 发送消息，url 可配置为自建的 Bot API 地址
+type telegram struct {
+	api    string
+	token  string
+	chatID string
+}
+
+func newTelegram(ch config.NotifyChannel) (Notifier, error) {
+	if err := requireFields(TypeTelegram, "token", ch.Token, "chatId", ch.ChatID); err != nil {
+		return nil, err
+	}
+	api := strings.TrimRight(strings.TrimSpace(ch.URL), "/")
+	if api == "" {
+		api = defaultTelegramAPI
+	}
+	return &telegram{api: api, token: strings.TrimSpace(ch.Token), chatID: strings.TrimSpace(ch.ChatID)}, nil
+}
+
+func (t *telegram) Send(ctx context.Context, msg Message) error {
+	payload := map[string]any{
+		"chat_id":                  t.chatID,
+		"text":                     joinMessage(msg),
+		"disable_web_page_preview": true,
+	}
+	switch msg.Format {
+	case FormatMarkdown:
+		payload["parse_mode"] = "Markdown"
+	case FormatHTML:
+		payload["parse_mode"] = "HTML"
+	}
+	_, err := postJSON(ctx, t.api+"/bot"+t.token+"/sendMessage", payload, nil)
+	return err
+}
+
+// joinMessage 将标题、正文与链接合并为一段纯文本，用于不区分标题的渠道
+func joinMessage(msg Message) string {
+	var b strings.Builder
+	if msg.Title != "" {
+		b.WriteString(msg.Title)
+		b.WriteString("\n\n")
+	}
+	b.WriteString(msg.Content)
+	if msg.URL != "" {
+		b.WriteString("\n")
+		b.WriteString(msg.URL)
+	}
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+)
+
+// webhook 通用 HTTP 通知。
+// GET 时在 URL 中替换 {title}/{content}/{url}/{image} 占位符；
+// POST 时默认发送 {"title","content","url","image"}，配置了 body 时按模板发送。
+type webhook struct {
+	url     string
+	method  string
+	headers map[string]string
+	body    string
+}
+
+func newWebhook(ch config.NotifyChannel) (Notifier, error) {
+	rawURL := strings.TrimPrefix(strings.TrimSpace(ch.URL), "@")
+	if err := requireFields(TypeWebhook, "url", rawURL); err != nil {
+		return nil, err
+	}
+	method := strings.ToUpper(strings.TrimSpace(ch.Method))
+	switch method {
+	case "":
+		method = http.MethodGet
+		if strings.TrimSpace(ch.Body) != "" {
+			method = http.MethodPost
+		}
+	case http.MethodGet, http.MethodPost, http.MethodPut:
+	default:
+		return nil, fmt.Errorf("unsupported notify method: %s", method)
+	}
+	return &webhook{url: rawURL, method: method, headers: ch.Headers, body: ch.Body}, nil
+}
+
+func (w *webhook) Send(ctx context.Context, msg Message) error {
+	if w.method == http.MethodGet {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, replacePlaceholders(w.url, msg, url.QueryEscape), nil)
+		if err != nil {
+			return err
+		}
+		for k, v := range w.headers {
+			req.Header.Set(k, v)
+		}
+		_, err = do(req)
+		return err
+	}
+
+	var body string
+	if strings.TrimSpace(w.body) != "" {
+		body = replacePlaceholders(w.body, msg, jsonEscape)
+	} else {
+		data, err := json.Marshal(map[string]string{
+			"title":   msg.Title,
+			"content": msg.Content,
+			"url":     msg.URL,
+			"image":   msg.Image,
+		})
+		if err != nil {
+			return err
+		}
+		body = string(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, w.method, w.url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	_, err = do(req)
+	return err
+}
+
+// replacePlaceholders 替换 {title}/{content}/{url}/{image} 占位符，escape 用于对值进行转义
+func replacePlaceholders(tpl string, msg Message, escape func(string) string) string {
+	return strings.NewReplacer(
+		"{title}", escape(msg.Title),
+		"{content}", escape(msg.Content),
+		"{url}", escape(msg.URL),
+		"{image}", escape(msg.Image),
+	).Replace(tpl)
+}
+
+// jsonEscape 将字符串转义为可直接放在 JSON 字符串字面量中的内容（不含两侧引号）
+func jsonEscape(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
@@ -0,0 +1,74 @@
+package oidc
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+)
+
+var (
+	ErrNoUsername = errors.New("id token has no username claim")
+	ErrNotAllowed = errors.New("user is not in oidc.allowedGroups or oidc.allowedEmails")
+)
+
+// roleOrder 角色从高到低，多个组映射到不同角色时取最高的
+var roleOrder = []string{"admin", "operator", "viewer"}
+
+// mfaMethods 表示多因素认证的 amr 值（RFC 8176）
+var mfaMethods = []string{"mfa", "otp", "hwk", "swk", "sc", "fpt", "face", "iris", "retina", "vbm"}
+
+// Allowed 检查用户是否满足 allowedGroups/allowedEmails；两者都为空时允许所有 IdP 用户
+func Allowed(cfg config.OIDCConfig, c *Claims) error {
+	if c.Username == "" {
+		return ErrNoUsername
+	}
+	if len(cfg.AllowedGroups) == 0 && len(cfg.AllowedEmails) == 0 {
+		return nil
+	}
+	for _, g := range c.Groups {
+		if slices.Contains(cfg.AllowedGroups, g) {
+			return nil
+		}
+	}
+	// 未验证的邮箱不参与匹配
+	if c.Email != "" && (c.EmailVerified == nil || *c.EmailVerified) {
+		email := strings.ToLower(c.Email)
+		for _, e := range cfg.AllowedEmails {
+			e = strings.ToLower(e)
+			// 以 @ 开头表示整个域名
+			if email == e || (strings.HasPrefix(e, "@") && strings.HasSuffix(email, e)) {
+				return nil
+			}
+		}
+	}
+	return ErrNotAllowed
+}
+
+// MappedRole 按 oidc.roleGroups 返回组对应的最高角色，没有匹配时返回空字符串
+func MappedRole(cfg config.OIDCConfig, groups []string) string {
+	for _, role := range roleOrder {
+		for _, g := range cfg.RoleGroups[role] {
+			if slices.Contains(groups, g) {
+				return role
+			}
+		}
+	}
+	return ""
+}
+
+// ExternalID 返回 IdP 用户的唯一标识 issuer|subject，用于关联本地用户；用户名可能变化或重复，不能作为标识
+func ExternalID(c *Claims) string {
+	return c.Issuer + "|" + c.Subject
+}
+
+// MFA IdP 是否通过 amr 声明表明已完成多因素认证
+func MFA(c *Claims) bool {
+	for _, m := range c.AMR {
+		if slices.Contains(mfaMethods, strings.ToLower(m)) {
+			return true
+		}
+	}
+	return false
+}
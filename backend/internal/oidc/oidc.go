@@ -0,0 +1,395 @@
+// Package oidc 实现 OpenID Connect 授权码（PKCE）登录：
+// 通过 issuer 的 /.well-known/openid-configuration 发现端点，使用 JWKS 校验 ID Token，
+// 并按 oidc 配置把 IdP 声明映射为本地用户名与角色。
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// discoveryTTL 发现文档与 JWKS 的缓存时间；遇到未知 kid 时提前刷新 JWKS
+const discoveryTTL = time.Hour
+
+var (
+	ErrNotConfigured = errors.New("oidc is not configured")
+	ErrInvalidToken  = errors.New("invalid id token")
+)
+
+// Discovery OpenID Provider 元数据
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims ID Token 中的声明
+type Claims struct {
+	Issuer  string
+	Subject string
+	Email   string
+	// EmailVerified 为 nil 表示 IdP 未提供 email_verified
+	EmailVerified *bool
+	Username      string // oidc.usernameClaim 对应的值，为空时回退到已验证的 email
+	Groups        []string
+	AMR           []string
+}
+
+// Client OIDC 客户端，缓存发现文档与签名密钥
+type Client struct {
+	http *http.Client
+
+	mu        sync.Mutex
+	issuer    string
+	discovery *Discovery
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+// NewClient 创建客户端，httpClient 为 nil 时使用带超时的默认客户端
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &Client{http: httpClient}
+}
+
+// Flow 一次登录流程的 state、nonce 与 PKCE verifier
+type Flow struct {
+	State       string
+	Nonce       string
+	Verifier    string
+	RedirectURI string
+	Expires     time.Time
+}
+
+// NewFlow 生成新的登录流程参数
+func NewFlow(redirectURI string, ttl time.Duration) (*Flow, error) {
+	f := &Flow{RedirectURI: redirectURI, Expires: time.Now().Add(ttl)}
+	for _, p := range []*string{&f.State, &f.Nonce, &f.Verifier} {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("generate oidc flow: %w", err)
+		}
+		*p = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return f, nil
+}
+
+// Challenge PKCE S256 code_challenge
+func (f *Flow) Challenge() string {
+	sum := sha256.Sum256([]byte(f.Verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL 返回 IdP 授权地址
+func (c *Client) AuthCodeURL(ctx context.Context, cfg config.OIDCConfig, f *Flow) (string, error) {
+	d, err := c.Discover(ctx, cfg.Issuer)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization_endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", cfg.ClientID)
+	q.Set("redirect_uri", f.RedirectURI)
+	q.Set("scope", strings.Join(cfg.Scopes, " "))
+	q.Set("state", f.State)
+	q.Set("nonce", f.Nonce)
+	q.Set("code_challenge", f.Challenge())
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange 使用授权码换取 ID Token 并校验签名、issuer、audience、有效期与 nonce
+func (c *Client) Exchange(ctx context.Context, cfg config.OIDCConfig, f *Flow, code string) (*Claims, error) {
+	d, err := c.Discover(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {f.RedirectURI},
+		"code_verifier": {f.Verifier},
+		"client_id":     {cfg.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read token response: %w", err)
+	}
+	var tr struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, fmt.Errorf("token response: status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || tr.Error != "" {
+		return nil, fmt.Errorf("token response: status %d: %s %s", resp.StatusCode, tr.Error, tr.ErrorDescription)
+	}
+	if tr.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+	return c.Verify(ctx, cfg, tr.IDToken, f.Nonce)
+}
+
+// Verify 校验 ID Token 并提取声明
+func (c *Client) Verify(ctx context.Context, cfg config.OIDCConfig, rawIDToken, nonce string) (*Claims, error) {
+	d, err := c.Discover(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	mc := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, mc, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return c.key(ctx, d, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if n, _ := mc["nonce"].(string); n != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+	// 多个 audience 时 azp 必须是本客户端
+	if azp, ok := mc["azp"].(string); ok && azp != cfg.ClientID {
+		return nil, fmt.Errorf("%w: azp mismatch", ErrInvalidToken)
+	}
+
+	claims := &Claims{
+		Issuer:  stringClaim(mc, "iss"),
+		Subject: stringClaim(mc, "sub"),
+		Email:   stringClaim(mc, "email"),
+		Groups:  stringsClaim(mc, cfg.GroupsClaim),
+		AMR:     stringsClaim(mc, "amr"),
+	}
+	if v, ok := mc["email_verified"].(bool); ok {
+		claims.EmailVerified = &v
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidToken)
+	}
+	claims.Username = stringClaim(mc, cfg.UsernameClaim)
+	// 邮箱未经 IdP 验证时任何人都可以填写，不能作为用户名
+	if claims.Username == "" && claims.EmailVerified != nil && *claims.EmailVerified {
+		claims.Username = claims.Email
+	}
+	return claims, nil
+}
+
+// Discover 获取并缓存 issuer 的发现文档
+func (c *Client) Discover(ctx context.Context, issuer string) (*Discovery, error) {
+	if issuer == "" {
+		return nil, ErrNotConfigured
+	}
+	issuer = strings.TrimSuffix(issuer, "/")
+	c.mu.Lock()
+	if c.issuer == issuer && c.discovery != nil && time.Since(c.fetchedAt) < discoveryTTL {
+		d := c.discovery
+		c.mu.Unlock()
+		return d, nil
+	}
+	c.mu.Unlock()
+
+	var d Discovery
+	if err := c.getJSON(ctx, issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch %q", d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery: missing endpoints")
+	}
+	c.mu.Lock()
+	c.issuer, c.discovery, c.keys, c.fetchedAt = issuer, &d, nil, time.Now()
+	c.mu.Unlock()
+	return &d, nil
+}
+
+// key 返回 kid 对应的公钥，缓存中没有时重新获取 JWKS
+func (c *Client) key(ctx context.Context, d *Discovery, kid string) (any, error) {
+	c.mu.Lock()
+	keys := c.keys
+	c.mu.Unlock()
+	if k := pickKey(keys, kid); k != nil {
+		return k, nil
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := c.getJSON(ctx, d.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	keys = make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+	if k := pickKey(keys, kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// pickKey 按 kid 查找公钥；token 未指定 kid 且只有一个密钥时使用该密钥
+func pickKey(keys map[string]any, kid string) any {
+	if k, ok := keys[kid]; ok {
+		return k
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k
+		}
+	}
+	return nil
+}
+
+func (c *Client) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// jwk JSON Web Key（只支持 RSA 与 EC 公钥）
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) > size || len(y) > size {
+			return nil, fmt.Errorf("invalid ec key")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		copy(point[1+size-len(x):1+size], x)
+		copy(point[1+2*size-len(y):], y)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func stringClaim(mc jwt.MapClaims, name string) string {
+	s, _ := mc[name].(string)
+	return s
+}
+
+// stringsClaim 读取字符串数组声明，也接受单个字符串
+func stringsClaim(mc jwt.MapClaims, name string) []string {
+	switch v := mc[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
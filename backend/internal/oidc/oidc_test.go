@@ -0,0 +1,236 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIssuer 本地模拟的 OIDC 提供方：授权码对应预先设置的 ID Token 声明
+type mockIssuer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+	// 授权请求中的参数，换取 token 时校验 PKCE
+	challenge string
+	nonce     string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                m.URL,
+			AuthorizationEndpoint: m.URL + "/authorize",
+			TokenEndpoint:         m.URL + "/token",
+			JWKSURI:               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		verifier := (&Flow{Verifier: r.PostForm.Get("code_verifier")}).Challenge()
+		if id != "watch-docker" || secret != "s3cret" || r.PostForm.Get("code") != "good-code" || verifier != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := jwt.MapClaims{"iss": m.URL, "aud": "watch-docker", "exp": time.Now().Add(time.Minute).Unix(), "nonce": m.nonce}
+		for k, v := range m.claims {
+			claims[k] = v
+		}
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = "k1"
+		signed, _ := tok.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": signed})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockIssuer) config() config.OIDCConfig {
+	return config.OIDCConfig{
+		Enabled:       true,
+		Issuer:        m.URL,
+		ClientID:      "watch-docker",
+		ClientSecret:  "s3cret",
+		Scopes:        []string{"openid", "profile", "email", "groups"},
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+	}
+}
+
+// authorize 模拟浏览器访问授权地址，记录 IdP 收到的 PKCE challenge 与 nonce
+func (m *mockIssuer) authorize(t *testing.T, c *Client, cfg config.OIDCConfig, f *Flow) {
+	t.Helper()
+	target, err := c.AuthCodeURL(context.Background(), cfg, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(target)
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("code_challenge_method") != "S256" || q.Get("state") != f.State || q.Get("redirect_uri") != f.RedirectURI {
+		t.Fatalf("auth url = %s", target)
+	}
+	m.challenge, m.nonce = q.Get("code_challenge"), q.Get("nonce")
+}
+
+func TestExchange(t *testing.T) {
+	m := newMockIssuer(t)
+	m.claims = jwt.MapClaims{"sub": "u1", "preferred_username": "alice", "email": "alice@example.com", "email_verified": true, "groups": []string{"docker-ops"}, "amr": []string{"pwd", "otp"}}
+	cfg := m.config()
+	c := NewClient(nil)
+
+	f, _ := NewFlow("http://localhost/api/v1/oidc/callback", time.Minute)
+	m.authorize(t, c, cfg, f)
+	claims, err := c.Exchange(context.Background(), cfg, f, "good-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Username != "alice" || ExternalID(claims) != m.URL+"|u1" || len(claims.Groups) != 1 || !MFA(claims) {
+		t.Fatalf("claims = %+v", claims)
+	}
+
+	// 授权码错误
+	if _, err := c.Exchange(context.Background(), cfg, f, "bad-code"); err == nil {
+		t.Fatal("bad code accepted")
+	}
+	// PKCE verifier 与授权请求不一致
+	other, _ := NewFlow(f.RedirectURI, time.Minute)
+	other.Nonce = f.Nonce
+	if _, err := c.Exchange(context.Background(), cfg, other, "good-code"); err == nil {
+		t.Fatal("wrong code_verifier accepted")
+	}
+	// nonce 不一致
+	m.nonce = "replayed"
+	if _, err := c.Exchange(context.Background(), cfg, f, "good-code"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("nonce mismatch: %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	m := newMockIssuer(t)
+	cfg := m.config()
+	c := NewClient(nil)
+	sign := func(claims jwt.MapClaims, key *rsa.PrivateKey) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = "k1"
+		s, _ := tok.SignedString(key)
+		return s
+	}
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{"iss": m.URL, "sub": "u1", "aud": "watch-docker", "exp": time.Now().Add(time.Minute).Unix(), "nonce": "n", "preferred_username": "alice"}
+	}
+	if _, err := c.Verify(context.Background(), cfg, sign(valid(), m.key), "n"); err != nil {
+		t.Fatalf("valid token: %v", err)
+	}
+
+	// 缺少用户名声明时只使用已验证的邮箱
+	withEmail := func(verified any) jwt.MapClaims {
+		c := valid()
+		delete(c, "preferred_username")
+		c["email"] = "alice@example.com"
+		if verified != nil {
+			c["email_verified"] = verified
+		}
+		return c
+	}
+	for verified, want := range map[any]string{true: "alice@example.com", false: "", nil: ""} {
+		claims, err := c.Verify(context.Background(), cfg, sign(withEmail(verified), m.key), "n")
+		if err != nil || claims.Username != want {
+			t.Errorf("email_verified=%v: username %q, %v", verified, claims.Username, err)
+		}
+	}
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	cases := map[string]string{
+		"wrong key":      sign(valid(), otherKey),
+		"wrong audience": sign(func() jwt.MapClaims { c := valid(); c["aud"] = "other"; return c }(), m.key),
+		"wrong issuer":   sign(func() jwt.MapClaims { c := valid(); c["iss"] = "https://evil"; return c }(), m.key),
+		"expired":        sign(func() jwt.MapClaims { c := valid(); c["exp"] = time.Now().Add(-time.Hour).Unix(); return c }(), m.key),
+		"wrong azp":      sign(func() jwt.MapClaims { c := valid(); c["azp"] = "other"; return c }(), m.key),
+		"missing sub":    sign(func() jwt.MapClaims { c := valid(); delete(c, "sub"); return c }(), m.key),
+		"unsigned": func() string {
+			s, _ := jwt.NewWithClaims(jwt.SigningMethodNone, valid()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return s
+		}(),
+	}
+	for name, tok := range cases {
+		if _, err := c.Verify(context.Background(), cfg, tok, "n"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestMapping(t *testing.T) {
+	yes, no := true, false
+	cfg := config.OIDCConfig{
+		AllowedGroups: []string{"staff"},
+		AllowedEmails: []string{"boss@example.com", "@partner.com"},
+		RoleGroups:    map[string][]string{"admin": {"docker-admins"}, "operator": {"docker-ops"}, "viewer": {"staff"}},
+	}
+	allowed := []*Claims{
+		{Username: "a", Groups: []string{"staff"}},
+		{Username: "b", Email: "Boss@example.com", EmailVerified: &yes},
+		{Username: "c", Email: "x@partner.com"},
+	}
+	for _, c := range allowed {
+		if err := Allowed(cfg, c); err != nil {
+			t.Errorf("%s: %v", c.Username, err)
+		}
+	}
+	denied := []*Claims{
+		{Username: "d", Groups: []string{"guests"}},
+		{Username: "e", Email: "boss@example.com", EmailVerified: &no},
+		{Username: "f", Email: "x@notpartner.com.evil"},
+	}
+	for _, c := range denied {
+		if err := Allowed(cfg, c); !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("%s: %v", c.Username, err)
+		}
+	}
+	if err := Allowed(config.OIDCConfig{}, &Claims{}); !errors.Is(err, ErrNoUsername) {
+		t.Errorf("empty username: %v", err)
+	}
+
+	if r := MappedRole(cfg, []string{"staff", "docker-ops"}); r != "operator" {
+		t.Errorf("mapped role = %q", r)
+	}
+	if r := MappedRole(cfg, []string{"guests"}); r != "" {
+		t.Errorf("unmapped role = %q", r)
+	}
+	if MFA(&Claims{AMR: []string{"pwd"}}) || !MFA(&Claims{AMR: []string{"pwd", "hwk"}}) {
+		t.Error("amr mfa detection")
+	}
+	// RFC 7636 附录 B 的示例
+	if c := (&Flow{Verifier: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"}).Challenge(); c != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("S256 challenge = %s", c)
+	}
+}
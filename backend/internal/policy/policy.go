@@ -2,6 +2,7 @@
 // - 依据容器 label、镜像来源（本地构建/固定 digest）、标签形态（严格语义化版本/浮动标签）
 // - 可选的 only/exclude label 过滤
 // - 对 Compose 管理的容器默认跳过（可配置允许）
+// - 可选的语义化版本升级模式（patch/minor/major），按 tag 升级而非仅比较 digest
 // 通过 Evaluate 返回跳过与否、原因以及是否强制更新标记。
 package policy
 
@@ -21,6 +22,9 @@ type Decision struct {
 	Force bool
 	// SkippedUpdate 表示此次应跳过更新动作， 但不跳过检测
 	SkippedUpdate bool
+	// SemverMode 非空时表示按语义化版本升级（patch/minor/major），
+	// 需要由调用方列出远端 tag 并通过 SelectSemverTag 选出目标 tag
+	SemverMode string
 }
 
 type Input struct {
@@ -47,10 +51,32 @@ type Input struct {
 	SkipLabels []string
 	// AllowComposeUpdate 允许对 Compose 管理的容器进行更新
 	AllowComposeUpdate bool
+	// SemverMode 全局语义化版本升级模式（patch/minor/major），可被 watchdocker.semver label 覆盖
+	SemverMode string
 }
 
+// 语义化版本升级模式：允许的最大升级幅度
+const (
+	SemverPatch = "patch"
+	SemverMinor = "minor"
+	SemverMajor = "major"
+)
+
+// SemverLabel 容器级语义化版本升级模式 label
+const SemverLabel = "watchdocker.semver"
+
 var semverStrict = regexp.MustCompile(`^(v?)(\d+)\.(\d+)\.(\d+)$`)
 
+// NormalizeSemverMode 规范化升级模式，非法值返回空字符串（表示关闭）。
+func NormalizeSemverMode(mode string) string {
+	switch m := strings.ToLower(strings.TrimSpace(mode)); m {
+	case SemverPatch, SemverMinor, SemverMajor:
+		return m
+	default:
+		return ""
+	}
+}
+
 // Evaluate 按输入条件与策略计算是否跳过。
 // 判定顺序遵循“显式 > 过滤 > 来源/固定 > 版本/标签”的原则，以便快速短路。
 func Evaluate(in Input) Decision {
@@ -61,9 +87,18 @@ func Evaluate(in Input) Decision {
 	if val := strings.ToLower(in.Labels["watchdocker.skipUpdate"]); val == "true" {
 		return Decision{SkippedUpdate: true, Reason: "label skip update"}
 	}
+	// 语义化版本升级模式：label 优先于全局配置，仅对严格语义化版本 tag 生效
+	semverMode := NormalizeSemverMode(in.SemverMode)
+	if label, ok := in.Labels[SemverLabel]; ok {
+		semverMode = NormalizeSemverMode(label)
+	}
+	if semverMode != "" && !isStrictSemverTag(in.ImageRef) {
+		semverMode = ""
+	}
+
 	// force update even if pinned
 	if val := strings.ToLower(in.Labels["watchdocker.force"]); val == "true" {
-		return Decision{Skipped: false, Force: true}
+		return Decision{Skipped: false, Force: true, SemverMode: semverMode}
 	}
 
 	// 2) 过滤：only / exclude label
@@ -115,7 +150,10 @@ func Evaluate(in Input) Decision {
 		return Decision{Skipped: true, Reason: "local build"}
 	}
 
-	// 5) 标签与版本：严格语义化版本、浮动标签白名单
+	// 5) 标签与版本：语义化版本升级、严格语义化版本、浮动标签白名单
+	if semverMode != "" {
+		return Decision{SemverMode: semverMode}
+	}
 	if in.SkipSemver && isStrictSemverTag(in.ImageRef) {
 		return Decision{Skipped: true, Reason: "pinned semver"}
 	}
@@ -147,6 +185,63 @@ func isStrictSemverTag(ref string) bool {
 	return err == nil
 }
 
+// SelectSemverTag 在候选 tags 中选出满足升级模式的最高版本。
+// 仅考虑与当前 tag 前缀形态一致（都带或都不带 "v"）的严格语义化版本；
+// patch 只允许同 major.minor 内升级，minor 只允许同 major 内升级，major 不限制。
+// 返回的 tag 必须严格高于当前版本，否则 ok 为 false。
+func SelectSemverTag(currentTag string, tags []string, mode string) (string, bool) {
+	mode = NormalizeSemverMode(mode)
+	if mode == "" {
+		return "", false
+	}
+	current, prefix, ok := parseStrictSemver(currentTag)
+	if !ok {
+		return "", false
+	}
+
+	best := current
+	bestTag := ""
+	for _, tag := range tags {
+		v, p, ok := parseStrictSemver(tag)
+		if !ok || p != prefix {
+			continue
+		}
+		switch mode {
+		case SemverPatch:
+			if v.Major != current.Major || v.Minor != current.Minor {
+				continue
+			}
+		case SemverMinor:
+			if v.Major != current.Major {
+				continue
+			}
+		}
+		if v.GT(best) {
+			best = v
+			bestTag = tag
+		}
+	}
+	return bestTag, bestTag != ""
+}
+
+// parseStrictSemver 解析严格语义化版本 tag，返回版本号与 "v" 前缀（若有）。
+func parseStrictSemver(tag string) (semver.Version, string, bool) {
+	m := semverStrict.FindStringSubmatch(tag)
+	if m == nil {
+		return semver.Version{}, "", false
+	}
+	v, err := semver.Parse(strings.TrimPrefix(tag, "v"))
+	if err != nil {
+		return semver.Version{}, "", false
+	}
+	return v, m[1], true
+}
+
+// ImageTag 从镜像引用中提取 tag，未显式给出时返回 "latest"。
+func ImageTag(ref string) string {
+	return imageTag(ref)
+}
+
 // imageTag 从镜像引用中提取 tag，未显式给出时返回 "latest"。
 func imageTag(ref string) string {
 	at := strings.LastIndex(ref, ":")
@@ -27,3 +27,53 @@ func TestEvaluateSkipLocalStillSkipsExplicitLocalImage(t *testing.T) {
 		t.Fatalf("expected explicit local image to be skipped, got %+v", dec)
 	}
 }
+
+func TestEvaluateSemverModeOverridesSkipSemver(t *testing.T) {
+	dec := Evaluate(Input{
+		ImageRef:     "nginx:1.25.3",
+		SkipSemver:   true,
+		FloatingTags: []string{"latest"},
+		Labels:       map[string]string{SemverLabel: "minor"},
+	})
+
+	if dec.Skipped || dec.SemverMode != SemverMinor {
+		t.Fatalf("expected semver label to keep container eligible in minor mode, got %+v", dec)
+	}
+}
+
+func TestEvaluateSemverModeIgnoredForFloatingTag(t *testing.T) {
+	dec := Evaluate(Input{
+		ImageRef:   "nginx:latest",
+		SemverMode: SemverMajor,
+	})
+
+	if dec.SemverMode != "" {
+		t.Fatalf("expected semver mode to be ignored for non-semver tag, got %+v", dec)
+	}
+}
+
+func TestSelectSemverTag(t *testing.T) {
+	tags := []string{"1.2.3", "1.2.9", "1.3.0", "1.10.1", "2.0.0", "v1.2.10", "1.2.11-rc1", "latest"}
+
+	cases := []struct {
+		mode string
+		want string
+	}{
+		{SemverPatch, "1.2.9"},
+		{SemverMinor, "1.10.1"},
+		{SemverMajor, "2.0.0"},
+	}
+	for _, tc := range cases {
+		got, ok := SelectSemverTag("1.2.3", tags, tc.mode)
+		if !ok || got != tc.want {
+			t.Fatalf("mode %s: expected %q, got %q (ok=%v)", tc.mode, tc.want, got, ok)
+		}
+	}
+
+	if got, ok := SelectSemverTag("2.0.0", tags, SemverMajor); ok {
+		t.Fatalf("expected no newer tag than 2.0.0, got %q", got)
+	}
+	if got, ok := SelectSemverTag("v1.2.3", tags, SemverPatch); !ok || got != "v1.2.10" {
+		t.Fatalf("expected v-prefixed candidate v1.2.10, got %q (ok=%v)", got, ok)
+	}
+}
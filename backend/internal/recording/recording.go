@@ -0,0 +1,297 @@
+// Package recording 以 asciinema v2（.cast）格式录制终端会话。
+// 每个会话保存为 CONFIG_PATH/recordings 下的 <id>.cast 与 <id>.json（会话信息），
+// 新会话开始时按 recording.maxAgeDays 与 recording.maxTotalMB 清理旧录像。
+package recording
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+)
+
+// 会话类型
+const (
+	KindHost      = "host"
+	KindContainer = "container"
+)
+
+// ErrNotFound 录像不存在
+var ErrNotFound = errors.New("recording not found")
+
+var idPattern = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}-[0-9a-f]{8}$`)
+
+// Meta 会话信息
+type Meta struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	User      string    `json:"user,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Endpoint  string    `json:"endpoint,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Shell     string    `json:"shell,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt,omitempty"` // 零值表示会话进行中或异常中断
+	Duration  float64   `json:"duration"`          // 秒
+	Size      int64     `json:"size"`              // .cast 文件大小（字节）
+}
+
+// header asciinema v2 文件头
+type header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Store 录像存储
+type Store struct {
+	dir string
+	mu  sync.Mutex // 保护清理与列表
+}
+
+// NewStore 创建录像存储，目录在首次录制时创建
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Start 开始录制；recording.enabled 为 false 时返回 nil（nil Recorder 的方法均为空操作）
+func (s *Store) Start(meta Meta, width, height int) (*Recorder, error) {
+	cfg := config.Get().Recording
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, fmt.Errorf("create recordings dir: %w", err)
+	}
+	s.prune(cfg)
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("generate recording id: %w", err)
+	}
+	now := time.Now()
+	meta.ID = now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+	meta.StartedAt = now
+
+	f, err := os.OpenFile(s.castPath(meta.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("create recording: %w", err)
+	}
+	title := meta.Kind + " shell"
+	if meta.Container != "" {
+		title = meta.Container + " shell"
+	}
+	if meta.User != "" {
+		title += " (" + meta.User + ")"
+	}
+	h, _ := json.Marshal(header{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: now.Unix(),
+		Title:     title,
+		Env:       map[string]string{"TERM": "xterm-256color", "SHELL": meta.Shell},
+	})
+	r := &Recorder{store: s, file: f, meta: meta, start: now, recordInput: cfg.RecordInput}
+	if err := r.write(h); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if err := s.writeMeta(meta); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return r, nil
+}
+
+// List 返回全部录像，按开始时间倒序排列
+func (s *Store) List() ([]Meta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listLocked()
+}
+
+func (s *Store) listLocked() ([]Meta, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Meta, 0, len(files))
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		var m Meta
+		if json.Unmarshal(data, &m) != nil || !idPattern.MatchString(m.ID) {
+			continue
+		}
+		// 进行中或异常中断的会话以文件实际大小为准
+		if st, err := os.Stat(s.castPath(m.ID)); err == nil {
+			m.Size = st.Size()
+		}
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.After(list[j].StartedAt) })
+	return list, nil
+}
+
+// Path 返回录像文件路径
+func (s *Store) Path(id string) (string, error) {
+	if !idPattern.MatchString(id) {
+		return "", ErrNotFound
+	}
+	p := s.castPath(id)
+	if _, err := os.Stat(p); err != nil {
+		return "", ErrNotFound
+	}
+	return p, nil
+}
+
+// Delete 删除录像
+func (s *Store) Delete(id string) error {
+	p, err := s.Path(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(p); err != nil {
+		return fmt.Errorf("delete recording: %w", err)
+	}
+	_ = os.Remove(s.metaPath(id))
+	return nil
+}
+
+// prune 删除超过保留天数的录像，并在总大小超限时从最旧的开始删除
+func (s *Store) prune(cfg config.RecordingConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.listLocked()
+	if err != nil {
+		return
+	}
+	var total int64
+	for _, m := range list {
+		total += m.Size
+	}
+	maxTotal := int64(cfg.MaxTotalMB) << 20
+	cutoff := time.Now().AddDate(0, 0, -cfg.MaxAgeDays)
+	for i := len(list) - 1; i >= 0; i-- {
+		m := list[i]
+		expired := cfg.MaxAgeDays > 0 && m.StartedAt.Before(cutoff)
+		oversize := maxTotal > 0 && total > maxTotal
+		if !expired && !oversize {
+			continue
+		}
+		_ = os.Remove(s.castPath(m.ID))
+		_ = os.Remove(s.metaPath(m.ID))
+		total -= m.Size
+	}
+}
+
+func (s *Store) castPath(id string) string { return filepath.Join(s.dir, id+".cast") }
+func (s *Store) metaPath(id string) string { return filepath.Join(s.dir, id+".json") }
+
+func (s *Store) writeMeta(m Meta) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal recording meta: %w", err)
+	}
+	if err := os.WriteFile(s.metaPath(m.ID), data, 0600); err != nil {
+		return fmt.Errorf("write recording meta: %w", err)
+	}
+	return nil
+}
+
+// Recorder 一个会话的录制器，可被输出与输入两个 goroutine 同时使用；nil Recorder 不做任何事
+type Recorder struct {
+	store       *Store
+	mu          sync.Mutex
+	file        *os.File
+	meta        Meta
+	start       time.Time
+	size        int64
+	recordInput bool
+	closed      bool
+}
+
+// Output 记录终端输出
+func (r *Recorder) Output(b []byte) { r.event("o", string(b)) }
+
+// Input 记录用户输入（recording.recordInput 为 false 时忽略）
+func (r *Recorder) Input(b []byte) {
+	if r != nil && r.recordInput {
+		r.event("i", string(b))
+	}
+}
+
+// Resize 记录终端大小变化
+func (r *Recorder) Resize(cols, rows int) { r.event("r", fmt.Sprintf("%dx%d", cols, rows)) }
+
+// ID 录像 ID
+func (r *Recorder) ID() string {
+	if r == nil {
+		return ""
+	}
+	return r.meta.ID
+}
+
+func (r *Recorder) event(code, data string) {
+	if r == nil {
+		return
+	}
+	// 终端输出可能在多字节字符中间截断，json 会把无效字节替换为 U+FFFD
+	line, _ := json.Marshal([]any{float64(time.Since(r.start).Microseconds()) / 1e6, code, data})
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	_ = r.write(line)
+}
+
+func (r *Recorder) write(line []byte) error {
+	n, err := r.file.Write(append(line, '\n'))
+	r.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write recording: %w", err)
+	}
+	return nil
+}
+
+// Close 结束录制并保存会话时长与大小
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	err := r.file.Close()
+	now := time.Now()
+	r.meta.EndedAt = now
+	r.meta.Duration = now.Sub(r.start).Seconds()
+	r.meta.Size = r.size
+	if merr := r.store.writeMeta(r.meta); err == nil {
+		err = merr
+	}
+	return err
+}
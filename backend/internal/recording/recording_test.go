@@ -0,0 +1,153 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+)
+
+func setRecordingConfig(t *testing.T, c config.RecordingConfig) {
+	t.Helper()
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	old := config.Get()
+	t.Cleanup(func() { config.SetGlobal(old) })
+	cfg := *old
+	cfg.Recording = c
+	config.SetGlobal(&cfg)
+}
+
+func TestRecordCast(t *testing.T) {
+	setRecordingConfig(t, config.RecordingConfig{Enabled: true, RecordInput: true})
+	s := NewStore(filepath.Join(t.TempDir(), "recordings"))
+
+	r, err := s.Start(Meta{Kind: KindContainer, User: "alice", Container: "web", Shell: "sh"}, 80, 24)
+	if err != nil || r == nil {
+		t.Fatalf("start = %v, %v", r, err)
+	}
+	r.Output([]byte("$ "))
+	r.Input([]byte("ls\r"))
+	r.Resize(120, 40)
+	r.Output([]byte("a.txt\r\n"))
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r.Output([]byte("after close"))
+
+	p, err := s.Path(r.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _ := os.Open(p)
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Scan()
+	var h header
+	if err := json.Unmarshal(sc.Bytes(), &h); err != nil || h.Version != 2 || h.Width != 80 || h.Height != 24 {
+		t.Fatalf("header = %s, %v", sc.Text(), err)
+	}
+	var codes, data []string
+	last := 0.0
+	for sc.Scan() {
+		var ev []any
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil || len(ev) != 3 {
+			t.Fatalf("event = %s, %v", sc.Text(), err)
+		}
+		if ts := ev[0].(float64); ts < last {
+			t.Fatalf("timestamps not monotonic: %s", sc.Text())
+		} else {
+			last = ts
+		}
+		codes = append(codes, ev[1].(string))
+		data = append(data, ev[2].(string))
+	}
+	if strings.Join(codes, ",") != "o,i,r,o" || data[1] != "ls\r" || data[2] != "120x40" {
+		t.Fatalf("events = %v %q", codes, data)
+	}
+
+	list, err := s.List()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("list = %+v, %v", list, err)
+	}
+	if m := list[0]; m.User != "alice" || m.Container != "web" || m.EndedAt.IsZero() || m.Size == 0 {
+		t.Fatalf("meta = %+v", m)
+	}
+
+	if _, err := s.Path("../users"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("path traversal: %v", err)
+	}
+	if err := s.Delete(r.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.List(); len(list) != 0 {
+		t.Fatalf("after delete = %+v", list)
+	}
+}
+
+func TestDisabledAndInputOff(t *testing.T) {
+	setRecordingConfig(t, config.RecordingConfig{Enabled: false})
+	dir := filepath.Join(t.TempDir(), "recordings")
+	s := NewStore(dir)
+	r, err := s.Start(Meta{Kind: KindHost}, 80, 24)
+	if err != nil || r != nil {
+		t.Fatalf("disabled start = %v, %v", r, err)
+	}
+	// nil Recorder 可以安全使用
+	r.Output([]byte("x"))
+	r.Input([]byte("x"))
+	r.Resize(1, 1)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatal("disabled recording wrote files")
+	}
+
+	setRecordingConfig(t, config.RecordingConfig{Enabled: true, RecordInput: false})
+	r, _ = s.Start(Meta{Kind: KindHost}, 80, 24)
+	r.Input([]byte("secret\r"))
+	r.Close()
+	p, _ := s.Path(r.ID())
+	data, _ := os.ReadFile(p)
+	if strings.Contains(string(data), "secret") {
+		t.Fatal("input recorded with recordInput disabled")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	setRecordingConfig(t, config.RecordingConfig{Enabled: true, MaxAgeDays: 7, MaxTotalMB: 1})
+	s := NewStore(filepath.Join(t.TempDir(), "recordings"))
+
+	old, _ := s.Start(Meta{Kind: KindHost}, 80, 24)
+	old.Close()
+	// 把开始时间改到保留期之前
+	old.meta.StartedAt = time.Now().AddDate(0, 0, -8)
+	if err := s.writeMeta(old.meta); err != nil {
+		t.Fatal(err)
+	}
+
+	big, _ := s.Start(Meta{Kind: KindHost}, 80, 24)
+	big.Output([]byte(strings.Repeat("a", 700<<10)))
+	big.Close()
+	if _, err := s.Path(old.ID()); !errors.Is(err, ErrNotFound) {
+		t.Fatal("expired recording not pruned")
+	}
+
+	// 再录制一个大文件使总大小超过 1MB，最旧的录像被删除
+	next, _ := s.Start(Meta{Kind: KindHost}, 80, 24)
+	next.Output([]byte(strings.Repeat("b", 700<<10)))
+	next.Close()
+	last, _ := s.Start(Meta{Kind: KindHost}, 80, 24)
+	last.Close()
+	list, _ := s.List()
+	if len(list) != 2 || list[len(list)-1].ID != next.ID() {
+		t.Fatalf("after size prune = %+v", list)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/jianxcao/watch-docker/backend/internal/config"
 	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
 	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/metrics"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"go.uber.org/zap"
 
@@ -46,6 +47,8 @@ type Client struct {
 	manifestClient *manifestpkg.Client
 	rateLimits     map[string]rateLimitState // registry key -> 限流状态
 	rateLimitMu    sync.RWMutex
+	tagCache       map[string]tagCacheEntry // host/repo -> tag 列表
+	tagMu          sync.RWMutex
 }
 
 func New() *Client {
@@ -71,6 +74,7 @@ func New() *Client {
 		cache:          make(map[string]CacheEntry),
 		manifestClient: manifestClient,
 		rateLimits:     make(map[string]rateLimitState),
+		tagCache:       make(map[string]tagCacheEntry),
 	}
 
 	// 初始化凭据
@@ -88,6 +92,14 @@ func (c *Client) getCache(key string) (CacheEntry, bool) {
 	return CacheEntry{}, false
 }
 
+// scanCacheTTL 返回远程查询结果的缓存时长（scan.cacheTTL，默认 5 分钟）
+func scanCacheTTL() time.Duration {
+	if cfg := config.Get(); cfg != nil && cfg.Scan.CacheTTL > 0 {
+		return cfg.Scan.CacheTTL.Duration()
+	}
+	return time.Minute * 5
+}
+
 func (c *Client) setCache(key, digest string, ttl time.Duration, errType ErrorType) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -207,7 +219,7 @@ func mapHostToRegistryKey(host string) string {
 	case "ghcr.io":
 		return manifestpkg.GHCRKey
 	default:
-		// 自定义 registry 暂不支持批量模式
+		// 自定义 registry 不经过 manifest 库，见 custom.go
 		return ""
 	}
 }
@@ -236,7 +248,7 @@ func (c *Client) GetRemoteDigestsBatch(ctx context.Context, imageRefs []string,
 	imageRefMap := make(map[string]string) // normalized -> original
 
 	for _, imageRef := range imageRefs {
-		normalized, _, _, tag, err := normalizeImageRef(imageRef)
+		normalized, host, _, tag, err := normalizeImageRef(imageRef)
 		if err != nil {
 			results[imageRef] = DigestResult{Error: err, ErrType: ErrorTypeGeneral}
 			continue
@@ -246,7 +258,9 @@ func (c *Client) GetRemoteDigestsBatch(ctx context.Context, imageRefs []string,
 
 		// 检查缓存（含负缓存）
 		if isUserCache {
-			if entry, ok := c.getCache(normalized); ok {
+			entry, ok := c.getCache(normalized)
+			metrics.ObserveCacheLookup(ok)
+			if ok {
 				if entry.ErrorType != ErrorTypeNone {
 					results[imageRef] = DigestResult{
 						Error:   fmt.Errorf("cached error: %s", entry.ErrorType),
@@ -268,6 +282,9 @@ func (c *Client) GetRemoteDigestsBatch(ctx context.Context, imageRefs []string,
 		// 检查该镜像所在 registry 是否被限流
 		imageName := strings.Split(imageRef, ":")[0]
 		registryKey := manifestpkg.DetectRegistry(imageName)
+		if isCustomRegistryHost(host) {
+			registryKey = registryKeyForHost(host)
+		}
 		if c.isRegistryRateLimited(registryKey) {
 			results[imageRef] = DigestResult{
 				Error:   fmt.Errorf("registry %s 请求频率超限，等待冷却", registryKey),
@@ -335,7 +352,6 @@ func (c *Client) GetRemoteDigestsBatch(ctx context.Context, imageRefs []string,
 		return results
 	}
 
-	// 3. 使用 manifestClient 批量查询
 	cfg := config.Get()
 	if concurrency <= 0 {
 		concurrency = 3
@@ -347,11 +363,23 @@ func (c *Client) GetRemoteDigestsBatch(ctx context.Context, imageRefs []string,
 		concurrency = cfg.Scan.Concurrency
 	}
 
+	// 2.6 自定义 registry（Harbor / Gitea / registry:2 等）单独查询，
+	// 支持 registry.auth 中的凭据、自定义 CA 与 HTTP 访问
+	needQuery, imageSpecs = c.queryCustomRegistries(ctx, needQuery, imageSpecs, results, concurrency)
+	if len(needQuery) == 0 {
+		return results
+	}
+
+	// 3. 使用 manifestClient 批量查询
+
 	logger.Logger.Debug("批量获取 manifest",
 		zap.Int("total", len(imageSpecs)),
 		zap.Int("concurrency", concurrency))
 
+	batchStart := time.Now()
 	manifestResults := c.manifestClient.GetManifestsWithDigest(imageSpecs, concurrency, true, nil)
+	// manifest 库不暴露单次请求耗时，按批次耗时记录每个镜像的请求
+	batchDuration := time.Since(batchStart)
 
 	// 4. 解析结果并缓存
 	ttl := time.Minute * 5
@@ -369,17 +397,17 @@ func (c *Client) GetRemoteDigestsBatch(ctx context.Context, imageRefs []string,
 		}
 
 		imageRef := needQuery[i]
+		imageRegistry := manifestpkg.DetectRegistry(strings.Split(imageRef, ":")[0])
 
 		if manifestResult.Error != nil {
 			errType := detectErrorType(manifestResult.Error)
+			metrics.ObserveRegistryRequest(imageRegistry, string(errType), batchDuration)
 
 			switch errType {
 			case ErrorTypeRateLimited:
-				imageName := strings.Split(imageRef, ":")[0]
-				registryKey := manifestpkg.DetectRegistry(imageName)
-				if !rateLimitedRegistries[registryKey] {
-					rateLimitedRegistries[registryKey] = true
-					c.setRegistryRateLimited(registryKey, rateLimitCooldown)
+				if !rateLimitedRegistries[imageRegistry] {
+					rateLimitedRegistries[imageRegistry] = true
+					c.setRegistryRateLimited(imageRegistry, rateLimitCooldown)
 				}
 				results[imageRef] = DigestResult{Error: manifestResult.Error, ErrType: ErrorTypeRateLimited}
 				logger.Logger.Warn("镜像请求频率超限",
@@ -404,6 +432,7 @@ func (c *Client) GetRemoteDigestsBatch(ctx context.Context, imageRefs []string,
 			continue
 		}
 
+		metrics.ObserveRegistryRequest(imageRegistry, "ok", batchDuration)
 		digest := manifestResult.Digest
 		if digest == "" {
 			results[imageRef] = DigestResult{Error: fmt.Errorf("empty digest"), ErrType: ErrorTypeGeneral}
@@ -442,6 +471,63 @@ func (c *Client) GetRemoteDigestsBatch(ctx context.Context, imageRefs []string,
 	return results
 }
 
+// queryCustomRegistries 查询 needQuery 中属于自定义 registry 的镜像并写入 results，
+// 返回剩余需要交给 manifest 库查询的镜像。
+func (c *Client) queryCustomRegistries(ctx context.Context, needQuery []string, imageSpecs []manifestpkg.ImageSpec, results map[string]DigestResult, concurrency int) ([]string, []manifestpkg.ImageSpec) {
+	customRefs := make([]string, 0)
+	restQuery := make([]string, 0, len(needQuery))
+	restSpecs := make([]manifestpkg.ImageSpec, 0, len(imageSpecs))
+	for i, imageRef := range needQuery {
+		_, host, _, _, err := normalizeImageRef(imageRef)
+		if err == nil && isCustomRegistryHost(host) {
+			customRefs = append(customRefs, imageRef)
+			continue
+		}
+		restQuery = append(restQuery, imageRef)
+		restSpecs = append(restSpecs, imageSpecs[i])
+	}
+	if len(customRefs) == 0 {
+		return restQuery, restSpecs
+	}
+
+	ttl := scanCacheTTL()
+	notFoundCacheTTL := 30 * time.Minute
+	rateLimitCooldown := 5 * time.Minute
+
+	for imageRef, r := range c.queryCustomManifests(ctx, customRefs, concurrency) {
+		normalized, host, _, _, _ := normalizeImageRef(imageRef)
+		if r.Error != nil {
+			errType := detectErrorType(r.Error)
+			switch errType {
+			case ErrorTypeRateLimited:
+				c.setRegistryRateLimited(registryKeyForHost(host), rateLimitCooldown)
+			case ErrorTypeNotFound:
+				if normalized != "" {
+					c.setCache(normalized, "", notFoundCacheTTL, ErrorTypeNotFound)
+				}
+			}
+			results[imageRef] = DigestResult{Error: r.Error, ErrType: errType}
+			logger.Logger.Warn("自定义 registry 获取 manifest 失败",
+				zap.String("image", imageRef),
+				zap.Error(r.Error))
+			continue
+		}
+
+		if normalized != "" {
+			c.setCache(normalized, r.Digest, ttl, ErrorTypeNone)
+		}
+		childDigest, _ := parseManifest(r.Manifest)
+		results[imageRef] = DigestResult{
+			IndexDigest: r.Digest,
+			ChildDigest: childDigest,
+		}
+		logger.Logger.Debug("自定义 registry 获取 manifest 成功",
+			zap.String("image", imageRef),
+			zap.String("digest", r.Digest))
+	}
+	return restQuery, restSpecs
+}
+
 func parseManifest(manifest string) (childDigest string, err error) {
 	var idx v1.Index
 	if err := json.Unmarshal([]byte(manifest), &idx); err != nil {
@@ -0,0 +1,234 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// 自定义 registry（Harbor / Gitea / 自建 registry:2 等）不在 manifest 库的内置列表里，
+// 这里按 distribution 规范直接访问：匿名请求 -> 401 -> 按 WWW-Authenticate 选择
+// Bearer token 或 Basic 认证 -> 重试。每个 host 的 HTTP/CA/证书校验选项取自 registry.auth。
+
+// registryAuthFor 查找 host 对应的 registry.auth 配置。
+// Docker Hub 的各种别名（docker.io / dockerhub / registry-1.docker.io）视为同一 host。
+func registryAuthFor(host string) (config.RegistryAuth, bool) {
+	cfg := config.Get()
+	if cfg == nil {
+		return config.RegistryAuth{}, false
+	}
+	target := strings.ToLower(strings.TrimSpace(host))
+	targetKey := mapHostToRegistryKey(target)
+	for _, auth := range cfg.Registry.Auth {
+		h := strings.ToLower(strings.TrimSpace(auth.Host))
+		if h == target || (targetKey != "" && mapHostToRegistryKey(h) == targetKey) {
+			return auth, true
+		}
+	}
+	return config.RegistryAuth{}, false
+}
+
+// registryKeyForHost 返回 host 的 registry key，用于限流状态等按 registry 区分的记录。
+// 非 Docker Hub / GHCR 的 host 统一为 "custom:<host>"（与 manifest 库的命名一致）。
+func registryKeyForHost(host string) string {
+	if key := mapHostToRegistryKey(host); key != "" {
+		return key
+	}
+	return "custom:" + strings.ToLower(host)
+}
+
+// isCustomRegistryHost 判断 host 是否需要走自定义 registry 流程
+func isCustomRegistryHost(host string) bool {
+	return mapHostToRegistryKey(host) == ""
+}
+
+// registryBaseURL 返回 host 的 API 基础地址，registry.auth 中配置 insecure 时使用 http。
+func registryBaseURL(host string) string {
+	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
+		return strings.TrimRight(host, "/")
+	}
+	if auth, ok := registryAuthFor(host); ok && auth.Insecure {
+		return "http://" + strings.TrimRight(host, "/")
+	}
+	return "https://" + strings.TrimRight(host, "/")
+}
+
+// registryHTTPClient 返回访问 host 的 http 客户端：遵循全局代理配置，
+// 并按 registry.auth 加载自定义 CA 证书或跳过证书校验。
+func (c *Client) registryHTTPClient(host string) (*http.Client, error) {
+	httpClient := c.mirrorHTTPClient()
+	auth, ok := registryAuthFor(host)
+	if !ok || (!auth.SkipTLSVerify && auth.CACert == "") {
+		return httpClient, nil
+	}
+
+	tlsCfg := &tls.Config{InsecureSkipVerify: auth.SkipTLSVerify}
+	if auth.CACert != "" {
+		pem, err := os.ReadFile(auth.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CA 证书失败: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA 证书 %s 中没有有效的 PEM 证书", auth.CACert)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	httpClient.Transport.(*http.Transport).TLSClientConfig = tlsCfg
+	return httpClient, nil
+}
+
+// authorize 根据 401 响应的 WWW-Authenticate 生成 Authorization 头。
+// Bearer challenge 走 token 握手；Basic challenge 直接使用配置的凭据。
+func (c *Client) authorize(httpClient *http.Client, wwwAuth, host, repoPath string) (string, error) {
+	var username, password string
+	if auth, ok := registryAuthFor(host); ok {
+		username, password = auth.Username, auth.Token
+	}
+
+	scheme := strings.ToLower(strings.TrimSpace(wwwAuth))
+	switch {
+	case strings.HasPrefix(scheme, "bearer"):
+		token, err := c.acquireBearerTokenWith(httpClient, wwwAuth, repoPath, username, password)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	case strings.HasPrefix(scheme, "basic"):
+		if username == "" {
+			return "", fmt.Errorf("registry %s 需要 Basic 认证，但未配置凭据", host)
+		}
+		cred := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		return "Basic " + cred, nil
+	default:
+		return "", fmt.Errorf("registry %s 返回了不支持的认证方式: %s", host, truncate(wwwAuth, 100))
+	}
+}
+
+// fetchCustomManifest 查询自定义 registry 中 repoPath:tag 的 manifest 与 digest。
+// 非 200 响应返回的错误包含 "状态码: N"，便于 detectErrorType 识别 404/429。
+func (c *Client) fetchCustomManifest(ctx context.Context, host, repoPath, tag string) (manifest string, digest string, err error) {
+	httpClient, err := c.registryHTTPClient(host)
+	if err != nil {
+		return "", "", err
+	}
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", registryBaseURL(host), repoPath, url.PathEscape(tag))
+
+	body, headers, status, err := doManifestRequest(ctx, httpClient, manifestURL, "")
+	if err != nil {
+		return "", "", err
+	}
+	if status == http.StatusUnauthorized {
+		wwwAuth := headers.Get("Www-Authenticate")
+		if wwwAuth == "" {
+			return "", "", fmt.Errorf("registry %s 返回 401 但未携带 Www-Authenticate", host)
+		}
+		authHeader, aerr := c.authorize(httpClient, wwwAuth, host, repoPath)
+		if aerr != nil {
+			return "", "", fmt.Errorf("registry %s 认证失败: %w", host, aerr)
+		}
+		body, headers, status, err = doManifestRequest(ctx, httpClient, manifestURL, authHeader)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if status != http.StatusOK {
+		return "", "", fmt.Errorf("获取 manifest 失败 (状态码: %d): %s", status, truncate(string(body), 200))
+	}
+
+	digest = headers.Get("Docker-Content-Digest")
+	if digest == "" {
+		sum := sha256.Sum256(body)
+		digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return string(body), digest, nil
+}
+
+// customManifestResult 自定义 registry 的单个查询结果
+type customManifestResult struct {
+	Manifest string
+	Digest   string
+	Error    error
+}
+
+// queryCustomManifests 并发查询自定义 registry 中的镜像，返回 imageRef -> 结果。
+func (c *Client) queryCustomManifests(ctx context.Context, imageRefs []string, concurrency int) map[string]customManifestResult {
+	results := make(map[string]customManifestResult, len(imageRefs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, imageRef := range imageRefs {
+		wg.Add(1)
+		go func(imageRef string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			var r customManifestResult
+			_, host, repoPath, tag, err := normalizeImageRef(imageRef)
+			if err != nil {
+				r.Error = err
+			} else {
+				r.Manifest, r.Digest, r.Error = c.fetchCustomManifest(ctx, host, repoPath, tag)
+			}
+
+			mu.Lock()
+			results[imageRef] = r
+			mu.Unlock()
+		}(imageRef)
+	}
+	wg.Wait()
+
+	logger.Logger.Debug("自定义 registry 查询完成", zap.Int("total", len(imageRefs)))
+	return results
+}
+
+// doManifestRequest 发起一次带 manifest Accept 头的 GET 请求。
+// authHeader 为完整的 Authorization 头（如 "Bearer xxx"），为空则匿名请求。
+func doManifestRequest(ctx context.Context, httpClient *http.Client, manifestURL, authHeader string) ([]byte, http.Header, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("创建请求失败: %w", err)
+	}
+	for _, a := range mirrorManifestAccept {
+		req.Header.Add("Accept", a)
+	}
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveRegistryResponse(req.URL.Host, 0, err, time.Since(start))
+		return nil, nil, 0, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	metrics.ObserveRegistryResponse(req.URL.Host, resp.StatusCode, nil, time.Since(start))
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, resp.Header, resp.StatusCode, fmt.Errorf("读取响应失败: %w", err)
+	}
+	return body, resp.Header, resp.StatusCode, nil
+}
@@ -0,0 +1,190 @@
+package registry
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"go.uber.org/zap"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// newTestRegistry 模拟一个需要认证的 registry:2。
+// scheme 为 "bearer" 时走 /token 握手，为 "basic" 时直接校验 Basic 凭据。
+func newTestRegistry(t *testing.T, scheme string, tls bool) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+
+	authorized := func(r *http.Request) bool {
+		if scheme == "basic" {
+			u, p, ok := r.BasicAuth()
+			return ok && u == "alice" && p == "secret"
+		}
+		return r.Header.Get("Authorization") == "Bearer test-token"
+	}
+	challenge := func(w http.ResponseWriter) {
+		if scheme == "basic" {
+			w.Header().Set("Www-Authenticate", `Basic realm="registry"`)
+		} else {
+			w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry"`, srv.URL))
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || u != "alice" || p != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("scope") != "repository:team/app:pull" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"token":"test-token"}`)
+	})
+	mux.HandleFunc("/v2/team/app/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			challenge(w)
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, "/1.0") {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[{"code":"MANIFEST_UNKNOWN"}]}`)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", testDigest)
+		fmt.Fprint(w, `{"schemaVersion":2,"manifests":[]}`)
+	})
+	mux.HandleFunc("/v2/team/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			challenge(w)
+			return
+		}
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/team/app/tags/list?last=1.1&n=2>; rel="next"`)
+			fmt.Fprint(w, `{"name":"team/app","tags":["1.0","1.1"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"name":"team/app","tags":["2.0"]}`)
+	})
+
+	if tls {
+		srv = httptest.NewTLSServer(mux)
+	} else {
+		srv = httptest.NewServer(mux)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func setupTestConfig(t *testing.T, auth config.RegistryAuth) {
+	t.Helper()
+	logger.Logger = zap.NewNop()
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	cfg := config.Get()
+	copied := *cfg
+	copied.Registry = config.RegistryConfig{Auth: []config.RegistryAuth{auth}}
+	config.SetGlobal(&copied)
+	t.Cleanup(func() { config.SetGlobal(cfg) })
+}
+
+func TestGetRemoteDigestsBatchCustomRegistry(t *testing.T) {
+	for _, scheme := range []string{"bearer", "basic"} {
+		t.Run(scheme, func(t *testing.T) {
+			srv := newTestRegistry(t, scheme, false)
+			host := strings.TrimPrefix(srv.URL, "http://")
+			setupTestConfig(t, config.RegistryAuth{Host: host, Username: "alice", Token: "secret", Insecure: true})
+
+			c := New()
+			ok := host + "/team/app:1.0"
+			missing := host + "/team/app:9.9"
+			results := c.GetRemoteDigestsBatch(context.Background(), []string{ok, missing}, false, false, 2)
+
+			if got := results[ok]; got.Error != nil || got.IndexDigest != testDigest {
+				t.Fatalf("expected digest %s, got %+v", testDigest, got)
+			}
+			if got := results[missing]; got.ErrType != ErrorTypeNotFound {
+				t.Fatalf("expected not_found for missing tag, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestGetRemoteDigestsBatchCustomRegistryWithCACert(t *testing.T) {
+	srv := newTestRegistry(t, "bearer", true)
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caPath, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	setupTestConfig(t, config.RegistryAuth{Host: host, Username: "alice", Token: "secret", CACert: caPath})
+
+	ref := host + "/team/app:1.0"
+	results := New().GetRemoteDigestsBatch(context.Background(), []string{ref}, false, false, 1)
+	if got := results[ref]; got.Error != nil || got.IndexDigest != testDigest {
+		t.Fatalf("expected digest %s, got %+v", testDigest, got)
+	}
+}
+
+func TestListTagsFollowsLinkHeader(t *testing.T) {
+	srv := newTestRegistry(t, "bearer", false)
+	host := strings.TrimPrefix(srv.URL, "http://")
+	setupTestConfig(t, config.RegistryAuth{Host: host, Username: "alice", Token: "secret", Insecure: true})
+
+	tags, err := New().ListTags(context.Background(), host+"/team/app")
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	want := []string{"1.0", "1.1", "2.0"}
+	if strings.Join(tags, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected tags %v, got %v", want, tags)
+	}
+}
+
+func TestListTagsDropsAuthOnCrossHostLink(t *testing.T) {
+	var leaked string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("Authorization")
+		fmt.Fprint(w, `{"name":"team/app","tags":["9.9"]}`)
+	}))
+	t.Cleanup(other.Close)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || u != "alice" || p != "secret" {
+			w.Header().Set("Www-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s/v2/team/app/tags/list?last=1.0>; rel="next"`, other.URL))
+		fmt.Fprint(w, `{"name":"team/app","tags":["1.0"]}`)
+	}))
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "http://")
+	setupTestConfig(t, config.RegistryAuth{Host: host, Username: "alice", Token: "secret", Insecure: true})
+
+	tags, err := New().ListTags(context.Background(), host+"/team/app")
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	if strings.Join(tags, ",") != "1.0,9.9" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if leaked != "" {
+		t.Fatalf("credentials sent to another host: %q", leaked)
+	}
+}
@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -94,34 +95,33 @@ func (c *Client) fetchManifestFromMirror(mirrorHost, imageRef string) (manifest
 
 // requestManifest 发起一次带 manifest Accept 头的 GET 请求，返回响应体与状态码。
 func (c *Client) requestManifest(manifestURL, bearer string) ([]byte, http.Header, int, error) {
-	req, err := http.NewRequest("GET", manifestURL, nil)
-	if err != nil {
-		return nil, nil, 0, fmt.Errorf("创建请求失败: %w", err)
-	}
-	for _, a := range mirrorManifestAccept {
-		req.Header.Add("Accept", a)
-	}
+	authHeader := ""
 	if bearer != "" {
-		req.Header.Set("Authorization", "Bearer "+bearer)
-	}
-
-	httpClient := c.mirrorHTTPClient()
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, nil, 0, fmt.Errorf("请求失败: %w", err)
+		authHeader = "Bearer " + bearer
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, resp.Header, resp.StatusCode, fmt.Errorf("读取响应失败: %w", err)
-	}
-	return body, resp.Header, resp.StatusCode, nil
+	return doManifestRequest(context.Background(), c.mirrorHTTPClient(), manifestURL, authHeader)
 }
 
 // acquireMirrorToken 通过 WWW-Authenticate 提供的 realm/service/scope 获取 token。
 // 若解析出的 scope 缺省，则使用 repository:{path}:pull 兜底。
 func (c *Client) acquireMirrorToken(wwwAuth, repoPath string) (string, error) {
+	// 如果配置了 docker.io 的凭据，带上 Basic Auth（部分镜像需要登录）
+	var username, password string
+	if cred, ok := c.manifestClient.GetCredential(manifestpkg.DockerHubKey); ok {
+		username, password = cred.Username, cred.Token
+	}
+	return c.acquireBearerToken(wwwAuth, repoPath, username, password)
+}
+
+// acquireBearerToken 按 WWW-Authenticate 的 Bearer challenge 获取 token，
+// username/password 非空时以 Basic Auth 方式提交给认证服务。
+func (c *Client) acquireBearerToken(wwwAuth, repoPath, username, password string) (string, error) {
+	return c.acquireBearerTokenWith(c.mirrorHTTPClient(), wwwAuth, repoPath, username, password)
+}
+
+// acquireBearerTokenWith 同 acquireBearerToken，使用指定的 http 客户端访问认证服务
+// （自定义 registry 的认证服务可能需要自定义 CA）。
+func (c *Client) acquireBearerTokenWith(httpClient *http.Client, wwwAuth, repoPath, username, password string) (string, error) {
 	realm, service, scope, err := manifestpkg.ParseWWWAuthenticate(wwwAuth)
 	if err != nil {
 		return "", err
@@ -151,12 +151,11 @@ func (c *Client) acquireMirrorToken(wwwAuth, repoPath string) (string, error) {
 		return "", fmt.Errorf("创建认证请求失败: %w", err)
 	}
 
-	// 如果配置了 docker.io 的凭据，带上 Basic Auth（部分镜像需要登录）
-	if cred, ok := c.manifestClient.GetCredential(manifestpkg.DockerHubKey); ok && cred.Username != "" && cred.Token != "" {
-		req.SetBasicAuth(cred.Username, cred.Token)
+	if username != "" && password != "" {
+		req.SetBasicAuth(username, password)
 	}
 
-	resp, err := c.mirrorHTTPClient().Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("认证请求失败: %w", err)
 	}
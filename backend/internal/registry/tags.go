@@ -0,0 +1,244 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// maxTagPages 单个仓库最多翻页次数，防止异常 registry 返回循环的 Link
+const maxTagPages = 100
+
+// tagPageSize 每页请求的 tag 数量
+const tagPageSize = 1000
+
+// maxTagResponseSize 单页 tag 列表响应的最大字节数
+const maxTagResponseSize = 16 << 20
+
+// tagsListResponse /v2/<repo>/tags/list 的响应体
+type tagsListResponse struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// tagCacheEntry tag 列表缓存
+type tagCacheEntry struct {
+	Tags   []string
+	Expiry time.Time
+}
+
+// ListTags 列出镜像仓库在 registry 中的全部 tag。
+// imageRef 可带或不带 tag（tag 部分会被忽略），例如 "nginx:1.25" / "ghcr.io/foo/bar"。
+// docker.io 镜像会优先通过启用的 mirror 查询，全部失败后回退到官方 registry。
+// 结果按 scan.cacheTTL 缓存。
+func (c *Client) ListTags(ctx context.Context, imageRef string) ([]string, error) {
+	_, host, repoPath, _, err := normalizeImageRef(imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("解析镜像引用失败: %w", err)
+	}
+
+	cacheKey := host + "/" + repoPath
+	if tags, ok := c.getTagCache(cacheKey); ok {
+		return tags, nil
+	}
+
+	hosts := make([]string, 0, 4)
+	if dockercli.IsDockerHubImage(imageRef) {
+		hosts = append(hosts, dockercli.EnabledMirrorHosts()...)
+	}
+	hosts = append(hosts, host)
+
+	var lastErr error
+	for _, h := range hosts {
+		tags, err := c.listTagsFrom(ctx, h, repoPath)
+		if err == nil {
+			c.setTagCache(cacheKey, tags)
+			return tags, nil
+		}
+		lastErr = err
+		if h != host {
+			logger.Logger.Debug("mirror 获取 tag 列表失败，尝试下一个",
+				zap.String("image", imageRef),
+				zap.String("mirror", h),
+				zap.Error(err))
+		}
+	}
+	return nil, lastErr
+}
+
+// listTagsFrom 从 endpoint 拉取 repoPath 的全部 tag，按 Link 头翻页。
+// 使用为 endpoint 配置的凭据（mirror 未配置时匿名查询）；Link 指向其他主机时不携带认证头。
+func (c *Client) listTagsFrom(ctx context.Context, endpoint, repoPath string) ([]string, error) {
+	httpClient, err := c.registryHTTPClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	nextURL := fmt.Sprintf("%s/v2/%s/tags/list?n=%d", registryBaseURL(endpoint), repoPath, tagPageSize)
+	endpointHost := urlHost(nextURL)
+
+	var (
+		authHeader string
+		all        []string
+	)
+	for page := 0; nextURL != "" && page < maxTagPages; page++ {
+		sameHost := urlHost(nextURL) == endpointHost
+		pageAuth := authHeader
+		if !sameHost {
+			pageAuth = ""
+		}
+		body, headers, status, err := getJSON(ctx, httpClient, nextURL, pageAuth)
+		if err != nil {
+			return nil, err
+		}
+		if status == http.StatusUnauthorized && sameHost && authHeader == "" {
+			authHeader, err = c.authorize(httpClient, headers.Get("Www-Authenticate"), endpoint, repoPath)
+			if err != nil {
+				return nil, fmt.Errorf("registry %s 认证失败: %w", endpoint, err)
+			}
+			body, headers, status, err = getJSON(ctx, httpClient, nextURL, authHeader)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if status != http.StatusOK {
+			return nil, fmt.Errorf("获取 tag 列表失败 (状态码: %d): %s", status, truncate(string(body), 200))
+		}
+
+		var resp tagsListResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("解析 tag 列表失败: %w", err)
+		}
+		all = append(all, resp.Tags...)
+		nextURL = nextPageURL(nextURL, headers.Get("Link"))
+	}
+	return all, nil
+}
+
+// urlHost 返回地址的 host（含端口），解析失败时返回空字符串
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// nextPageURL 解析 Link 头中的 rel="next"，相对地址按当前请求地址补全。
+// 例如: </v2/library/nginx/tags/list?last=1.25&n=1000>; rel="next"
+func nextPageURL(current, link string) string {
+	if link == "" {
+		return ""
+	}
+	for _, part := range strings.Split(link, ",") {
+		segs := strings.Split(part, ";")
+		if len(segs) < 2 {
+			continue
+		}
+		isNext := false
+		for _, p := range segs[1:] {
+			p = strings.ReplaceAll(strings.TrimSpace(p), " ", "")
+			if p == `rel="next"` || p == "rel=next" {
+				isNext = true
+				break
+			}
+		}
+		if !isNext {
+			continue
+		}
+		target := strings.Trim(strings.TrimSpace(segs[0]), "<>")
+		base, err := url.Parse(current)
+		if err != nil {
+			return ""
+		}
+		ref, err := url.Parse(target)
+		if err != nil {
+			return ""
+		}
+		return base.ResolveReference(ref).String()
+	}
+	return ""
+}
+
+// InvalidateRepository 清除镜像仓库（所有 tag）的 digest 与 tag 列表缓存，
+// 用于收到镜像推送通知后立即重新查询远端
+func (c *Client) InvalidateRepository(imageRef string) error {
+	_, host, repoPath, _, err := normalizeImageRef(imageRef)
+	if err != nil {
+		return fmt.Errorf("解析镜像引用失败: %w", err)
+	}
+	prefix := host + "/" + repoPath + ":"
+	c.mu.Lock()
+	for key := range c.cache {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.cache, key)
+		}
+	}
+	c.mu.Unlock()
+
+	c.tagMu.Lock()
+	delete(c.tagCache, host+"/"+repoPath)
+	c.tagMu.Unlock()
+	return nil
+}
+
+// CachedTags 只读缓存的 ListTags：缓存命中时返回 tag 列表，不发起远程请求
+func (c *Client) CachedTags(imageRef string) ([]string, bool) {
+	_, host, repoPath, _, err := normalizeImageRef(imageRef)
+	if err != nil {
+		return nil, false
+	}
+	return c.getTagCache(host + "/" + repoPath)
+}
+
+func (c *Client) getTagCache(key string) ([]string, bool) {
+	c.tagMu.RLock()
+	defer c.tagMu.RUnlock()
+	if e, ok := c.tagCache[key]; ok && time.Now().Before(e.Expiry) {
+		return e.Tags, true
+	}
+	return nil, false
+}
+
+func (c *Client) setTagCache(key string, tags []string) {
+	c.tagMu.Lock()
+	defer c.tagMu.Unlock()
+	c.tagCache[key] = tagCacheEntry{Tags: tags, Expiry: time.Now().Add(scanCacheTTL())}
+}
+
+// getJSON 发起一次 GET 请求，返回响应体、响应头与状态码。
+// authHeader 为完整的 Authorization 头，为空则匿名请求。
+func getJSON(ctx context.Context, httpClient *http.Client, rawURL, authHeader string) ([]byte, http.Header, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveRegistryResponse(req.URL.Host, 0, err, time.Since(start))
+		return nil, nil, 0, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	metrics.ObserveRegistryResponse(req.URL.Host, resp.StatusCode, nil, time.Since(start))
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTagResponseSize))
+	if err != nil {
+		return nil, resp.Header, resp.StatusCode, fmt.Errorf("读取响应失败: %w", err)
+	}
+	return body, resp.Header, resp.StatusCode, nil
+}
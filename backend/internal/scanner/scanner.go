@@ -8,8 +8,11 @@ import (
 	"github.com/jianxcao/watch-docker/backend/internal/config"
 	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
 	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/metrics"
 	"github.com/jianxcao/watch-docker/backend/internal/policy"
 	"github.com/jianxcao/watch-docker/backend/internal/registry"
+
+	"github.com/distribution/reference"
 	"go.uber.org/zap"
 )
 
@@ -30,6 +33,20 @@ type ContainerStatus struct {
 	StartedAt     string                    `json:"startedAt"`
 	Ports         []dockercli.PortInfo      `json:"ports"`
 	Stats         *dockercli.ContainerStats `json:"stats,omitempty"`
+	// SemverMode 语义化版本升级模式（patch/minor/major），为空表示按 digest 比较
+	SemverMode string `json:"semverMode,omitempty"`
+	// NewTag 语义化版本升级模式下选中的新 tag
+	NewTag string `json:"newTag,omitempty"`
+	// TargetImage 更新目标镜像引用（换成新 tag 后的完整引用），为空表示沿用 Image
+	TargetImage string `json:"targetImage,omitempty"`
+}
+
+// UpdateImage 返回更新时应拉取并用于重建容器的镜像引用。
+func (st ContainerStatus) UpdateImage() string {
+	if st.TargetImage != "" {
+		return st.TargetImage
+	}
+	return st.Image
 }
 
 type Scanner struct {
@@ -63,6 +80,7 @@ func (s *Scanner) ScanOnce(ctx context.Context, includeStopped bool, concurrency
 		image         string
 		repoDigests   []string
 		skippedUpdate bool
+		semverMode    string
 	}
 	imageToContainers := make(map[string][]containerInfo)
 
@@ -91,6 +109,7 @@ func (s *Scanner) ScanOnce(ctx context.Context, includeStopped bool, concurrency
 			OnlyLabels:         cfg.Policy.OnlyLabels,
 			SkipLabels:         cfg.Policy.SkipLabels,
 			AllowComposeUpdate: cfg.Scan.AllowComposeUpdate,
+			SemverMode:         cfg.Policy.SemverMode,
 		})
 
 		if dec.Skipped && !dec.Force {
@@ -108,11 +127,13 @@ func (s *Scanner) ScanOnce(ctx context.Context, includeStopped bool, concurrency
 			image:         ct.Image,
 			repoDigests:   ct.RepoDigests,
 			skippedUpdate: dec.SkippedUpdate,
+			semverMode:    dec.SemverMode,
 		})
 	}
 
 	// 2. 批量查询所有镜像
 	if len(imageToContainers) == 0 {
+		observeScan(result)
 		return result, nil
 	}
 
@@ -148,6 +169,7 @@ func (s *Scanner) ScanOnce(ctx context.Context, includeStopped bool, concurrency
 				Ports:         ct.Ports,
 				CurrentDigest: info.repoDigests,
 				SkippedUpdate: info.skippedUpdate,
+				SemverMode:    info.semverMode,
 			}
 
 			if digestResult.Error != nil {
@@ -194,9 +216,102 @@ func (s *Scanner) ScanOnce(ctx context.Context, includeStopped bool, concurrency
 		}
 	}
 
+	// 4. 语义化版本升级：列出远端 tag，选出满足升级幅度的最高版本作为更新目标；
+	// 只读缓存的扫描使用缓存的 tag 列表，保证界面与批量更新拿到同样的目标镜像
+	s.resolveSemverTargets(ctx, result, cacheOnly)
+	if !cacheOnly {
+		// 只读缓存的扫描可能未命中缓存，不计入指标
+		observeScan(result)
+	}
+
 	return result, nil
 }
 
+// observeScan 将扫描结果按状态、跳过原因与错误类型汇总到指标
+func observeScan(result []ContainerStatus) {
+	r := metrics.ScanResult{
+		ByStatus:    make(map[string]int),
+		BySkip:      make(map[string]int),
+		ByErrorType: make(map[string]int),
+	}
+	for _, st := range result {
+		r.ByStatus[st.Status]++
+		switch st.Status {
+		case "Skipped":
+			r.BySkip[st.SkipReason]++
+		case "Error":
+			r.ByErrorType[st.ErrorType]++
+		}
+	}
+	metrics.ObserveScan(r)
+}
+
+// resolveSemverTargets 为开启语义化版本升级模式的容器选出新 tag。
+// 同一仓库只列一次 tag；列 tag 失败时保留 digest 比较的结果。
+// cacheOnly 时只使用 registry 缓存的 tag 列表，未命中缓存的容器保留 digest 比较的结果。
+func (s *Scanner) resolveSemverTargets(ctx context.Context, result []ContainerStatus, cacheOnly bool) {
+	tagsByRepo := make(map[string][]string)
+	failedRepos := make(map[string]bool)
+
+	for i := range result {
+		st := &result[i]
+		if st.SemverMode == "" || st.Status == "Error" {
+			continue
+		}
+		named, err := reference.ParseNormalizedNamed(st.Image)
+		if err != nil {
+			continue
+		}
+		tagged, ok := named.(reference.NamedTagged)
+		if !ok {
+			continue
+		}
+		repo := named.Name()
+		if failedRepos[repo] {
+			continue
+		}
+		tags, ok := tagsByRepo[repo]
+		if !ok && cacheOnly {
+			if tags, ok = s.registry.CachedTags(st.Image); !ok {
+				failedRepos[repo] = true
+				continue
+			}
+			tagsByRepo[repo] = tags
+		}
+		if !ok {
+			tags, err = s.registry.ListTags(ctx, st.Image)
+			if err != nil {
+				failedRepos[repo] = true
+				logger.Logger.Warn("列出镜像 tag 失败，回退为 digest 比较",
+					zap.String("image", st.Image),
+					logger.ZapErr(err))
+				continue
+			}
+			tagsByRepo[repo] = tags
+		}
+
+		newTag, found := policy.SelectSemverTag(tagged.Tag(), tags, st.SemverMode)
+		if !found {
+			continue
+		}
+		target, err := reference.WithTag(reference.TrimNamed(named), newTag)
+		if err != nil {
+			continue
+		}
+		st.NewTag = newTag
+		st.TargetImage = reference.FamiliarString(target)
+		st.Status = "UpdateAvailable"
+		if cacheOnly {
+			continue
+		}
+		logger.Logger.Info("发现语义化版本升级",
+			zap.String("container", st.Name),
+			zap.String("image", st.Image),
+			zap.String("targetImage", st.TargetImage),
+			zap.String("mode", st.SemverMode))
+	}
+}
+
 func compareDigests(currentDigests []string, remoteDigest string) bool {
 	if remoteDigest == "" {
 		return false
@@ -232,6 +347,11 @@ func extractDigest(value string) string {
 	return ""
 }
 
+// GetDockerClient 返回 Docker 客户端
+func (s *Scanner) GetDockerClient() *dockercli.Client {
+	return s.docker
+}
+
 // GetRegistryClient 返回 registry 客户端（用于动态更新凭据）
 func (s *Scanner) GetRegistryClient() *registry.Client {
 	return s.registry
@@ -0,0 +1,247 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/history"
+	"github.com/jianxcao/watch-docker/backend/internal/scanner"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// ScheduleLabel 容器级更新计划 label，值为 cron 表达式，如 watchdocker.schedule="0 3 * * SUN"
+const ScheduleLabel = "watchdocker.schedule"
+
+// 任务类型
+const (
+	JobKindGlobal    = "global"    // 全局 scan.cron 任务
+	JobKindContainer = "container" // 容器独立更新计划
+	JobKindSystem    = "system"    // 内部维护任务
+)
+
+// JobInfo 定时任务信息
+type JobInfo struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Cron      string    `json:"cron"`
+	Source    string    `json:"source,omitempty"` // 容器计划的来源：label 或 rule:<label>
+	Container string    `json:"container,omitempty"`
+	Error     string    `json:"error,omitempty"` // cron 表达式无效时的错误
+	Next      time.Time `json:"next"`
+	Prev      time.Time `json:"prev"`
+}
+
+// QueuedUpdate 因不在维护窗口内而排队等待的更新
+type QueuedUpdate struct {
+	Container string    `json:"container"`
+	Image     string    `json:"image"`
+	QueuedAt  time.Time `json:"queuedAt"`
+}
+
+type containerJob struct {
+	entryID cron.EntryID
+	spec    string
+	source  string
+	err     string
+}
+
+// scheduleParser 容器计划兼容 5 段（分 时 日 月 周）与 6 段（带秒）cron 表达式
+var scheduleParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// scheduleFor 返回容器的独立更新计划：优先使用容器的 watchdocker.schedule 标签，其次匹配 schedule.rules
+func scheduleFor(labels map[string]string, rules []config.ScheduleRule) (spec, source string) {
+	if v := strings.TrimSpace(labels[ScheduleLabel]); v != "" {
+		return v, "label"
+	}
+	for _, r := range rules {
+		key, value, hasValue := strings.Cut(strings.TrimSpace(r.Label), "=")
+		if v, ok := labels[key]; ok && (!hasValue || v == value) {
+			return strings.TrimSpace(r.Cron), "rule:" + r.Label
+		}
+	}
+	return "", ""
+}
+
+// hasOwnSchedule 容器是否有有效的独立更新计划，有则不由全局任务自动更新
+func hasOwnSchedule(labels map[string]string, rules []config.ScheduleRule) bool {
+	spec, _ := scheduleFor(labels, rules)
+	if spec == "" {
+		return false
+	}
+	_, err := scheduleParser.Parse(spec)
+	return err == nil
+}
+
+// tick 每分钟执行：同步容器计划，维护窗口打开时执行排队的更新
+func (s *Scheduler) tick(ctx context.Context) {
+	s.syncContainerJobs(ctx)
+
+	s.mu.Lock()
+	queued := len(s.queue)
+	s.mu.Unlock()
+	if queued > 0 && InMaintenanceWindow(config.Get().Schedule.MaintenanceWindows, time.Now()) {
+		s.drainQueue(ctx)
+	}
+}
+
+// syncContainerJobs 根据容器标签与 schedule.rules 增删容器的独立更新任务
+func (s *Scheduler) syncContainerJobs(ctx context.Context) {
+	cfg := config.Get()
+	labels, err := s.scanner.GetDockerClient().ListContainerLabels(ctx, cfg.Docker.IncludeStopped)
+	if err != nil {
+		s.logger.Warn("同步容器更新计划失败", zap.Error(err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cron == nil || ctx.Err() != nil {
+		return
+	}
+
+	for name, job := range s.containerJobs {
+		spec, _ := scheduleFor(labels[name], cfg.Schedule.Rules)
+		if _, exists := labels[name]; exists && spec == job.spec {
+			continue
+		}
+		if job.entryID != 0 {
+			s.cron.Remove(job.entryID)
+		}
+		delete(s.containerJobs, name)
+		s.logger.Info("已移除容器更新计划", zap.String("container", name), zap.String("cron", job.spec))
+	}
+
+	for name, l := range labels {
+		spec, source := scheduleFor(l, cfg.Schedule.Rules)
+		if spec == "" {
+			continue
+		}
+		if _, exists := s.containerJobs[name]; exists {
+			continue
+		}
+		job := containerJob{spec: spec, source: source}
+		sched, err := scheduleParser.Parse(spec)
+		if err != nil {
+			job.err = err.Error()
+			s.logger.Warn("容器更新计划的 cron 表达式无效",
+				zap.String("container", name),
+				zap.String("cron", spec),
+				zap.Error(err))
+		} else {
+			containerName := name
+			job.entryID = s.cron.Schedule(sched, cron.FuncJob(func() {
+				s.runContainerJob(ctx, containerName)
+			}))
+			s.logger.Info("已添加容器更新计划",
+				zap.String("container", name),
+				zap.String("cron", spec),
+				zap.String("source", source))
+		}
+		s.containerJobs[name] = job
+	}
+}
+
+// enqueue 将维护窗口外到期的更新加入队列
+func (s *Scheduler) enqueue(statuses []scanner.ContainerStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, st := range statuses {
+		if _, exists := s.queue[st.Name]; exists {
+			continue
+		}
+		s.queue[st.Name] = QueuedUpdate{Container: st.Name, Image: st.UpdateImage(), QueuedAt: now}
+		s.logger.Info("不在维护窗口内，更新已排队", zap.String("container", st.Name))
+	}
+}
+
+// drainQueue 维护窗口打开后重新扫描，更新队列中仍有可用更新的容器
+func (s *Scheduler) drainQueue(ctx context.Context) {
+	s.mu.Lock()
+	names := make(map[string]bool, len(s.queue))
+	for name := range s.queue {
+		names[name] = true
+	}
+	s.queue = make(map[string]QueuedUpdate)
+	s.mu.Unlock()
+
+	s.logger.Info("维护窗口已打开，开始执行排队的更新", zap.Int("count", len(names)))
+	// 排队时已经通知过可用更新，这里不再重复通知
+	s.scanAndUpdate(ctx, history.TriggerCron, func(scanner.ContainerStatus) bool { return false }, func(st scanner.ContainerStatus) bool {
+		return names[st.Name]
+	})
+}
+
+// QueuedUpdates 返回等待维护窗口的更新，按排队时间排序
+func (s *Scheduler) QueuedUpdates() []QueuedUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]QueuedUpdate, 0, len(s.queue))
+	for _, q := range s.queue {
+		result = append(result, q)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].QueuedAt.Before(result[j].QueuedAt) })
+	return result
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// InMaintenanceWindow 判断 t 是否处于任一维护窗口内，未配置维护窗口时始终返回 true
+func InMaintenanceWindow(windows []config.MaintenanceWindow, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, w := range windows {
+		if inWindow(w, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func inWindow(w config.MaintenanceWindow, t time.Time) bool {
+	start, err1 := time.Parse("15:04", w.Start)
+	end, err2 := time.Parse("15:04", w.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	startMin := start.Hour()*60 + start.Minute()
+	endMin := end.Hour()*60 + end.Minute()
+	cur := t.Hour()*60 + t.Minute()
+
+	dayOK := func(d time.Weekday) bool {
+		if len(w.Days) == 0 {
+			return true
+		}
+		for _, name := range w.Days {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if len(name) > 3 {
+				name = name[:3]
+			}
+			if wd, ok := weekdays[name]; ok && wd == d {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case startMin == endMin:
+		// 开始与结束相同表示全天
+		return dayOK(t.Weekday())
+	case startMin < endMin:
+		return dayOK(t.Weekday()) && cur >= startMin && cur < endMin
+	default:
+		// 跨越午夜：当天 start 之后，或前一天开始的窗口在今天 end 之前
+		return (dayOK(t.Weekday()) && cur >= startMin) || (dayOK((t.Weekday()+6)%7) && cur < endMin)
+	}
+}
@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+)
+
+func TestInMaintenanceWindow(t *testing.T) {
+	// 2024-06-02 是周日
+	at := func(day int, hm string) time.Time {
+		tm, _ := time.Parse("15:04", hm)
+		return time.Date(2024, 6, day, tm.Hour(), tm.Minute(), 0, 0, time.Local)
+	}
+	weekendNight := []config.MaintenanceWindow{{Days: []string{"sat", "sunday"}, Start: "23:00", End: "05:00"}}
+	daily := []config.MaintenanceWindow{{Start: "02:00", End: "04:00"}}
+
+	cases := []struct {
+		name    string
+		windows []config.MaintenanceWindow
+		t       time.Time
+		want    bool
+	}{
+		{"no windows", nil, at(3, "12:00"), true},
+		{"daily inside", daily, at(4, "03:59"), true},
+		{"daily end exclusive", daily, at(4, "04:00"), false},
+		{"overnight start day", weekendNight, at(1, "23:30"), true},      // 周六晚
+		{"overnight next morning", weekendNight, at(2, "04:00"), true},   // 周日凌晨（周六开始）
+		{"overnight monday morning", weekendNight, at(3, "04:00"), true}, // 周一凌晨（周日开始）
+		{"overnight tuesday morning", weekendNight, at(4, "04:00"), false},
+		{"overnight daytime", weekendNight, at(2, "12:00"), false},
+	}
+	for _, tc := range cases {
+		if got := InMaintenanceWindow(tc.windows, tc.t); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestScheduleForPrefersLabelOverRule(t *testing.T) {
+	rules := []config.ScheduleRule{{Label: "tier=db", Cron: "0 4 * * *"}, {Label: "nightly", Cron: "0 1 * * *"}}
+
+	if spec, src := scheduleFor(map[string]string{ScheduleLabel: "0 3 * * SUN", "tier": "db"}, rules); spec != "0 3 * * SUN" || src != "label" {
+		t.Errorf("expected label schedule, got %q from %q", spec, src)
+	}
+	if spec, src := scheduleFor(map[string]string{"tier": "db"}, rules); spec != "0 4 * * *" || src != "rule:tier=db" {
+		t.Errorf("expected rule schedule, got %q from %q", spec, src)
+	}
+	if spec, _ := scheduleFor(map[string]string{"tier": "web"}, rules); spec != "" {
+		t.Errorf("expected no schedule, got %q", spec)
+	}
+	if hasOwnSchedule(map[string]string{ScheduleLabel: "not a cron"}, nil) {
+		t.Error("invalid cron should not count as an own schedule")
+	}
+	if !hasOwnSchedule(map[string]string{ScheduleLabel: "0 0 3 * * *"}, nil) {
+		t.Error("6-field cron should be accepted")
+	}
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/scanner"
+	"github.com/jianxcao/watch-docker/backend/internal/updater"
+)
+
+// PlanScanAndUpdate RunScanAndUpdate 的计划模式：扫描并给出本次运行会执行的操作，不更新、不排队、不发送可用更新通知
+func (s *Scheduler) PlanScanAndUpdate(ctx context.Context) (*updater.Plan, error) {
+	cfg := config.Get()
+	statuses, err := s.scanner.ScanOnce(ctx, cfg.Docker.IncludeStopped, cfg.Scan.Concurrency, true, true)
+	if err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+
+	inWindow := InMaintenanceWindow(cfg.Schedule.MaintenanceWindows, time.Now())
+	rules := cfg.Schedule.Rules
+	plan := s.updater.BuildPlan(ctx, statuses, func(st scanner.ContainerStatus) (string, string) {
+		return runDecision(st, cfg.Scan.IsUpdate, !hasOwnSchedule(st.Labels, rules), inWindow)
+	})
+	plan.AutoUpdate = cfg.Scan.IsUpdate
+	plan.InMaintenanceWindow = inWindow
+	return plan, nil
+}
+
+// runDecision 给出 RunScanAndUpdate 对容器的处理决策，与 scanAndUpdate 的判断保持一致
+func runDecision(st scanner.ContainerStatus, autoUpdate, target, inWindow bool) (string, string) {
+	decision, reason := updater.StatusDecision(st)
+	if decision != updater.DecisionUpdate {
+		return decision, reason
+	}
+	switch {
+	case st.SkippedUpdate:
+		return updater.DecisionNotifyOnly, "watchdocker.skipUpdate 标签，仅检测不更新"
+	case !target:
+		return updater.DecisionNotifyOnly, "由容器独立更新计划更新"
+	case !autoUpdate:
+		return updater.DecisionNotifyOnly, "自动更新开关关闭"
+	case !inWindow:
+		return updater.DecisionQueued, "不在维护窗口内，将排队等待"
+	}
+	return updater.DecisionUpdate, ""
+}
@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/jianxcao/watch-docker/backend/internal/scanner"
+	"github.com/jianxcao/watch-docker/backend/internal/updater"
+)
+
+func TestRunDecision(t *testing.T) {
+	available := scanner.ContainerStatus{Name: "web", Status: "UpdateAvailable"}
+	cases := []struct {
+		name       string
+		st         scanner.ContainerStatus
+		autoUpdate bool
+		target     bool
+		inWindow   bool
+		want       string
+	}{
+		{"update", available, true, true, true, updater.DecisionUpdate},
+		{"queued outside window", available, true, true, false, updater.DecisionQueued},
+		{"auto update off", available, false, true, true, updater.DecisionNotifyOnly},
+		{"own schedule", available, true, false, true, updater.DecisionNotifyOnly},
+		{"skip update label", scanner.ContainerStatus{Status: "UpdateAvailable", SkippedUpdate: true}, true, true, true, updater.DecisionNotifyOnly},
+		{"policy skip", scanner.ContainerStatus{Status: "Skipped", Skipped: true, SkipReason: "label skip"}, true, true, true, updater.DecisionSkip},
+		{"up to date", scanner.ContainerStatus{Status: "UpToDate"}, true, true, true, updater.DecisionUpToDate},
+		{"error", scanner.ContainerStatus{Status: "Error", SkipReason: "镜像或标签不存在"}, true, true, true, updater.DecisionError},
+	}
+	for _, tc := range cases {
+		if got, _ := runDecision(tc.st, tc.autoUpdate, tc.target, tc.inWindow); got != tc.want {
+			t.Errorf("%s: decision = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
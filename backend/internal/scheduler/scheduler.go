@@ -2,10 +2,14 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/history"
 	"github.com/jianxcao/watch-docker/backend/internal/notificationmanager"
 	"github.com/jianxcao/watch-docker/backend/internal/scanner"
 	"github.com/jianxcao/watch-docker/backend/internal/updater"
@@ -15,15 +19,23 @@ import (
 )
 
 // Scheduler 负责周期扫描与按 cron 自动更新
+// 除全局的 scan-and-update 任务外，还为设置了独立更新计划的容器各维护一个 cron 任务，
+// 并在维护窗口外将到期的更新排队，等窗口打开后执行。
 type Scheduler struct {
 	logger              *zap.Logger
 	scanner             *scanner.Scanner
 	updater             *updater.Updater
 	notificationManager *notificationmanager.Manager
 
-	cancel  context.CancelFunc
-	cron    *cron.Cron
-	entryID cron.EntryID // 任务ID，用于管理和移除任务
+	mu            sync.Mutex
+	cancel        context.CancelFunc
+	cron          *cron.Cron
+	entryID       cron.EntryID            // 全局任务ID，用于管理和移除任务
+	tickID        cron.EntryID            // 每分钟同步容器计划并处理排队更新的任务
+	containerJobs map[string]containerJob // 容器名 -> 独立更新计划
+	queue         map[string]QueuedUpdate // 容器名 -> 等待维护窗口的更新
+
+	runMu sync.Mutex // 扫描更新流程串行执行
 }
 
 func New(logger *zap.Logger, sc *scanner.Scanner, up *updater.Updater, nm *notificationmanager.Manager) *Scheduler {
@@ -32,20 +44,19 @@ func New(logger *zap.Logger, sc *scanner.Scanner, up *updater.Updater, nm *notif
 		scanner:             sc,
 		updater:             up,
 		notificationManager: nm,
+		containerJobs:       make(map[string]containerJob),
+		queue:               make(map[string]QueuedUpdate),
 	}
 }
 
-// Start 启动调度器：优先使用 cron；未配置 cron 时退回到 interval 定时器。
+// Start 启动调度器：按 scan.cron 添加全局任务，并为设置了独立更新计划的容器添加任务。
 func (s *Scheduler) Start() {
 	cfg := config.Get()
-	if cfg.Scan.Cron == "" {
-		s.logger.Info("未配置 cron 表达式，调度器将不启动")
-		return
-	}
 
 	// 移除已存在的任务
 	s.RemoveTask()
 
+	s.mu.Lock()
 	// 创建或复用 cron 实例
 	if s.cron == nil {
 		s.cron = cron.New(cron.WithSeconds())
@@ -60,53 +71,83 @@ func (s *Scheduler) Start() {
 	ctx, cancel := context.WithCancel(context.Background())
 	s.cancel = cancel
 
-	// 添加新任务
+	// 添加全局任务
 	taskName := "scan-and-update"
-	s.logger.Info("开始添加 cron 任务",
-		zap.String("taskName", taskName),
-		zap.String("cron", cfg.Scan.Cron))
-
-	entryID, err := s.cron.AddFunc(cfg.Scan.Cron, func() {
-		s.RunScanAndUpdate(ctx)
-	})
-	if err != nil {
-		s.logger.Error("添加 cron 任务失败",
+	if cfg.Scan.Cron == "" {
+		s.logger.Info("未配置 cron 表达式，全局扫描任务将不启动")
+	} else {
+		s.logger.Info("开始添加 cron 任务",
 			zap.String("taskName", taskName),
-			zap.Error(err))
-		return
+			zap.String("cron", cfg.Scan.Cron))
+
+		entryID, err := s.cron.AddFunc(cfg.Scan.Cron, func() {
+			s.RunScanAndUpdate(ctx)
+		})
+		if err != nil {
+			s.logger.Error("添加 cron 任务失败",
+				zap.String("taskName", taskName),
+				zap.Error(err))
+		} else {
+			s.entryID = entryID
+			s.logger.Info("cron 任务添加成功",
+				zap.String("taskName", taskName),
+				zap.Int("entryID", int(entryID)))
+		}
 	}
 
-	s.entryID = entryID
-	s.logger.Info("cron 任务添加成功",
-		zap.String("taskName", taskName),
-		zap.Int("entryID", int(entryID)))
+	// 每分钟同步容器的独立更新计划，并在维护窗口打开时执行排队的更新
+	if tickID, err := s.cron.AddFunc("0 * * * * *", func() { s.tick(ctx) }); err == nil {
+		s.tickID = tickID
+	}
+	s.mu.Unlock()
+
+	s.syncContainerJobs(ctx)
 }
 
 // Stop 停止调度器
 func (s *Scheduler) Stop() {
 	s.RemoveTask()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.cancel != nil {
 		s.cancel()
 		s.cancel = nil
 	}
 }
 
-// RemoveTask 移除当前的定时任务
+// RemoveTask 移除当前的全部定时任务
 func (s *Scheduler) RemoveTask() {
-	if s.cron != nil && s.entryID != 0 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cron == nil {
+		return
+	}
+	if s.entryID != 0 {
 		s.cron.Remove(s.entryID)
 		s.logger.Info("已移除定时任务", zap.Int("entryID", int(s.entryID)))
 		s.entryID = 0
 	}
+	if s.tickID != 0 {
+		s.cron.Remove(s.tickID)
+		s.tickID = 0
+	}
+	for name, job := range s.containerJobs {
+		if job.entryID != 0 {
+			s.cron.Remove(job.entryID)
+		}
+		delete(s.containerJobs, name)
+	}
 }
 
 // StopCron 停止并清理 cron 调度器
 func (s *Scheduler) StopCron() {
+	s.RemoveTask()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.cron != nil {
 		ctx := s.cron.Stop()
 		<-ctx.Done()
 		s.cron = nil
-		s.entryID = 0
 		s.logger.Info("cron 调度器已停止")
 	}
 }
@@ -119,20 +160,89 @@ func (s *Scheduler) Restart() {
 
 // IsRunning 检查调度器是否正在运行
 func (s *Scheduler) IsRunning() bool {
-	return s.cron != nil && s.entryID != 0
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cron != nil && (s.entryID != 0 || len(s.containerJobs) > 0)
 }
 
-// GetTaskInfo 获取当前任务信息
-func (s *Scheduler) GetTaskInfo() (bool, int, string) {
-	if s.cron == nil || s.entryID == 0 {
-		return false, 0, ""
+// GetTaskInfo 获取全部定时任务及其下次执行时间
+func (s *Scheduler) GetTaskInfo() []JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]JobInfo, 0, len(s.containerJobs)+2)
+	if s.cron == nil {
+		return jobs
+	}
+	if s.entryID != 0 {
+		jobs = append(jobs, s.jobInfo(s.entryID, JobInfo{Name: "scan-and-update", Kind: JobKindGlobal, Cron: config.Get().Scan.Cron}))
 	}
+	names := make([]string, 0, len(s.containerJobs))
+	for name := range s.containerJobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		job := s.containerJobs[name]
+		info := JobInfo{
+			Name:      "container:" + name,
+			Kind:      JobKindContainer,
+			Cron:      job.spec,
+			Source:    job.source,
+			Container: name,
+			Error:     job.err,
+		}
+		if job.entryID == 0 {
+			jobs = append(jobs, info)
+			continue
+		}
+		jobs = append(jobs, s.jobInfo(job.entryID, info))
+	}
+	if s.tickID != 0 {
+		jobs = append(jobs, s.jobInfo(s.tickID, JobInfo{Name: "maintenance-tick", Kind: JobKindSystem, Cron: "0 * * * * *"}))
+	}
+	return jobs
+}
 
-	cfg := config.Get()
-	return true, int(s.entryID), cfg.Scan.Cron
+// jobInfo 补充任务的 ID 与执行时间（需要在持有 s.mu 的情况下调用）
+func (s *Scheduler) jobInfo(id cron.EntryID, info JobInfo) JobInfo {
+	entry := s.cron.Entry(id)
+	info.ID = int(id)
+	info.Next = entry.Next
+	info.Prev = entry.Prev
+	return info
 }
 
+// RunScanAndUpdate 全局扫描更新任务：扫描全部容器并通知可用更新，
+// 自动更新没有独立更新计划的容器
 func (s *Scheduler) RunScanAndUpdate(ctx context.Context) {
+	rules := config.Get().Schedule.Rules
+	s.scanAndUpdate(ctx, history.TriggerCron, allContainers, func(st scanner.ContainerStatus) bool {
+		return !hasOwnSchedule(st.Labels, rules)
+	})
+}
+
+// runContainerJob 容器独立更新计划到期：只更新该容器
+func (s *Scheduler) runContainerJob(ctx context.Context, name string) {
+	only := func(st scanner.ContainerStatus) bool { return st.Name == name }
+	s.scanAndUpdate(ctx, history.TriggerCron, only, only)
+}
+
+// RunHookUpdate CI webhook 触发：扫描并更新 names 中的容器。
+// webhook 是显式的更新请求，不受 scan.isUpdate 开关限制，但仍遵守维护窗口。
+func (s *Scheduler) RunHookUpdate(ctx context.Context, names map[string]bool) {
+	only := func(st scanner.ContainerStatus) bool { return names[st.Name] }
+	s.scanAndUpdate(ctx, history.TriggerWebhook, only, only)
+}
+
+func allContainers(scanner.ContainerStatus) bool { return true }
+
+// scanAndUpdate 扫描容器，通知 notifyFilter 选中容器的可用更新，并自动更新 target 选中的容器。
+// 不在维护窗口内时，需要更新的容器会排队等待窗口打开。
+func (s *Scheduler) scanAndUpdate(ctx context.Context, trigger history.Trigger, notifyFilter, target func(scanner.ContainerStatus) bool) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
 	s.logger.Info("开始执行扫描更新任务")
 	cfg := config.Get()
 	includeStopped := cfg.Docker.IncludeStopped
@@ -149,7 +259,7 @@ func (s *Scheduler) RunScanAndUpdate(ctx context.Context) {
 	if s.notificationManager != nil {
 		var updateAvailableContainers []scanner.ContainerStatus
 		for _, st := range statuses {
-			if st.Status == "UpdateAvailable" && !st.Skipped {
+			if st.Status == "UpdateAvailable" && !st.Skipped && notifyFilter(st) {
 				updateAvailableContainers = append(updateAvailableContainers, st)
 			}
 		}
@@ -162,13 +272,14 @@ func (s *Scheduler) RunScanAndUpdate(ctx context.Context) {
 
 	var updateStatuses []scanner.ContainerStatus = make([]scanner.ContainerStatus, 0)
 	for _, st := range statuses {
-		if st.Skipped || st.SkippedUpdate || st.Status != "UpdateAvailable" {
+		if st.Skipped || st.SkippedUpdate || st.Status != "UpdateAvailable" || !target(st) {
 			continue
 		}
 		updateStatuses = append(updateStatuses, st)
 	}
+	autoUpdate := cfg.Scan.IsUpdate || trigger == history.TriggerWebhook
 	if len(updateStatuses) == 0 {
-		if cfg.Scan.IsUpdate {
+		if autoUpdate {
 			s.logger.Info("没有需要更新的容器")
 		} else {
 			s.logger.Warn("没有需要更新的容器, 更新开关关闭")
@@ -176,19 +287,44 @@ func (s *Scheduler) RunScanAndUpdate(ctx context.Context) {
 		return
 	}
 
-	if !cfg.Scan.IsUpdate {
+	if !autoUpdate {
+		return
+	}
+
+	if !InMaintenanceWindow(cfg.Schedule.MaintenanceWindows, time.Now()) {
+		s.enqueue(updateStatuses)
 		return
 	}
+	s.updateContainers(ctx, trigger, updateStatuses)
+}
 
+// updateContainers 依次更新容器并发送结果通知
+func (s *Scheduler) updateContainers(ctx context.Context, trigger history.Trigger, updateStatuses []scanner.ContainerStatus) {
 	s.logger.Info("开始执行批量更新任务")
+	// 按依赖关系排序，被依赖的容器先更新
+	byID := make(map[string]scanner.ContainerStatus, len(updateStatuses))
+	targets := make([]updater.Target, 0, len(updateStatuses))
 	for _, st := range updateStatuses {
-		uctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+		byID[st.ID] = st
+		targets = append(targets, updater.Target{ID: st.ID, Name: st.Name, Image: st.UpdateImage()})
+	}
+	for _, t := range s.updater.OrderTargets(ctx, targets) {
+		st := byID[t.ID]
+		uctx, cancel := context.WithTimeout(updater.WithTrigger(ctx, trigger), 10*time.Minute)
 		s.logger.Info(fmt.Sprintf("开始执行更新任务: %s", st.Name))
-		if err := s.updater.UpdateContainer(uctx, st.ID, st.Image); err != nil {
+		if err := s.updater.UpdateContainer(uctx, st.ID, st.UpdateImage()); err != nil {
 			s.logger.Error(fmt.Sprintf("更新任务失败: %s", st.Name), zap.Error(err))
-			// 通知更新失败
-			if s.notificationManager != nil {
-				if notifyErr := s.notificationManager.NotifyUpdateFailed(ctx, st.Name, st.Image, err.Error()); notifyErr != nil {
+			var verr *updater.VerifyError
+			if errors.As(err, &verr) && verr.RolledBack() {
+				// 新容器校验失败，已回滚到旧容器
+				if s.notificationManager != nil {
+					if notifyErr := s.notificationManager.NotifyUpdateRolledBack(ctx, st.Name, st.UpdateImage(), verr.Reason); notifyErr != nil {
+						s.logger.Error("发送更新回滚通知失败", zap.Error(notifyErr))
+					}
+				}
+			} else if s.notificationManager != nil {
+				// 通知更新失败
+				if notifyErr := s.notificationManager.NotifyUpdateFailed(ctx, st.Name, st.UpdateImage(), err.Error()); notifyErr != nil {
 					s.logger.Error("发送更新失败通知失败", zap.Error(notifyErr))
 				}
 			}
@@ -196,7 +332,7 @@ func (s *Scheduler) RunScanAndUpdate(ctx context.Context) {
 			s.logger.Info(fmt.Sprintf("更新任务完成: %s", st.Name))
 			// 通知更新成功
 			if s.notificationManager != nil {
-				if notifyErr := s.notificationManager.NotifyUpdateSuccess(ctx, st.Name, st.Image); notifyErr != nil {
+				if notifyErr := s.notificationManager.NotifyUpdateSuccess(ctx, st.Name, st.UpdateImage()); notifyErr != nil {
 					s.logger.Error("发送更新成功通知失败", zap.Error(notifyErr))
 				}
 			}
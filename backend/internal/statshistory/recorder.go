@@ -0,0 +1,138 @@
+package statshistory
+
+import (
+	"context"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// Source 资源统计来源，由 dockercli.Client 实现
+type Source interface {
+	AddStatsConnection(ctx context.Context)
+	RemoveStatsConnection()
+	ListContainers(ctx context.Context, includeStopped bool) ([]dockercli.ContainerInfo, error)
+	GetAllContainersStats() []dockercli.ContainerStats
+}
+
+// counters 上一次采样的累计值
+type counters struct {
+	time                time.Time
+	rx, tx, read, write uint64
+}
+
+// Run 每 10 秒从 StatsManager 的缓存中采样一次并写入历史，直到 ctx 结束。
+// statsHistory.enabled 关闭时停止采集，开启时作为一个统计连接让 StatsManager 持续采集。
+func (s *Store) Run(ctx context.Context, src Source) {
+	ticker := time.NewTicker(tiers[0].step)
+	defer ticker.Stop()
+	connected := false
+	defer func() {
+		if connected {
+			src.RemoveStatsConnection()
+		}
+	}()
+	lastPrune := time.Time{}
+
+	for {
+		enabled := config.Get().StatsHistory.Enabled
+		switch {
+		case enabled && !connected:
+			logger.Logger.Info("开始记录容器资源历史")
+			src.AddStatsConnection(ctx)
+			connected = true
+		case !enabled && connected:
+			logger.Logger.Info("停止记录容器资源历史")
+			src.RemoveStatsConnection()
+			connected = false
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if !connected {
+				continue
+			}
+			s.sample(ctx, src, now)
+			s.Flush(now)
+			if now.Sub(lastPrune) >= time.Hour {
+				s.Prune(now)
+				lastPrune = now
+			}
+		}
+	}
+}
+
+// sample 记录所有运行中容器的一个采样点
+func (s *Store) sample(ctx context.Context, src Source, now time.Time) {
+	containers, err := src.ListContainers(ctx, false)
+	if err != nil {
+		logger.Logger.Warn("读取容器列表失败，跳过资源历史采样", zap.Error(err))
+		return
+	}
+	names := make(map[string]string, len(containers))
+	for _, ct := range containers {
+		names[ct.ID] = ct.Name
+	}
+
+	seen := make(map[string]bool, len(names))
+	for _, st := range src.GetAllContainersStats() {
+		name, ok := names[st.ID]
+		if !ok {
+			continue
+		}
+		seen[st.ID] = true
+		s.Record(name, s.point(st, now))
+	}
+
+	s.mu.Lock()
+	for id := range s.prev {
+		if !seen[id] {
+			delete(s.prev, id)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// point 由统计数据生成采样点，网络与块设备速率按两次采样之间的累计值差计算
+func (s *Store) point(st dockercli.ContainerStats, now time.Time) Point {
+	p := Point{
+		Time:          now,
+		CPUPercent:    st.CPUPercent,
+		MemoryUsage:   float64(st.MemoryUsage),
+		MemoryLimit:   float64(st.MemoryLimit),
+		NetworkRxRate: float64(st.NetworkRxRate),
+		NetworkTxRate: float64(st.NetworkTxRate),
+	}
+	cur := counters{time: now, rx: st.NetworkRx, tx: st.NetworkTx, read: st.BlockRead, write: st.BlockWrite}
+
+	s.mu.Lock()
+	prev, ok := s.prev[st.ID]
+	s.prev[st.ID] = cur
+	s.mu.Unlock()
+	if !ok {
+		return p
+	}
+	secs := now.Sub(prev.time).Seconds()
+	if secs <= 0 {
+		return p
+	}
+	p.NetworkRxRate = rate(prev.rx, cur.rx, secs)
+	p.NetworkTxRate = rate(prev.tx, cur.tx, secs)
+	p.BlockReadRate = rate(prev.read, cur.read, secs)
+	p.BlockWriteRate = rate(prev.write, cur.write, secs)
+	return p
+}
+
+// rate 计算累计值的速率，累计值回退（容器重启）时返回 0
+func rate(prev, cur uint64, secs float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / secs
+}
@@ -0,0 +1,171 @@
+package statshistory
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// 环形文件格式（小端）：
+//
+//	header  32 字节：magic "WDSH" | version u32 | step 秒 u32 | capacity u32 | next u32 | count u32 | 保留 8 字节
+//	record  64 字节：unix 秒 i64 | cpu | memUsage | memLimit | netRx | netTx | blkRead | blkWrite（均为 f64）
+//
+// 文件大小固定为 header + capacity*record，写满后覆盖最旧的记录。
+const (
+	ringMagic   = "WDSH"
+	ringVersion = 1
+	headerSize  = 32
+	recordSize  = 64
+)
+
+type ringHeader struct {
+	step     uint32
+	capacity uint32
+	next     uint32 // 下一条记录写入的位置
+	count    uint32 // 已有记录数，不超过 capacity
+}
+
+func (h ringHeader) marshal() []byte {
+	b := make([]byte, headerSize)
+	copy(b, ringMagic)
+	binary.LittleEndian.PutUint32(b[4:], ringVersion)
+	binary.LittleEndian.PutUint32(b[8:], h.step)
+	binary.LittleEndian.PutUint32(b[12:], h.capacity)
+	binary.LittleEndian.PutUint32(b[16:], h.next)
+	binary.LittleEndian.PutUint32(b[20:], h.count)
+	return b
+}
+
+func readHeader(f *os.File) (ringHeader, error) {
+	b := make([]byte, headerSize)
+	if _, err := f.ReadAt(b, 0); err != nil {
+		return ringHeader{}, err
+	}
+	if string(b[:4]) != ringMagic || binary.LittleEndian.Uint32(b[4:]) != ringVersion {
+		return ringHeader{}, errors.New("invalid stats history file")
+	}
+	h := ringHeader{
+		step:     binary.LittleEndian.Uint32(b[8:]),
+		capacity: binary.LittleEndian.Uint32(b[12:]),
+		next:     binary.LittleEndian.Uint32(b[16:]),
+		count:    binary.LittleEndian.Uint32(b[20:]),
+	}
+	if h.capacity == 0 || h.next >= h.capacity || h.count > h.capacity {
+		return ringHeader{}, errors.New("corrupted stats history header")
+	}
+	return h, nil
+}
+
+func marshalPoint(p Point) []byte {
+	b := make([]byte, recordSize)
+	binary.LittleEndian.PutUint64(b, uint64(p.Time.Unix()))
+	for i, v := range []float64{p.CPUPercent, p.MemoryUsage, p.MemoryLimit, p.NetworkRxRate, p.NetworkTxRate, p.BlockReadRate, p.BlockWriteRate} {
+		binary.LittleEndian.PutUint64(b[8+i*8:], math.Float64bits(v))
+	}
+	return b
+}
+
+func unmarshalPoint(b []byte) Point {
+	f := func(i int) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b[8+i*8:])) }
+	return Point{
+		Time:           time.Unix(int64(binary.LittleEndian.Uint64(b)), 0),
+		CPUPercent:     f(0),
+		MemoryUsage:    f(1),
+		MemoryLimit:    f(2),
+		NetworkRxRate:  f(3),
+		NetworkTxRate:  f(4),
+		BlockReadRate:  f(5),
+		BlockWriteRate: f(6),
+	}
+}
+
+// readRing 按时间顺序（旧 -> 新）读取环形文件中的全部记录，文件不存在时返回空
+func readRing(path string) ([]Point, ringHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ringHeader{}, nil
+		}
+		return nil, ringHeader{}, err
+	}
+	defer f.Close()
+
+	h, err := readHeader(f)
+	if err != nil {
+		return nil, ringHeader{}, fmt.Errorf("%s: %w", path, err)
+	}
+	buf := make([]byte, int(h.capacity)*recordSize)
+	n, err := f.ReadAt(buf, headerSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, h, err
+	}
+	buf = buf[:n]
+
+	points := make([]Point, 0, h.count)
+	start := (h.next + h.capacity - h.count) % h.capacity
+	for i := uint32(0); i < h.count; i++ {
+		off := int((start+i)%h.capacity) * recordSize
+		if off+recordSize > len(buf) {
+			continue
+		}
+		points = append(points, unmarshalPoint(buf[off:off+recordSize]))
+	}
+	return points, h, nil
+}
+
+// appendRing 向环形文件追加一条记录。文件不存在、损坏或容量与配置不一致时，
+// 按新容量重建文件并保留最新的记录。
+func appendRing(path string, step time.Duration, capacity int, p Point) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err == nil {
+		h, herr := readHeader(f)
+		if herr == nil && int(h.capacity) == capacity && h.step == uint32(step.Seconds()) {
+			defer f.Close()
+			if _, err := f.WriteAt(marshalPoint(p), headerSize+int64(h.next)*recordSize); err != nil {
+				return err
+			}
+			h.next = (h.next + 1) % h.capacity
+			if h.count < h.capacity {
+				h.count++
+			}
+			_, err = f.WriteAt(h.marshal(), 0)
+			return err
+		}
+		f.Close()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// 重建：保留旧文件中最新的记录
+	old, _, _ := readRing(path)
+	points := append(old, p)
+	if len(points) > capacity {
+		points = points[len(points)-capacity:]
+	}
+	return writeRing(path, step, capacity, points)
+}
+
+// writeRing 以给定容量写入一个新的环形文件，先写临时文件再替换
+func writeRing(path string, step time.Duration, capacity int, points []Point) error {
+	h := ringHeader{
+		step:     uint32(step.Seconds()),
+		capacity: uint32(capacity),
+		next:     uint32(len(points) % capacity),
+		count:    uint32(len(points)),
+	}
+	buf := make([]byte, headerSize+capacity*recordSize)
+	copy(buf, h.marshal())
+	for i, p := range points {
+		copy(buf[headerSize+i*recordSize:], marshalPoint(p))
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,297 @@
+// Package statshistory 记录容器资源使用的历史数据。
+// 数据按容器名称保存在 CONFIG_PATH 下的环形文件中（容器更新重建后历史仍然连续），
+// 分为 10 秒、1 分钟、1 小时三个精度，各自按配置的保留时长循环覆盖。
+package statshistory
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// Point 一个时间点（或时间段平均）的资源使用情况，速率单位为字节/秒
+type Point struct {
+	Time           time.Time `json:"time"`
+	CPUPercent     float64   `json:"cpuPercent"`
+	MemoryUsage    float64   `json:"memoryUsage"`
+	MemoryLimit    float64   `json:"memoryLimit"`
+	NetworkRxRate  float64   `json:"networkRxRate"`
+	NetworkTxRate  float64   `json:"networkTxRate"`
+	BlockReadRate  float64   `json:"blockReadRate"`
+	BlockWriteRate float64   `json:"blockWriteRate"`
+}
+
+// tier 一个精度层级
+type tier struct {
+	name string
+	step time.Duration
+}
+
+// tiers 按精度从高到低排列，采样间隔与最细精度一致
+var tiers = []tier{
+	{name: "10s", step: 10 * time.Second},
+	{name: "1m", step: time.Minute},
+	{name: "1h", step: time.Hour},
+}
+
+// maxPoints 未指定 step 时单次查询返回的最大点数
+const maxPoints = 1000
+
+// retention 返回层级的保留时长，0 表示不记录该层级
+func retention(i int) time.Duration {
+	cfg := config.Get().StatsHistory
+	hours := []int{cfg.Retention10s, cfg.Retention1m, cfg.Retention1h}[i]
+	if hours < 0 {
+		hours = 0
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// capacity 返回层级环形文件的记录数
+func capacity(i int) int {
+	return int(retention(i) / tiers[i].step)
+}
+
+// bucket 高层级正在累计的时间段
+type bucket struct {
+	start time.Time
+	sum   Point
+	n     int
+}
+
+func (b *bucket) add(p Point) {
+	b.sum.CPUPercent += p.CPUPercent
+	b.sum.MemoryUsage += p.MemoryUsage
+	b.sum.MemoryLimit += p.MemoryLimit
+	b.sum.NetworkRxRate += p.NetworkRxRate
+	b.sum.NetworkTxRate += p.NetworkTxRate
+	b.sum.BlockReadRate += p.BlockReadRate
+	b.sum.BlockWriteRate += p.BlockWriteRate
+	b.n++
+}
+
+func (b *bucket) avg() Point {
+	n := float64(b.n)
+	return Point{
+		Time:           b.start,
+		CPUPercent:     b.sum.CPUPercent / n,
+		MemoryUsage:    b.sum.MemoryUsage / n,
+		MemoryLimit:    b.sum.MemoryLimit / n,
+		NetworkRxRate:  b.sum.NetworkRxRate / n,
+		NetworkTxRate:  b.sum.NetworkTxRate / n,
+		BlockReadRate:  b.sum.BlockReadRate / n,
+		BlockWriteRate: b.sum.BlockWriteRate / n,
+	}
+}
+
+// Store 资源历史存储
+type Store struct {
+	dir string
+	mu  sync.Mutex
+	// buckets 容器名称 -> 各高层级（1m、1h）正在累计的时间段，下标与 tiers 对应
+	buckets map[string][]*bucket
+	// prev 容器 ID -> 上一次采样的累计值，用于计算速率
+	prev map[string]counters
+}
+
+// New 创建存储，dir 不存在时自动创建
+func New(dir string) *Store {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logger.Logger.Warn("创建资源历史目录失败", zap.String("dir", dir), zap.Error(err))
+	}
+	return &Store{
+		dir:     dir,
+		buckets: make(map[string][]*bucket),
+		prev:    make(map[string]counters),
+	}
+}
+
+func (s *Store) path(name string, i int) string {
+	return filepath.Join(s.dir, url.PathEscape(name)+"."+tiers[i].name+".ring")
+}
+
+// Record 记录容器的一个 10 秒采样点，并累计到 1 分钟、1 小时层级
+func (s *Store) Record(name string, p Point) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p.Time = p.Time.Truncate(tiers[0].step)
+	s.appendLocked(name, 0, p)
+
+	bs, ok := s.buckets[name]
+	if !ok {
+		bs = make([]*bucket, len(tiers))
+		s.buckets[name] = bs
+	}
+	for i := 1; i < len(tiers); i++ {
+		start := p.Time.Truncate(tiers[i].step)
+		if b := bs[i]; b != nil && !b.start.Equal(start) {
+			s.appendLocked(name, i, b.avg())
+			bs[i] = nil
+		}
+		if bs[i] == nil {
+			bs[i] = &bucket{start: start}
+		}
+		bs[i].add(p)
+	}
+}
+
+// Flush 写入已经结束的时间段（如容器已停止不再有新的采样）
+func (s *Store) Flush(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, bs := range s.buckets {
+		empty := true
+		for i := 1; i < len(tiers); i++ {
+			b := bs[i]
+			if b == nil {
+				continue
+			}
+			if !now.Before(b.start.Add(tiers[i].step)) {
+				s.appendLocked(name, i, b.avg())
+				bs[i] = nil
+				continue
+			}
+			empty = false
+		}
+		if empty {
+			delete(s.buckets, name)
+		}
+	}
+}
+
+func (s *Store) appendLocked(name string, i int, p Point) {
+	n := capacity(i)
+	if n <= 0 {
+		return
+	}
+	if err := appendRing(s.path(name, i), tiers[i].step, n, p); err != nil {
+		logger.Logger.Warn("写入资源历史失败", zap.String("container", name), zap.String("tier", tiers[i].name), zap.Error(err))
+	}
+}
+
+// Has 判断是否有该容器的历史数据
+func (s *Store) Has(name string) bool {
+	for i := range tiers {
+		if _, err := os.Stat(s.path(name, i)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Series 一次查询的结果
+type Series struct {
+	Name   string  `json:"name"`
+	Step   int     `json:"step"` // 点之间的间隔（秒）
+	Tier   string  `json:"tier"` // 数据来源的精度层级
+	Points []Point `json:"points"`
+}
+
+// Query 查询 [from, to] 时间段的数据。
+// step 为 0 时选择能覆盖 from 的最细层级，点数过多时自动放大 step；
+// 否则使用不超过 step 的最粗层级，并按 step 对数据求平均。
+func (s *Store) Query(name string, from, to time.Time, step time.Duration) (Series, error) {
+	if !to.After(from) {
+		return Series{}, fmt.Errorf("to must be after from")
+	}
+	if step <= 0 {
+		step = tiers[len(tiers)-1].step
+		for i, t := range tiers {
+			if retention(i) > 0 && time.Since(from) <= retention(i) {
+				step = t.step
+				break
+			}
+		}
+		if n := to.Sub(from) / step; n > maxPoints {
+			step = (to.Sub(from)/maxPoints + tiers[0].step - 1).Truncate(tiers[0].step)
+		}
+	}
+	if step < tiers[0].step {
+		step = tiers[0].step
+	}
+	step = step.Truncate(time.Second)
+
+	src := 0
+	for i, t := range tiers {
+		if t.step <= step && retention(i) > 0 {
+			src = i
+		}
+	}
+
+	s.mu.Lock()
+	points, _, err := readRing(s.path(name, src))
+	s.mu.Unlock()
+	if err != nil {
+		return Series{}, err
+	}
+
+	in := make([]Point, 0, len(points))
+	for _, p := range points {
+		if !p.Time.Before(from) && !p.Time.After(to) {
+			in = append(in, p)
+		}
+	}
+	return Series{
+		Name:   name,
+		Step:   int(step.Seconds()),
+		Tier:   tiers[src].name,
+		Points: resample(in, step),
+	}, nil
+}
+
+// resample 按 step 对齐并求平均，points 需按时间升序
+func resample(points []Point, step time.Duration) []Point {
+	out := make([]Point, 0, len(points))
+	var cur *bucket
+	for _, p := range points {
+		start := p.Time.Truncate(step)
+		if cur != nil && !cur.start.Equal(start) {
+			out = append(out, cur.avg())
+			cur = nil
+		}
+		if cur == nil {
+			cur = &bucket{start: start}
+		}
+		cur.add(p)
+	}
+	if cur != nil {
+		out = append(out, cur.avg())
+	}
+	return out
+}
+
+// Prune 删除超过最长保留时长未更新的历史文件（容器已删除）
+func (s *Store) Prune(now time.Time) {
+	var keep time.Duration
+	for i := range tiers {
+		keep = max(keep, retention(i))
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".ring") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || now.Sub(info.ModTime()) <= keep {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, e.Name())); err == nil {
+			logger.Logger.Debug("删除过期的资源历史", zap.String("file", e.Name()))
+		}
+	}
+}
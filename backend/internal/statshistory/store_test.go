@@ -0,0 +1,98 @@
+package statshistory
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+func setRetention(t *testing.T, h10s, h1m, h1h int) {
+	t.Helper()
+	logger.Logger = zap.NewNop()
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	old := config.Get()
+	t.Cleanup(func() { config.SetGlobal(old) })
+	cfg := *old
+	cfg.StatsHistory = config.StatsHistoryConfig{Enabled: true, Retention10s: h10s, Retention1m: h1m, Retention1h: h1h}
+	config.SetGlobal(&cfg)
+}
+
+func TestRingOverwritesOldest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web.10s.ring")
+	base := time.Unix(1700000000, 0)
+	for i := 0; i < 5; i++ {
+		if err := appendRing(path, 10*time.Second, 3, Point{Time: base.Add(time.Duration(i) * 10 * time.Second), CPUPercent: float64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	points, _, err := readRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 3 || points[0].CPUPercent != 2 || points[2].CPUPercent != 4 {
+		t.Fatalf("unexpected points %+v", points)
+	}
+
+	// 容量变化时保留最新的记录
+	if err := appendRing(path, 10*time.Second, 2, Point{Time: base.Add(50 * time.Second), CPUPercent: 5}); err != nil {
+		t.Fatal(err)
+	}
+	points, h, _ := readRing(path)
+	if h.capacity != 2 || len(points) != 2 || points[0].CPUPercent != 4 || points[1].CPUPercent != 5 {
+		t.Fatalf("unexpected points after resize %+v (capacity %d)", points, h.capacity)
+	}
+}
+
+func TestRecordDownsamplesTiers(t *testing.T) {
+	setRetention(t, 1, 1, 1)
+	s := New(t.TempDir())
+	base := time.Unix(1700000000, 0).Truncate(time.Hour)
+
+	// 两分钟的 10 秒采样：第一分钟 CPU 为 10，第二分钟为 30
+	for i := 0; i < 12; i++ {
+		cpu := 10.0
+		if i >= 6 {
+			cpu = 30
+		}
+		s.Record("web", Point{Time: base.Add(time.Duration(i) * 10 * time.Second), CPUPercent: cpu, MemoryUsage: 100})
+	}
+	s.Flush(base.Add(2 * time.Hour))
+
+	minute, _, _ := readRing(s.path("web", 1))
+	if len(minute) != 2 || minute[0].CPUPercent != 10 || minute[1].CPUPercent != 30 || !minute[1].Time.Equal(base.Add(time.Minute)) {
+		t.Fatalf("unexpected 1m points %+v", minute)
+	}
+	hour, _, _ := readRing(s.path("web", 2))
+	if len(hour) != 1 || hour[0].CPUPercent != 20 || hour[0].MemoryUsage != 100 {
+		t.Fatalf("unexpected 1h points %+v", hour)
+	}
+}
+
+func TestQueryResamplesByStep(t *testing.T) {
+	setRetention(t, 24, 0, 0)
+	s := New(t.TempDir())
+	now := time.Now().Truncate(time.Minute)
+	for i := 0; i < 12; i++ {
+		s.Record("db", Point{Time: now.Add(-2*time.Minute + time.Duration(i)*10*time.Second), CPUPercent: float64(i)})
+	}
+
+	series, err := s.Query("db", now.Add(-2*time.Minute), now, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.Tier != "10s" || series.Step != 60 || len(series.Points) != 2 {
+		t.Fatalf("unexpected series %+v", series)
+	}
+	if series.Points[0].CPUPercent != 2.5 || series.Points[1].CPUPercent != 8.5 {
+		t.Fatalf("unexpected averages %+v", series.Points)
+	}
+	if _, err := s.Query("db", now, now.Add(-time.Minute), 0); err == nil {
+		t.Fatal("expected error for reversed range")
+	}
+}
@@ -0,0 +1,183 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/composecli"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// composeRef 返回容器所属的 compose 服务。未开启 compose.enabled、不是 compose 管理的容器，
+// 或项目目录在本机不可用（如 watch-docker 运行在容器中且未挂载项目目录）时返回 false，按普通容器重建。
+func (u *Updater) composeRef(labels map[string]string) (composecli.ServiceRef, bool) {
+	if u.compose == nil || !config.Get().Compose.Enabled {
+		return composecli.ServiceRef{}, false
+	}
+	ref, ok := composecli.ServiceFromLabels(labels)
+	if !ok {
+		return composecli.ServiceRef{}, false
+	}
+	if _, err := os.Stat(ref.WorkingDir); err != nil {
+		logger.Logger.Debug("compose 项目目录不可用，按普通容器重建",
+			zap.String("service", ref.Key()),
+			zap.String("workingDir", ref.WorkingDir))
+		return composecli.ServiceRef{}, false
+	}
+	return ref, true
+}
+
+// composeServiceFor 判断容器是否应通过 docker compose 更新。
+// 更新目标镜像与容器当前镜像不同时（如语义化版本换了 tag），compose 文件中的镜像并未改变，
+// 这种情况仍按原方式重建容器。
+func (u *Updater) composeServiceFor(ctx context.Context, uctx *updateContext) (composecli.ServiceRef, bool) {
+	if u.compose == nil {
+		return composecli.ServiceRef{}, false
+	}
+	info, err := u.docker.InspectContainer(ctx, uctx.containerID)
+	if err != nil || info.Config == nil {
+		return composecli.ServiceRef{}, false
+	}
+	ref, ok := u.composeRef(info.Config.Labels)
+	if !ok {
+		return composecli.ServiceRef{}, false
+	}
+	if info.Config.Image != uctx.imageRef {
+		logger.Logger.Warn("更新目标镜像与 compose 服务镜像不一致，按普通容器重建",
+			zap.String("service", ref.Key()),
+			zap.String("image", info.Config.Image),
+			zap.String("imageRef", uctx.imageRef))
+		return composecli.ServiceRef{}, false
+	}
+	return ref, true
+}
+
+// updateComposeService 在项目工作目录中执行 pull <service> 与 up -d --no-deps <service>，
+// 同一服务的所有容器由 compose 一并重建；新容器校验失败时把服务镜像标签指回旧镜像并再次 up 回滚
+func (u *Updater) updateComposeService(ctx context.Context, uctx *updateContext, ref composecli.ServiceRef, cb *UpdateProgressCallback) error {
+	info, err := u.docker.InspectContainer(ctx, uctx.containerID)
+	if err != nil {
+		return err
+	}
+	uctx.oldInfo = info
+	uctx.oldName = strings.TrimPrefix(info.Name, "/")
+	uctx.wasRunning = info.State != nil && info.State.Running
+	if img, ierr := u.docker.ImageInspect(ctx, info.Image); ierr == nil {
+		uctx.oldDigests = img.RepoDigests
+	}
+	logger.Logger.Info("通过 docker compose 更新服务",
+		zap.String("container", uctx.oldName),
+		zap.String("service", ref.Key()),
+		zap.String("workingDir", ref.WorkingDir))
+
+	// compose 重建服务时会停止并删除旧容器，这些事件不应触发生命周期通知
+	for _, id := range u.serviceContainerIDs(ctx, ref) {
+		u.docker.ExpectEvents(id)
+	}
+
+	cb.step("pulling", "正在通过 docker compose 拉取服务 "+ref.Service+" 的镜像")
+	err = u.compose.UpdateService(ctx, ref, func(step, line string) {
+		cb.step(step, line)
+	})
+	if err != nil {
+		logger.Logger.Error("docker compose 更新服务失败", zap.String("service", ref.Key()), zap.Error(err))
+		return err
+	}
+
+	// compose 重建后容器名称不变，按名称找到新容器
+	if uctx.oldName != "" {
+		if newInfo, ierr := u.docker.InspectContainer(ctx, uctx.oldName); ierr == nil {
+			uctx.newID = newInfo.ID
+		}
+	}
+	if uctx.newID == "" || uctx.newID == info.ID {
+		logger.Logger.Info("compose 服务无需重建", zap.String("service", ref.Key()))
+		return nil
+	}
+
+	// 校验新容器（健康检查 / 稳定运行），失败则回滚到旧镜像
+	if uctx.wasRunning {
+		if config.Get().Update.Verify {
+			cb.step("verifying", "正在校验新容器运行状态")
+		}
+		if verr := u.verifyNewContainer(ctx, uctx); verr != nil {
+			cb.step("rollback", "新容器校验失败，正在回滚到旧镜像: "+verr.Error())
+			return u.rollbackComposeService(ctx, uctx, ref, verr)
+		}
+	}
+	u.refreshDependants(ctx, uctx, true, cb)
+	logger.Logger.Info("compose 服务更新完成", zap.String("service", ref.Key()), zap.String("newID", uctx.newID))
+	return nil
+}
+
+// rollbackComposeService 把 compose 服务使用的镜像标签指回旧镜像，并在不拉取镜像的情况下重建服务。
+// compose 已删除旧容器，回滚后的容器是用旧镜像新建的。
+func (u *Updater) rollbackComposeService(ctx context.Context, uctx *updateContext, ref composecli.ServiceRef, reason error) error {
+	logger.Logger.Error("新容器校验失败，开始回滚 compose 服务",
+		zap.String("service", ref.Key()),
+		zap.String("newID", uctx.newID),
+		zap.String("oldImage", uctx.oldInfo.Image),
+		zap.Error(reason))
+
+	rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Minute)
+	defer cancel()
+
+	for _, id := range u.serviceContainerIDs(rctx, ref) {
+		u.docker.ExpectEvents(id)
+	}
+	verr := &VerifyError{Reason: reason.Error()}
+	if err := u.docker.TagImage(rctx, uctx.oldInfo.Image, uctx.oldInfo.Config.Image); err != nil {
+		verr.RollbackErr = fmt.Errorf("恢复镜像标签失败: %w", err)
+	} else if err := u.compose.RecreateService(rctx, ref); err != nil {
+		verr.RollbackErr = err
+	}
+	if verr.RollbackErr != nil {
+		logger.Logger.Error("回滚 compose 服务失败", zap.String("service", ref.Key()), zap.Error(verr.RollbackErr))
+	} else {
+		logger.Logger.Info("已回滚 compose 服务到旧镜像", zap.String("service", ref.Key()), zap.String("image", uctx.oldInfo.Image))
+	}
+	return verr
+}
+
+// serviceContainerIDs 返回 compose 服务下的全部容器 ID
+func (u *Updater) serviceContainerIDs(ctx context.Context, ref composecli.ServiceRef) []string {
+	all, err := u.docker.InspectContainers(ctx, true)
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for _, c := range all {
+		if c.Config == nil {
+			continue
+		}
+		if r, ok := composecli.ServiceFromLabels(c.Config.Labels); ok && r.Key() == ref.Key() {
+			ids = append(ids, c.ID)
+		}
+	}
+	return ids
+}
+
+// groupComposeTargets 合并同一 compose 服务的多个容器（scale 多副本），每个服务只更新一次
+func groupComposeTargets(targets []Target) []Target {
+	result := make([]Target, 0, len(targets))
+	seen := make(map[string]int)
+	for _, t := range targets {
+		if t.Compose == nil {
+			result = append(result, t)
+			continue
+		}
+		if i, ok := seen[t.Compose.Key()]; ok {
+			result[i].Replicas = append(result[i].Replicas, t.Name)
+			continue
+		}
+		seen[t.Compose.Key()] = len(result)
+		result = append(result, t)
+	}
+	return result
+}
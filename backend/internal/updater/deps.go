@@ -0,0 +1,332 @@
+package updater
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/composecli"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"github.com/docker/docker/api/types/container"
+	"go.uber.org/zap"
+)
+
+// DependsOnLabel 声明容器依赖的 label，值为逗号分隔的容器名称，如 watchdocker.depends-on="vpn,db"
+const DependsOnLabel = "watchdocker.depends-on"
+
+// composeDependsOnLabel compose 写入的服务依赖标签
+const composeDependsOnLabel = "com.docker.compose.depends_on"
+
+// Target 一个待更新的容器
+type Target struct {
+	ID    string
+	Name  string
+	Image string
+	// DependsOn 本批次中需要先于该容器更新的容器名称
+	DependsOn []string
+	// Compose 通过 docker compose 更新时所属的服务，为 nil 表示按普通容器重建
+	Compose *composecli.ServiceRef
+	// Replicas 同一 compose 服务的其他容器，随该服务一起更新
+	Replicas []string
+}
+
+// OrderTargets 按依赖关系对待更新容器排序：被依赖的容器（如 VPN 边车、数据库）先更新。
+// 依赖来自 network_mode/pid/ipc: container:X、--link、compose depends_on 以及 watchdocker.depends-on 标签；
+// 存在循环依赖时，环上的容器保持原有顺序。同一 compose 服务的多个容器合并为一项。
+func (u *Updater) OrderTargets(ctx context.Context, targets []Target) []Target {
+	infos := make(map[string]container.InspectResponse, len(targets))
+	for i, t := range targets {
+		info, err := u.docker.InspectContainer(ctx, t.ID)
+		if err != nil {
+			logger.Logger.Warn("读取容器依赖关系失败", zap.String("container", t.Name), zap.Error(err))
+			continue
+		}
+		infos[t.ID] = info
+		if ref, ok := u.composeRef(info.Config.Labels); ok && info.Config.Image == t.Image {
+			targets[i].Compose = &ref
+		}
+	}
+	return groupComposeTargets(orderTargets(targets, infos))
+}
+
+// orderTargets 拓扑排序，同一层级内保持 targets 的原有顺序
+func orderTargets(targets []Target, infos map[string]container.InspectResponse) []Target {
+	n := len(targets)
+	// 按 ID、名称、compose 服务索引本批次的容器
+	byKey := make(map[string]int, n*3)
+	for i, t := range targets {
+		byKey[t.Name] = i
+		info, ok := infos[t.ID]
+		if !ok {
+			continue
+		}
+		if project, service := info.Config.Labels[composecli.LabelProject], info.Config.Labels[composecli.LabelService]; project != "" && service != "" {
+			byKey[composeKey(project, service)] = i
+		}
+	}
+	lookup := func(ref string) (int, bool) {
+		if i, ok := byKey[ref]; ok {
+			return i, true
+		}
+		for i, t := range targets {
+			if matchesID(ref, t.ID) {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	parents := make([][]int, n)
+	for i, t := range targets {
+		info, ok := infos[t.ID]
+		if !ok {
+			continue
+		}
+		seen := make(map[int]bool)
+		for _, ref := range parentRefs(info) {
+			if p, ok := lookup(ref); ok && p != i && !seen[p] {
+				seen[p] = true
+				parents[i] = append(parents[i], p)
+			}
+		}
+	}
+
+	ordered := make([]Target, 0, n)
+	done := make([]bool, n)
+	for len(ordered) < n {
+		next := -1
+		for i := range targets {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for _, p := range parents[i] {
+				if !done[p] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			// 循环依赖：取第一个未处理的容器继续
+			for i := range targets {
+				if !done[i] {
+					next = i
+					break
+				}
+			}
+			logger.Logger.Warn("容器之间存在循环依赖，按原顺序更新", zap.String("container", targets[next].Name))
+		}
+		done[next] = true
+		t := targets[next]
+		t.DependsOn = nil
+		for _, p := range parents[next] {
+			t.DependsOn = append(t.DependsOn, targets[p].Name)
+		}
+		ordered = append(ordered, t)
+	}
+	return ordered
+}
+
+// parentRefs 返回容器依赖的容器引用（名称、ID 或 compose 服务）
+func parentRefs(info container.InspectResponse) []string {
+	refs := namespaceRefs(info)
+	if info.HostConfig != nil {
+		for _, link := range info.HostConfig.Links {
+			// inspect 中的格式为 /parent:/child/alias
+			parent, _, _ := strings.Cut(link, ":")
+			refs = append(refs, strings.TrimPrefix(parent, "/"))
+		}
+	}
+	if info.Config == nil {
+		return refs
+	}
+	labels := info.Config.Labels
+	for _, name := range strings.Split(labels[DependsOnLabel], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			refs = append(refs, name)
+		}
+	}
+	if project := labels[composecli.LabelProject]; project != "" {
+		// 格式为 service:condition:restart，多个服务以逗号分隔
+		for _, dep := range strings.Split(labels[composeDependsOnLabel], ",") {
+			service, _, _ := strings.Cut(strings.TrimSpace(dep), ":")
+			if service != "" {
+				refs = append(refs, composeKey(project, service))
+			}
+		}
+	}
+	return refs
+}
+
+// namespaceRefs 返回容器通过 container:X 共享命名空间的容器引用（network/pid/ipc）
+func namespaceRefs(info container.InspectResponse) []string {
+	if info.HostConfig == nil {
+		return nil
+	}
+	hc := info.HostConfig
+	var refs []string
+	if hc.NetworkMode.IsContainer() {
+		refs = append(refs, hc.NetworkMode.ConnectedContainer())
+	}
+	if hc.PidMode.IsContainer() {
+		refs = append(refs, hc.PidMode.Container())
+	}
+	if hc.IpcMode.IsContainer() {
+		refs = append(refs, hc.IpcMode.Container())
+	}
+	return refs
+}
+
+func composeKey(project, service string) string {
+	return "compose:" + project + "/" + service
+}
+
+// matchesID 判断 ref 是否为容器 id 的完整 ID 或至少 12 位的 ID 前缀
+func matchesID(ref, id string) bool {
+	return ref != "" && id != "" && (ref == id || (len(ref) >= 12 && strings.HasPrefix(id, ref)))
+}
+
+// replacedTTL 旧ID -> 新ID 映射的保留时间，足够覆盖一次批量更新中仍持有旧 ID 的目标
+const replacedTTL = time.Hour
+
+// replacement 容器重建后的新 ID 及记录时间
+type replacement struct {
+	id string
+	at time.Time
+}
+
+// markReplaced 记录 oldID 已被 newID 替换：指向 oldID 的记录直接改指 newID，
+// 使映射始终只有一跳；同时清理超过 replacedTTL 的记录，避免映射无限增长。
+func (u *Updater) markReplaced(oldID, newID string) {
+	now := time.Now()
+	u.replaced.Range(func(k, v any) bool {
+		r := v.(replacement)
+		switch {
+		case now.Sub(r.at) > replacedTTL:
+			u.replaced.Delete(k)
+		case r.id == oldID:
+			u.replaced.Store(k, replacement{id: newID, at: now})
+		}
+		return true
+	})
+	u.replaced.Store(oldID, replacement{id: newID, at: now})
+}
+
+// resolveID 返回容器被重建后的最新 ID，未被重建时原样返回
+func (u *Updater) resolveID(id string) string {
+	if v, ok := u.replaced.Load(id); ok {
+		return v.(replacement).id
+	}
+	return id
+}
+
+// rewriteNamespaceRefs 将 HostConfig 中 container:<旧ID> 形式的命名空间引用替换为重建后的新 ID
+func (u *Updater) rewriteNamespaceRefs(hc *container.HostConfig) *container.HostConfig {
+	if hc == nil {
+		return nil
+	}
+	out := *hc
+	if out.NetworkMode.IsContainer() {
+		out.NetworkMode = container.NetworkMode("container:" + u.resolveID(out.NetworkMode.ConnectedContainer()))
+	}
+	if out.PidMode.IsContainer() {
+		out.PidMode = container.PidMode("container:" + u.resolveID(out.PidMode.Container()))
+	}
+	if out.IpcMode.IsContainer() {
+		out.IpcMode = container.IpcMode("container:" + u.resolveID(out.IpcMode.Container()))
+	}
+	return &out
+}
+
+// refreshDependants 在容器重建（或失败回滚）后处理依赖它的容器：
+// 以旧容器 ID 共享命名空间的容器已无法启动，按新 ID 重建；
+// 以名称共享命名空间或通过 --link 连接的运行中容器重启，重新加入命名空间并刷新 hosts。
+func (u *Updater) refreshDependants(ctx context.Context, uctx *updateContext, replaced bool, cb *UpdateProgressCallback) {
+	oldID := uctx.oldInfo.ID
+	activeID := oldID
+	if replaced && uctx.newID != "" {
+		activeID = uctx.newID
+		u.markReplaced(oldID, uctx.newID)
+	}
+
+	all, err := u.docker.InspectContainers(ctx, true)
+	if err != nil {
+		logger.Logger.Warn("查找依赖容器失败", zap.String("container", uctx.oldName), zap.Error(err))
+		return
+	}
+	for _, d := range all {
+		if d.ID == activeID {
+			continue
+		}
+		name := strings.TrimPrefix(d.Name, "/")
+		switch dependantAction(d, oldID, uctx.oldName, activeID != oldID) {
+		case DependantRecreate:
+			cb.step("dependants", "正在重建依赖容器 "+name)
+			logger.Logger.Info("重建共享命名空间的依赖容器",
+				zap.String("container", name),
+				zap.String("parent", uctx.oldName))
+			if err := u.recreateDependant(ctx, d); err != nil {
+				logger.Logger.Error("重建依赖容器失败", zap.String("container", name), zap.Error(err))
+			}
+		case DependantRestart:
+			cb.step("dependants", "正在重启依赖容器 "+name)
+			logger.Logger.Info("重启依赖容器", zap.String("container", name), zap.String("parent", uctx.oldName))
+			if err := u.docker.RestartContainer(ctx, d.ID, 30); err != nil {
+				logger.Logger.Error("重启依赖容器失败", zap.String("container", name), zap.Error(err))
+			}
+		}
+	}
+}
+
+// 父容器重建后依赖容器的处理方式
+const (
+	DependantRecreate = "recreate"
+	DependantRestart  = "restart"
+)
+
+// dependantAction 判断父容器（parentID/parentName）重建后容器 d 需要如何处理，无需处理时返回空字符串。
+// replaced 表示父容器已换成新 ID。
+func dependantAction(d container.InspectResponse, parentID, parentName string, replaced bool) string {
+	if d.ContainerJSONBase == nil || d.ID == parentID {
+		return ""
+	}
+	byID, byName := false, false
+	for _, ref := range namespaceRefs(d) {
+		byID = byID || matchesID(ref, parentID)
+		byName = byName || (parentName != "" && ref == parentName)
+	}
+	linked := false
+	if d.HostConfig != nil {
+		for _, link := range d.HostConfig.Links {
+			parent, _, _ := strings.Cut(link, ":")
+			linked = linked || strings.TrimPrefix(parent, "/") == parentName
+		}
+	}
+
+	switch {
+	case byID && replaced:
+		return DependantRecreate
+	case (byID || byName || linked) && d.State != nil && d.State.Running:
+		return DependantRestart
+	}
+	return ""
+}
+
+// recreateDependant 使用容器当前的镜像重建依赖容器，命名空间引用在创建时替换为新 ID
+func (u *Updater) recreateDependant(ctx context.Context, d container.InspectResponse) error {
+	mutex := u.getContainerLock(d.ID)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	uctx := &updateContext{
+		containerID: d.ID,
+		imageRef:    d.Config.Image,
+	}
+	return u.recreate(ctx, uctx, nil)
+}
@@ -0,0 +1,134 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/composecli"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"github.com/docker/docker/api/types/container"
+	"go.uber.org/zap"
+)
+
+func inspectWith(id string, hc container.HostConfig, labels map[string]string) container.InspectResponse {
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: id, HostConfig: &hc},
+		Config:            &container.Config{Labels: labels},
+	}
+}
+
+func TestOrderTargetsUpdatesParentsFirst(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	vpnID := "aaaaaaaaaaaa1111111111111111111111111111111111111111111111111111"
+	targets := []Target{
+		{ID: "app", Name: "app"},
+		{ID: "torrent", Name: "torrent"},
+		{ID: "web", Name: "web"},
+		{ID: vpnID, Name: "vpn"},
+		{ID: "db", Name: "db"},
+	}
+	infos := map[string]container.InspectResponse{
+		"app":     inspectWith("app", container.HostConfig{}, map[string]string{DependsOnLabel: "web, db"}),
+		"torrent": inspectWith("torrent", container.HostConfig{NetworkMode: container.NetworkMode("container:" + vpnID)}, nil),
+		"web": inspectWith("web", container.HostConfig{}, map[string]string{
+			composecli.LabelProject: "stack", composecli.LabelService: "web", composeDependsOnLabel: "db:service_started:false",
+		}),
+		vpnID: inspectWith(vpnID, container.HostConfig{}, nil),
+		"db":  inspectWith("db", container.HostConfig{}, map[string]string{composecli.LabelProject: "stack", composecli.LabelService: "db"}),
+	}
+
+	got := orderTargets(targets, infos)
+	var names []string
+	for _, tg := range got {
+		names = append(names, tg.Name)
+	}
+	want := []string{"vpn", "torrent", "db", "web", "app"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("order = %v, want %v", names, want)
+	}
+	if !reflect.DeepEqual(got[4].DependsOn, []string{"web", "db"}) {
+		t.Fatalf("app dependsOn = %v", got[4].DependsOn)
+	}
+}
+
+func TestOrderTargetsKeepsOrderOnCycle(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	targets := []Target{{ID: "a", Name: "a"}, {ID: "b", Name: "b"}}
+	infos := map[string]container.InspectResponse{
+		"a": inspectWith("a", container.HostConfig{Links: []string{"/b:/a/b"}}, nil),
+		"b": inspectWith("b", container.HostConfig{}, map[string]string{DependsOnLabel: "a"}),
+	}
+	got := orderTargets(targets, infos)
+	if len(got) != 2 || got[0].Name != "a" || got[1].Name != "b" {
+		t.Fatalf("unexpected order %+v", got)
+	}
+}
+
+func TestRewriteNamespaceRefsUsesReplacedID(t *testing.T) {
+	u := &Updater{}
+	u.markReplaced("old", "mid")
+	u.markReplaced("mid", "new")
+	hc := &container.HostConfig{NetworkMode: "container:old", PidMode: "container:other"}
+
+	got := u.rewriteNamespaceRefs(hc)
+	if got.NetworkMode != "container:new" || got.PidMode != "container:other" {
+		t.Fatalf("unexpected host config: network=%s pid=%s", got.NetworkMode, got.PidMode)
+	}
+	if hc.NetworkMode != "container:old" {
+		t.Fatalf("original host config must not be modified")
+	}
+}
+
+func TestMarkReplacedCollapsesChainsAndExpires(t *testing.T) {
+	u := &Updater{}
+	u.replaced.Store("stale", replacement{id: "gone", at: time.Now().Add(-2 * replacedTTL)})
+	ids := []string{"v0"}
+	for i := 1; i <= 20; i++ {
+		ids = append(ids, fmt.Sprintf("v%d", i))
+		u.markReplaced(ids[i-1], ids[i])
+	}
+
+	if got := u.resolveID("v0"); got != "v20" {
+		t.Fatalf("resolveID(v0) = %s, want v20", got)
+	}
+	if _, ok := u.replaced.Load("stale"); ok {
+		t.Fatal("expired entry must be pruned")
+	}
+}
+
+func TestGroupComposeTargetsMergesReplicas(t *testing.T) {
+	web := &composecli.ServiceRef{Project: "stack", Service: "web"}
+	targets := []Target{
+		{ID: "1", Name: "stack-web-1", Compose: web},
+		{ID: "2", Name: "db"},
+		{ID: "3", Name: "stack-web-2", Compose: web},
+	}
+	got := groupComposeTargets(targets)
+	if len(got) != 2 || got[0].Name != "stack-web-1" || got[1].Name != "db" {
+		t.Fatalf("unexpected targets %+v", got)
+	}
+	if !reflect.DeepEqual(got[0].Replicas, []string{"stack-web-2"}) {
+		t.Fatalf("replicas = %v", got[0].Replicas)
+	}
+}
+
+// 停止旧容器失败时旧容器保持原状，不应处理依赖容器
+func TestRecreateSkipsDependantsWhenStopFails(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	f := &fakeDocker{states: []container.InspectResponse{state(true, 0, "")}, stopErr: errors.New("timeout")}
+	f.states[0].ID = "old"
+	u := &Updater{docker: f}
+
+	err := u.recreate(context.Background(), &updateContext{containerID: "old", imageRef: "nginx:latest"}, nil)
+	if err == nil {
+		t.Fatal("expected stop error")
+	}
+	if !reflect.DeepEqual(f.calls, []string{"stop old"}) {
+		t.Fatalf("calls = %v", f.calls)
+	}
+}
@@ -0,0 +1,179 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/history"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/metrics"
+
+	"github.com/distribution/reference"
+	"go.uber.org/zap"
+)
+
+type triggerKey struct{}
+
+// WithTrigger 在 ctx 中标记本次更新的触发来源（cron/manual/batch），写入更新历史
+func WithTrigger(ctx context.Context, t history.Trigger) context.Context {
+	return context.WithValue(ctx, triggerKey{}, t)
+}
+
+// triggerFrom 读取 ctx 中的触发来源，未标记时视为手动更新
+func triggerFrom(ctx context.Context) history.Trigger {
+	if t, ok := ctx.Value(triggerKey{}).(history.Trigger); ok && t != "" {
+		return t
+	}
+	return history.TriggerManual
+}
+
+// RollbackContainer 使用更新历史中记录的旧镜像重建容器（不拉取镜像），
+// 复用更新流程的停止、重建、校验与失败回滚逻辑。
+func (u *Updater) RollbackContainer(ctx context.Context, containerID string, rec history.Record, cb *UpdateProgressCallback) error {
+	containerID = u.resolveID(containerID)
+	mutex := u.getContainerLock(containerID)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	imageRef, err := u.rollbackImageRef(ctx, rec)
+	if err != nil {
+		return err
+	}
+	logger.Logger.Info("开始回滚容器",
+		zap.String("containerID", containerID),
+		zap.String("recordID", rec.ID),
+		zap.String("imageRef", imageRef))
+
+	uctx := &updateContext{
+		containerID: containerID,
+		imageRef:    imageRef,
+		startedAt:   time.Now(),
+	}
+	cb.step("rollback", "正在回滚到镜像 "+imageRef)
+	err = u.recreate(ctx, uctx, cb)
+	u.recordHistory(ctx, uctx, history.TriggerRollback, err)
+	return err
+}
+
+// rollbackImageRef 返回回滚使用的镜像引用。
+// 优先使用与旧镜像同仓库的 repo digest（如 nginx@sha256:...），容器配置仍能看出镜像来源，
+// 且固定 digest 后不会被下一次自动更新覆盖；没有 repo digest 时使用镜像 ID。
+func (u *Updater) rollbackImageRef(ctx context.Context, rec history.Record) (string, error) {
+	if rec.OldImageID == "" {
+		return "", fmt.Errorf("历史记录中没有旧镜像信息")
+	}
+	img, err := u.docker.ImageInspect(ctx, rec.OldImageID)
+	if err != nil {
+		return "", fmt.Errorf("旧镜像 %s 已不存在，无法回滚: %w", rec.OldImageID, err)
+	}
+
+	repo := ""
+	if named, err := reference.ParseNormalizedNamed(rec.OldImage); err == nil {
+		repo = named.Name()
+	}
+	for _, d := range img.RepoDigests {
+		named, err := reference.ParseNormalizedNamed(d)
+		if err != nil {
+			continue
+		}
+		if repo == "" || named.Name() == repo {
+			return d, nil
+		}
+	}
+	return rec.OldImageID, nil
+}
+
+// recordHistory 记录更新指标并写入一条更新历史；更新成功时按 update.keepImages 清理多余的旧镜像
+func (u *Updater) recordHistory(ctx context.Context, uctx *updateContext, trigger history.Trigger, updateErr error) {
+	result := updateResult(updateErr)
+	if !uctx.startedAt.IsZero() {
+		metrics.ObserveUpdate(string(trigger), string(result), time.Since(uctx.startedAt))
+	}
+	if u.history == nil {
+		return
+	}
+	// ctx 可能已超时，记录历史不应受影响
+	rctx := context.WithoutCancel(ctx)
+
+	info := uctx.oldInfo
+	if info.ContainerJSONBase == nil {
+		// 拉取镜像阶段失败时还没有读取旧容器信息
+		if i, err := u.docker.InspectContainer(rctx, uctx.containerID); err == nil {
+			info = i
+		}
+	}
+
+	rec := history.Record{
+		OldContainerID: uctx.containerID,
+		NewContainerID: uctx.newID,
+		ImageRef:       uctx.imageRef,
+		OldDigests:     uctx.oldDigests,
+		Trigger:        trigger,
+		Result:         result,
+	}
+	if info.ContainerJSONBase != nil {
+		rec.ContainerName = strings.TrimPrefix(info.Name, "/")
+		rec.OldImageID = info.Image
+	}
+	if info.Config != nil {
+		rec.OldImage = info.Config.Image
+	}
+
+	if updateErr != nil {
+		rec.Error = updateErr.Error()
+		rec.NewContainerID = ""
+	} else if uctx.newID != "" {
+		if newInfo, err := u.docker.InspectContainer(rctx, uctx.newID); err == nil {
+			rec.NewImageID = newInfo.Image
+			if img, err := u.docker.ImageInspect(rctx, newInfo.Image); err == nil {
+				rec.NewDigests = img.RepoDigests
+			}
+		}
+	}
+
+	u.history.Add(rec)
+	if updateErr == nil && rec.ContainerName != "" {
+		u.pruneOldImages(rctx, rec.ContainerName)
+	}
+}
+
+// updateResult 根据更新返回的错误判断更新结果
+func updateResult(updateErr error) history.Result {
+	if updateErr == nil {
+		return history.ResultSuccess
+	}
+	var verr *VerifyError
+	if errors.As(updateErr, &verr) && verr.RolledBack() {
+		return history.ResultRolledBack
+	}
+	return history.ResultFailed
+}
+
+// pruneOldImages 每个容器只保留最近 update.keepImages 个旧镜像，删除更早的旧镜像。
+// 仍被容器使用的镜像不会被删除。
+func (u *Updater) pruneOldImages(ctx context.Context, containerName string) {
+	keep := config.Get().Update.KeepImages
+	if keep <= 0 {
+		return
+	}
+
+	seen := make(map[string]bool)
+	kept := 0
+	for _, r := range u.history.ListByContainer(containerName) {
+		if r.Result != history.ResultSuccess || r.OldImageID == "" || r.OldImageID == r.NewImageID || seen[r.OldImageID] {
+			continue
+		}
+		seen[r.OldImageID] = true
+		kept++
+		if kept <= keep {
+			continue
+		}
+		if err := u.docker.SafeRemoveImage(ctx, r.OldImageID); err != nil {
+			logger.Logger.Debug("清理旧镜像失败", zap.String("imageID", r.OldImageID), zap.Error(err))
+		}
+	}
+}
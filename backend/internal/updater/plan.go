@@ -0,0 +1,180 @@
+package updater
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+	"github.com/jianxcao/watch-docker/backend/internal/scanner"
+
+	"go.uber.org/zap"
+)
+
+// 计划模式下容器的决策
+const (
+	DecisionUpdate     = "update"      // 本次会更新
+	DecisionQueued     = "queued"      // 不在维护窗口内，排队等待窗口打开后更新
+	DecisionNotifyOnly = "notify_only" // 有可用更新，但本次不会自动更新
+	DecisionSkip       = "skip"        // 被策略跳过
+	DecisionUpToDate   = "up_to_date"  // 已是最新
+	DecisionError      = "error"       // 检查更新失败
+)
+
+// PlannedDependant 父容器更新后需要处理的依赖容器
+type PlannedDependant struct {
+	Name    string   `json:"name"`
+	Action  string   `json:"action"`            // recreate | restart
+	Volumes []string `json:"volumes,omitempty"` // 重建时会删除的匿名卷
+}
+
+// PlanContainer 计划中一个容器的详情
+type PlanContainer struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Image         string   `json:"image"`
+	Running       bool     `json:"running"`
+	Decision      string   `json:"decision"`
+	Reason        string   `json:"reason,omitempty"`
+	CurrentDigest []string `json:"currentDigest,omitempty"`
+	RemoteDigest  string   `json:"remoteDigest,omitempty"`
+	// PullImage 将要拉取的镜像，没有可用更新时为空
+	PullImage string `json:"pullImage,omitempty"`
+	// Order 更新顺序（从 1 开始），不会更新的容器为 0
+	Order     int      `json:"order,omitempty"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Compose 通过 docker compose 更新的服务（project/service）
+	Compose    string             `json:"compose,omitempty"`
+	Replicas   []string           `json:"replicas,omitempty"`
+	Dependants []PlannedDependant `json:"dependants,omitempty"`
+	// Cleanup 更新完成后 CleanupContainerResources 会删除的资源；通过 compose 更新时由 compose 处理，为空
+	Cleanup *dockercli.CleanupPlan `json:"cleanup,omitempty"`
+}
+
+// Plan 一次扫描更新的执行计划，生成过程中不修改任何容器
+type Plan struct {
+	GeneratedAt time.Time       `json:"generatedAt"`
+	Order       []string        `json:"order"`
+	Containers  []PlanContainer `json:"containers"`
+	// 以下字段由调用方按需填写
+	AutoUpdate          bool `json:"autoUpdate"`
+	InMaintenanceWindow bool `json:"inMaintenanceWindow"`
+}
+
+// Updates 返回本次会更新的容器数量
+func (p *Plan) Updates() int {
+	n := 0
+	for _, c := range p.Containers {
+		if c.Decision == DecisionUpdate {
+			n++
+		}
+	}
+	return n
+}
+
+// StatusDecision 根据扫描结果给出基础决策：有可用更新时返回 DecisionUpdate，由调用方按自动更新开关等进一步调整
+func StatusDecision(st scanner.ContainerStatus) (decision, reason string) {
+	switch {
+	case st.Status == "Error":
+		return DecisionError, st.SkipReason
+	case st.Skipped:
+		return DecisionSkip, st.SkipReason
+	case st.Status != "UpdateAvailable":
+		return DecisionUpToDate, ""
+	}
+	return DecisionUpdate, ""
+}
+
+// BuildPlan 根据扫描结果生成更新计划，decide 给出每个容器的决策。
+// 决策为 update 与 queued 的容器会计算更新顺序、依赖容器的处理方式以及将被清理的资源。
+func (u *Updater) BuildPlan(ctx context.Context, statuses []scanner.ContainerStatus, decide func(scanner.ContainerStatus) (string, string)) *Plan {
+	plan := &Plan{GeneratedAt: time.Now(), Order: []string{}, Containers: make([]PlanContainer, 0, len(statuses))}
+	index := make(map[string]int, len(statuses))
+	var targets []Target
+	for _, st := range statuses {
+		decision, reason := decide(st)
+		pc := PlanContainer{
+			ID:            st.ID,
+			Name:          st.Name,
+			Image:         st.Image,
+			Running:       st.Running,
+			Decision:      decision,
+			Reason:        reason,
+			CurrentDigest: st.CurrentDigest,
+			RemoteDigest:  st.RemoteDigest,
+		}
+		switch decision {
+		case DecisionUpdate, DecisionQueued:
+			targets = append(targets, Target{ID: st.ID, Name: st.Name, Image: st.UpdateImage()})
+			pc.PullImage = st.UpdateImage()
+		case DecisionNotifyOnly:
+			pc.PullImage = st.UpdateImage()
+		}
+		index[st.ID] = len(plan.Containers)
+		plan.Containers = append(plan.Containers, pc)
+	}
+	if len(targets) == 0 {
+		return plan
+	}
+
+	all, err := u.docker.InspectContainers(ctx, true)
+	if err != nil {
+		logger.Logger.Warn("读取容器信息失败，计划中不包含依赖容器", zap.Error(err))
+	}
+	byName := make(map[string]int, len(plan.Containers))
+	for i, c := range plan.Containers {
+		byName[c.Name] = i
+	}
+	keepImages := config.Get().Update.KeepImages > 0
+
+	for i, t := range u.OrderTargets(ctx, targets) {
+		pc := &plan.Containers[index[t.ID]]
+		pc.Order = i + 1
+		pc.DependsOn = t.DependsOn
+		pc.Replicas = t.Replicas
+		plan.Order = append(plan.Order, t.Name)
+		for _, r := range t.Replicas {
+			if j, ok := byName[r]; ok {
+				plan.Containers[j].Order = i + 1
+				plan.Containers[j].Reason = "随 compose 服务 " + t.Compose.Key() + " 一起更新"
+			}
+		}
+
+		for _, d := range all {
+			action := dependantAction(d, t.ID, t.Name, true)
+			if action == "" {
+				continue
+			}
+			dep := PlannedDependant{Name: strings.TrimPrefix(d.Name, "/"), Action: action}
+			if action == DependantRecreate {
+				if cp, err := u.docker.PlanCleanupResources(ctx, d); err == nil {
+					// 依赖容器使用原镜像重建，镜像不会被删除
+					dep.Volumes = cp.Volumes
+				}
+			}
+			pc.Dependants = append(pc.Dependants, dep)
+		}
+
+		if t.Compose != nil {
+			pc.Compose = t.Compose.Key()
+			continue
+		}
+		info, err := u.docker.InspectContainer(ctx, t.ID)
+		if err != nil {
+			continue
+		}
+		cp, err := u.docker.PlanCleanupResources(ctx, info)
+		if err != nil {
+			logger.Logger.Warn("计算待清理资源失败", zap.String("container", t.Name), zap.Error(err))
+			continue
+		}
+		if keepImages {
+			// 保留旧镜像用于回滚，由 update.keepImages 控制清理
+			cp.Image = ""
+		}
+		pc.Cleanup = &cp
+	}
+	return plan
+}
@@ -6,20 +6,65 @@ import (
 	"sync"
 	"time"
 
+	"github.com/jianxcao/watch-docker/backend/internal/composecli"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
 	"github.com/jianxcao/watch-docker/backend/internal/dockercli"
+	"github.com/jianxcao/watch-docker/backend/internal/history"
 	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
 	"go.uber.org/zap"
 )
 
+// dockerAPI 更新过程使用的 Docker 操作，由 dockercli.Client 实现
+type dockerAPI interface {
+	InspectContainer(ctx context.Context, id string) (container.InspectResponse, error)
+	InspectContainers(ctx context.Context, includeStopped bool) ([]container.InspectResponse, error)
+	ImageInspect(ctx context.Context, ref string) (*image.InspectResponse, error)
+	ImagePullWithProgress(ctx context.Context, ref string, onProgress func(dockercli.PullProgress)) error
+	TagImage(ctx context.Context, source, target string) error
+	SafeRemoveImage(ctx context.Context, imageID string) error
+	CreateContainer(ctx context.Context, name string, cfg *container.Config, host *container.HostConfig, netCfg *network.NetworkingConfig) (string, error)
+	StartContainer(ctx context.Context, id string) error
+	StopContainer(ctx context.Context, id string, timeoutSeconds int) error
+	RestartContainer(ctx context.Context, id string, timeoutSeconds int) error
+	WaitContainerStopped(ctx context.Context, id string, maxWaitSeconds int) error
+	RenameContainer(ctx context.Context, id string, newName string) error
+	RemoveContainerWithVolumes(ctx context.Context, id string, force bool) error
+	PlanCleanupResources(ctx context.Context, containerInfo container.InspectResponse) (dockercli.CleanupPlan, error)
+	CleanupContainerResources(ctx context.Context, containerInfo container.InspectResponse) error
+	ExpectEvents(id string)
+}
+
 type Updater struct {
-	docker      *dockercli.Client
+	docker      dockerAPI
+	compose     *composecli.Client
+	history     *history.Store
 	updateLocks sync.Map // map[string]*sync.Mutex - 每个容器ID对应一个锁
+	replaced    sync.Map // map[string]replacement - 已被重建的容器：旧ID -> 新ID
+}
+
+// New 创建 Updater；h 为更新历史存储，为 nil 时不记录历史
+func New(d *dockercli.Client, h *history.Store) *Updater {
+	u := &Updater{history: h}
+	if d != nil {
+		u.docker = d
+		u.compose = composecli.NewClient(d.GetDockerClient())
+	}
+	return u
+}
+
+// WithoutCompose 不再通过 docker compose 更新服务，compose 管理的容器按普通容器重建。
+// 用于远程端点：compose 项目目录不在本机，无法在本机执行 docker compose。
+func (u *Updater) WithoutCompose() *Updater {
+	u.compose = nil
+	return u
 }
 
-func New(d *dockercli.Client) *Updater { return &Updater{docker: d} }
+// History 返回更新历史存储
+func (u *Updater) History() *history.Store { return u.history }
 
 // getContainerLock 获取或创建指定容器的互斥锁
 func (u *Updater) getContainerLock(containerID string) *sync.Mutex {
@@ -29,50 +74,7 @@ func (u *Updater) getContainerLock(containerID string) *sync.Mutex {
 
 // UpdateContainer 拉取镜像并按原配置重建容器，尽量无感更新。
 func (u *Updater) UpdateContainer(ctx context.Context, containerID string, imageRef string) error {
-	// 获取容器专属锁，防止并发更新同一容器
-	mutex := u.getContainerLock(containerID)
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	logger.Logger.Info("开始更新容器", zap.String("containerID", containerID), zap.String("imageRef", imageRef))
-
-	// 初始化更新上下文
-	uctx := &updateContext{
-		containerID: containerID,
-		imageRef:    imageRef,
-	}
-
-	// 1. 拉取镜像
-	if err := u.pullImage(ctx, imageRef); err != nil {
-		return err
-	}
-
-	// 2. 准备旧容器（停止、重命名、清理资源）
-	if err := u.prepareOldContainer(ctx, uctx); err != nil {
-		return err
-	}
-
-	// 3. 创建新容器（带重试）
-	if err := u.createContainerWithRetry(ctx, uctx); err != nil {
-		u.rollbackOnCreateFailure(ctx, uctx)
-		return err
-	}
-
-	// 4. 根据旧容器状态决定是否启动新容器
-	if uctx.wasRunning {
-		// 只有旧容器原来在运行时，才启动新容器
-		if err := u.startContainerWithRetry(ctx, uctx); err != nil {
-			u.rollbackOnStartFailure(ctx, uctx)
-			return err
-		}
-		logger.Logger.Info("新容器已启动（因为旧容器原来在运行）", zap.String("containerID", uctx.newID))
-	} else {
-		logger.Logger.Info("新容器已创建但未启动（因为旧容器原来是停止状态）", zap.String("containerID", uctx.newID))
-	}
-
-	// 5. 最终清理旧容器及相关资源
-	u.finalCleanup(ctx, uctx)
-	return nil
+	return u.UpdateContainerWithProgress(ctx, containerID, imageRef, nil)
 }
 
 // UpdateProgressCallback 更新进度回调
@@ -81,23 +83,39 @@ type UpdateProgressCallback struct {
 	OnPullProgress func(progress dockercli.PullProgress)
 }
 
-// UpdateContainerWithProgress 带进度回调的容器更新
+// step 报告步骤进度，cb 为 nil 时忽略
+func (cb *UpdateProgressCallback) step(step, message string) {
+	if cb != nil && cb.OnStep != nil {
+		cb.OnStep(step, message)
+	}
+}
+
+// UpdateContainerWithProgress 带进度回调的容器更新，cb 可以为 nil
 func (u *Updater) UpdateContainerWithProgress(ctx context.Context, containerID, imageRef string, cb *UpdateProgressCallback) error {
+	// 容器可能已因依赖的容器更新而被重建，使用最新 ID
+	containerID = u.resolveID(containerID)
+	// 获取容器专属锁，防止并发更新同一容器
 	mutex := u.getContainerLock(containerID)
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	logger.Logger.Info("开始更新容器（带进度）", zap.String("containerID", containerID), zap.String("imageRef", imageRef))
+	logger.Logger.Info("开始更新容器", zap.String("containerID", containerID), zap.String("imageRef", imageRef))
 
 	uctx := &updateContext{
 		containerID: containerID,
 		imageRef:    imageRef,
+		startedAt:   time.Now(),
 	}
 
-	// 1. 拉取镜像（带进度）
-	if cb != nil && cb.OnStep != nil {
-		cb.OnStep("pulling", "正在拉取镜像 "+imageRef)
+	// compose 管理的容器通过 docker compose 更新所属服务，避免容器与项目配置脱节
+	if ref, ok := u.composeServiceFor(ctx, uctx); ok {
+		err := u.updateComposeService(ctx, uctx, ref, cb)
+		u.recordHistory(ctx, uctx, triggerFrom(ctx), err)
+		return err
 	}
+
+	// 1. 拉取镜像（带进度）
+	cb.step("pulling", "正在拉取镜像 "+imageRef)
 	logger.Logger.Info("开始拉取镜像", zap.String("imageRef", imageRef))
 	err := u.docker.ImagePullWithProgress(ctx, imageRef, func(p dockercli.PullProgress) {
 		if cb != nil && cb.OnPullProgress != nil {
@@ -106,46 +124,67 @@ func (u *Updater) UpdateContainerWithProgress(ctx context.Context, containerID,
 	})
 	if err != nil {
 		logger.Logger.Error("拉取镜像失败", zap.String("imageRef", imageRef), zap.Error(err))
-		return fmt.Errorf("pull: %w", err)
+		err = fmt.Errorf("pull: %w", err)
+		u.recordHistory(ctx, uctx, triggerFrom(ctx), err)
+		return err
 	}
 	logger.Logger.Info("镜像拉取成功", zap.String("imageRef", imageRef))
 
-	// 2. 准备旧容器
-	if cb != nil && cb.OnStep != nil {
-		cb.OnStep("stopping", "正在停止旧容器")
-	}
+	// 2-5. 停止旧容器、创建并启动新容器、校验、清理
+	err = u.recreate(ctx, uctx, cb)
+	u.recordHistory(ctx, uctx, triggerFrom(ctx), err)
+	return err
+}
+
+// recreate 用 uctx.imageRef 按旧容器配置重建容器：
+// 准备旧容器 -> 创建新容器 -> 启动并校验 -> 清理旧容器，任一步失败都会回滚。
+// 结束后处理共享其命名空间或链接到它的依赖容器。
+func (u *Updater) recreate(ctx context.Context, uctx *updateContext, cb *UpdateProgressCallback) (err error) {
+	defer func() {
+		// 旧容器未被停止也未被替换时，依赖容器不受影响
+		if uctx.stopped || (err == nil && uctx.newID != "") {
+			u.refreshDependants(ctx, uctx, err == nil, cb)
+		}
+	}()
+
+	// 2. 准备旧容器（停止、重命名、清理资源）
+	cb.step("stopping", "正在停止旧容器")
 	if err := u.prepareOldContainer(ctx, uctx); err != nil {
 		return err
 	}
 
-	// 3. 创建新容器
-	if cb != nil && cb.OnStep != nil {
-		cb.OnStep("creating", "正在创建新容器")
-	}
+	// 3. 创建新容器（带重试）
+	cb.step("creating", "正在创建新容器")
 	if err := u.createContainerWithRetry(ctx, uctx); err != nil {
 		u.rollbackOnCreateFailure(ctx, uctx)
 		return err
 	}
 
-	// 4. 启动新容器
+	// 4. 根据旧容器状态决定是否启动新容器
 	if uctx.wasRunning {
-		if cb != nil && cb.OnStep != nil {
-			cb.OnStep("starting", "正在启动新容器")
-		}
+		// 只有旧容器原来在运行时，才启动新容器
+		cb.step("starting", "正在启动新容器")
 		if err := u.startContainerWithRetry(ctx, uctx); err != nil {
 			u.rollbackOnStartFailure(ctx, uctx)
 			return err
 		}
-		logger.Logger.Info("新容器已启动", zap.String("containerID", uctx.newID))
+		logger.Logger.Info("新容器已启动（因为旧容器原来在运行）", zap.String("containerID", uctx.newID))
+
+		// 校验新容器（健康检查 / 稳定运行），失败则回滚到旧容器
+		if config.Get().Update.Verify {
+			cb.step("verifying", "正在校验新容器运行状态")
+		}
+		if err := u.verifyNewContainer(ctx, uctx); err != nil {
+			cb.step("rollback", "新容器校验失败，正在回滚到旧容器: "+err.Error())
+			return u.rollbackOnVerifyFailure(ctx, uctx, err)
+		}
 	} else {
-		logger.Logger.Info("新容器已创建但未启动", zap.String("containerID", uctx.newID))
+		logger.Logger.Info("新容器已创建但未启动（因为旧容器原来是停止状态）", zap.String("containerID", uctx.newID))
 	}
 
-	// 5. 清理
-	if cb != nil && cb.OnStep != nil {
-		cb.OnStep("cleanup", "正在清理旧容器")
-	}
-	u.finalCleanup(ctx, uctx)
+	// 5. 最终清理旧容器及相关资源；新容器已就绪，请求取消时也要删除改名后的旧容器
+	cb.step("cleanup", "正在清理旧容器")
+	u.finalCleanup(context.WithoutCancel(ctx), uctx)
 	return nil
 }
 
@@ -157,23 +196,14 @@ type updateContext struct {
 	oldName     string
 	backupName  string
 	newID       string
-	wasRunning  bool // 记录旧容器是否在运行状态
+	wasRunning  bool     // 记录旧容器是否在运行状态
+	stopped     bool     // 旧容器已被本次更新停止
+	oldDigests  []string // 旧镜像的 RepoDigests，用于记录历史
+	startedAt   time.Time
 }
 
 const maxRetries = 3
 
-// pullImage 拉取镜像
-func (u *Updater) pullImage(ctx context.Context, imageRef string) error {
-	logger.Logger.Info("开始拉取镜像", zap.String("imageRef", imageRef))
-	err := u.docker.ImagePull(ctx, imageRef)
-	if err != nil {
-		logger.Logger.Error("拉取镜像失败", zap.String("imageRef", imageRef), zap.Error(err))
-		return fmt.Errorf("pull: %w", err)
-	}
-	logger.Logger.Info("镜像拉取成功", zap.String("imageRef", imageRef))
-	return nil
-}
-
 // prepareOldContainer 准备旧容器：获取信息、停止、等待、重命名、清理资源
 func (u *Updater) prepareOldContainer(ctx context.Context, uctx *updateContext) error {
 	// 读取旧容器详细信息与配置
@@ -182,6 +212,9 @@ func (u *Updater) prepareOldContainer(ctx context.Context, uctx *updateContext)
 		return fmt.Errorf("inspect: %w", err)
 	}
 	uctx.oldInfo = oldInfo
+	if img, ierr := u.docker.ImageInspect(ctx, oldInfo.Image); ierr == nil {
+		uctx.oldDigests = img.RepoDigests
+	}
 
 	// 记录旧容器的运行状态
 	uctx.wasRunning = oldInfo.State.Running
@@ -195,6 +228,7 @@ func (u *Updater) prepareOldContainer(ctx context.Context, uctx *updateContext)
 		if err != nil {
 			return fmt.Errorf("停止旧容器失败: %w", err)
 		}
+		uctx.stopped = true
 		logger.Logger.Info("旧容器停止成功", zap.String("containerID", uctx.containerID))
 	} else {
 		logger.Logger.Info("旧容器原本就是停止状态，无需停止", zap.String("containerID", uctx.containerID))
@@ -247,12 +281,14 @@ func (u *Updater) createContainerWithRetry(ctx context.Context, uctx *updateCont
 	newCfg := uctx.oldInfo.Config
 	newCfg.Image = uctx.imageRef
 	netCfg := &network.NetworkingConfig{EndpointsConfig: uctx.oldInfo.NetworkSettings.Networks}
+	// 依赖的容器可能已被重建，container:<旧ID> 形式的命名空间引用需要换成新 ID
+	hostCfg := u.rewriteNamespaceRefs(uctx.oldInfo.HostConfig)
 	logger.Logger.Info("创建新容器", zap.String("containerName", uctx.oldName), zap.String("imageRef", uctx.imageRef))
 
 	// 尝试创建新容器，增加重试机制
 	var createErr error
 	for i := 0; i < maxRetries; i++ {
-		uctx.newID, createErr = u.docker.CreateContainer(ctx, uctx.oldName, newCfg, uctx.oldInfo.HostConfig, netCfg)
+		uctx.newID, createErr = u.docker.CreateContainer(ctx, uctx.oldName, newCfg, hostCfg, netCfg)
 		if createErr == nil {
 			break
 		}
@@ -319,19 +355,31 @@ func (u *Updater) rollbackOnStartFailure(ctx context.Context, uctx *updateContex
 		zap.String("newID", uctx.newID),
 		zap.String("oldName", uctx.oldName))
 
+	_ = u.restoreOldContainer(ctx, uctx)
+}
+
+// restoreOldContainer 删除新容器，恢复旧容器名称，并按原始状态重新启动旧容器
+func (u *Updater) restoreOldContainer(ctx context.Context, uctx *updateContext) error {
 	// 删除新容器
-	_ = u.docker.RemoveContainerWithVolumes(ctx, uctx.newID, true)
+	if err := u.docker.RemoveContainerWithVolumes(ctx, uctx.newID, true); err != nil {
+		logger.Logger.Warn("删除新容器失败", zap.String("newID", uctx.newID), zap.Error(err))
+	}
 
 	// 恢复旧容器名称
 	if uctx.oldName != "" {
-		_ = u.docker.RenameContainer(ctx, uctx.containerID, uctx.oldName)
+		if err := u.docker.RenameContainer(ctx, uctx.containerID, uctx.oldName); err != nil {
+			return fmt.Errorf("恢复旧容器名称失败: %w", err)
+		}
 	}
 
 	// 根据原始状态决定是否重新启动旧容器
 	if uctx.wasRunning {
-		_ = u.docker.StartContainer(ctx, uctx.containerID)
+		if err := u.docker.StartContainer(ctx, uctx.containerID); err != nil {
+			return fmt.Errorf("重新启动旧容器失败: %w", err)
+		}
 		logger.Logger.Info("已重新启动旧容器（因为原来在运行）", zap.String("containerID", uctx.containerID))
 	}
+	return nil
 }
 
 // finalCleanup 最终清理旧容器及相关资源
@@ -350,7 +398,12 @@ func (u *Updater) finalCleanup(ctx context.Context, uctx *updateContext) {
 
 	// 删除容器后，再清理旧容器相关的镜像、网络、卷等资源
 	logger.Logger.Info("安全清理旧容器的相关资源", zap.String("oldImageID", uctx.oldInfo.Image))
-	err = u.docker.CleanupContainerResources(ctx, uctx.oldInfo)
+	cleanupInfo := uctx.oldInfo
+	if config.Get().Update.KeepImages > 0 {
+		// 保留旧镜像用于回滚，超出数量的旧镜像在记录历史后清理
+		cleanupInfo.Image = ""
+	}
+	err = u.docker.CleanupContainerResources(ctx, cleanupInfo)
 	if err != nil {
 		logger.Logger.Warn("清理旧容器资源失败", zap.String("containerID", uctx.containerID), zap.Error(err))
 	}
@@ -0,0 +1,144 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"github.com/docker/docker/api/types/container"
+	"go.uber.org/zap"
+)
+
+// verifyPollInterval 校验新容器状态的轮询间隔（测试中调小）
+var verifyPollInterval = 2 * time.Second
+
+// VerifyError 新容器启动后校验失败，更新已回滚到旧容器
+type VerifyError struct {
+	Reason      string // 校验失败原因
+	RollbackErr error  // 回滚过程中的错误，nil 表示已成功恢复旧容器
+}
+
+func (e *VerifyError) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("新容器校验失败: %s；回滚旧容器失败: %v", e.Reason, e.RollbackErr)
+	}
+	return fmt.Sprintf("新容器校验失败，已回滚到旧容器: %s", e.Reason)
+}
+
+// RolledBack 是否已成功恢复旧容器
+func (e *VerifyError) RolledBack() bool { return e.RollbackErr == nil }
+
+// verifyNewContainer 校验刚启动的新容器：
+//   - 镜像定义了 HEALTHCHECK：等待其变为 healthy，unhealthy 或超时视为失败
+//   - 未定义 HEALTHCHECK：要求容器在 stableSeconds 内持续运行且没有重启
+//
+// 未开启 update.verify 时直接返回 nil。
+// 关闭页面或断开 WebSocket 会取消 ctx，但这不代表新容器有问题：校验不随 ctx 取消，只受自身时长限制。
+func (u *Updater) verifyNewContainer(ctx context.Context, uctx *updateContext) error {
+	cfg := config.Get().Update
+	if !cfg.Verify {
+		return nil
+	}
+	limit := time.Duration(max(cfg.HealthTimeout, cfg.StableSeconds))*time.Second + time.Minute
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), limit)
+	defer cancel()
+
+	info, err := u.docker.InspectContainer(ctx, uctx.newID)
+	if err != nil {
+		return fmt.Errorf("读取新容器状态失败: %w", err)
+	}
+	hasHealthCheck := info.State != nil && info.State.Health != nil
+	window := time.Duration(cfg.StableSeconds) * time.Second
+	if hasHealthCheck {
+		window = time.Duration(cfg.HealthTimeout) * time.Second
+	}
+	initialRestarts := info.RestartCount
+	start := time.Now()
+
+	logger.Logger.Info("开始校验新容器",
+		zap.String("containerID", uctx.newID),
+		zap.Bool("healthCheck", hasHealthCheck),
+		zap.Duration("window", window))
+
+	for {
+		state := info.State
+		if state == nil {
+			return fmt.Errorf("无法获取新容器状态")
+		}
+		if state.Restarting || info.RestartCount > initialRestarts {
+			return fmt.Errorf("新容器发生重启 (exitCode=%d)", state.ExitCode)
+		}
+		if !state.Running {
+			return fmt.Errorf("新容器已退出 (status=%s, exitCode=%d)", state.Status, state.ExitCode)
+		}
+
+		elapsed := time.Since(start)
+		if hasHealthCheck {
+			switch state.Health.Status {
+			case container.Healthy:
+				logger.Logger.Info("新容器健康检查通过", zap.String("containerID", uctx.newID), zap.Duration("elapsed", elapsed))
+				return nil
+			case container.Unhealthy:
+				return fmt.Errorf("健康检查失败: %s", lastHealthOutput(state.Health))
+			}
+			if elapsed >= window {
+				return fmt.Errorf("等待健康检查超时 (%s)，当前状态: %s", window, state.Health.Status)
+			}
+		} else if elapsed >= window {
+			logger.Logger.Info("新容器运行稳定", zap.String("containerID", uctx.newID), zap.Duration("elapsed", elapsed))
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("校验超时: %w", ctx.Err())
+		case <-time.After(verifyPollInterval):
+		}
+
+		info, err = u.docker.InspectContainer(ctx, uctx.newID)
+		if err != nil {
+			return fmt.Errorf("读取新容器状态失败: %w", err)
+		}
+	}
+}
+
+// lastHealthOutput 返回最近一次健康检查的输出，便于定位失败原因
+func lastHealthOutput(h *container.Health) string {
+	if h == nil || len(h.Log) == 0 {
+		return "unhealthy"
+	}
+	out := strings.TrimSpace(h.Log[len(h.Log)-1].Output)
+	if out == "" {
+		return fmt.Sprintf("exitCode=%d", h.Log[len(h.Log)-1].ExitCode)
+	}
+	if len(out) > 200 {
+		out = out[:200] + "..."
+	}
+	return out
+}
+
+// rollbackOnVerifyFailure 新容器校验失败时的回滚：删除新容器，恢复旧容器名称并重新启动。
+// ctx 可能已经超时或被取消，回滚使用独立的超时上下文，确保旧容器能被恢复。
+func (u *Updater) rollbackOnVerifyFailure(ctx context.Context, uctx *updateContext, reason error) error {
+	logger.Logger.Error("新容器校验失败，开始回滚",
+		zap.String("containerID", uctx.containerID),
+		zap.String("newID", uctx.newID),
+		zap.String("oldName", uctx.oldName),
+		zap.Error(reason))
+
+	rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Minute)
+	defer cancel()
+
+	verr := &VerifyError{Reason: reason.Error()}
+	verr.RollbackErr = u.restoreOldContainer(rctx, uctx)
+	if verr.RollbackErr != nil {
+		logger.Logger.Error("回滚旧容器失败", zap.String("containerID", uctx.containerID), zap.Error(verr.RollbackErr))
+	} else {
+		logger.Logger.Info("已回滚到旧容器", zap.String("containerID", uctx.containerID), zap.String("name", uctx.oldName))
+	}
+	return verr
+}
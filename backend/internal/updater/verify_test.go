@@ -0,0 +1,173 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+	"github.com/jianxcao/watch-docker/backend/internal/config"
+	logger "github.com/jianxcao/watch-docker/backend/internal/logging"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"go.uber.org/zap"
+)
+
+// fakeDocker 按顺序返回新容器的状态，并记录回滚时的操作；未实现的方法调用时 panic
+type fakeDocker struct {
+	dockerAPI
+	states    []container.InspectResponse // 依次返回，用完后重复最后一个
+	inspects  int
+	calls     []string
+	renameErr error
+	stopErr   error
+}
+
+func (f *fakeDocker) InspectContainer(_ context.Context, id string) (container.InspectResponse, error) {
+	i := min(f.inspects, len(f.states)-1)
+	f.inspects++
+	return f.states[i], nil
+}
+
+func (f *fakeDocker) InspectContainers(context.Context, bool) ([]container.InspectResponse, error) {
+	f.calls = append(f.calls, "list")
+	return nil, nil
+}
+
+func (f *fakeDocker) ImageInspect(context.Context, string) (*image.InspectResponse, error) {
+	return nil, errors.New("not found")
+}
+
+func (f *fakeDocker) StopContainer(_ context.Context, id string, _ int) error {
+	f.calls = append(f.calls, "stop "+id)
+	return f.stopErr
+}
+
+func (f *fakeDocker) RemoveContainerWithVolumes(_ context.Context, id string, _ bool) error {
+	f.calls = append(f.calls, "remove "+id)
+	return nil
+}
+
+func (f *fakeDocker) RenameContainer(_ context.Context, id, name string) error {
+	f.calls = append(f.calls, "rename "+id+" "+name)
+	return f.renameErr
+}
+
+func (f *fakeDocker) StartContainer(_ context.Context, id string) error {
+	f.calls = append(f.calls, "start "+id)
+	return nil
+}
+
+func setVerifyConfig(t *testing.T, verify bool) {
+	t.Helper()
+	logger.Logger = zap.NewNop()
+	conf.EnvCfg.CONFIG_PATH = t.TempDir()
+	old := config.Get()
+	t.Cleanup(func() { config.SetGlobal(old) })
+	cfg := *old
+	cfg.Update.Verify = verify
+	cfg.Update.HealthTimeout = 1
+	cfg.Update.StableSeconds = 1
+	config.SetGlobal(&cfg)
+
+	oldInterval := verifyPollInterval
+	verifyPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { verifyPollInterval = oldInterval })
+}
+
+// state 构造新容器的一次 inspect 结果，health 为空表示镜像没有 HEALTHCHECK
+func state(running bool, restarts int, health container.HealthStatus) container.InspectResponse {
+	st := &container.State{Running: running, Status: "running"}
+	if !running {
+		st.Status, st.ExitCode = "exited", 1
+	}
+	if health != "" {
+		st.Health = &container.Health{Status: health, Log: []*container.HealthcheckResult{{Output: "connection refused"}}}
+	}
+	return container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{ID: "new", State: st, RestartCount: restarts}}
+}
+
+func TestVerifyNewContainer(t *testing.T) {
+	cases := []struct {
+		name    string
+		states  []container.InspectResponse
+		wantErr string // 为空表示校验通过
+	}{
+		{"stable", []container.InspectResponse{state(true, 0, "")}, ""},
+		{"exited", []container.InspectResponse{state(true, 0, ""), state(false, 0, "")}, "已退出"},
+		{"restarted", []container.InspectResponse{state(true, 0, ""), state(true, 1, "")}, "重启"},
+		{"healthy", []container.InspectResponse{state(true, 0, container.Starting), state(true, 0, container.Healthy)}, ""},
+		{"unhealthy", []container.InspectResponse{state(true, 0, container.Starting), state(true, 0, container.Unhealthy)}, "connection refused"},
+		{"health timeout", []container.InspectResponse{state(true, 0, container.Starting)}, "超时"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setVerifyConfig(t, true)
+			u := &Updater{docker: &fakeDocker{states: c.states}}
+			err := u.verifyNewContainer(context.Background(), &updateContext{newID: "new"})
+			switch {
+			case c.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)):
+				t.Fatalf("error = %v, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyNewContainerDisabled(t *testing.T) {
+	setVerifyConfig(t, false)
+	f := &fakeDocker{states: []container.InspectResponse{state(false, 0, "")}}
+	u := &Updater{docker: f}
+	if err := u.verifyNewContainer(context.Background(), &updateContext{newID: "new"}); err != nil || f.inspects != 0 {
+		t.Fatalf("verify disabled: err=%v inspects=%d", err, f.inspects)
+	}
+}
+
+// 请求被取消（关闭页面、断开 WebSocket）时继续校验，健康的新容器不会被回滚
+func TestVerifyNewContainerIgnoresCancel(t *testing.T) {
+	setVerifyConfig(t, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	u := &Updater{docker: &fakeDocker{states: []container.InspectResponse{state(true, 0, "")}}}
+	if err := u.verifyNewContainer(ctx, &updateContext{newID: "new"}); err != nil {
+		t.Fatalf("cancelled request must not fail verification: %v", err)
+	}
+}
+
+func TestRollbackOnVerifyFailure(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	cases := []struct {
+		name       string
+		wasRunning bool
+		renameErr  error
+		wantCalls  []string
+	}{
+		{"running", true, nil, []string{"remove new", "rename old web", "start old"}},
+		{"stopped", false, nil, []string{"remove new", "rename old web"}},
+		{"rename fails", true, errors.New("conflict"), []string{"remove new", "rename old web"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := &fakeDocker{renameErr: c.renameErr}
+			u := &Updater{docker: f}
+			uctx := &updateContext{containerID: "old", newID: "new", oldName: "web", wasRunning: c.wasRunning}
+			err := u.rollbackOnVerifyFailure(context.Background(), uctx, errors.New("unhealthy"))
+
+			var verr *VerifyError
+			if !errors.As(err, &verr) || verr.Reason != "unhealthy" {
+				t.Fatalf("error = %v", err)
+			}
+			if verr.RolledBack() != (c.renameErr == nil) {
+				t.Fatalf("rolledBack = %v, rollbackErr = %v", verr.RolledBack(), verr.RollbackErr)
+			}
+			if !reflect.DeepEqual(f.calls, c.wantCalls) {
+				t.Fatalf("calls = %v, want %v", f.calls, c.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+// Package users 管理本地用户账号与角色。
+// 用户保存在 CONFIG_PATH/users.json，密码使用 bcrypt 哈希；
+// USER_NAME/USER_PASSWORD 配置的账号作为内置管理员，不保存在此。
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 角色
+const (
+	RoleAdmin    = "admin"    // 全部权限，包括用户、配置与主机终端
+	RoleOperator = "operator" // 查看、更新与操作容器，包括容器文件与容器终端
+	RoleViewer   = "viewer"   // 只读
+)
+
+// Roles 全部角色
+var Roles = []string{RoleAdmin, RoleOperator, RoleViewer}
+
+// MinPasswordLength 密码最小长度
+const MinPasswordLength = 8
+
+var (
+	ErrUserExists      = errors.New("user already exists")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrInvalidRole     = errors.New("invalid role")
+	ErrInvalidUsername = errors.New("invalid username")
+	ErrWeakPassword    = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	ErrBuiltinUser     = errors.New("username is reserved for the built-in admin")
+	ErrLastAdmin       = errors.New("cannot remove the last admin")
+	// ErrIdentityConflict 同名账号属于本地、其他来源或其他外部身份
+	ErrIdentityConflict = errors.New("username is taken by another account")
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._@-]{1,64}$`)
+
+// User 用户账号
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"passwordHash,omitempty"`
+	Role         string    `json:"role"`
+	Source       string    `json:"source,omitempty"`     // 外部身份来源（如 oidc），这类用户没有本地密码
+	ExternalID   string    `json:"externalId,omitempty"` // 关联的外部身份（OIDC 为 issuer|subject）
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// Store 用户存储
+type Store struct {
+	path  string
+	mu    sync.RWMutex
+	users []*User
+}
+
+var (
+	store     *Store
+	storeOnce sync.Once
+)
+
+// GetStore 获取用户存储实例，数据保存在 CONFIG_PATH/users.json
+func GetStore() *Store {
+	storeOnce.Do(func() {
+		store = NewStore(filepath.Join(conf.EnvCfg.CONFIG_PATH, "users.json"))
+	})
+	return store
+}
+
+// NewStore 从文件加载用户，文件不存在时为空
+func NewStore(path string) *Store {
+	s := &Store{path: path}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &s.users)
+	}
+	return s
+}
+
+// ValidRole 角色是否有效
+func ValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+// dummyHash 用户不存在时也执行一次 bcrypt 比较，避免通过响应时间枚举用户名
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("watch-docker"), bcrypt.DefaultCost)
+
+// Authenticate 校验用户名与密码
+func (s *Store) Authenticate(username, password string) (*User, bool) {
+	s.mu.RLock()
+	u := s.findLocked(username)
+	hash := dummyHash
+	if u != nil && u.PasswordHash != "" {
+		hash = []byte(u.PasswordHash)
+	}
+	s.mu.RUnlock()
+	// 外部用户没有本地密码，不能使用密码登录
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || u == nil || u.PasswordHash == "" {
+		return nil, false
+	}
+	c := *u
+	return &c, true
+}
+
+// Get 按用户名查找用户
+func (s *Store) Get(username string) (*User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u := s.findLocked(username)
+	if u == nil {
+		return nil, false
+	}
+	c := *u
+	return &c, true
+}
+
+// Count 用户数量
+func (s *Store) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.users)
+}
+
+// List 返回全部用户（不含密码哈希），按用户名排序
+func (s *Store) List() []User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		c := *u
+		c.PasswordHash = ""
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	return list
+}
+
+// Create 创建用户
+func (s *Store) Create(username, password, role string) (*User, error) {
+	if !usernamePattern.MatchString(username) {
+		return nil, ErrInvalidUsername
+	}
+	if username == conf.EnvCfg.USER_NAME {
+		return nil, ErrBuiltinUser
+	}
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.findLocked(username) != nil {
+		return nil, ErrUserExists
+	}
+	now := time.Now()
+	u := &User{Username: username, PasswordHash: hash, Role: role, CreatedAt: now, UpdatedAt: now}
+	s.users = append(s.users, u)
+	if err := s.saveLocked(); err != nil {
+		s.users = s.users[:len(s.users)-1]
+		return nil, err
+	}
+	c := *u
+	c.PasswordHash = ""
+	return &c, nil
+}
+
+// ExternalIdentity 外部身份（OIDC、反向代理）登录的用户
+type ExternalIdentity struct {
+	Username string
+	Source   string // oidc、proxy
+	ID       string // 外部身份的唯一标识（OIDC 为 issuer|subject），为空表示只按用户名匹配
+	// Role 按外部身份同步的角色，为空表示不修改；只同步由该来源创建的账号
+	Role string
+	// DefaultRole 新用户未指定 Role 时的角色，为空表示不自动创建
+	DefaultRole string
+	// LinkExisting 允许关联同名的本地账号或其他来源的账号，关联后保留其原有角色
+	LinkExisting bool
+}
+
+// EnsureExternal 确保外部身份对应的本地用户存在：不存在时创建无密码用户；
+// 同名账号只有来源与外部身份标识都一致时才视为同一用户，否则需要 LinkExisting，且不能关联已绑定其他外部身份的账号
+func (s *Store) EnsureExternal(id ExternalIdentity) (*User, error) {
+	if !usernamePattern.MatchString(id.Username) {
+		return nil, ErrInvalidUsername
+	}
+	if id.Username == conf.EnvCfg.USER_NAME {
+		return nil, ErrBuiltinUser
+	}
+	if id.Role != "" && !ValidRole(id.Role) {
+		return nil, ErrInvalidRole
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.findLocked(id.Username)
+	if u == nil {
+		role := id.Role
+		if role == "" {
+			role = id.DefaultRole
+		}
+		if !ValidRole(role) {
+			return nil, ErrUserNotFound
+		}
+		now := time.Now()
+		u = &User{Username: id.Username, Role: role, Source: id.Source, ExternalID: id.ID, CreatedAt: now, UpdatedAt: now}
+		s.users = append(s.users, u)
+		if err := s.saveLocked(); err != nil {
+			s.users = s.users[:len(s.users)-1]
+			return nil, err
+		}
+		c := *u
+		c.PasswordHash = ""
+		return &c, nil
+	}
+
+	owned := u.Source == id.Source && u.ExternalID == id.ID
+	if !owned {
+		if !id.LinkExisting || (u.ExternalID != "" && u.ExternalID != id.ID) {
+			return nil, ErrIdentityConflict
+		}
+		if id.ID != "" && u.ExternalID == "" {
+			old := *u
+			u.ExternalID = id.ID
+			u.UpdatedAt = time.Now()
+			if err := s.saveLocked(); err != nil {
+				*u = old
+				return nil, err
+			}
+		}
+	}
+	// 关联的本地账号由管理员分配角色，不随外部身份变化
+	if owned && id.Role != "" && id.Role != u.Role {
+		if u.Role == RoleAdmin && s.lastAdminLocked() {
+			return nil, ErrLastAdmin
+		}
+		old := *u
+		u.Role = id.Role
+		u.UpdatedAt = time.Now()
+		if err := s.saveLocked(); err != nil {
+			*u = old
+			return nil, err
+		}
+	}
+	c := *u
+	c.PasswordHash = ""
+	return &c, nil
+}
+
+// Update 修改用户的密码或角色，空字符串表示不修改
+func (s *Store) Update(username, password, role string) (*User, error) {
+	if role != "" && !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	var hash string
+	if password != "" {
+		h, err := hashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		hash = h
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.findLocked(username)
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	if role != "" && role != RoleAdmin && u.Role == RoleAdmin && s.lastAdminLocked() {
+		return nil, ErrLastAdmin
+	}
+	old := *u
+	if hash != "" {
+		u.PasswordHash = hash
+	}
+	if role != "" {
+		u.Role = role
+	}
+	u.UpdatedAt = time.Now()
+	if err := s.saveLocked(); err != nil {
+		*u = old
+		return nil, err
+	}
+	c := *u
+	c.PasswordHash = ""
+	return &c, nil
+}
+
+// Delete 删除用户
+func (s *Store) Delete(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, u := range s.users {
+		if u.Username != username {
+			continue
+		}
+		if u.Role == RoleAdmin && s.lastAdminLocked() {
+			return ErrLastAdmin
+		}
+		old := s.users
+		s.users = append(s.users[:i:i], s.users[i+1:]...)
+		if err := s.saveLocked(); err != nil {
+			s.users = old
+			return err
+		}
+		return nil
+	}
+	return ErrUserNotFound
+}
+
+// lastAdminLocked 未配置内置管理员且只剩一个管理员
+func (s *Store) lastAdminLocked() bool {
+	if conf.EnvCfg.USER_NAME != "" && conf.EnvCfg.USER_PASSWORD != "" {
+		return false
+	}
+	n := 0
+	for _, u := range s.users {
+		if u.Role == RoleAdmin {
+			n++
+		}
+	}
+	return n <= 1
+}
+
+func (s *Store) findLocked(username string) *User {
+	for _, u := range s.users {
+		if u.Username == username {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *Store) saveLocked() error {
+	data, err := json.MarshalIndent(s.users, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal users: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("create users dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write users: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("rename users: %w", err)
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return string(hash), nil
+}
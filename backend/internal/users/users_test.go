@@ -0,0 +1,160 @@
+package users
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jianxcao/watch-docker/backend/internal/conf"
+)
+
+func TestStore(t *testing.T) {
+	old := *conf.EnvCfg
+	t.Cleanup(func() { *conf.EnvCfg = old })
+	conf.EnvCfg.USER_NAME = "admin"
+	conf.EnvCfg.USER_PASSWORD = "admin"
+
+	path := filepath.Join(t.TempDir(), "users.json")
+	s := NewStore(path)
+
+	if _, err := s.Create("admin", "password1", RoleViewer); !errors.Is(err, ErrBuiltinUser) {
+		t.Fatalf("builtin username: %v", err)
+	}
+	if _, err := s.Create("alice", "short", RoleViewer); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("weak password: %v", err)
+	}
+	if _, err := s.Create("alice", "password1", "root"); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("invalid role: %v", err)
+	}
+	if _, err := s.Create("al ice", "password1", RoleViewer); !errors.Is(err, ErrInvalidUsername) {
+		t.Fatalf("invalid username: %v", err)
+	}
+	if _, err := s.Create("alice", "password1", RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create("alice", "password1", RoleViewer); !errors.Is(err, ErrUserExists) {
+		t.Fatalf("duplicate: %v", err)
+	}
+
+	// 文件中只有 bcrypt 哈希
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "password1") {
+		t.Fatal("plaintext password stored")
+	}
+
+	s = NewStore(path)
+	if _, ok := s.Authenticate("alice", "wrong-pass"); ok {
+		t.Fatal("wrong password accepted")
+	}
+	if _, ok := s.Authenticate("bob", "password1"); ok {
+		t.Fatal("unknown user accepted")
+	}
+	u, ok := s.Authenticate("alice", "password1")
+	if !ok || u.Role != RoleViewer {
+		t.Fatalf("authenticate = %+v, %v", u, ok)
+	}
+
+	if _, err := s.Update("alice", "password2", RoleOperator); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Authenticate("alice", "password1"); ok {
+		t.Fatal("old password accepted")
+	}
+	if u, ok := s.Authenticate("alice", "password2"); !ok || u.Role != RoleOperator {
+		t.Fatalf("after update = %+v, %v", u, ok)
+	}
+	for _, l := range s.List() {
+		if l.PasswordHash != "" {
+			t.Fatal("list exposes password hash")
+		}
+	}
+
+	if err := s.Delete("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("alice"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("delete missing: %v", err)
+	}
+}
+
+func TestLastAdmin(t *testing.T) {
+	old := *conf.EnvCfg
+	t.Cleanup(func() { *conf.EnvCfg = old })
+	conf.EnvCfg.USER_NAME = ""
+	conf.EnvCfg.USER_PASSWORD = ""
+
+	s := NewStore(filepath.Join(t.TempDir(), "users.json"))
+	if _, err := s.Create("root", "password1", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Update("root", "", RoleViewer); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("demote last admin: %v", err)
+	}
+	if err := s.Delete("root"); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("delete last admin: %v", err)
+	}
+	if _, err := s.Create("root2", "password1", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("root"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnsureExternal(t *testing.T) {
+	old := *conf.EnvCfg
+	t.Cleanup(func() { *conf.EnvCfg = old })
+	conf.EnvCfg.USER_NAME = "admin"
+	conf.EnvCfg.USER_PASSWORD = "admin"
+
+	s := NewStore(filepath.Join(t.TempDir(), "users.json"))
+	carol := ExternalIdentity{Username: "carol", Source: "oidc", ID: "https://idp|c1"}
+	if _, err := s.EnsureExternal(carol); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("no role for new user: %v", err)
+	}
+	if _, err := s.EnsureExternal(ExternalIdentity{Username: "admin", Source: "oidc", Role: RoleAdmin}); !errors.Is(err, ErrBuiltinUser) {
+		t.Fatalf("builtin username: %v", err)
+	}
+	carol.DefaultRole = RoleViewer
+	u, err := s.EnsureExternal(carol)
+	if err != nil || u.Role != RoleViewer || u.Source != "oidc" || u.ExternalID != "https://idp|c1" {
+		t.Fatalf("create = %+v, %v", u, err)
+	}
+	// 外部用户没有本地密码
+	if _, ok := s.Authenticate("carol", ""); ok {
+		t.Fatal("external user accepted empty password")
+	}
+	// 未匹配角色时保留已有角色，匹配时同步
+	carol.DefaultRole = RoleAdmin
+	if u, _ := s.EnsureExternal(carol); u.Role != RoleViewer {
+		t.Fatalf("default role overrode existing: %+v", u)
+	}
+	carol.Role = RoleOperator
+	if u, _ := s.EnsureExternal(carol); u.Role != RoleOperator {
+		t.Fatalf("mapped role not synced: %+v", u)
+	}
+
+	// 同名但 subject 不同的 IdP 用户不能接管账号，即使允许关联
+	impostor := ExternalIdentity{Username: "carol", Source: "oidc", ID: "https://idp|evil", Role: RoleAdmin, LinkExisting: true}
+	if _, err := s.EnsureExternal(impostor); !errors.Is(err, ErrIdentityConflict) {
+		t.Fatalf("other subject: %v", err)
+	}
+
+	// 同名的本地账号默认拒绝；允许关联后保留本地角色
+	if _, err := s.Create("dave", "password1", RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	dave := ExternalIdentity{Username: "dave", Source: "oidc", ID: "https://idp|d1", Role: RoleAdmin}
+	if _, err := s.EnsureExternal(dave); !errors.Is(err, ErrIdentityConflict) {
+		t.Fatalf("local account linked without opt-in: %v", err)
+	}
+	dave.LinkExisting = true
+	if u, err := s.EnsureExternal(dave); err != nil || u.Role != RoleViewer || u.ExternalID != "https://idp|d1" {
+		t.Fatalf("link local = %+v, %v", u, err)
+	}
+	if _, ok := s.Authenticate("dave", "password1"); !ok {
+		t.Fatal("linked account lost its local password")
+	}
+}
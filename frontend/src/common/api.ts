@@ -39,27 +39,44 @@ export const healthApi = {
   ready: () => axios.get<any>(API_ENDPOINTS.READY),
 }
 
+// 登录响应：直接返回 token，或需要二次验证时返回临时 token
+export interface LoginResult {
+  token?: string
+  refreshToken?: string
+  expiresIn?: number
+  username?: string
+  needTwoFA?: boolean
+  isSetup?: boolean
+  method?: string
+  tempToken?: string
+}
+
 // 身份验证相关API
 export const authApi = {
   // 登录
   login: (username: string, password: string) =>
-    axios.post<{
-      token?: string
-      username?: string
-      needTwoFA?: boolean
-      isSetup?: boolean
-      method?: string
-      tempToken?: string
-    }>(API_ENDPOINTS.LOGIN, {
+    axios.post<LoginResult>(API_ENDPOINTS.LOGIN, {
       username,
       password,
     }),
 
+  // 使用 OIDC 回调返回的一次性登录码换取 token，响应与登录相同
+  oidcExchange: (code: string) => axios.post<LoginResult>(API_ENDPOINTS.OIDC_EXCHANGE, { code }),
+
   // 登出
   logout: () => axios.post<{ message: string }>(API_ENDPOINTS.LOGOUT),
 
+  // 使用刷新令牌换取新的 token
+  refresh: (refreshToken: string) =>
+    axios.post<{ token: string; refreshToken: string; expiresIn: number }>(API_ENDPOINTS.REFRESH, {
+      refreshToken,
+    }),
+
   // 检查身份验证状态
-  checkAuthStatus: () => axios.get<{ authEnabled: boolean }>(API_ENDPOINTS.AUTH_STATUS),
+  checkAuthStatus: () =>
+    axios.get<{ authEnabled: boolean; oidcEnabled?: boolean; oidcButtonText?: string }>(
+      API_ENDPOINTS.AUTH_STATUS,
+    ),
 
   // 获取系统信息
   getInfo: () => axios.get<{ info: SystemInfo }>(API_ENDPOINTS.INFO),
@@ -3,6 +3,7 @@ import { systemErrInfo } from '@/constants/msg'
 import axios from 'axios'
 import type { AxiosRequestConfig, CancelTokenSource } from 'axios'
 import router from '@/router'
+import { API_ENDPOINTS } from '@/constants/api'
 // format全局只有在应用初始化后才可以使用
 // import toast from './toast'
 export interface IResData<T = any> {
@@ -20,6 +21,9 @@ const CancelToken = axios.CancelToken
 axios.defaults.timeout = 60 * 60 * 1000
 window.axios = axios
 
+// 刷新 token 后重试请求使用的实例：不经过拦截器，返回的原始响应交给后续拦截器处理
+const retryAxios = axios.create()
+
 const lockUrl: Record<string, any> = {}
 window.lockUrl = {}
 // 后到优先策略：取消前一个未完成的ajax请求，然后发送新的ajax请求
@@ -99,8 +103,16 @@ axios.interceptors.response.use(
   },
   async function (err) {
     const res = err.response
-    // const config = err?.config || res?.config
+    const config = err?.config || res?.config
     const status = res?.status
+    // token 过期时使用刷新令牌换取新的 token 并重试一次
+    if (status === 401 && config && config.url !== API_ENDPOINTS.REFRESH) {
+      const { useAuthStore } = await import('@/store/auth')
+      if (await useAuthStore().refreshSession()) {
+        config.headers.Authorization = `Bearer ${getToken()}`
+        return retryAxios.request(config)
+      }
+    }
     if (status === 401 || status === 403) {
       // 导入并调用强制登出
       const { useAuthStore } = await import('@/store/auth')
@@ -7,8 +7,11 @@ export const API_ENDPOINTS = {
   // 身份验证
   LOGIN: '/login',
   LOGOUT: '/logout',
+  REFRESH: '/auth/refresh',
   AUTH_STATUS: '/auth/status',
   INFO: '/info',
+  OIDC_LOGIN: '/oidc/login',
+  OIDC_EXCHANGE: '/oidc/exchange',
 
   // 容器相关
   CONTAINERS: '/containers',
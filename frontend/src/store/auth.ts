@@ -1,6 +1,6 @@
 import { defineStore } from 'pinia'
 import { ref, computed } from 'vue'
-import { authApi } from '@/common/api'
+import { authApi, type LoginResult } from '@/common/api'
 import { navigateTo } from '@/router'
 import { useSettingStore } from '@/store/setting'
 
@@ -14,6 +14,10 @@ export const useAuthStore = defineStore('auth', () => {
   // 身份验证是否启用
   const authEnabled = ref(false)
 
+  // OIDC 单点登录
+  const oidcEnabled = ref(false)
+  const oidcButtonText = ref('')
+
   // 二次验证状态
   const twoFARequired = ref(false)
   const twoFASetupRequired = ref(false)
@@ -26,6 +30,10 @@ export const useAuthStore = defineStore('auth', () => {
   // 检查认证状态加载中
   const checkingAuth = ref(false)
 
+  // 定时刷新 token
+  let refreshTimer: ReturnType<typeof setTimeout> | undefined
+  let refreshing: Promise<boolean> | null = null
+
   // 计算属性：是否需要登录
   const requiresAuth = computed(() => {
     return authEnabled.value && !isLoggedIn.value
@@ -36,6 +44,45 @@ export const useAuthStore = defineStore('auth', () => {
     return settingStore.currentUsername
   })
 
+  // 保存 token 与刷新令牌，并在 token 过期前一分钟自动刷新
+  const setSession = (token: string, refreshToken?: string, expiresIn?: number) => {
+    settingStore.setToken(token)
+    if (refreshToken) {
+      settingStore.setRefreshToken(refreshToken)
+    }
+    clearTimeout(refreshTimer)
+    if (expiresIn) {
+      refreshTimer = setTimeout(refreshSession, Math.max(expiresIn - 60, 10) * 1000)
+    }
+  }
+
+  // 使用刷新令牌换取新的 token，同时只发起一个刷新请求
+  const refreshSession = () => {
+    const refreshToken = settingStore.getRefreshToken()
+    if (!refreshToken) {
+      return Promise.resolve(false)
+    }
+    if (!refreshing) {
+      refreshing = authApi
+        .refresh(refreshToken)
+        .then((res) => {
+          if (res.code === 0 && res.data?.token) {
+            setSession(res.data.token, res.data.refreshToken, res.data.expiresIn)
+            return true
+          }
+          return false
+        })
+        .catch((error) => {
+          console.error('Refresh token failed:', error)
+          return false
+        })
+        .finally(() => {
+          refreshing = null
+        })
+    }
+    return refreshing
+  }
+
   // 初始化：从setting store恢复token
   const initAuth = async () => {
     checkingAuth.value = true
@@ -43,13 +90,18 @@ export const useAuthStore = defineStore('auth', () => {
       // 检查是否启用身份验证
       const authStatusRes = await authApi.checkAuthStatus()
       authEnabled.value = authStatusRes.data.authEnabled
+      oidcEnabled.value = !!authStatusRes.data.oidcEnabled
+      oidcButtonText.value = authStatusRes.data.oidcButtonText || ''
 
       // 如果启用了身份验证，检查当前token状态
       if (authEnabled.value) {
         const savedToken = settingStore.getToken()
         if (savedToken) {
           isLoggedIn.value = true
-          // 这里可以添加验证token有效性的逻辑
+          // 有刷新令牌时立即刷新，获取新的过期时间
+          if (settingStore.getRefreshToken() && !(await refreshSession())) {
+            forceLogout()
+          }
         }
       } else {
         // 如果未启用身份验证，自动设为已登录状态
@@ -65,42 +117,63 @@ export const useAuthStore = defineStore('auth', () => {
     }
   }
 
+  // 处理登录响应：需要二次验证时保存临时 token，否则直接保存会话
+  const applyLoginResult = (data: LoginResult | undefined, fallbackUsername: string) => {
+    // 检查是否需要二次验证
+    if (data?.needTwoFA) {
+      twoFARequired.value = true
+      twoFASetupRequired.value = !data.isSetup
+      tempToken.value = data.tempToken || ''
+      twoFAMethod.value = data.method || ''
+      // 保存临时 token 到 setting store
+      settingStore.setTmpToken(data.tempToken || '')
+      settingStore.setCurrentUsername(data.username || fallbackUsername)
+
+      return {
+        success: true,
+        needTwoFA: true,
+        isSetup: data.isSetup,
+        method: data.method,
+      }
+    }
+
+    // 不需要二次验证，直接登录
+    if (data?.token) {
+      setSession(data.token, data.refreshToken, data.expiresIn)
+      settingStore.setCurrentUsername(data.username || fallbackUsername)
+      isLoggedIn.value = true
+      twoFARequired.value = false
+      return { success: true }
+    }
+    return { success: false, message: '登录失败' }
+  }
+
   // 登录
   const login = async (loginUsername: string, password: string) => {
     loginLoading.value = true
     try {
       const res = await authApi.login(loginUsername, password)
+      return applyLoginResult(res.data, loginUsername)
+    } catch (error: any) {
+      console.error('Login failed:', error)
+      const message = error.response?.data?.msg || error.message || '登录失败'
+      return { success: false, message }
+    } finally {
+      loginLoading.value = false
+    }
+  }
 
-      // 检查是否需要二次验证
-      if (res.data?.needTwoFA) {
-        twoFARequired.value = true
-        twoFASetupRequired.value = !res.data.isSetup
-        tempToken.value = res.data.tempToken || ''
-        twoFAMethod.value = res.data.method || ''
-        // 保存临时 token 到 setting store
-        settingStore.setTmpToken(res.data.tempToken || '')
-        settingStore.setCurrentUsername(res.data.username || loginUsername)
-
-        return {
-          success: true,
-          needTwoFA: true,
-          isSetup: res.data.isSetup,
-          method: res.data.method,
-        }
-      }
-
-      // 不需要二次验证，直接登录
-      if (res.data?.token) {
-        settingStore.setToken(res.data.token)
-        settingStore.setCurrentUsername(res.data.username || loginUsername)
-        isLoggedIn.value = true
-        twoFARequired.value = false
-        return { success: true }
-      } else {
-        return { success: false, message: '登录失败' }
+  // OIDC 登录：用回调返回的一次性登录码换取 token
+  const oidcLogin = async (code: string) => {
+    loginLoading.value = true
+    try {
+      const res = await authApi.oidcExchange(code)
+      if (res.code !== 0) {
+        return { success: false, message: res.msg || '登录失败' }
       }
+      return applyLoginResult(res.data, '')
     } catch (error: any) {
-      console.error('Login failed:', error)
+      console.error('OIDC login failed:', error)
       const message = error.response?.data?.msg || error.message || '登录失败'
       return { success: false, message }
     } finally {
@@ -119,6 +192,7 @@ export const useAuthStore = defineStore('auth', () => {
     } finally {
       console.debug('logout')
       // 无论API调用是否成功，都清除本地状态
+      clearTimeout(refreshTimer)
       settingStore.clearToken()
       settingStore.clearCurrentUsername()
       isLoggedIn.value = false
@@ -129,6 +203,7 @@ export const useAuthStore = defineStore('auth', () => {
   // 强制登出（用于token过期等情况）
   const forceLogout = () => {
     console.debug('forceLogout')
+    clearTimeout(refreshTimer)
     settingStore.clearToken()
     settingStore.clearCurrentUsername()
     isLoggedIn.value = false
@@ -142,8 +217,8 @@ export const useAuthStore = defineStore('auth', () => {
   }
 
   // 完成二次验证后设置完整 token
-  const completeTwoFA = (fullToken: string) => {
-    settingStore.setToken(fullToken)
+  const completeTwoFA = (fullToken: string, refreshToken?: string, expiresIn?: number) => {
+    setSession(fullToken, refreshToken, expiresIn)
     isLoggedIn.value = true
     twoFARequired.value = false
     twoFASetupRequired.value = false
@@ -155,6 +230,8 @@ export const useAuthStore = defineStore('auth', () => {
     isLoggedIn,
     username,
     authEnabled,
+    oidcEnabled,
+    oidcButtonText,
     loginLoading,
     checkingAuth,
     twoFARequired,
@@ -168,8 +245,10 @@ export const useAuthStore = defineStore('auth', () => {
     // 方法
     initAuth,
     login,
+    oidcLogin,
     logout,
     forceLogout,
     completeTwoFA,
+    refreshSession,
   }
 })
@@ -14,6 +14,7 @@ export const useSettingStore = defineStore('setting', () => {
       rememberedUsername: '', // 重命名为记住的用户名
       rememberUsername: false, // 改为只记住用户名
       token: '',
+      refreshToken: '',
     },
     localStorage,
   )
@@ -122,6 +123,16 @@ export const useSettingStore = defineStore('setting', () => {
 
   function clearToken() {
     setting.value.token = ''
+    setting.value.refreshToken = ''
+  }
+
+  // 刷新令牌，用于在 token 过期前换取新的 token
+  function setRefreshToken(token: string) {
+    setting.value.refreshToken = token
+  }
+
+  function getRefreshToken() {
+    return setting.value.refreshToken || ''
   }
 
   return {
@@ -144,6 +155,8 @@ export const useSettingStore = defineStore('setting', () => {
     setTmpToken,
     getToken,
     clearToken,
+    setRefreshToken,
+    getRefreshToken,
     contentSafeTop,
     contentSafeBottom,
   }